@@ -5,15 +5,22 @@ import (
 	_ "github.com/observiq/stanza/operator/builtin/input/aws/cloudwatch"
 	_ "github.com/observiq/stanza/operator/builtin/input/azure/eventhub"
 	_ "github.com/observiq/stanza/operator/builtin/input/azure/loganalytics"
+	_ "github.com/observiq/stanza/operator/builtin/input/container"
+	_ "github.com/observiq/stanza/operator/builtin/input/exec"
 	_ "github.com/observiq/stanza/operator/builtin/input/file"
+	_ "github.com/observiq/stanza/operator/builtin/input/fluentforward"
 	_ "github.com/observiq/stanza/operator/builtin/input/forward"
 	_ "github.com/observiq/stanza/operator/builtin/input/generate"
 	_ "github.com/observiq/stanza/operator/builtin/input/goflow"
+	_ "github.com/observiq/stanza/operator/builtin/input/http"
 	_ "github.com/observiq/stanza/operator/builtin/input/k8sevent"
+	_ "github.com/observiq/stanza/operator/builtin/input/otlp"
 	_ "github.com/observiq/stanza/operator/builtin/input/stanza"
 	_ "github.com/observiq/stanza/operator/builtin/input/stdin"
+	_ "github.com/observiq/stanza/operator/builtin/input/syslog"
 	_ "github.com/observiq/stanza/operator/builtin/input/tcp"
 	_ "github.com/observiq/stanza/operator/builtin/input/udp"
+	_ "github.com/observiq/stanza/operator/builtin/input/windows"
 
 	_ "github.com/observiq/stanza/operator/builtin/parser/csv"
 	_ "github.com/observiq/stanza/operator/builtin/parser/json"
@@ -22,6 +29,7 @@ import (
 	_ "github.com/observiq/stanza/operator/builtin/parser/syslog"
 	_ "github.com/observiq/stanza/operator/builtin/parser/time"
 	_ "github.com/observiq/stanza/operator/builtin/parser/uri"
+	_ "github.com/observiq/stanza/operator/builtin/parser/windowsxml"
 
 	_ "github.com/observiq/stanza/operator/builtin/transformer/add"
 	_ "github.com/observiq/stanza/operator/builtin/transformer/copy"
@@ -41,8 +49,10 @@ import (
 	_ "github.com/observiq/stanza/operator/builtin/output/drop"
 	_ "github.com/observiq/stanza/operator/builtin/output/elastic"
 	_ "github.com/observiq/stanza/operator/builtin/output/file"
+	_ "github.com/observiq/stanza/operator/builtin/output/fluentforward"
 	_ "github.com/observiq/stanza/operator/builtin/output/forward"
 	_ "github.com/observiq/stanza/operator/builtin/output/googlecloud"
+	_ "github.com/observiq/stanza/operator/builtin/output/http"
 	_ "github.com/observiq/stanza/operator/builtin/output/newrelic"
 	_ "github.com/observiq/stanza/operator/builtin/output/otlp"
 	_ "github.com/observiq/stanza/operator/builtin/output/stdout"
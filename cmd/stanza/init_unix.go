@@ -0,0 +1,8 @@
+// +build !windows
+
+package main
+
+import (
+	// Load unix only packages when importing input operators
+	_ "github.com/observiq/stanza/operator/builtin/input/unix"
+)
@@ -1,6 +0,0 @@
-package main
-
-import (
-	// Load windows only packages when importing input operators
-	_ "github.com/observiq/stanza/operator/builtin/input/windows"
-)
@@ -0,0 +1,237 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/builtin/input/file"
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+)
+
+// Formats of container log files
+const (
+	formatAuto   = "auto"
+	formatDocker = "docker"
+	formatCRI    = "cri"
+)
+
+const (
+	defaultInclude = "/var/log/pods/*/*/*.log"
+
+	// maxMetadataPaths is the number of file paths whose resource keys are cached
+	maxMetadataPaths = 4096
+
+	filePathLabel         = "file_path"
+	filePathResolvedLabel = "file_path_resolved"
+	streamLabel           = "stream"
+)
+
+func init() {
+	operator.Register("container_input", func() operator.Builder { return NewContainerInputConfig("") })
+}
+
+// NewContainerInputConfig creates a new container input config with default values
+func NewContainerInputConfig(operatorID string) *ContainerInputConfig {
+	fileConfig := file.NewInputConfig(operatorID)
+	return &ContainerInputConfig{
+		InputConfig:        helper.NewInputConfig(operatorID, "container_input"),
+		Include:            []string{defaultInclude},
+		Format:             formatAuto,
+		PollInterval:       fileConfig.PollInterval,
+		StartAt:            fileConfig.StartAt,
+		FingerprintSize:    fileConfig.FingerprintSize,
+		MaxLogSize:         fileConfig.MaxLogSize,
+		MaxConcurrentFiles: fileConfig.MaxConcurrentFiles,
+	}
+}
+
+// ContainerInputConfig is the configuration of a container input operator
+type ContainerInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Include            []string        `json:"include,omitempty"              yaml:"include,omitempty"`
+	Exclude            []string        `json:"exclude,omitempty"              yaml:"exclude,omitempty"`
+	Format             string          `json:"format,omitempty"               yaml:"format,omitempty"`
+	IncludeFilePath    bool            `json:"include_file_path,omitempty"    yaml:"include_file_path,omitempty"`
+	PollInterval       helper.Duration `json:"poll_interval,omitempty"        yaml:"poll_interval,omitempty"`
+	StartAt            string          `json:"start_at,omitempty"             yaml:"start_at,omitempty"`
+	FingerprintSize    helper.ByteSize `json:"fingerprint_size,omitempty"     yaml:"fingerprint_size,omitempty"`
+	MaxLogSize         helper.ByteSize `json:"max_log_size,omitempty"         yaml:"max_log_size,omitempty"`
+	MaxConcurrentFiles int             `json:"max_concurrent_files,omitempty" yaml:"max_concurrent_files,omitempty"`
+}
+
+// Build will build a container input operator
+func (c ContainerInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Format {
+	case formatAuto, formatDocker, formatCRI:
+	default:
+		return nil, fmt.Errorf("invalid format '%s', must be one of '%s', '%s' or '%s'", c.Format, formatAuto, formatDocker, formatCRI)
+	}
+
+	// Container log files are read by a file input, which tracks the fingerprints
+	// and offsets of the files, and writes each line to the container input
+	fileConfig := file.NewInputConfig(c.ID())
+	fileConfig.Include = c.Include
+	fileConfig.Exclude = c.Exclude
+	fileConfig.PollInterval = c.PollInterval
+	fileConfig.StartAt = c.StartAt
+	fileConfig.FingerprintSize = c.FingerprintSize
+	fileConfig.MaxLogSize = c.MaxLogSize
+	fileConfig.MaxConcurrentFiles = c.MaxConcurrentFiles
+	fileConfig.IncludeFileName = false
+	fileConfig.IncludeFilePath = true
+	fileConfig.IncludeFilePathResolved = true
+
+	fileOperators, err := fileConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+	fileInput := fileOperators[0].(*file.InputOperator)
+
+	containerInput := &ContainerInput{
+		InputOperator:   inputOperator,
+		fileInput:       fileInput,
+		format:          c.Format,
+		maxLogSize:      int(c.MaxLogSize),
+		includeFilePath: c.IncludeFilePath,
+		partials:        make(map[partialKey]*partial),
+		metadata:        make(map[string]map[string]string),
+	}
+	fileInput.OutputOperators = []operator.Operator{containerInput}
+
+	return []operator.Operator{containerInput}, nil
+}
+
+// ContainerInput is an operator that reads the log files of containers
+type ContainerInput struct {
+	helper.InputOperator
+	fileInput       *file.InputOperator
+	format          string
+	maxLogSize      int
+	includeFilePath bool
+
+	// partials are the parts of lines that were split by the container runtime
+	partials map[partialKey]*partial
+
+	// metadata are the resource keys of each file path
+	metadata map[string]map[string]string
+	mux      sync.Mutex
+}
+
+// partialKey identifies a stream of a container log file
+type partialKey struct {
+	path   string
+	stream string
+}
+
+// partial is a line that has only been partially read
+type partial struct {
+	line     *line
+	resource map[string]string
+}
+
+// Start will start reading the container log files
+func (c *ContainerInput) Start() error {
+	return c.fileInput.Start()
+}
+
+// Stop will stop reading the container log files, and flush any partial lines
+func (c *ContainerInput) Stop() error {
+	if err := c.fileInput.Stop(); err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	partials := c.partials
+	c.partials = make(map[partialKey]*partial)
+	c.mux.Unlock()
+
+	for key, p := range partials {
+		c.write(context.Background(), key.path, p.line, p.resource)
+	}
+	return nil
+}
+
+// Process handles the lines read from the container log files by the file input.
+// The container input can not process entries from other operators.
+func (c *ContainerInput) Process(ctx context.Context, e *entry.Entry) error {
+	raw, ok := e.Record.(string)
+	if !ok {
+		return fmt.Errorf("unexpected record type %T", e.Record)
+	}
+	path := e.Labels[filePathLabel]
+	resource := c.resource(e.Labels[filePathResolvedLabel])
+
+	parsed, err := parseLine(c.format, raw)
+	if err != nil {
+		c.Warnw("Failed to parse container log line", zap.Error(err), "path", path)
+		parsed = &line{log: raw, timestamp: e.Timestamp}
+	}
+
+	key := partialKey{path: path, stream: parsed.stream}
+	c.mux.Lock()
+	if p, ok := c.partials[key]; ok {
+		p.line.log += parsed.log
+		parsed.log, parsed.timestamp = p.line.log, p.line.timestamp
+		delete(c.partials, key)
+	}
+	if parsed.partial && len(parsed.log) < c.maxLogSize {
+		c.partials[key] = &partial{line: parsed, resource: resource}
+		c.mux.Unlock()
+		return nil
+	}
+	c.mux.Unlock()
+
+	c.write(ctx, path, parsed, resource)
+	return nil
+}
+
+// write writes a complete line as an entry
+func (c *ContainerInput) write(ctx context.Context, path string, l *line, resource map[string]string) {
+	e, err := c.NewEntry(l.log)
+	if err != nil {
+		c.Errorw("Failed to create entry", zap.Error(err))
+		return
+	}
+
+	if !l.timestamp.IsZero() {
+		e.Timestamp = l.timestamp
+	}
+	if l.stream != "" {
+		e.AddLabel(streamLabel, l.stream)
+	}
+	if c.includeFilePath {
+		e.AddLabel(filePathLabel, path)
+	}
+	for key, value := range resource {
+		e.AddResourceKey(key, value)
+	}
+
+	c.Write(ctx, e)
+}
+
+// resource returns the resource keys of a resolved file path
+func (c *ContainerInput) resource(path string) map[string]string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if resource, ok := c.metadata[path]; ok {
+		return resource
+	}
+	resource := metadataFromPath(filepath.ToSlash(path))
+	if len(c.metadata) >= maxMetadataPaths {
+		c.metadata = make(map[string]map[string]string)
+	}
+	c.metadata[path] = resource
+	return resource
+}
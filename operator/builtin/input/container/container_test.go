@@ -0,0 +1,300 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const containerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func writeLogFile(t *testing.T, path string, lines ...string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+}
+
+func expectEntry(t *testing.T, fake *testutil.FakeOutput) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		return e
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+func TestContainerInputBuild(t *testing.T) {
+	cfg := NewContainerInputConfig("test_id")
+	_, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	cfg.Format = "syslog"
+	_, err = cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+
+	cfg = NewContainerInputConfig("test_id")
+	cfg.MaxConcurrentFiles = 1
+	_, err = cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+}
+
+func TestParseLine(t *testing.T) {
+	timestamp := time.Date(2021, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	cases := []struct {
+		name     string
+		format   string
+		raw      string
+		expected *line
+	}{
+		{
+			"CRIFull",
+			formatAuto,
+			"2021-06-01T12:00:00.123456789Z stdout F hello world",
+			&line{timestamp: timestamp, stream: "stdout", log: "hello world"},
+		},
+		{
+			"CRIPartial",
+			formatCRI,
+			"2021-06-01T12:00:00.123456789Z stderr P hello ",
+			&line{timestamp: timestamp, stream: "stderr", log: "hello ", partial: true},
+		},
+		{
+			"CRIEmpty",
+			formatAuto,
+			"2021-06-01T12:00:00.123456789Z stdout F",
+			&line{timestamp: timestamp, stream: "stdout"},
+		},
+		{
+			"DockerFull",
+			formatAuto,
+			`{"log":"hello world\n","stream":"stdout","time":"2021-06-01T12:00:00.123456789Z"}`,
+			&line{timestamp: timestamp, stream: "stdout", log: "hello world"},
+		},
+		{
+			"DockerPartial",
+			formatDocker,
+			`{"log":"hello ","stream":"stderr","time":"2021-06-01T12:00:00.123456789Z"}`,
+			&line{timestamp: timestamp, stream: "stderr", log: "hello ", partial: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := parseLine(tc.format, tc.raw)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, parsed)
+		})
+	}
+}
+
+func TestParseLineInvalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		raw    string
+	}{
+		{"CRIMissingTag", formatCRI, "2021-06-01T12:00:00Z stdout"},
+		{"CRIInvalidTag", formatCRI, "2021-06-01T12:00:00Z stdout X message"},
+		{"CRIInvalidTime", formatAuto, "yesterday stdout F message"},
+		{"DockerInvalidJSON", formatAuto, `{"log":`},
+		{"DockerAsCRI", formatCRI, `{"log":"message\n","stream":"stdout","time":"2021-06-01T12:00:00Z"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseLine(tc.format, tc.raw)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMetadataFromPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		expected map[string]string
+	}{
+		{
+			"Pods",
+			"/var/log/pods/kube-system_coredns-74ff55c5b-8xzvf_2f6ae7d5-4d4b-4f0a-9a5e-6a5b7b3c2d1e/coredns/3.log",
+			map[string]string{
+				"k8s.namespace.name":          "kube-system",
+				"k8s.pod.name":                "coredns-74ff55c5b-8xzvf",
+				"k8s.pod.uid":                 "2f6ae7d5-4d4b-4f0a-9a5e-6a5b7b3c2d1e",
+				"k8s.container.name":          "coredns",
+				"k8s.container.restart_count": "3",
+			},
+		},
+		{
+			"Containers",
+			"/var/log/containers/coredns-74ff55c5b-8xzvf_kube-system_coredns-" + containerID + ".log",
+			map[string]string{
+				"k8s.namespace.name": "kube-system",
+				"k8s.pod.name":       "coredns-74ff55c5b-8xzvf",
+				"k8s.container.name": "coredns",
+				"container.id":       containerID,
+			},
+		},
+		{
+			"Docker",
+			"/var/lib/docker/containers/" + containerID + "/" + containerID + "-json.log",
+			map[string]string{
+				"container.id": containerID,
+			},
+		},
+		{
+			"Other",
+			"/var/log/syslog",
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, metadataFromPath(tc.path))
+		})
+	}
+}
+
+func TestContainerInputCRI(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+
+	cfg := NewContainerInputConfig("test_id")
+	cfg.StartAt = "beginning"
+	cfg.PollInterval.Duration = 50 * time.Millisecond
+	cfg.Include = []string{filepath.Join(tempDir, "pods", "*", "*", "*.log")}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	containerInput := ops[0].(*ContainerInput)
+
+	fake := testutil.NewFakeOutput(t)
+	containerInput.OutputOperators = []operator.Operator{fake}
+
+	path := filepath.Join(tempDir, "pods", "default_app-1_uid-1", "app", "0.log")
+	writeLogFile(t, path,
+		"2021-06-01T12:00:00.000000001Z stdout P first ",
+		"2021-06-01T12:00:00.000000002Z stderr F error",
+		"2021-06-01T12:00:00.000000003Z stdout P second ",
+		"2021-06-01T12:00:00.000000004Z stdout F third",
+	)
+
+	require.NoError(t, containerInput.Start())
+	defer containerInput.Stop()
+
+	// Partial lines are reassembled for each stream
+	e := expectEntry(t, fake)
+	require.Equal(t, "error", e.Record)
+	require.Equal(t, "stderr", e.Labels["stream"])
+
+	e = expectEntry(t, fake)
+	require.Equal(t, "first second third", e.Record)
+	require.Equal(t, "stdout", e.Labels["stream"])
+	require.Equal(t, time.Date(2021, 6, 1, 12, 0, 0, 1, time.UTC), e.Timestamp)
+	require.Equal(t, map[string]string{
+		"k8s.namespace.name":          "default",
+		"k8s.pod.name":                "app-1",
+		"k8s.pod.uid":                 "uid-1",
+		"k8s.container.name":          "app",
+		"k8s.container.restart_count": "0",
+	}, e.Resource)
+	require.NotContains(t, e.Labels, "file_path")
+	require.NotContains(t, e.Labels, "file_name")
+}
+
+func TestContainerInputDocker(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+
+	cfg := NewContainerInputConfig("test_id")
+	cfg.StartAt = "beginning"
+	cfg.PollInterval.Duration = 50 * time.Millisecond
+	cfg.Include = []string{filepath.Join(tempDir, "containers", "*", "*-json.log")}
+	cfg.IncludeFilePath = true
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	containerInput := ops[0].(*ContainerInput)
+
+	fake := testutil.NewFakeOutput(t)
+	containerInput.OutputOperators = []operator.Operator{fake}
+
+	// Docker splits lines longer than 16KiB
+	long := strings.Repeat("a", 16*1024)
+	path := filepath.Join(tempDir, "containers", containerID, containerID+"-json.log")
+	writeLogFile(t, path,
+		fmt.Sprintf(`{"log":"%s","stream":"stdout","time":"2021-06-01T12:00:00.000000001Z"}`, long),
+		`{"log":"end\n","stream":"stdout","time":"2021-06-01T12:00:00.000000002Z"}`,
+		`{"log":"next\n","stream":"stdout","time":"2021-06-01T12:00:00.000000003Z"}`,
+	)
+
+	require.NoError(t, containerInput.Start())
+	defer containerInput.Stop()
+
+	e := expectEntry(t, fake)
+	require.Equal(t, long+"end", e.Record)
+	require.Equal(t, path, e.Labels["file_path"])
+	require.Equal(t, map[string]string{"container.id": containerID}, e.Resource)
+
+	e = expectEntry(t, fake)
+	require.Equal(t, "next", e.Record)
+}
+
+func TestContainerInputFlushOnStop(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+
+	cfg := NewContainerInputConfig("test_id")
+	cfg.StartAt = "beginning"
+	cfg.PollInterval.Duration = 50 * time.Millisecond
+	cfg.Include = []string{filepath.Join(tempDir, "*.log")}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	containerInput := ops[0].(*ContainerInput)
+
+	fake := testutil.NewFakeOutput(t)
+	containerInput.OutputOperators = []operator.Operator{fake}
+
+	writeLogFile(t, filepath.Join(tempDir, "0.log"), "2021-06-01T12:00:00Z stdout P partial")
+
+	require.NoError(t, containerInput.Start())
+	fake.ExpectNoEntry(t, 300*time.Millisecond)
+
+	// A partial line is flushed when the operator is stopped
+	require.NoError(t, containerInput.Stop())
+	e := expectEntry(t, fake)
+	require.Equal(t, "partial", e.Record)
+}
+
+func TestContainerInputInvalidLine(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+
+	cfg := NewContainerInputConfig("test_id")
+	cfg.StartAt = "beginning"
+	cfg.PollInterval.Duration = 50 * time.Millisecond
+	cfg.Include = []string{filepath.Join(tempDir, "*.log")}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	containerInput := ops[0].(*ContainerInput)
+
+	fake := testutil.NewFakeOutput(t)
+	containerInput.OutputOperators = []operator.Operator{fake}
+
+	// Lines that can not be parsed are written as they are
+	writeLogFile(t, filepath.Join(tempDir, "0.log"), "not a container log")
+
+	require.NoError(t, containerInput.Start())
+	defer containerInput.Stop()
+
+	e := expectEntry(t, fake)
+	require.Equal(t, "not a container log", e.Record)
+}
@@ -0,0 +1,101 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// line is a parsed line of a container log file
+type line struct {
+	timestamp time.Time
+	stream    string
+	log       string
+
+	// partial is whether the container runtime split the line,
+	// and the rest of it follows in the next lines of the stream
+	partial bool
+}
+
+// dockerLine is a line of a docker json-file log
+type dockerLine struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+// parseLine parses a line of a container log file in the given format
+func parseLine(format, raw string) (*line, error) {
+	switch format {
+	case formatDocker:
+		return parseDocker(raw)
+	case formatCRI:
+		return parseCRI(raw)
+	default:
+		// Docker lines are JSON objects, while CRI lines start with a timestamp
+		if strings.HasPrefix(raw, "{") {
+			return parseDocker(raw)
+		}
+		return parseCRI(raw)
+	}
+}
+
+// parseDocker parses a line of the docker json-file format, such as
+// {"log":"message\n","stream":"stdout","time":"2021-06-01T12:00:00.000000000Z"}
+// Lines longer than 16KiB are split into parts that do not end with a newline.
+func parseDocker(raw string) (*line, error) {
+	var parsed dockerLine
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("parse docker json: %s", err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, parsed.Time)
+	if err != nil {
+		return nil, fmt.Errorf("parse docker time: %s", err)
+	}
+
+	l := &line{
+		timestamp: timestamp,
+		stream:    parsed.Stream,
+		log:       parsed.Log,
+		partial:   !strings.HasSuffix(parsed.Log, "\n"),
+	}
+	if !l.partial {
+		l.log = strings.TrimSuffix(strings.TrimSuffix(l.log, "\n"), "\r")
+	}
+	return l, nil
+}
+
+// parseCRI parses a line of the CRI log format, such as
+// 2021-06-01T12:00:00.000000000Z stdout F message
+// The tag is P for the parts of a split line, and F for the last part.
+func parseCRI(raw string) (*line, error) {
+	parts := strings.SplitN(raw, " ", 4)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("parse cri: expected timestamp, stream and tag")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse cri time: %s", err)
+	}
+
+	l := &line{
+		timestamp: timestamp,
+		stream:    parts[1],
+	}
+	if len(parts) == 4 {
+		l.log = parts[3]
+	}
+
+	// Tags are separated by colons, and the first is the partial tag
+	switch strings.SplitN(parts[2], ":", 2)[0] {
+	case "P":
+		l.partial = true
+	case "F":
+	default:
+		return nil, fmt.Errorf("parse cri: invalid tag '%s'", parts[2])
+	}
+	return l, nil
+}
@@ -0,0 +1,46 @@
+package container
+
+import (
+	"regexp"
+)
+
+var (
+	// /var/log/pods/<namespace>_<pod>_<uid>/<container>/<restart_count>.log
+	podsPathRegex = regexp.MustCompile(`/pods/(?P<namespace>[^_/]+)_(?P<pod>[^_/]+)_(?P<uid>[^_/]+)/(?P<container>[^/]+)/(?P<restart>\d+)\.log`)
+
+	// /var/log/containers/<pod>_<namespace>_<container>-<container_id>.log
+	containersPathRegex = regexp.MustCompile(`/containers/(?P<pod>[^_/]+)_(?P<namespace>[^_/]+)_(?P<container>[^/]+)-(?P<id>[0-9a-f]{64})\.log`)
+
+	// /var/lib/docker/containers/<container_id>/<container_id>-json.log
+	dockerPathRegex = regexp.MustCompile(`/containers/(?P<id>[0-9a-f]{64})/[0-9a-f]{64}-json\.log`)
+)
+
+// Resource keys of the names in container log file paths
+var resourceKeys = map[string]string{
+	"namespace": "k8s.namespace.name",
+	"pod":       "k8s.pod.name",
+	"uid":       "k8s.pod.uid",
+	"container": "k8s.container.name",
+	"restart":   "k8s.container.restart_count",
+	"id":        "container.id",
+}
+
+// metadataFromPath returns the resource keys of the names in a container log file path
+func metadataFromPath(path string) map[string]string {
+	for _, re := range []*regexp.Regexp{podsPathRegex, containersPathRegex, dockerPathRegex} {
+		matches := re.FindStringSubmatch(path)
+		if matches == nil {
+			continue
+		}
+
+		resource := make(map[string]string, len(matches))
+		for i, name := range re.SubexpNames() {
+			if i == 0 || name == "" {
+				continue
+			}
+			resource[resourceKeys[name]] = matches[i]
+		}
+		return resource
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+)
+
+const (
+	modeScheduled = "scheduled"
+	modeStream    = "stream"
+
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+
+	streamLabel   = "stream"
+	exitCodeLabel = "exit_code"
+
+	defaultMaxLogSize = 1024 * 1024
+)
+
+func init() {
+	operator.Register("exec_input", func() operator.Builder { return NewExecInputConfig("") })
+}
+
+// NewExecInputConfig creates a new exec input config with default values
+func NewExecInputConfig(operatorID string) *ExecInputConfig {
+	return &ExecInputConfig{
+		InputConfig:     helper.NewInputConfig(operatorID, "exec_input"),
+		Mode:            modeScheduled,
+		Interval:        helper.NewDuration(time.Minute),
+		RestartDelay:    helper.NewDuration(time.Second),
+		MaxRestartDelay: helper.NewDuration(time.Minute),
+		Multiline:       helper.NewMultilineConfig(),
+		MaxLogSize:      defaultMaxLogSize,
+		Encoding:        helper.NewEncodingConfig(),
+	}
+}
+
+// ExecInputConfig is the configuration of an exec input operator
+type ExecInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Command          string                 `json:"command,omitempty"           yaml:"command,omitempty"`
+	Args             []string               `json:"args,omitempty"              yaml:"args,omitempty"`
+	Env              map[string]string      `json:"env,omitempty"               yaml:"env,omitempty"`
+	WorkingDirectory string                 `json:"working_directory,omitempty" yaml:"working_directory,omitempty"`
+	Mode             string                 `json:"mode,omitempty"              yaml:"mode,omitempty"`
+	Interval         helper.Duration        `json:"interval,omitempty"          yaml:"interval,omitempty"`
+	Timeout          helper.Duration        `json:"timeout,omitempty"           yaml:"timeout,omitempty"`
+	RestartDelay     helper.Duration        `json:"restart_delay,omitempty"     yaml:"restart_delay,omitempty"`
+	MaxRestartDelay  helper.Duration        `json:"max_restart_delay,omitempty" yaml:"max_restart_delay,omitempty"`
+	Multiline        helper.MultilineConfig `json:"multiline,omitempty"         yaml:"multiline,omitempty"`
+	MaxLogSize       helper.ByteSize        `json:"max_log_size,omitempty"      yaml:"max_log_size,omitempty"`
+	Encoding         helper.EncodingConfig  `json:",inline,omitempty"           yaml:",inline,omitempty"`
+}
+
+// Build will build an exec input operator
+func (c ExecInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Command == "" {
+		return nil, fmt.Errorf("missing required parameter 'command'")
+	}
+
+	switch c.Mode {
+	case modeScheduled:
+		if c.Interval.Raw() <= 0 {
+			return nil, fmt.Errorf("`interval` must be positive")
+		}
+		if c.Timeout.Raw() < 0 {
+			return nil, fmt.Errorf("`timeout` must not be negative")
+		}
+	case modeStream:
+		if c.RestartDelay.Raw() <= 0 {
+			return nil, fmt.Errorf("`restart_delay` must be positive")
+		}
+		if c.MaxRestartDelay.Raw() < c.RestartDelay.Raw() {
+			return nil, fmt.Errorf("`max_restart_delay` must not be less than `restart_delay`")
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode '%s', must be one of '%s' or '%s'", c.Mode, modeScheduled, modeStream)
+	}
+
+	if c.MaxLogSize <= 0 {
+		return nil, fmt.Errorf("`max_log_size` must be positive")
+	}
+
+	encoding, err := c.Encoding.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	splitFunc, err := c.Multiline.Build(context, encoding.Encoding, true)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(c.Env))
+	for key, value := range c.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+
+	timeout := c.Timeout.Raw()
+	if timeout == 0 {
+		timeout = c.Interval.Raw()
+	}
+
+	execInput := &ExecInput{
+		InputOperator:    inputOperator,
+		command:          c.Command,
+		args:             c.Args,
+		env:              env,
+		dir:              c.WorkingDirectory,
+		mode:             c.Mode,
+		interval:         c.Interval.Raw(),
+		timeout:          timeout,
+		maxLogSize:       int(c.MaxLogSize),
+		encoding:         encoding,
+		splitFunc:        splitFunc,
+		forceFlushPeriod: c.Multiline.ForceFlushPeriod.Raw(),
+		backoff: backoff.Backoff{
+			Min:    c.RestartDelay.Raw(),
+			Max:    c.MaxRestartDelay.Raw(),
+			Factor: 2,
+		},
+	}
+	return []operator.Operator{execInput}, nil
+}
+
+// ExecInput is an operator that reads the output of a command
+type ExecInput struct {
+	helper.InputOperator
+	command          string
+	args             []string
+	env              []string
+	dir              string
+	mode             string
+	interval         time.Duration
+	timeout          time.Duration
+	maxLogSize       int
+	encoding         helper.Encoding
+	splitFunc        bufio.SplitFunc
+	forceFlushPeriod time.Duration
+	backoff          backoff.Backoff
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start will start running the command
+func (e *ExecInput) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if e.mode == modeStream {
+			e.stream(ctx)
+			return
+		}
+		e.schedule(ctx)
+	}()
+	return nil
+}
+
+// Stop will stop running the command, killing it if it is running
+func (e *ExecInput) Stop() error {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+	return nil
+}
+
+// schedule runs the command on every interval. Entries are written once the
+// command exits, so that they can be labeled with its exit code.
+func (e *ExecInput) schedule(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.runScheduled(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runScheduled runs the command once, within the timeout
+func (e *ExecInput) runScheduled(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var mux sync.Mutex
+	var entries []*entry.Entry
+	exitCode, err := e.run(runCtx, func(ent *entry.Entry) {
+		mux.Lock()
+		entries = append(entries, ent)
+		mux.Unlock()
+	})
+	if err != nil {
+		e.Errorw("Failed to run command", zap.Error(err), "command", e.command)
+		return
+	}
+
+	if runCtx.Err() == context.DeadlineExceeded {
+		e.Warnw("Command was killed after exceeding the timeout", "command", e.command, "timeout", e.timeout)
+	}
+
+	code := strconv.Itoa(exitCode)
+	for _, ent := range entries {
+		ent.AddLabel(exitCodeLabel, code)
+		e.Write(ctx, ent)
+	}
+}
+
+// stream runs the command, and restarts it with a backoff whenever it exits.
+// Entries are written as they are read.
+func (e *ExecInput) stream(ctx context.Context) {
+	for {
+		started := time.Now()
+		exitCode, err := e.run(ctx, func(ent *entry.Entry) {
+			e.Write(ctx, ent)
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err != nil {
+			e.Errorw("Failed to run command", zap.Error(err), "command", e.command)
+		} else {
+			e.Warnw("Command exited", "command", e.command, "exit_code", exitCode)
+		}
+
+		// A command that ran for longer than the longest delay is restarted
+		// without delay increasing from previous failures
+		if time.Since(started) >= e.backoff.Max {
+			e.backoff.Reset()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.backoff.Duration()):
+		}
+	}
+}
+
+// run runs the command until it exits, handling an entry for each of its
+// stdout and stderr logs, and returns its exit code
+func (e *ExecInput) run(ctx context.Context, handle func(*entry.Entry)) (int, error) {
+	cmd := exec.CommandContext(ctx, e.command, e.args...) // #nosec - the command is configured by the user
+	cmd.Dir = e.dir
+	if len(e.env) > 0 {
+		cmd.Env = append(os.Environ(), e.env...)
+	}
+
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+		return 0, err
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	err = cmd.Start()
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return 0, err
+	}
+
+	// Processes started by the command may keep the pipes open after it
+	// is killed, so the pipes are closed to stop reading them
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stdout.Close()
+			stderr.Close()
+		case <-done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		e.read(stdout, streamStdout, handle)
+	}()
+	go func() {
+		defer wg.Done()
+		e.read(stderr, streamStderr, handle)
+	}()
+	wg.Wait()
+	close(done)
+	stdout.Close()
+	stderr.Close()
+
+	if err := cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return 0, err
+		}
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
+
+// read splits the output of a stream of the command into entries
+func (e *ExecInput) read(file *os.File, stream string, handle func(*entry.Entry)) {
+	bufferSize := 16 * 1024
+	if e.maxLogSize < bufferSize {
+		bufferSize = e.maxLogSize
+	}
+
+	flusher := helper.NewFlusher(e.forceFlushPeriod)
+	reader := helper.NewFlushReader(file, flusher)
+	buf := make([]byte, 0, bufferSize)
+
+	var scanner *bufio.Scanner
+	for {
+		scanner = bufio.NewScanner(reader)
+		scanner.Buffer(buf, e.maxLogSize)
+		scanner.Split(flusher.SplitFunc(e.splitFunc))
+
+		for scanner.Scan() {
+			decoded, err := e.encoding.Decode(scanner.Bytes())
+			if err != nil {
+				e.Errorw("Failed to decode output", zap.Error(err))
+				continue
+			}
+			e.handleOutput(decoded, stream, reader.ForceFlushed, handle)
+		}
+
+		// The stream is read again after an incomplete entry is force flushed
+		if !reader.ForceFlushed || scanner.Err() != nil {
+			break
+		}
+		reader.ForceFlushed = false
+	}
+
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			e.Errorw("Discarding output that exceeds max_log_size", "stream", stream, "max_log_size", e.maxLogSize)
+		} else if !isClosedError(err) {
+			e.Errorw("Failed to read output", zap.Error(err), "stream", stream)
+		}
+
+		// The rest of the output is discarded, so that the command
+		// is not blocked writing to a full pipe
+		_, _ = io.Copy(ioutil.Discard, file)
+	}
+}
+
+// handleOutput creates an entry from a log of a stream of the command
+func (e *ExecInput) handleOutput(message, stream string, forceFlushed bool, handle func(*entry.Entry)) {
+	if message == "" {
+		return
+	}
+
+	ent, err := e.NewEntry(message)
+	if err != nil {
+		e.Errorw("Failed to create entry", zap.Error(err))
+		return
+	}
+
+	ent.AddLabel(streamLabel, stream)
+	if forceFlushed {
+		ent.AddLabel(helper.ForceFlushedLabel, "true")
+	}
+	handle(ent)
+}
+
+// isClosedError returns true if the error is expected when a pipe is closed
+func isClosedError(err error) bool {
+	return errors.Is(err, os.ErrClosed)
+}
@@ -0,0 +1,294 @@
+// +build !windows
+
+package exec
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func expectRecord(t *testing.T, fake *testutil.FakeOutput, expected interface{}) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, expected, e.Record)
+		return e
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+func TestExecInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*ExecInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *ExecInputConfig) {}, false},
+		{"Stream", func(cfg *ExecInputConfig) { cfg.Mode = modeStream }, false},
+		{"MissingCommand", func(cfg *ExecInputConfig) { cfg.Command = "" }, true},
+		{"InvalidMode", func(cfg *ExecInputConfig) { cfg.Mode = "cron" }, true},
+		{"ZeroInterval", func(cfg *ExecInputConfig) { cfg.Interval = helper.NewDuration(0) }, true},
+		{"NegativeTimeout", func(cfg *ExecInputConfig) { cfg.Timeout = helper.NewDuration(-time.Second) }, true},
+		{"ZeroRestartDelay", func(cfg *ExecInputConfig) {
+			cfg.Mode = modeStream
+			cfg.RestartDelay = helper.NewDuration(0)
+		}, true},
+		{"MaxRestartDelayTooShort", func(cfg *ExecInputConfig) {
+			cfg.Mode = modeStream
+			cfg.MaxRestartDelay = helper.NewDuration(time.Millisecond)
+		}, true},
+		{"ZeroMaxLogSize", func(cfg *ExecInputConfig) { cfg.MaxLogSize = 0 }, true},
+		{"InvalidMultiline", func(cfg *ExecInputConfig) {
+			cfg.Multiline.LineStartPattern = "a"
+			cfg.Multiline.LineEndPattern = "b"
+		}, true},
+		{"InvalidEncoding", func(cfg *ExecInputConfig) { cfg.Encoding.Encoding = "invalid" }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewExecInputConfig("test")
+			cfg.Command = "true"
+			tc.modify(cfg)
+
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecInputScheduled(t *testing.T) {
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", "echo out; echo err >&2; exit 3"}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Interval = helper.NewDuration(100 * time.Millisecond)
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	// The command runs again on the next interval
+	for run := 0; run < 2; run++ {
+		streams := map[string]string{}
+		for i := 0; i < 2; i++ {
+			select {
+			case e := <-fake.Received:
+				require.Equal(t, "3", e.Labels[exitCodeLabel])
+				streams[e.Labels[streamLabel]] = e.Record.(string)
+			case <-time.After(3 * time.Second):
+				require.FailNow(t, "Timed out waiting for entry")
+			}
+		}
+		require.Equal(t, map[string]string{streamStdout: "out", streamStderr: "err"}, streams)
+	}
+}
+
+func TestExecInputScheduledTimeout(t *testing.T) {
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", "echo before; sleep 10"}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Timeout = helper.NewDuration(200 * time.Millisecond)
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	e := expectRecord(t, fake, "before")
+	require.Equal(t, "-1", e.Labels[exitCodeLabel])
+}
+
+func TestExecInputEnv(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", `echo "$GREETING from $(pwd)"`}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Env = map[string]string{"GREETING": "hello"}
+	cfg.WorkingDirectory = dir
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	expectRecord(t, fake, "hello from "+resolved)
+}
+
+func TestExecInputStream(t *testing.T) {
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", "echo first; sleep 10"}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Mode = modeStream
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	// Entries are written before the command exits, without an exit code
+	e := expectRecord(t, fake, "first")
+	require.Equal(t, streamStdout, e.Labels[streamLabel])
+	require.NotContains(t, e.Labels, exitCodeLabel)
+}
+
+func TestExecInputStreamRestart(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	counter := filepath.Join(dir, "counter")
+	script := `echo x >> ` + counter + `; wc -l < ` + counter + ` | tr -d ' '`
+
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", script}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Mode = modeStream
+	cfg.RestartDelay = helper.NewDuration(10 * time.Millisecond)
+	cfg.MaxRestartDelay = helper.NewDuration(50 * time.Millisecond)
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	expectRecord(t, fake, "1")
+	expectRecord(t, fake, "2")
+	expectRecord(t, fake, "3")
+}
+
+func TestExecInputMultiline(t *testing.T) {
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", `printf 'START a\nb\nSTART c\n'`}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Multiline.LineStartPattern = "START"
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	expectRecord(t, fake, "START a\nb\n")
+	expectRecord(t, fake, "START c\n")
+}
+
+func TestExecInputForceFlush(t *testing.T) {
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", `printf 'incomplete'; sleep 10`}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Mode = modeStream
+	cfg.Multiline.ForceFlushPeriod = helper.NewDuration(100 * time.Millisecond)
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	e := expectRecord(t, fake, "incomplete")
+	require.Equal(t, "true", e.Labels[helper.ForceFlushedLabel])
+}
+
+func TestExecInputMaxLogSize(t *testing.T) {
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", `printf '%0100d\n' 0; echo err >&2`}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.MaxLogSize = 10
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	// The stdout output is discarded, without blocking the command
+	e := expectRecord(t, fake, "err")
+	require.Equal(t, streamStderr, e.Labels[streamLabel])
+}
+
+func TestExecInputStopKillsCommand(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	marker := filepath.Join(dir, "marker")
+
+	cfg := NewExecInputConfig("test")
+	cfg.Command = "sh"
+	cfg.Args = []string{"-c", "echo started; sleep 10; touch " + marker}
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Mode = modeStream
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	execInput := ops[0].(*ExecInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, execInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, execInput.Start())
+	defer execInput.Stop()
+
+	expectRecord(t, fake, "started")
+
+	stopped := make(chan struct{})
+	go func() {
+		require.NoError(t, execInput.Stop())
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for the operator to stop")
+	}
+
+	_, err = ioutil.ReadFile(marker)
+	require.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Modes of reading files
+const (
+	modeTail  = "tail"
+	modeBatch = "batch"
+)
+
+// Actions taken on files that are completely read in batch mode
+const (
+	onCompleteNone   = "none"
+	onCompleteDelete = "delete"
+	onCompleteMove   = "move"
+)
+
+const completedFilesKey = "completedFiles"
+
+// skipCompleted closes and removes the readers of files that have already
+// been completed in batch mode. Since the file still exists, the on_complete
+// action is retried, in case it failed or was interrupted.
+func (f *InputOperator) skipCompleted(readers []*Reader) []*Reader {
+	remaining := make([]*Reader, 0, len(readers))
+	for _, reader := range readers {
+		fp, ok := f.completedFiles[reader.fileLabels.Path]
+		if !ok || !(reader.Fingerprint.StartsWith(fp) || fp.StartsWith(reader.Fingerprint)) {
+			remaining = append(remaining, reader)
+			continue
+		}
+
+		reader.Close()
+		if err := f.applyOnComplete(reader.fileLabels.Path); err != nil {
+			f.Errorw("Failed to apply on_complete action", zap.Error(err), "path", reader.fileLabels.Path)
+		}
+	}
+	return remaining
+}
+
+// completeFiles handles the readers of files that were read to the end in
+// batch mode. Each file is recorded as completed before its on_complete action
+// is applied, so that it is never read twice. The readers of files that are not
+// yet complete are returned, to be read again on the next poll.
+func (f *InputOperator) completeFiles(ctx context.Context, readers []*Reader) []*Reader {
+	remaining := make([]*Reader, 0, len(readers))
+	completed := make([]*Reader, 0, len(readers))
+	for _, reader := range readers {
+		size, ok := reader.completedSize(ctx)
+		if !ok {
+			remaining = append(remaining, reader)
+			continue
+		}
+
+		if err := reader.emitComplete(ctx, size); err != nil {
+			reader.Errorw("Failed to emit completion entry", zap.Error(err))
+		}
+		f.completedFiles[reader.fileLabels.Path] = reader.Fingerprint.Copy()
+		completed = append(completed, reader)
+	}
+
+	if len(completed) == 0 {
+		return remaining
+	}
+
+	f.syncLastPollFiles()
+	for _, reader := range completed {
+		reader.Close()
+		if err := f.applyOnComplete(reader.fileLabels.Path); err != nil {
+			reader.Errorw("Failed to apply on_complete action", zap.Error(err))
+		}
+	}
+	return remaining
+}
+
+// applyOnComplete applies the on_complete action to a file
+func (f *InputOperator) applyOnComplete(path string) error {
+	switch f.onComplete {
+	case onCompleteDelete:
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete: %s", err)
+		}
+		f.Debugw("Deleted completed file", "path", path)
+	case onCompleteMove:
+		dst := filepath.Join(f.moveTo, filepath.Base(path))
+		if _, err := os.Stat(dst); err == nil {
+			// Avoid overwriting a previously completed file with the same name
+			dst = dst + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+		}
+		if err := os.Rename(path, dst); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("move: %s", err)
+		}
+		f.Debugw("Moved completed file", "path", path, "destination", dst)
+	}
+	return nil
+}
+
+// pruneCompleted forgets completed files that no longer exist,
+// such as files that were deleted or moved after completion
+func (f *InputOperator) pruneCompleted() {
+	for path := range f.completedFiles {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(f.completedFiles, path)
+		}
+	}
+}
+
+// encodeCompletedFiles encodes the completed files as a map of path to fingerprint
+func (f *InputOperator) encodeCompletedFiles() ([]byte, error) {
+	return json.Marshal(f.completedFiles)
+}
+
+// loadCompletedFiles loads the completed files from the database
+func (f *InputOperator) loadCompletedFiles() error {
+	f.completedFiles = make(map[string]*Fingerprint)
+
+	encoded := f.persist.Get(completedFilesKey)
+	if encoded == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(encoded, &f.completedFiles); err != nil {
+		return fmt.Errorf("decoding completed files: %w", err)
+	}
+	return nil
+}
+
+// completedSize returns the size of the file if it has been read to the end
+func (f *Reader) completedSize(ctx context.Context) (int64, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		f.Errorw("Failed to stat", zap.Error(err))
+		return 0, false
+	}
+	return info.Size(), f.Offset >= info.Size()
+}
+
+// emitComplete sends an entry that signals that the file was completely read
+func (f *Reader) emitComplete(ctx context.Context, size int64) error {
+	e, err := f.fileInput.NewEntry(map[string]interface{}{
+		"event":     "complete",
+		"file_path": f.fileLabels.Path,
+		"size":      size,
+	})
+	if err != nil {
+		return fmt.Errorf("create entry: %s", err)
+	}
+	e.AddLabel("file_event", "complete")
+
+	if err := f.setFileFields(e); err != nil {
+		return err
+	}
+
+	f.fileInput.Write(ctx, e)
+	return nil
+}
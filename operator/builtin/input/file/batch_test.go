@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForComplete(t *testing.T, c chan *entry.Entry, path string) {
+	select {
+	case e := <-c:
+		require.Equal(t, "complete", e.Labels["file_event"])
+		record, ok := e.Record.(map[string]interface{})
+		require.True(t, ok, "expected a completion entry, got %v", e.Record)
+		require.Equal(t, "complete", record["event"])
+		require.Equal(t, path, record["file_path"])
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for completion entry", path)
+	}
+}
+
+func writeBatchFile(t *testing.T, path, contents string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+}
+
+func TestBatchReadsOnce(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Mode = modeBatch
+		cfg.StartAt = "end"
+	}, nil)
+
+	// Batch mode reads existing files from the beginning, and
+	// flushes the last entry since the file is complete
+	path := filepath.Join(tempDir, "bundle1.log")
+	writeBatchFile(t, path, "testlog1\ntestlog2")
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	waitForMessage(t, logReceived, "testlog1")
+	waitForMessage(t, logReceived, "testlog2")
+	waitForComplete(t, logReceived, path)
+	expectNoMessagesUntil(t, logReceived, 500*time.Millisecond)
+	require.FileExists(t, path)
+
+	// Completed files are not read again after a restart
+	require.NoError(t, operator.Stop())
+	require.NoError(t, operator.Start())
+	expectNoMessagesUntil(t, logReceived, 500*time.Millisecond)
+
+	path2 := filepath.Join(tempDir, "bundle2.log")
+	writeBatchFile(t, path2, "testlog3\n")
+	waitForMessage(t, logReceived, "testlog3")
+	waitForComplete(t, logReceived, path2)
+}
+
+func TestBatchDelete(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Mode = modeBatch
+		cfg.OnComplete = onCompleteDelete
+	}, nil)
+
+	path := filepath.Join(tempDir, "bundle.log")
+	writeBatchFile(t, path, "testlog1\n")
+
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog1")
+	waitForComplete(t, logReceived, path)
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	// Deleted files are forgotten, so a new file with the same name is read
+	operator.poll(context.Background())
+	require.Empty(t, operator.completedFiles)
+
+	writeBatchFile(t, path, "testlog2\n")
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog2")
+	waitForComplete(t, logReceived, path)
+}
+
+func TestBatchMove(t *testing.T) {
+	t.Parallel()
+	moveTo := testutil.NewTempDir(t)
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Mode = modeBatch
+		cfg.OnComplete = onCompleteMove
+		cfg.MoveTo = moveTo
+	}, nil)
+
+	path := filepath.Join(tempDir, "bundle.log")
+	writeBatchFile(t, path, "testlog1\n")
+
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog1")
+	waitForComplete(t, logReceived, path)
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+	require.FileExists(t, filepath.Join(moveTo, "bundle.log"))
+
+	// A completed file with the same name is not overwritten
+	writeBatchFile(t, path, "testlog2\n")
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog2")
+	waitForComplete(t, logReceived, path)
+
+	moved, err := ioutil.ReadDir(moveTo)
+	require.NoError(t, err)
+	require.Len(t, moved, 2)
+}
+
+func TestBatchRetryOnComplete(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Mode = modeBatch
+		cfg.OnComplete = onCompleteDelete
+	}, nil)
+
+	// A file that was completed, but not deleted, is deleted without being read again
+	path := filepath.Join(tempDir, "bundle.log")
+	writeBatchFile(t, path, "testlog1\n")
+	operator.completedFiles[path] = &Fingerprint{FirstBytes: []byte("testlog1\n")}
+
+	operator.poll(context.Background())
+	expectNoMessages(t, logReceived)
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestBatchIncompleteCompressed(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Mode = modeBatch
+		cfg.OnComplete = onCompleteDelete
+	}, nil)
+
+	data := gzipBytes(t, "testlog1\ntestlog2\ntestlog3")
+	path := filepath.Join(tempDir, "bundle.log.gz")
+	temp := openFile(t, path)
+
+	// An archive that is still being written is not completed
+	_, err := temp.Write(data[:len(data)-12])
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog1")
+	waitForMessage(t, logReceived, "testlog2")
+	expectNoMessages(t, logReceived)
+	require.FileExists(t, path)
+
+	_, err = temp.Write(data[len(data)-12:])
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog3")
+	waitForComplete(t, logReceived, path)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
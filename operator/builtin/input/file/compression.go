@@ -0,0 +1,190 @@
+package file
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+// Compression formats of files
+const (
+	compressionAuto  = "auto"
+	compressionNone  = "none"
+	compressionGzip  = "gzip"
+	compressionZstd  = "zstd"
+	compressionBzip2 = "bzip2"
+)
+
+var compressionMagic = []struct {
+	compression string
+	magic       []byte
+}{
+	{compressionGzip, []byte{0x1f, 0x8b}},
+	{compressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{compressionBzip2, []byte("BZh")},
+}
+
+var compressionExtensions = map[string]string{
+	".gz":   compressionGzip,
+	".gzip": compressionGzip,
+	".zst":  compressionZstd,
+	".zstd": compressionZstd,
+	".bz2":  compressionBzip2,
+}
+
+// validateCompression returns an error if the compression is not supported
+func validateCompression(compression string) error {
+	switch compression {
+	case compressionAuto, compressionNone, compressionGzip, compressionZstd, compressionBzip2:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression '%s', must be one of '%s', '%s', '%s', '%s' or '%s'",
+			compression, compressionAuto, compressionNone, compressionGzip, compressionZstd, compressionBzip2)
+	}
+}
+
+// detectCompression returns the compression of a file, or an empty string if
+// the file is not compressed. A file is detected by the magic bytes at the start
+// of its fingerprint, or by its extension if the file is too short to tell.
+func detectCompression(path string, fp *Fingerprint) string {
+	for _, c := range compressionMagic {
+		if bytes.HasPrefix(fp.FirstBytes, c.magic) {
+			return c.compression
+		}
+	}
+
+	for _, c := range compressionMagic {
+		if len(fp.FirstBytes) < len(c.magic) && bytes.HasPrefix(c.magic, fp.FirstBytes) {
+			return compressionExtensions[strings.ToLower(filepath.Ext(path))]
+		}
+	}
+	return ""
+}
+
+// newDecompressor returns a reader that decompresses r
+func newDecompressor(compression string, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case compressionGzip:
+		return gzip.NewReader(r)
+	case compressionZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case compressionBzip2:
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression '%s'", compression)
+	}
+}
+
+// fileCompression returns the compression of a file, or an empty string
+// if the file should be read as plain text
+func (f *InputOperator) fileCompression(path string, fp *Fingerprint) string {
+	switch f.compression {
+	case compressionAuto:
+		return detectCompression(path, fp)
+	case compressionNone:
+		return ""
+	default:
+		return f.compression
+	}
+}
+
+// readCompressed reads a compressed file to the end. Since a compressed
+// stream cannot be resumed from the middle, the file is decompressed from
+// the start and the entries before DecompressedOffset are skipped. Once the
+// file is fully read, Offset is set to its size so that it is not read again.
+func (f *Reader) readCompressed(ctx context.Context, compression string) {
+	info, err := f.file.Stat()
+	if err != nil {
+		f.Errorw("Failed to stat", zap.Error(err))
+		return
+	}
+	if f.Offset >= info.Size() {
+		return
+	}
+
+	if _, err := f.file.Seek(0, 0); err != nil {
+		f.Errorw("Failed to seek", zap.Error(err))
+		return
+	}
+
+	tr := f.throttle(f.file, 0)
+	fr := NewFingerprintUpdatingReader(tr, 0, f.Fingerprint, f.fileInput.fingerprintSize)
+	decompressor, err := newDecompressor(compression, fr)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		f.Debugw("Compressed file is incomplete, waiting for more data", "compression", compression)
+		return
+	} else if err != nil {
+		f.Errorw("Failed to decompress", zap.Error(err), "compression", compression)
+		return
+	}
+	defer decompressor.Close()
+
+	cr := &compressedReader{reader: decompressor}
+	if f.DecompressedOffset > 0 {
+		if _, err := io.CopyN(ioutil.Discard, cr, f.DecompressedOffset); err != nil {
+			if cr.incomplete {
+				f.Debugw("Compressed file is incomplete, waiting for more data", "compression", compression)
+			} else {
+				f.Errorw("Failed to skip to offset", zap.Error(err), "compression", compression)
+			}
+			return
+		}
+	}
+
+	scanner := NewPositionalScanner(cr, f.fileInput.MaxLogSize, f.DecompressedOffset, f.flushingSplitFunc(func() bool { return !cr.incomplete && !tr.throttled }))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		ok := scanner.Scan()
+		if !ok {
+			if err := getScannerError(scanner); err != nil {
+				f.Errorw("Failed during scan", zap.Error(err))
+				return
+			}
+			break
+		}
+
+		if err := f.emit(ctx, scanner.Bytes()); err != nil {
+			f.Error("Failed to emit entry", zap.Error(err))
+		}
+		f.DecompressedOffset = scanner.Pos()
+	}
+
+	if !cr.incomplete && !tr.throttled {
+		f.Offset = info.Size()
+	}
+}
+
+// compressedReader reports a truncated compressed stream as the end of the
+// stream, so that the entries read so far are emitted
+type compressedReader struct {
+	reader     io.Reader
+	incomplete bool
+}
+
+// Read reads from the decompressor
+func (r *compressedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.ErrUnexpectedEOF {
+		r.incomplete = true
+		return n, io.EOF
+	}
+	return n, err
+}
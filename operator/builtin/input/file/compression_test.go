@@ -0,0 +1,199 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDetectCompression(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		bytes    []byte
+		expected string
+	}{
+		{"Gzip", "app.log", []byte{0x1f, 0x8b, 0x08, 0x00}, compressionGzip},
+		{"Zstd", "app.log", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x04}, compressionZstd},
+		{"Bzip2", "app.log", []byte("BZh91AY&SY"), compressionBzip2},
+		{"PlainText", "app.log.gz", []byte("testlog1\n"), ""},
+		{"PartialMagicWithExtension", "app.log.GZ", []byte{0x1f}, compressionGzip},
+		{"PartialMagicWithoutExtension", "app.log", []byte{0x1f}, ""},
+		{"PartialMagicBzip2", "app.log.bz2", []byte("BZ"), compressionBzip2},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fp := &Fingerprint{FirstBytes: tc.bytes}
+			require.Equal(t, tc.expected, detectCompression(tc.path, fp))
+		})
+	}
+}
+
+func TestFileCompression(t *testing.T) {
+	fp := &Fingerprint{FirstBytes: []byte{0x1f, 0x8b, 0x08, 0x00}}
+
+	cases := []struct {
+		compression string
+		expected    string
+	}{
+		{compressionAuto, compressionGzip},
+		{compressionNone, ""},
+		{compressionZstd, compressionZstd},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.compression, func(t *testing.T) {
+			operator, _, _ := newTestFileOperator(t, func(cfg *InputConfig) {
+				cfg.Compression = tc.compression
+			}, nil)
+			require.Equal(t, tc.expected, operator.fileCompression("app.log", fp))
+		})
+	}
+}
+
+func TestReadCompressed(t *testing.T) {
+	bzip2Data, err := ioutil.ReadFile(filepath.Join("testdata", "compressed.log.bz2"))
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{"Gzip", "app.log.gz", gzipBytes(t, "testlog1\ntestlog2")},
+		{"Zstd", "app.log.zst", zstdBytes(t, "testlog1\ntestlog2")},
+		{"Bzip2", "app.log.bz2", bzip2Data},
+		{"NoExtension", "app.log.1", gzipBytes(t, "testlog1\ntestlog2")},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			operator, logReceived, tempDir := newTestFileOperator(t, nil, nil)
+
+			temp := openFile(t, filepath.Join(tempDir, tc.file))
+			_, err := temp.Write(tc.data)
+			require.NoError(t, err)
+
+			require.NoError(t, operator.Start())
+			defer operator.Stop()
+
+			// The last entry is flushed since the file is complete
+			waitForMessage(t, logReceived, "testlog1")
+			waitForMessage(t, logReceived, "testlog2")
+			expectNoMessages(t, logReceived)
+		})
+	}
+}
+
+func TestReadCompressedMultiline(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Multiline.LineStartPattern = "^START"
+	}, nil)
+
+	temp := openFile(t, filepath.Join(tempDir, "app.log.gz"))
+	_, err := temp.Write(gzipBytes(t, "START 1\nline\nSTART 2\nline\nSTART 3\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	waitForMessage(t, logReceived, "START 1\nline\n")
+	waitForMessage(t, logReceived, "START 2\nline\n")
+	waitForMessage(t, logReceived, "START 3\n")
+	expectNoMessages(t, logReceived)
+}
+
+func TestReadCompressedIncomplete(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, nil, nil)
+
+	data := gzipBytes(t, "testlog1\ntestlog2\ntestlog3")
+	temp := openFile(t, filepath.Join(tempDir, "app.log.gz"))
+
+	// Only the entries that are fully decompressed are read
+	// from an archive that is still being written
+	_, err := temp.Write(data[:len(data)-12])
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog1")
+	waitForMessage(t, logReceived, "testlog2")
+	expectNoMessages(t, logReceived)
+
+	_, err = temp.Write(data[len(data)-12:])
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog3")
+	expectNoMessages(t, logReceived)
+}
+
+func TestReadCompressedNone(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Compression = compressionNone
+	}, nil)
+
+	temp := openFile(t, filepath.Join(tempDir, "app.log.gz"))
+	writeString(t, temp, "\x1f\x8b\x08testlog1\n")
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	waitForMessage(t, logReceived, "\x1f\x8b\x08testlog1")
+}
+
+// CompressedOffsetsAfterRestart tests that a completed archive
+// is not read again after a restart
+func TestCompressedOffsetsAfterRestart(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, nil, nil)
+
+	temp1 := openFile(t, filepath.Join(tempDir, "app.log.1.gz"))
+	_, err := temp1.Write(gzipBytes(t, "testlog1\ntestlog2\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+	waitForMessage(t, logReceived, "testlog1")
+	waitForMessage(t, logReceived, "testlog2")
+
+	require.NoError(t, operator.Stop())
+	require.NoError(t, operator.Start())
+
+	temp2 := openFile(t, filepath.Join(tempDir, "app.log.2.gz"))
+	_, err = temp2.Write(gzipBytes(t, "testlog3\n"))
+	require.NoError(t, err)
+
+	waitForMessage(t, logReceived, "testlog3")
+	expectNoMessages(t, logReceived)
+}
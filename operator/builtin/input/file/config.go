@@ -2,10 +2,12 @@ package file
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v2"
 	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/errors"
 	"github.com/observiq/stanza/operator"
 	"github.com/observiq/stanza/operator/helper"
 )
@@ -19,6 +21,12 @@ const (
 	defaultMaxConcurrentFiles = 1024
 )
 
+// Targets of the named capture groups of path_regex
+const (
+	pathRegexTargetLabels   = "labels"
+	pathRegexTargetResource = "resource"
+)
+
 // NewInputConfig creates a new input config with default values
 func NewInputConfig(operatorID string) *InputConfig {
 	return &InputConfig{
@@ -32,6 +40,12 @@ func NewInputConfig(operatorID string) *InputConfig {
 		FingerprintSize:         defaultFingerprintSize,
 		MaxLogSize:              defaultMaxLogSize,
 		MaxConcurrentFiles:      defaultMaxConcurrentFiles,
+		Compression:             compressionAuto,
+		Mode:                    modeTail,
+		OnComplete:              onCompleteNone,
+		Discovery:               discoveryPoll,
+		FallbackPollInterval:    helper.Duration{Duration: defaultFallbackPollInterval},
+		PathRegexTarget:         pathRegexTargetLabels,
 		Encoding:                helper.NewEncodingConfig(),
 	}
 }
@@ -53,6 +67,19 @@ type InputConfig struct {
 	FingerprintSize         helper.ByteSize        `json:"fingerprint_size,omitempty"            yaml:"fingerprint_size,omitempty"`
 	MaxLogSize              helper.ByteSize        `json:"max_log_size,omitempty"                yaml:"max_log_size,omitempty"`
 	MaxConcurrentFiles      int                    `json:"max_concurrent_files,omitempty"        yaml:"max_concurrent_files,omitempty"`
+	Compression             string                 `json:"compression,omitempty"                 yaml:"compression,omitempty"`
+	Mode                    string                 `json:"mode,omitempty"                        yaml:"mode,omitempty"`
+	OnComplete              string                 `json:"on_complete,omitempty"                 yaml:"on_complete,omitempty"`
+	MoveTo                  string                 `json:"move_to,omitempty"                     yaml:"move_to,omitempty"`
+	Discovery               string                 `json:"discovery,omitempty"                   yaml:"discovery,omitempty"`
+	FallbackPollInterval    helper.Duration        `json:"fallback_poll_interval,omitempty"      yaml:"fallback_poll_interval,omitempty"`
+	PathRegex               string                 `json:"path_regex,omitempty"                  yaml:"path_regex,omitempty"`
+	PathRegexTarget         string                 `json:"path_regex_target,omitempty"           yaml:"path_regex_target,omitempty"`
+	Header                  HeaderConfig           `json:"header,omitempty"                      yaml:"header,omitempty"`
+	MaxFileReadRate         helper.ByteSize        `json:"max_file_read_rate,omitempty"          yaml:"max_file_read_rate,omitempty"`
+	MaxReadRate             helper.ByteSize        `json:"max_read_rate,omitempty"               yaml:"max_read_rate,omitempty"`
+	PrioritizeInclude       bool                   `json:"prioritize_include,omitempty"          yaml:"prioritize_include,omitempty"`
+	EventsOutput            helper.OutputIDs       `json:"events_output,omitempty"               yaml:"events_output,omitempty"`
 	Encoding                helper.EncodingConfig  `json:",inline,omitempty"                     yaml:",inline,omitempty"`
 }
 
@@ -87,6 +114,10 @@ func (c InputConfig) Build(context operator.BuildContext) ([]operator.Operator,
 		return nil, fmt.Errorf("`max_log_size` must be positive")
 	}
 
+	if c.MaxFileReadRate < 0 || c.MaxReadRate < 0 {
+		return nil, fmt.Errorf("`max_file_read_rate` and `max_read_rate` must not be negative")
+	}
+
 	if c.MaxConcurrentFiles <= 1 {
 		return nil, fmt.Errorf("`max_concurrent_files` must be greater than 1")
 	}
@@ -97,6 +128,12 @@ func (c InputConfig) Build(context operator.BuildContext) ([]operator.Operator,
 		return nil, fmt.Errorf("`fingerprint_size` must be at least %d bytes", minFingerprintSize)
 	}
 
+	if c.Compression == "" {
+		c.Compression = compressionAuto
+	} else if err := validateCompression(c.Compression); err != nil {
+		return nil, err
+	}
+
 	encoding, err := c.Encoding.Build(context)
 	if err != nil {
 		return nil, err
@@ -107,6 +144,12 @@ func (c InputConfig) Build(context operator.BuildContext) ([]operator.Operator,
 		return nil, err
 	}
 
+	// Files that are complete, such as compressed files, have their last entry flushed
+	flushingSplitFunc, err := c.Multiline.Build(context, encoding.Encoding, true)
+	if err != nil {
+		return nil, err
+	}
+
 	var startAtBeginning bool
 	switch c.StartAt {
 	case "beginning":
@@ -117,6 +160,82 @@ func (c InputConfig) Build(context operator.BuildContext) ([]operator.Operator,
 		return nil, fmt.Errorf("invalid start_at location '%s'", c.StartAt)
 	}
 
+	var batch bool
+	switch c.Mode {
+	case "", modeTail:
+		batch = false
+	case modeBatch:
+		// Files in batch mode are always read from the beginning
+		batch = true
+		startAtBeginning = true
+	default:
+		return nil, fmt.Errorf("invalid mode '%s'", c.Mode)
+	}
+
+	switch c.OnComplete {
+	case "", onCompleteNone:
+		c.OnComplete = onCompleteNone
+	case onCompleteDelete, onCompleteMove:
+		if !batch {
+			return nil, fmt.Errorf("`on_complete` requires `mode` to be '%s'", modeBatch)
+		}
+	default:
+		return nil, fmt.Errorf("invalid on_complete action '%s'", c.OnComplete)
+	}
+
+	switch c.Discovery {
+	case "":
+		c.Discovery = discoveryPoll
+	case discoveryPoll, discoveryNotify:
+	default:
+		return nil, fmt.Errorf("invalid discovery '%s', must be '%s' or '%s'", c.Discovery, discoveryPoll, discoveryNotify)
+	}
+
+	if c.FallbackPollInterval.Raw() == 0 {
+		c.FallbackPollInterval = helper.Duration{Duration: defaultFallbackPollInterval}
+	} else if c.FallbackPollInterval.Raw() < 0 {
+		return nil, fmt.Errorf("`fallback_poll_interval` must be positive")
+	}
+
+	if c.OnComplete == onCompleteMove && c.MoveTo == "" {
+		return nil, fmt.Errorf("`move_to` is required when `on_complete` is '%s'", onCompleteMove)
+	} else if c.OnComplete != onCompleteMove && c.MoveTo != "" {
+		return nil, fmt.Errorf("`move_to` requires `on_complete` to be '%s'", onCompleteMove)
+	}
+
+	var pathRegex *regexp.Regexp
+	if c.PathRegex != "" {
+		pathRegex, err = regexp.Compile(c.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("compiling path_regex: %s", err)
+		}
+
+		namedCaptureGroups := 0
+		for _, groupName := range pathRegex.SubexpNames() {
+			if groupName != "" {
+				namedCaptureGroups++
+			}
+		}
+		if namedCaptureGroups == 0 {
+			return nil, errors.NewError(
+				"no named capture groups in path_regex",
+				"use named capture groups like '^/var/log/(?P<app>[^/]+)/' to specify the label names",
+			)
+		}
+	}
+
+	switch c.PathRegexTarget {
+	case "":
+		c.PathRegexTarget = pathRegexTargetLabels
+	case pathRegexTargetLabels, pathRegexTargetResource:
+	default:
+		return nil, fmt.Errorf("invalid path_regex_target '%s', must be '%s' or '%s'", c.PathRegexTarget, pathRegexTargetLabels, pathRegexTargetResource)
+	}
+
+	if err := c.Header.validate(); err != nil {
+		return nil, err
+	}
+
 	fileNameField := entry.NewNilField()
 	if c.IncludeFileName {
 		fileNameField = entry.NewLabelField("file_name")
@@ -142,6 +261,23 @@ func (c InputConfig) Build(context operator.BuildContext) ([]operator.Operator,
 		Include:               c.Include,
 		Exclude:               c.Exclude,
 		SplitFunc:             splitFunc,
+		flushingSplitFunc:     flushingSplitFunc,
+		forceFlushPeriod:      c.Multiline.ForceFlushPeriod.Raw(),
+		compression:           c.Compression,
+		batch:                 batch,
+		onComplete:            c.OnComplete,
+		moveTo:                c.MoveTo,
+		completedFiles:        make(map[string]*Fingerprint),
+		discovery:             c.Discovery,
+		fallbackPollInterval:  c.FallbackPollInterval.Raw(),
+		pathRegex:             pathRegex,
+		pathRegexTarget:       c.PathRegexTarget,
+		headerFormat:          c.Header.Format,
+		headerLabel:           c.Header.Label,
+		maxFileReadRate:       int64(c.MaxFileReadRate),
+		readBucket:            newBucket(int64(c.MaxReadRate)),
+		prioritizeInclude:     c.PrioritizeInclude,
+		eventOutputIDs:        c.EventsOutput.WithNamespace(context),
 		PollInterval:          c.PollInterval.Raw(),
 		persist:               helper.NewScopedDBPersister(context.Database, c.ID()),
 		FilePathField:         filePathField,
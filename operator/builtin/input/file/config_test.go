@@ -503,6 +503,76 @@ func TestUnmarshal(t *testing.T) {
 				return cfg
 			}(),
 		},
+		{
+			Name:      "discovery_notify",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.Discovery = "notify"
+				cfg.FallbackPollInterval = helper.Duration{Duration: 30 * time.Second}
+				return cfg
+			}(),
+		},
+		{
+			Name:      "path_regex",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.PathRegex = `^/var/log/pods/(?P<namespace>[^_]+)_(?P<pod>[^_]+)_`
+				cfg.PathRegexTarget = "resource"
+				return cfg
+			}(),
+		},
+		{
+			Name:      "header_csv",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.Header = HeaderConfig{Format: "csv", Label: "csv_header"}
+				return cfg
+			}(),
+		},
+		{
+			Name:      "mode_batch",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.Mode = "batch"
+				cfg.OnComplete = "move"
+				cfg.MoveTo = "/var/log/done"
+				return cfg
+			}(),
+		},
+		{
+			Name:      "compression_gzip",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.Compression = "gzip"
+				return cfg
+			}(),
+		},
+		{
+			Name:      "events_output",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.EventsOutput = helper.OutputIDs{"file_events"}
+				return cfg
+			}(),
+		},
+		{
+			Name:      "read_rate",
+			ExpectErr: false,
+			Expect: func() *InputConfig {
+				cfg := defaultCfg()
+				cfg.Include = append(cfg.Include, "/var/log/critical/*.log", "/var/log/debug/*.log")
+				cfg.MaxFileReadRate = helper.ByteSize(1024 * 1024)
+				cfg.MaxReadRate = helper.ByteSize(10 * 1024 * 1024)
+				cfg.PrioritizeInclude = true
+				return cfg
+			}(),
+		},
 	}
 
 	for _, tc := range cases {
@@ -637,6 +707,196 @@ func TestBuild(t *testing.T) {
 			require.Error,
 			nil,
 		},
+		{
+			"CompressionDefault",
+			func(f *InputConfig) {
+				f.Compression = ""
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, "auto", f.compression)
+			},
+		},
+		{
+			"CompressionZstd",
+			func(f *InputConfig) {
+				f.Compression = "zstd"
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, "zstd", f.compression)
+			},
+		},
+		{
+			"InvalidCompression",
+			func(f *InputConfig) {
+				f.Compression = "lz4"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"BatchMode",
+			func(f *InputConfig) {
+				f.Mode = "batch"
+				f.OnComplete = "move"
+				f.MoveTo = "/var/log/done"
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.True(t, f.batch)
+				require.True(t, f.startAtBeginning)
+				require.Equal(t, "move", f.onComplete)
+				require.Equal(t, "/var/log/done", f.moveTo)
+			},
+		},
+		{
+			"NotifyDiscovery",
+			func(f *InputConfig) {
+				f.Discovery = "notify"
+				f.FallbackPollInterval = helper.Duration{Duration: time.Minute}
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, "notify", f.discovery)
+				require.Equal(t, time.Minute, f.fallbackPollInterval)
+			},
+		},
+		{
+			"InvalidDiscovery",
+			func(f *InputConfig) {
+				f.Discovery = "inotify"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NegativeFallbackPollInterval",
+			func(f *InputConfig) {
+				f.FallbackPollInterval = helper.Duration{Duration: -time.Second}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PathRegex",
+			func(f *InputConfig) {
+				f.PathRegex = `^/var/log/(?P<app>[^/]+)/`
+				f.PathRegexTarget = "resource"
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, `^/var/log/(?P<app>[^/]+)/`, f.pathRegex.String())
+				require.Equal(t, "resource", f.pathRegexTarget)
+			},
+		},
+		{
+			"InvalidPathRegex",
+			func(f *InputConfig) {
+				f.PathRegex = `(?P<app>`
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PathRegexWithoutNamedGroups",
+			func(f *InputConfig) {
+				f.PathRegex = `^/var/log/([^/]+)/`
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"InvalidPathRegexTarget",
+			func(f *InputConfig) {
+				f.PathRegex = `^/var/log/(?P<app>[^/]+)/`
+				f.PathRegexTarget = "record"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"HeaderW3C",
+			func(f *InputConfig) {
+				f.Header = HeaderConfig{Format: "w3c"}
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, "w3c", f.headerFormat)
+				require.Equal(t, "file_header", f.headerLabel)
+			},
+		},
+		{
+			"InvalidHeaderFormat",
+			func(f *InputConfig) {
+				f.Header = HeaderConfig{Format: "tsv"}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"InvalidMode",
+			func(f *InputConfig) {
+				f.Mode = "once"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"InvalidOnComplete",
+			func(f *InputConfig) {
+				f.Mode = "batch"
+				f.OnComplete = "archive"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"OnCompleteWithoutBatchMode",
+			func(f *InputConfig) {
+				f.OnComplete = "delete"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MoveWithoutMoveTo",
+			func(f *InputConfig) {
+				f.Mode = "batch"
+				f.OnComplete = "move"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"ReadRates",
+			func(f *InputConfig) {
+				f.MaxFileReadRate = 1024
+				f.MaxReadRate = 4096
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, int64(1024), f.maxFileReadRate)
+				require.Equal(t, float64(4096), f.readBucket.rate)
+			},
+		},
+		{
+			"NegativeReadRate",
+			func(f *InputConfig) {
+				f.MaxReadRate = -1
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MoveToWithoutMove",
+			func(f *InputConfig) {
+				f.Mode = "batch"
+				f.MoveTo = "/var/log/done"
+			},
+			require.Error,
+			nil,
+		},
 	}
 
 	for _, tc := range cases {
@@ -0,0 +1,125 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+
+	"github.com/observiq/stanza/operator"
+	"go.uber.org/zap"
+)
+
+// Types of file lifecycle events
+const (
+	eventDiscovered = "discovered"
+	eventRotated    = "rotated"
+	eventTruncated  = "truncated"
+	eventDeleted    = "deleted"
+	eventSkipped    = "skipped"
+)
+
+// Outputs returns the outputs of the operator, including the event outputs
+func (f *InputOperator) Outputs() []operator.Operator {
+	outputs := make([]operator.Operator, 0, len(f.OutputOperators)+len(f.eventOutputs))
+	outputs = append(outputs, f.OutputOperators...)
+	return append(outputs, f.eventOutputs...)
+}
+
+// SetOutputs sets the outputs of the operator, and the outputs of lifecycle events
+func (f *InputOperator) SetOutputs(operators []operator.Operator) error {
+	if err := f.InputOperator.SetOutputs(operators); err != nil {
+		return err
+	}
+
+	eventOutputs := make([]operator.Operator, 0, len(f.eventOutputIDs))
+	for _, operatorID := range f.eventOutputIDs {
+		output, ok := findOperator(operators, operatorID)
+		if !ok {
+			return fmt.Errorf("events output '%s' does not exist", operatorID)
+		}
+		if !output.CanProcess() {
+			return fmt.Errorf("events output '%s' can not process entries", operatorID)
+		}
+		eventOutputs = append(eventOutputs, output)
+	}
+
+	f.eventOutputs = eventOutputs
+	return nil
+}
+
+func findOperator(operators []operator.Operator, operatorID string) (operator.Operator, bool) {
+	for _, op := range operators {
+		if op.ID() == operatorID {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// emitEvent sends a lifecycle event of a file to the event outputs
+func (f *Reader) emitEvent(ctx context.Context, eventType string, fields map[string]interface{}) {
+	if len(f.fileInput.eventOutputs) == 0 {
+		return
+	}
+
+	record := map[string]interface{}{
+		"event":       eventType,
+		"file_path":   f.fileLabels.Path,
+		"fingerprint": f.Fingerprint.hash(),
+		"offset":      f.Offset,
+	}
+	for key, value := range fields {
+		record[key] = value
+	}
+
+	e, err := f.fileInput.NewEntry(record)
+	if err != nil {
+		f.Errorw("Failed to create event entry", zap.Error(err))
+		return
+	}
+	e.AddLabel("file_event", eventType)
+
+	if err := f.setFileFields(e); err != nil {
+		f.Errorw("Failed to set file fields on event entry", zap.Error(err))
+		return
+	}
+
+	outputs := f.fileInput.eventOutputs
+	for i, output := range outputs {
+		if i == len(outputs)-1 {
+			_ = output.Process(ctx, e)
+			return
+		}
+		_ = output.Process(ctx, e.Copy())
+	}
+}
+
+// emitLostEvent sends the event of a file that was read in the last poll, but
+// was not found by this poll. The file was deleted if its path no longer
+// exists, or rotated if another file now has its path. Otherwise, it is the same
+// file, such as when it was truncated, which is found when it is read.
+func (f *Reader) emitLostEvent(ctx context.Context) {
+	if len(f.fileInput.eventOutputs) == 0 {
+		return
+	}
+
+	info, err := os.Stat(f.fileLabels.Path)
+	if os.IsNotExist(err) {
+		f.emitEvent(ctx, eventDeleted, nil)
+		return
+	} else if err != nil {
+		return
+	}
+
+	if current, err := f.file.Stat(); err == nil && !os.SameFile(current, info) {
+		f.emitEvent(ctx, eventRotated, nil)
+	}
+}
+
+// hash returns a short hash that identifies the fingerprint
+func (f Fingerprint) hash() string {
+	h := fnv.New64a()
+	_, _ = h.Write(f.FirstBytes)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
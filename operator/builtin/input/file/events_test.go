@@ -0,0 +1,160 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForEvent(t *testing.T, c chan *entry.Entry, eventType, path string) map[string]interface{} {
+	select {
+	case e := <-c:
+		require.Equal(t, eventType, e.Labels["file_event"])
+		record, ok := e.Record.(map[string]interface{})
+		require.True(t, ok, "expected an event entry, got %v", e.Record)
+		require.Equal(t, eventType, record["event"])
+		require.Equal(t, path, record["file_path"])
+		require.NotEmpty(t, record["fingerprint"])
+		return record
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for event", eventType)
+		return nil
+	}
+}
+
+func TestEventsSetOutputs(t *testing.T) {
+	t.Parallel()
+	cfg := newDefaultConfig(testutil.NewTempDir(t))
+	cfg.EventsOutput = []string{"events"}
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*InputOperator)
+
+	output := testutil.NewMockOperator("$.fake")
+	events := testutil.NewMockOperator("$.events")
+	require.NoError(t, op.SetOutputs([]operator.Operator{output, events}))
+	require.Equal(t, []operator.Operator{output, events}, op.Outputs())
+
+	err = op.SetOutputs([]operator.Operator{output})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "events output '$.events' does not exist")
+}
+
+func TestEventsDiscoveredAndDeleted(t *testing.T) {
+	t.Parallel()
+	op, logReceived, tempDir := newTestFileOperator(t, nil, nil)
+	events := testutil.NewFakeOutput(t)
+	op.eventOutputs = []operator.Operator{events}
+
+	path := filepath.Join(tempDir, "app.log")
+	temp := openFile(t, path)
+	writeString(t, temp, "testlog1\n")
+
+	op.poll(context.Background())
+	record := waitForEvent(t, events.Received, eventDiscovered, path)
+	require.Equal(t, int64(0), record["offset"])
+	waitForMessage(t, logReceived, "testlog1")
+
+	// Events are only emitted when the file changes
+	op.poll(context.Background())
+	expectNoMessages(t, events.Received)
+
+	require.NoError(t, temp.Close())
+	require.NoError(t, os.Remove(path))
+	op.poll(context.Background())
+	record = waitForEvent(t, events.Received, eventDeleted, path)
+	require.Equal(t, int64(9), record["offset"])
+	expectNoMessages(t, events.Received)
+}
+
+func TestEventsRotated(t *testing.T) {
+	t.Parallel()
+	op, logReceived, tempDir := newTestFileOperator(t, nil, nil)
+	events := testutil.NewFakeOutput(t)
+	op.eventOutputs = []operator.Operator{events}
+
+	path := filepath.Join(tempDir, "app.log")
+	temp := openFile(t, path)
+	writeString(t, temp, "testlog1\n")
+	op.poll(context.Background())
+	waitForEvent(t, events.Received, eventDiscovered, path)
+	waitForMessage(t, logReceived, "testlog1")
+
+	// The rotated file is found at its new path
+	rotatedPath := filepath.Join(tempDir, "app.log.1")
+	require.NoError(t, os.Rename(path, rotatedPath))
+	op.poll(context.Background())
+	record := waitForEvent(t, events.Received, eventRotated, rotatedPath)
+	require.Equal(t, path, record["previous_path"])
+	expectNoMessages(t, events.Received)
+}
+
+func TestEventsTruncated(t *testing.T) {
+	t.Parallel()
+	op, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.FingerprintSize = minFingerprintSize
+	}, nil)
+	events := testutil.NewFakeOutput(t)
+	op.eventOutputs = []operator.Operator{events}
+
+	// The fingerprint is unchanged, since the first bytes are rewritten
+	path := filepath.Join(tempDir, "app.log")
+	temp := openFile(t, path)
+	header := "header-padding-to-fill-the-fingerprint\n"
+	writeString(t, temp, header+"testlog1\ntestlog2\n")
+	op.poll(context.Background())
+	waitForEvent(t, events.Received, eventDiscovered, path)
+	waitForMessage(t, logReceived, header[:len(header)-1])
+	waitForMessage(t, logReceived, "testlog1")
+	waitForMessage(t, logReceived, "testlog2")
+
+	require.NoError(t, temp.Truncate(0))
+	_, err := temp.Seek(0, 0)
+	require.NoError(t, err)
+	writeString(t, temp, header)
+	op.poll(context.Background())
+	record := waitForEvent(t, events.Received, eventTruncated, path)
+	require.Equal(t, int64(len(header)+18), record["offset"])
+	require.Equal(t, int64(len(header)), record["size"])
+
+	// The event is emitted once, and reading continues from the offset
+	writeString(t, temp, "testlog3\ntestlog4\n")
+	op.poll(context.Background())
+	expectNoMessages(t, events.Received)
+	expectNoMessages(t, logReceived)
+
+	writeString(t, temp, "testlog5\n")
+	op.poll(context.Background())
+	waitForMessage(t, logReceived, "testlog5")
+	expectNoMessages(t, logReceived)
+}
+
+func TestEventsSkipped(t *testing.T) {
+	t.Parallel()
+	op, _, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.StartAt = "beginning"
+		cfg.MaxLogSize = 1024
+	}, nil)
+	events := testutil.NewFakeOutput(t)
+	op.eventOutputs = []operator.Operator{events}
+
+	// The scanner buffer is at least 16KiB
+	path := filepath.Join(tempDir, "app.log")
+	writeString(t, openFile(t, path), strings.Repeat("a", 32*1024)+"\n")
+	op.poll(context.Background())
+	waitForEvent(t, events.Received, eventDiscovered, path)
+	record := waitForEvent(t, events.Received, eventSkipped, path)
+	require.Equal(t, "max_log_size", record["reason"])
+
+	// The event is emitted once for each entry
+	op.poll(context.Background())
+	expectNoMessages(t, events.Received)
+}
@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sync"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v2"
 	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
 	"github.com/observiq/stanza/operator/helper"
 	"go.uber.org/zap"
 )
@@ -46,6 +48,32 @@ type InputOperator struct {
 
 	encoding helper.Encoding
 
+	compression       string
+	flushingSplitFunc bufio.SplitFunc
+	forceFlushPeriod  time.Duration
+
+	batch          bool
+	onComplete     string
+	moveTo         string
+	completedFiles map[string]*Fingerprint
+
+	discovery            string
+	fallbackPollInterval time.Duration
+
+	pathRegex       *regexp.Regexp
+	pathRegexTarget string
+
+	headerFormat string
+	headerLabel  string
+
+	maxFileReadRate   int64
+	readBucket        *bucket
+	readShare         int
+	prioritizeInclude bool
+
+	eventOutputIDs helper.OutputIDs
+	eventOutputs   []operator.Operator
+
 	wg         sync.WaitGroup
 	readerWg   sync.WaitGroup
 	firstCheck bool
@@ -63,6 +91,19 @@ func (f *InputOperator) Start() error {
 		return fmt.Errorf("read known files from database: %s", err)
 	}
 
+	if err := f.loadCompletedFiles(); err != nil {
+		return fmt.Errorf("read completed files from database: %s", err)
+	}
+
+	if f.discovery == discoveryNotify {
+		n, err := newNotifier(f.Include, f.Exclude, f.SugaredLogger)
+		if err == nil {
+			f.startNotifyPoller(ctx, n)
+			return nil
+		}
+		f.Warnw("Failed to create file watcher, falling back to polling", zap.Error(err))
+	}
+
 	// Start polling goroutine
 	f.startPoller(ctx)
 
@@ -122,6 +163,9 @@ func (f *InputOperator) poll(ctx context.Context) {
 
 		// Get the list of paths on disk
 		matches = getMatches(f.Include, f.Exclude)
+		if f.batch {
+			f.pruneCompleted()
+		}
 		if f.firstCheck && len(matches) == 0 {
 			f.Warnw("no files match the configured include patterns", "include", f.Include)
 		} else if len(matches) > f.maxBatchFiles {
@@ -129,8 +173,11 @@ func (f *InputOperator) poll(ctx context.Context) {
 		}
 	}
 
-	readers := f.makeReaders(matches)
+	readers := f.makeReaders(ctx, matches)
 	f.firstCheck = false
+	if f.batch {
+		readers = f.skipCompleted(readers)
+	}
 
 	// Detect files that have been rotated out of matching pattern
 	lostReaders := make([]*Reader, 0, len(f.lastPollReaders))
@@ -142,17 +189,50 @@ OUTER:
 			}
 		}
 		lostReaders = append(lostReaders, oldReader)
+		oldReader.emitLostEvent(ctx)
 	}
 
-	var wg sync.WaitGroup
-	for _, reader := range lostReaders {
-		wg.Add(1)
-		go func(r *Reader) {
-			defer wg.Done()
-			r.ReadToEnd(ctx)
-		}(reader)
+	// Groups of readers are read in order of priority, so that higher priority
+	// files use the global read rate limit first
+	for _, group := range f.priorityGroups(append(lostReaders, readers...)) {
+		f.readAll(ctx, group)
+	}
+
+	// Close all files
+	for _, reader := range f.lastPollReaders {
+		reader.Close()
+	}
+
+	f.lastPollReaders = readers
+	if f.batch {
+		f.lastPollReaders = f.completeFiles(ctx, readers)
+	}
+
+	f.saveCurrent(readers)
+	f.syncLastPollFiles()
+}
+
+// readAll reads the readers to the end concurrently
+func (f *InputOperator) readAll(ctx context.Context, readers []*Reader) {
+	if len(readers) == 0 {
+		return
 	}
 
+	// Each file with new data may take an equal share of the global read rate limit
+	if f.readBucket != nil {
+		active := 0
+		for _, reader := range readers {
+			if reader.hasNewData() {
+				active++
+			}
+		}
+		if active == 0 {
+			active = 1
+		}
+		f.readShare = int(f.readBucket.rate)/active + 1
+	}
+
+	var wg sync.WaitGroup
 	for _, reader := range readers {
 		wg.Add(1)
 		go func(r *Reader) {
@@ -163,16 +243,6 @@ OUTER:
 
 	// Wait until all the reader goroutines are finished
 	wg.Wait()
-
-	// Close all files
-	for _, reader := range f.lastPollReaders {
-		reader.Close()
-	}
-
-	f.lastPollReaders = readers
-
-	f.saveCurrent(readers)
-	f.syncLastPollFiles()
 }
 
 // getMatches gets a list of paths given an array of glob patterns to include and exclude
@@ -204,7 +274,7 @@ func getMatches(includes, excludes []string) []string {
 // makeReaders takes a list of paths, then creates readers from each of those paths,
 // discarding any that have a duplicate fingerprint to other files that have already
 // been read this polling interval
-func (f *InputOperator) makeReaders(filePaths []string) []*Reader {
+func (f *InputOperator) makeReaders(ctx context.Context, filePaths []string) []*Reader {
 	// Open the files first to minimize the time between listing and opening
 	files := make([]*os.File, 0, len(filePaths))
 	for _, path := range filePaths {
@@ -262,7 +332,7 @@ OUTER:
 
 	readers := make([]*Reader, 0, len(fps))
 	for i := 0; i < len(fps); i++ {
-		reader, err := f.newReader(files[i], fps[i], f.firstCheck)
+		reader, err := f.newReader(ctx, files[i], fps[i], f.firstCheck)
 		if err != nil {
 			f.Errorw("Failed to create reader", zap.Error(err))
 			continue
@@ -294,7 +364,7 @@ func (f *InputOperator) saveCurrent(readers []*Reader) {
 	}
 }
 
-func (f *InputOperator) newReader(file *os.File, fp *Fingerprint, firstCheck bool) (*Reader, error) {
+func (f *InputOperator) newReader(ctx context.Context, file *os.File, fp *Fingerprint, firstCheck bool) (*Reader, error) {
 	// Check if the new path has the same fingerprint as an old path
 	if oldReader, ok := f.findFingerprintMatch(fp); ok {
 		newReader, err := oldReader.Copy(file)
@@ -302,6 +372,9 @@ func (f *InputOperator) newReader(file *os.File, fp *Fingerprint, firstCheck boo
 			return nil, err
 		}
 		newReader.fileLabels = f.resolveFileLabels(file.Name())
+		if newReader.fileLabels.Path != oldReader.fileLabels.Path {
+			newReader.emitEvent(ctx, eventRotated, map[string]interface{}{"previous_path": oldReader.fileLabels.Path})
+		}
 		return newReader, nil
 	}
 
@@ -314,6 +387,7 @@ func (f *InputOperator) newReader(file *os.File, fp *Fingerprint, firstCheck boo
 	if err := newReader.InitializeOffset(startAtBeginning); err != nil {
 		return nil, fmt.Errorf("initialize offset: %s", err)
 	}
+	newReader.emitEvent(ctx, eventDiscovered, nil)
 	return newReader, nil
 }
 
@@ -349,6 +423,16 @@ func (f *InputOperator) syncLastPollFiles() {
 	}
 
 	f.persist.Set(knownFilesKey, buf.Bytes())
+
+	if f.batch {
+		completed, err := f.encodeCompletedFiles()
+		if err != nil {
+			f.Errorw("Failed to encode completed files", zap.Error(err))
+		} else {
+			f.persist.Set(completedFilesKey, completed)
+		}
+	}
+
 	if err := f.persist.Sync(); err != nil {
 		f.Errorw("Failed to sync to database", zap.Error(err))
 	}
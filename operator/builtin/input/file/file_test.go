@@ -97,6 +97,70 @@ func TestAddFileResolvedFields(t *testing.T) {
 	os.RemoveAll(dir)
 }
 
+// PathRegex tests that the named capture groups of path_regex are
+// matched against the resolved path and added to every entry
+func TestPathRegex(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		target   string
+		expected func(*entry.Entry) map[string]string
+	}{
+		{pathRegexTargetLabels, func(e *entry.Entry) map[string]string { return e.Labels }},
+		{pathRegexTargetResource, func(e *entry.Entry) map[string]string { return e.Resource }},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.target, func(t *testing.T) {
+			t.Parallel()
+			operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+				cfg.PathRegex = `/pods/(?P<namespace>[^_]+)_(?P<pod>[^_]+)_[^/]+/(?P<container>[^/]+)/`
+				cfg.PathRegexTarget = tc.target
+			}, nil)
+
+			// Create a pod log file outside of the monitored directory
+			podDir := filepath.Join(testutil.NewTempDir(t), "pods", "default_web-1_1234", "nginx")
+			require.NoError(t, os.MkdirAll(podDir, 0755))
+			file := openFile(t, filepath.Join(podDir, "0.log"))
+			writeString(t, file, "testlog1\ntestlog2\n")
+
+			// Create symbolic link in monitored directory
+			require.NoError(t, os.Symlink(file.Name(), filepath.Join(tempDir, "web-1.log")))
+
+			require.NoError(t, operator.Start())
+			defer operator.Stop()
+
+			for _, message := range []string{"testlog1", "testlog2"} {
+				e := waitForOne(t, logReceived)
+				require.Equal(t, message, e.Record)
+				captured := tc.expected(e)
+				require.Equal(t, "default", captured["namespace"])
+				require.Equal(t, "web-1", captured["pod"])
+				require.Equal(t, "nginx", captured["container"])
+			}
+		})
+	}
+}
+
+// PathRegexNoMatch tests that files which do not match path_regex are still read
+func TestPathRegexNoMatch(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.PathRegex = `/pods/(?P<pod>[^/]+)/`
+	}, nil)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	e := waitForOne(t, logReceived)
+	require.Equal(t, "testlog1", e.Record)
+	require.NotContains(t, e.Labels, "pod")
+}
+
 // AddFileResolvedFields tests that the `file.name.resolved` and `file.path.resolved` fields are included
 // when IncludeFileNameResolved and IncludeFilePathResolved are set to true and underlaying symlink change
 // Scenario:
@@ -291,6 +355,43 @@ func TestNoNewline(t *testing.T) {
 	waitForMessage(t, logReceived, "testlog2")
 }
 
+// ForceFlush tests that an incomplete multiline entry at the end
+// of a file is flushed once the force flush period has elapsed
+func TestForceFlush(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Multiline.LineStartPattern = "^START"
+		cfg.Multiline.ForceFlushPeriod = helper.Duration{Duration: 100 * time.Millisecond}
+	}, nil)
+	defer operator.Stop()
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "START 1\nline\nSTART 2\nline\n")
+
+	operator.poll(context.Background())
+	e := waitForEntry(t, logReceived)
+	require.Equal(t, "START 1\nline\n", e.Record)
+	require.NotContains(t, e.Labels, helper.ForceFlushedLabel)
+
+	// The entry is not flushed until the period has elapsed without new data
+	operator.poll(context.Background())
+	expectNoMessages(t, logReceived)
+
+	time.Sleep(150 * time.Millisecond)
+	operator.poll(context.Background())
+	e = waitForEntry(t, logReceived)
+	require.Equal(t, "START 2\nline\n", e.Record)
+	require.Equal(t, "true", e.Labels[helper.ForceFlushedLabel])
+
+	// Flushed data is not read again
+	writeString(t, temp, "START 3\n")
+	operator.poll(context.Background())
+	expectNoMessages(t, logReceived)
+	writeString(t, temp, "START 4\n")
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "START 3\n")
+}
+
 // SkipEmpty tests that the any empty lines are skipped
 func TestSkipEmpty(t *testing.T) {
 	t.Parallel()
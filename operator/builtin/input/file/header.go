@@ -0,0 +1,94 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Formats of file headers
+const (
+	headerFormatCSV = "csv"
+	headerFormatW3C = "w3c"
+)
+
+const (
+	defaultHeaderLabel = "file_header"
+	w3cFieldsDirective = "#Fields:"
+)
+
+// HeaderConfig is the configuration of file headers
+type HeaderConfig struct {
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	Label  string `json:"label,omitempty"  yaml:"label,omitempty"`
+}
+
+// validate checks the header config and sets its defaults
+func (c *HeaderConfig) validate() error {
+	switch c.Format {
+	case "":
+		return nil
+	case headerFormatCSV, headerFormatW3C:
+	default:
+		return fmt.Errorf("invalid header format '%s', must be '%s' or '%s'", c.Format, headerFormatCSV, headerFormatW3C)
+	}
+
+	if c.Label == "" {
+		c.Label = defaultHeaderLabel
+	}
+	return nil
+}
+
+// updateHeader updates the header of the file if msg is a header line,
+// and returns whether msg should be skipped rather than emitted
+func (f *Reader) updateHeader(msg string) bool {
+	switch f.fileInput.headerFormat {
+	case headerFormatCSV:
+		// The first line of the file is the header
+		if f.Header == "" {
+			f.Header = msg
+			return true
+		}
+	case headerFormatW3C:
+		// Directives start with '#', and the fields may change mid-file
+		if strings.HasPrefix(msg, "#") {
+			if strings.HasPrefix(msg, w3cFieldsDirective) {
+				f.Header = strings.TrimSpace(strings.TrimPrefix(msg, w3cFieldsDirective))
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// readHeader reads the header of a file from its beginning up to the offset,
+// for files that are not read from the beginning
+func (f *Reader) readHeader() {
+	if _, err := f.file.Seek(0, 0); err != nil {
+		f.Errorw("Failed to seek", zap.Error(err))
+		return
+	}
+
+	scanner := NewPositionalScanner(f.file, f.fileInput.MaxLogSize, 0, f.fileInput.SplitFunc)
+	for scanner.Pos() < f.Offset && scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		msg, err := f.decode(scanner.Bytes())
+		if err != nil {
+			f.Errorw("Failed to decode header", zap.Error(err))
+			return
+		}
+
+		f.updateHeader(msg)
+		if f.fileInput.headerFormat == headerFormatCSV && f.Header != "" {
+			return
+		}
+	}
+
+	if err := getScannerError(scanner); err != nil {
+		f.Errorw("Failed to read header", zap.Error(err))
+	}
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/stretchr/testify/require"
+)
+
+func expectHeader(t *testing.T, c chan *entry.Entry, record, header string) {
+	e := waitForOne(t, c)
+	require.Equal(t, record, e.Record)
+	require.Equal(t, header, e.Labels["file_header"])
+}
+
+func TestHeaderCSV(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Header.Format = headerFormatCSV
+	}, nil)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "name,sev,msg\nstanza,INFO,started\nstanza,DEBUG,polling\n")
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	// The header line is not emitted
+	expectHeader(t, logReceived, "stanza,INFO,started", "name,sev,msg")
+	expectHeader(t, logReceived, "stanza,DEBUG,polling", "name,sev,msg")
+	expectNoMessages(t, logReceived)
+}
+
+func TestHeaderW3C(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Header.Format = headerFormatW3C
+		cfg.Header.Label = "w3c_fields"
+	}, nil)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "#Software: Microsoft Internet Information Services 10.0\r\n#Version: 1.0\r\n#Fields: date time cs-method\r\n2021-07-01 12:00:00 GET\r\n")
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	e := waitForOne(t, logReceived)
+	require.Equal(t, "2021-07-01 12:00:00 GET", e.Record)
+	require.Equal(t, "date time cs-method", e.Labels["w3c_fields"])
+	require.NotContains(t, e.Labels, "file_header")
+
+	// The fields may change mid-file
+	writeString(t, temp, "#Date: 2021-07-01 13:00:00\r\n#Fields: date time sc-status\r\n2021-07-01 13:00:01 200\r\n")
+	e = waitForOne(t, logReceived)
+	require.Equal(t, "2021-07-01 13:00:01 200", e.Record)
+	require.Equal(t, "date time sc-status", e.Labels["w3c_fields"])
+	expectNoMessages(t, logReceived)
+}
+
+// HeaderAfterRestart tests that the header is persisted with the offset
+func TestHeaderAfterRestart(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Header.Format = headerFormatCSV
+	}, nil)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "name,sev\nstanza,INFO\n")
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+	expectHeader(t, logReceived, "stanza,INFO", "name,sev")
+
+	require.NoError(t, operator.Stop())
+	require.NoError(t, operator.Start())
+
+	writeString(t, temp, "stanza,DEBUG\n")
+	expectHeader(t, logReceived, "stanza,DEBUG", "name,sev")
+}
+
+// HeaderStartAtEnd tests that the header is read from the beginning of
+// files that are otherwise read from the end
+func TestHeaderStartAtEnd(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		format   string
+		existing string
+		expected string
+	}{
+		{headerFormatCSV, "name,sev\nstanza,INFO\n", "name,sev"},
+		{headerFormatW3C, "#Fields: date cs-method\n2021-07-01 GET\n#Fields: date sc-status\n2021-07-01 200\n", "date sc-status"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.format, func(t *testing.T) {
+			t.Parallel()
+			operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+				cfg.StartAt = "end"
+				cfg.Header.Format = tc.format
+			}, nil)
+
+			defer operator.Stop()
+
+			temp := openTemp(t, tempDir)
+			writeString(t, temp, tc.existing)
+
+			// Expect no entries on the first poll
+			operator.poll(context.Background())
+			expectNoMessages(t, logReceived)
+
+			writeString(t, temp, "new entry\n")
+			operator.poll(context.Background())
+			expectHeader(t, logReceived, "new entry", tc.expected)
+		})
+	}
+}
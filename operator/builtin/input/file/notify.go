@@ -0,0 +1,205 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// Methods of discovering changes to files
+const (
+	discoveryPoll   = "poll"
+	discoveryNotify = "notify"
+)
+
+const defaultFallbackPollInterval = 10 * time.Second
+
+// notifier watches the directories implied by the include patterns
+// for filesystem events
+type notifier struct {
+	*fsnotify.Watcher
+	include []string
+	exclude []string
+
+	// dirPatterns are the glob patterns of every directory
+	// that may contain a matching file
+	dirPatterns []string
+	watched     map[string]struct{}
+	logger      *zap.SugaredLogger
+}
+
+func newNotifier(include, exclude []string, logger *zap.SugaredLogger) (*notifier, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &notifier{
+		Watcher:     watcher,
+		include:     include,
+		exclude:     exclude,
+		dirPatterns: dirPatterns(include),
+		watched:     make(map[string]struct{}),
+		logger:      logger,
+	}
+	n.refresh()
+	return n, nil
+}
+
+// dirPatterns returns the glob patterns of the directories that must be
+// watched to see every file matching the include patterns. This includes the
+// parents of any directory with a glob pattern, so that new directories are seen.
+func dirPatterns(include []string) []string {
+	patterns := make([]string, 0, len(include))
+	seen := make(map[string]struct{})
+	add := func(pattern string) {
+		if _, ok := seen[pattern]; !ok {
+			seen[pattern] = struct{}{}
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	for _, pattern := range include {
+		dir := filepath.Dir(filepath.Clean(pattern))
+		parents := []string{dir}
+		for hasMeta(dir) {
+			dir = filepath.Dir(dir)
+			parents = append(parents, dir)
+		}
+
+		for i := len(parents) - 1; i >= 0; i-- {
+			add(parents[i])
+		}
+	}
+	return patterns
+}
+
+// hasMeta returns true if a path contains glob pattern characters
+func hasMeta(path string) bool {
+	magicChars := `*?[`
+	if runtime.GOOS != "windows" {
+		magicChars = `*?[\`
+	}
+	return strings.ContainsAny(path, magicChars)
+}
+
+// refresh watches every directory that currently matches a directory pattern
+func (n *notifier) refresh() {
+	for _, pattern := range n.dirPatterns {
+		dirs, _ := filepath.Glob(pattern) // compile error checked in build
+		for _, dir := range dirs {
+			if _, ok := n.watched[dir]; ok {
+				continue
+			}
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				continue
+			}
+			if err := n.Add(dir); err != nil {
+				n.logger.Warnw("Failed to watch directory, changes will be found by the fallback poll", zap.Error(err), "directory", dir)
+				continue
+			}
+			n.watched[dir] = struct{}{}
+		}
+	}
+
+	// Directories that were removed are no longer watched
+	for dir := range n.watched {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			_ = n.Remove(dir)
+			delete(n.watched, dir)
+		}
+	}
+}
+
+// relevant returns whether an event may change the files that are read, and
+// whether the watched directories must be refreshed because of the event
+func (n *notifier) relevant(event fsnotify.Event) (poll bool, refresh bool) {
+	if event.Op&(fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+		for _, pattern := range n.dirPatterns {
+			if ok, _ := filepath.Match(pattern, event.Name); ok {
+				return true, true
+			}
+		}
+	}
+
+	for _, pattern := range n.include {
+		if ok, _ := filepath.Match(pattern, event.Name); !ok {
+			continue
+		}
+		for _, exclude := range n.exclude {
+			if ok, _ := doublestar.PathMatch(exclude, event.Name); ok {
+				return false, false
+			}
+		}
+		return true, false
+	}
+	return false, false
+}
+
+// startNotifyPoller kicks off a goroutine that polls the filesystem when
+// files are created, written or renamed. Polls are at least poll_interval
+// apart, and a fallback poll is made periodically in case events are missed.
+func (f *InputOperator) startNotifyPoller(ctx context.Context, n *notifier) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer n.Close()
+
+		fallbackTicker := time.NewTicker(f.fallbackPollInterval)
+		defer fallbackTicker.Stop()
+
+		// Poll once at startup to find the existing files
+		pending, refresh := true, false
+		var lastPoll time.Time
+		var wait <-chan time.Time
+		for {
+			if pending && wait == nil {
+				if remaining := f.PollInterval - time.Since(lastPoll); remaining > 0 {
+					wait = time.After(remaining)
+				} else {
+					if refresh {
+						n.refresh()
+						refresh = false
+					}
+					f.poll(ctx)
+					pending, lastPoll = false, time.Now()
+					// Matches beyond max_concurrent_files are queued for the next poll,
+					// which must not wait for another event
+					if len(f.queuedMatches) > 0 {
+						pending, wait = true, time.After(f.PollInterval)
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-n.Events:
+				if !ok {
+					return
+				}
+				poll, refreshDirs := n.relevant(event)
+				pending = pending || poll
+				refresh = refresh || refreshDirs
+			case err, ok := <-n.Errors:
+				if !ok {
+					return
+				}
+				// Events may have been dropped, such as when the event queue overflows
+				f.Warnw("File watcher error", zap.Error(err))
+				pending, refresh = true, true
+			case <-wait:
+				wait = nil
+			case <-fallbackTicker.C:
+				pending, refresh = true, true
+			}
+		}
+	}()
+}
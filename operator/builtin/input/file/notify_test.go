@@ -0,0 +1,143 @@
+// +build linux
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDirPatterns(t *testing.T) {
+	cases := []struct {
+		name     string
+		include  []string
+		expected []string
+	}{
+		{"Static", []string{"/var/log/*.log"}, []string{"/var/log"}},
+		{"Nested", []string{"/var/log/pods/*/*/0.log"}, []string{"/var/log/pods", "/var/log/pods/*", "/var/log/pods/*/*"}},
+		{"Duplicates", []string{"/var/log/*.log", "/var/log/*.txt"}, []string{"/var/log"}},
+		{"Relative", []string{"logs/*.log"}, []string{"logs"}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, dirPatterns(tc.include))
+		})
+	}
+}
+
+func TestNotifierRelevant(t *testing.T) {
+	n := &notifier{
+		include:     []string{"/var/log/pods/*/*.log"},
+		exclude:     []string{"/var/log/pods/*/debug.log"},
+		dirPatterns: dirPatterns([]string{"/var/log/pods/*/*.log"}),
+	}
+
+	cases := []struct {
+		name    string
+		event   fsnotify.Event
+		poll    bool
+		refresh bool
+	}{
+		{"Write", fsnotify.Event{Name: "/var/log/pods/a/app.log", Op: fsnotify.Write}, true, false},
+		{"Create", fsnotify.Event{Name: "/var/log/pods/a/app.log", Op: fsnotify.Create}, true, false},
+		{"Rename", fsnotify.Event{Name: "/var/log/pods/a/app.log", Op: fsnotify.Rename}, true, false},
+		{"Excluded", fsnotify.Event{Name: "/var/log/pods/a/debug.log", Op: fsnotify.Write}, false, false},
+		{"Unmatched", fsnotify.Event{Name: "/var/log/pods/a/app.txt", Op: fsnotify.Write}, false, false},
+		{"NewDirectory", fsnotify.Event{Name: "/var/log/pods/b", Op: fsnotify.Create}, true, true},
+		{"RemovedDirectory", fsnotify.Event{Name: "/var/log/pods/b", Op: fsnotify.Remove}, true, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			poll, refresh := n.relevant(tc.event)
+			require.Equal(t, tc.poll, poll)
+			require.Equal(t, tc.refresh, refresh)
+		})
+	}
+}
+
+func TestNotifyDiscovery(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Include = []string{filepath.Join(filepath.Dir(cfg.Include[0]), "*", "*.log")}
+		cfg.Discovery = discoveryNotify
+		cfg.PollInterval = helper.Duration{Duration: 10 * time.Millisecond}
+		cfg.FallbackPollInterval = helper.Duration{Duration: time.Hour}
+	}, nil)
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	// Files in a new directory are found without waiting for the fallback poll
+	dir := filepath.Join(tempDir, "app")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	time.Sleep(100 * time.Millisecond)
+
+	temp := openFile(t, filepath.Join(dir, "app.log"))
+	writeString(t, temp, "testlog1\n")
+	waitForMessage(t, logReceived, "testlog1")
+
+	writeString(t, temp, "testlog2\n")
+	waitForMessage(t, logReceived, "testlog2")
+
+	// Rotated files are still read to the end
+	require.NoError(t, os.Rename(temp.Name(), filepath.Join(dir, "app.log.1")))
+	writeString(t, temp, "testlog3\n")
+	temp2 := openFile(t, filepath.Join(dir, "app.log"))
+	writeString(t, temp2, "testlog4\n")
+	waitForMessages(t, logReceived, []string{"testlog3", "testlog4"})
+}
+
+func TestNotifyDiscoveryQueuedMatches(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.Discovery = discoveryNotify
+		cfg.MaxConcurrentFiles = 2
+		cfg.PollInterval = helper.Duration{Duration: 10 * time.Millisecond}
+		cfg.FallbackPollInterval = helper.Duration{Duration: time.Hour}
+	}, nil)
+
+	// Only one file is read per poll, so the remaining files are
+	// read by further polls, without any more events
+	expected := make([]string, 0, 6)
+	for i := 0; i < 6; i++ {
+		temp := openTemp(t, tempDir)
+		message := fmt.Sprintf("testlog%d", i)
+		writeString(t, temp, message+"\n")
+		expected = append(expected, message)
+	}
+
+	require.NoError(t, operator.Start())
+	defer operator.Stop()
+
+	waitForMessages(t, logReceived, expected)
+}
+
+func TestNotifierWatchesNewDirectories(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	n, err := newNotifier([]string{filepath.Join(dir, "*", "*.log")}, nil, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer n.Close()
+	require.Contains(t, n.watched, dir)
+
+	sub := filepath.Join(dir, "app")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	n.refresh()
+	require.Contains(t, n.watched, sub)
+
+	require.NoError(t, os.Remove(sub))
+	n.refresh()
+	require.NotContains(t, n.watched, sub)
+}
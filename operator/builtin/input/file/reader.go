@@ -8,7 +8,9 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/errors"
+	"github.com/observiq/stanza/operator/helper"
 	"go.uber.org/zap"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/transform"
@@ -20,6 +22,9 @@ type fileLabels struct {
 	Path         string
 	ResolvedName string
 	ResolvedPath string
+
+	// Captured are the named capture groups of path_regex
+	Captured map[string]string
 }
 
 // resolveFileLabels resolves file labels
@@ -40,14 +45,55 @@ func (f *InputOperator) resolveFileLabels(path string) *fileLabels {
 		Name:         filepath.Base(path),
 		ResolvedPath: abs,
 		ResolvedName: filepath.Base(abs),
+		Captured:     f.capturePath(abs),
 	}
 }
 
+// capturePath returns the named capture groups of path_regex in the resolved path
+func (f *InputOperator) capturePath(path string) map[string]string {
+	if f.pathRegex == nil {
+		return nil
+	}
+
+	matches := f.pathRegex.FindStringSubmatch(path)
+	if matches == nil {
+		f.Debugw("File path does not match path_regex", "path", path)
+		return nil
+	}
+
+	captured := make(map[string]string, len(matches))
+	for i, name := range f.pathRegex.SubexpNames() {
+		if i == 0 {
+			// Skip whole match
+			continue
+		}
+		if name != "" {
+			captured[name] = matches[i]
+		}
+	}
+	return captured
+}
+
 // Reader manages a single file
 type Reader struct {
 	Fingerprint *Fingerprint
 	Offset      int64
 
+	// DecompressedOffset is the position in the decompressed data of a
+	// compressed file. Offset is only set once the file is fully read.
+	DecompressedOffset int64 `json:",omitempty"`
+
+	// Header is the most recent header of the file, if headers are configured
+	Header string `json:",omitempty"`
+
+	// skippedOffset is the offset of the last entry that exceeded max_log_size,
+	// so that the skipped event is only emitted once
+	skippedOffset int64
+
+	// truncated is whether the file is smaller than the offset,
+	// so that the truncated event is only emitted once
+	truncated bool
+
 	generation int
 	fileInput  *InputOperator
 	file       *os.File
@@ -56,6 +102,14 @@ type Reader struct {
 	decoder      *encoding.Decoder
 	decodeBuffer []byte
 
+	// flusher force flushes an incomplete last entry, and is
+	// shared by the copies of a reader across polls
+	flusher *helper.Flusher
+
+	// throttler limits the read rate of the file, and is
+	// shared by the copies of a reader across polls
+	throttler *fileThrottle
+
 	*zap.SugaredLogger `json:"-"`
 }
 
@@ -69,6 +123,9 @@ func (f *InputOperator) NewReader(path string, file *os.File, fp *Fingerprint) (
 		decoder:       f.encoding.Encoding.NewDecoder(),
 		decodeBuffer:  make([]byte, 1<<12),
 		fileLabels:    f.resolveFileLabels(path),
+		flusher:       helper.NewFlusher(f.forceFlushPeriod),
+		throttler:     &fileThrottle{bucket: newBucket(f.maxFileReadRate)},
+		skippedOffset: -1,
 	}
 	return r, nil
 }
@@ -80,6 +137,12 @@ func (f *Reader) Copy(file *os.File) (*Reader, error) {
 		return nil, err
 	}
 	reader.Offset = f.Offset
+	reader.DecompressedOffset = f.DecompressedOffset
+	reader.Header = f.Header
+	reader.flusher = f.flusher
+	reader.throttler = f.throttler
+	reader.skippedOffset = f.skippedOffset
+	reader.truncated = f.truncated
 	return reader, nil
 }
 
@@ -91,6 +154,11 @@ func (f *Reader) InitializeOffset(startAtBeginning bool) error {
 			return fmt.Errorf("stat: %s", err)
 		}
 		f.Offset = info.Size()
+
+		// The header is needed for the entries after the offset
+		if f.fileInput.headerFormat != "" && f.fileInput.fileCompression(f.fileLabels.Path, f.Fingerprint) == "" {
+			f.readHeader()
+		}
 	}
 
 	return nil
@@ -98,13 +166,28 @@ func (f *Reader) InitializeOffset(startAtBeginning bool) error {
 
 // ReadToEnd will read until the end of the file
 func (f *Reader) ReadToEnd(ctx context.Context) {
+	if compression := f.fileInput.fileCompression(f.fileLabels.Path, f.Fingerprint); compression != "" {
+		f.readCompressed(ctx, compression)
+		return
+	}
+
+	f.checkTruncated(ctx)
+
 	if _, err := f.file.Seek(f.Offset, 0); err != nil {
 		f.Errorw("Failed to seek", zap.Error(err))
 		return
 	}
 
-	fr := NewFingerprintUpdatingReader(f.file, f.Offset, f.Fingerprint, f.fileInput.fingerprintSize)
-	scanner := NewPositionalScanner(fr, f.fileInput.MaxLogSize, f.Offset, f.fileInput.SplitFunc)
+	// Files in batch mode are complete, so their last entry is flushed
+	splitFunc := f.fileInput.SplitFunc
+	if f.fileInput.batch {
+		splitFunc = f.flushingSplitFunc(func() bool { return true })
+	}
+	splitFunc = f.flusher.SplitFunc(splitFunc)
+
+	tr := f.throttle(f.file, f.Offset)
+	fr := NewFingerprintUpdatingReader(tr, f.Offset, f.Fingerprint, f.fileInput.fingerprintSize)
+	scanner := NewPositionalScanner(fr, f.fileInput.MaxLogSize, f.Offset, tr.split(splitFunc))
 
 	// Iterate over the tokenized file, emitting entries as we go
 	for {
@@ -118,6 +201,13 @@ func (f *Reader) ReadToEnd(ctx context.Context) {
 		if !ok {
 			if err := getScannerError(scanner); err != nil {
 				f.Errorw("Failed during scan", zap.Error(err))
+				if scanner.Err() == bufio.ErrTooLong && f.skippedOffset != f.Offset {
+					f.skippedOffset = f.Offset
+					f.emitEvent(ctx, eventSkipped, map[string]interface{}{"reason": "max_log_size"})
+				}
+			}
+			if tr.throttled {
+				f.Debugw("Reached read rate limit", "offset", f.Offset)
 			}
 			break
 		}
@@ -129,12 +219,52 @@ func (f *Reader) ReadToEnd(ctx context.Context) {
 	}
 }
 
+// checkTruncated emits the truncated event once the file is smaller than the
+// offset, such as when it was truncated. The offset is unchanged, so reading
+// continues once the file grows past it.
+func (f *Reader) checkTruncated(ctx context.Context) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return
+	}
+	if info.Size() >= f.Offset {
+		f.truncated = false
+		return
+	}
+	if f.truncated {
+		return
+	}
+
+	f.truncated = true
+	f.Debugw("File was truncated", "offset", f.Offset, "size", info.Size())
+	f.emitEvent(ctx, eventTruncated, map[string]interface{}{"size": info.Size()})
+}
+
+// flushingSplitFunc returns a split func that flushes the last entry
+// of a file at EOF, if the file is complete and will not be written to
+func (f *Reader) flushingSplitFunc(complete func() bool) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if !atEOF {
+			return f.fileInput.SplitFunc(data, atEOF)
+		}
+
+		// Complete entries are returned first, since split
+		// funcs flush all remaining data at EOF
+		advance, token, err := f.fileInput.SplitFunc(data, false)
+		if err != nil || advance > 0 || token != nil || !complete() {
+			return advance, token, err
+		}
+		return f.fileInput.flushingSplitFunc(data, atEOF)
+	}
+}
+
 // Close will close the file
 func (f *Reader) Close() {
 	if f.file != nil {
 		if err := f.file.Close(); err != nil {
 			f.Debugf("Problem closing reader", "Error", err.Error())
 		}
+		f.file = nil
 	}
 }
 
@@ -151,11 +281,31 @@ func (f *Reader) emit(ctx context.Context, msgBuf []byte) error {
 		return fmt.Errorf("decode: %s", err)
 	}
 
+	if f.fileInput.headerFormat != "" && f.updateHeader(msg) {
+		return nil
+	}
+
 	e, err := f.fileInput.NewEntry(msg)
 	if err != nil {
 		return fmt.Errorf("create entry: %s", err)
 	}
 
+	if err := f.setFileFields(e); err != nil {
+		return err
+	}
+	if f.Header != "" {
+		e.AddLabel(f.fileInput.headerLabel, f.Header)
+	}
+	if f.flusher.Forced() {
+		e.AddLabel(helper.ForceFlushedLabel, "true")
+	}
+
+	f.fileInput.Write(ctx, e)
+	return nil
+}
+
+// setFileFields sets the configured file name and path fields on an entry
+func (f *Reader) setFileFields(e *entry.Entry) error {
 	if err := e.Set(f.fileInput.FilePathField, f.fileLabels.Path); err != nil {
 		return err
 	}
@@ -170,7 +320,13 @@ func (f *Reader) emit(ctx context.Context, msgBuf []byte) error {
 		return err
 	}
 
-	f.fileInput.Write(ctx, e)
+	for key, value := range f.fileLabels.Captured {
+		if f.fileInput.pathRegexTarget == pathRegexTargetResource {
+			e.AddResourceKey(key, value)
+		} else {
+			e.AddLabel(key, value)
+		}
+	}
 	return nil
 }
 
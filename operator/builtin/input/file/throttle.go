@@ -0,0 +1,201 @@
+package file
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket that limits the number of bytes read per second.
+// It holds up to one second of tokens, and starts full.
+type bucket struct {
+	mux      sync.Mutex
+	rate     float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newBucket(rate int64) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &bucket{
+		rate:     float64(rate),
+		tokens:   float64(rate),
+		lastFill: time.Now(),
+	}
+}
+
+// take removes up to max tokens from the bucket, and returns the number removed
+func (b *bucket) take(max int) int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.lastFill = now
+
+	n := max
+	if float64(n) > b.tokens {
+		n = int(b.tokens)
+	}
+	b.tokens -= float64(n)
+	return n
+}
+
+// refund returns tokens that were taken but not used
+func (b *bucket) refund(n int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.tokens += float64(n)
+}
+
+// fileThrottle limits the read rate of a file. It is shared by the copies
+// of a reader across polls, so that the limit applies to the file.
+type fileThrottle struct {
+	bucket *bucket
+
+	// readEnd is the furthest position that has been read. Data before
+	// it is read again without being limited, such as an incomplete entry
+	// at the end of the file, or a compressed file that is decompressed
+	// from the start.
+	readEnd int64
+}
+
+// throttledReader reads a file within the file and global read rate limits.
+// When either limit is exhausted, the end of the file is reported so that
+// the reader yields to other files until the next poll.
+type throttledReader struct {
+	reader   io.Reader
+	offset   int64
+	throttle *fileThrottle
+	global   *bucket
+
+	// share is the most that is taken from the global limit in a poll,
+	// so that the files read concurrently share it fairly
+	share     int
+	taken     int
+	throttled bool
+}
+
+// throttle wraps a reader of the file at offset with the read rate limits
+func (f *Reader) throttle(r io.Reader, offset int64) *throttledReader {
+	return &throttledReader{
+		reader:   r,
+		offset:   offset,
+		throttle: f.throttler,
+		global:   f.fileInput.readBucket,
+		share:    f.fileInput.readShare,
+	}
+}
+
+// Read reads up to as many bytes as the limits allow
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return r.reader.Read(p)
+	}
+
+	// Data that has been read before is not limited
+	free := int(r.throttle.readEnd - r.offset)
+	if free <= 0 {
+		allowed := r.allow(len(p))
+		if allowed == 0 {
+			r.throttled = true
+			return 0, io.EOF
+		}
+		p = p[:allowed]
+	} else if free < len(p) {
+		p = p[:free]
+	}
+
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	if r.offset > r.throttle.readEnd {
+		r.throttle.readEnd = r.offset
+	}
+	if free <= 0 && n < len(p) {
+		r.refund(len(p) - n)
+	}
+	return n, err
+}
+
+// allow takes tokens from the file and global limits, returning the number of bytes that may be read
+func (r *throttledReader) allow(max int) int {
+	if r.global != nil && r.share > 0 && max > r.share-r.taken {
+		max = r.share - r.taken
+	}
+
+	allowed := max
+	if r.throttle.bucket != nil {
+		allowed = r.throttle.bucket.take(allowed)
+	}
+	if r.global != nil && allowed > 0 {
+		globalAllowed := r.global.take(allowed)
+		if r.throttle.bucket != nil {
+			r.throttle.bucket.refund(allowed - globalAllowed)
+		}
+		allowed = globalAllowed
+		r.taken += globalAllowed
+	}
+	return allowed
+}
+
+// refund returns the tokens of bytes that were allowed but not read
+func (r *throttledReader) refund(n int) {
+	if r.throttle.bucket != nil {
+		r.throttle.bucket.refund(n)
+	}
+	if r.global != nil {
+		r.global.refund(n)
+		r.taken -= n
+	}
+}
+
+// split wraps a split func so that the end of the file reported when the
+// reader is throttled does not flush an incomplete entry
+func (r *throttledReader) split(splitFunc bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		return splitFunc(data, atEOF && !r.throttled)
+	}
+}
+
+// hasNewData returns whether the file has grown past the offset
+func (f *Reader) hasNewData() bool {
+	info, err := f.file.Stat()
+	if err != nil {
+		return true
+	}
+	return info.Size() > f.Offset
+}
+
+// includeIndex returns the index of the first include pattern that matches
+// a path, or the number of include patterns if none match
+func (f *InputOperator) includeIndex(path string) int {
+	for i, include := range f.Include {
+		if ok, _ := filepath.Match(include, path); ok {
+			return i
+		}
+	}
+	return len(f.Include)
+}
+
+// priorityGroups groups readers by the index of the include pattern that
+// matches their path, in order of priority. Without prioritization, all
+// readers are in a single group.
+func (f *InputOperator) priorityGroups(readers []*Reader) [][]*Reader {
+	if !f.prioritizeInclude {
+		return [][]*Reader{readers}
+	}
+
+	groups := make([][]*Reader, len(f.Include)+1)
+	for _, reader := range readers {
+		i := f.includeIndex(reader.fileLabels.Path)
+		groups[i] = append(groups[i], reader)
+	}
+	return groups
+}
@@ -0,0 +1,133 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tenByteLines returns lines of exactly ten bytes, including the newline
+func tenByteLines(prefix string, start, count int) (string, []string) {
+	contents := ""
+	lines := make([]string, 0, count)
+	for i := start; i < start+count; i++ {
+		line := fmt.Sprintf("%s%03d", prefix, i)
+		lines = append(lines, line)
+		contents += line + "\n"
+	}
+	return contents, lines
+}
+
+func TestMaxFileReadRate(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.StartAt = "beginning"
+		cfg.MaxFileReadRate = 50
+	}, nil)
+
+	contents, lines := tenByteLines("testlo", 0, 12)
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, contents)
+
+	// Only one second of the rate is read at once
+	operator.poll(context.Background())
+	for _, line := range lines[:5] {
+		waitForMessage(t, logReceived, line)
+	}
+	operator.poll(context.Background())
+	expectNoMessages(t, logReceived)
+
+	time.Sleep(time.Second)
+	operator.poll(context.Background())
+	for _, line := range lines[5:10] {
+		waitForMessage(t, logReceived, line)
+	}
+
+	time.Sleep(time.Second)
+	operator.poll(context.Background())
+	for _, line := range lines[10:] {
+		waitForMessage(t, logReceived, line)
+	}
+	expectNoMessages(t, logReceived)
+}
+
+func TestMaxFileReadRatePartialEntry(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.StartAt = "beginning"
+		cfg.MaxFileReadRate = 15
+	}, nil)
+
+	// An entry that is cut off by the limit is read again in full
+	contents, lines := tenByteLines("testlo", 0, 2)
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, contents)
+
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, lines[0])
+	expectNoMessages(t, logReceived)
+
+	time.Sleep(500 * time.Millisecond)
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, lines[1])
+	expectNoMessages(t, logReceived)
+}
+
+func TestMaxReadRateShared(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.StartAt = "beginning"
+		cfg.MaxReadRate = 100
+	}, nil)
+
+	contents1, _ := tenByteLines("file1_", 0, 20)
+	contents2, _ := tenByteLines("file2_", 0, 20)
+	writeString(t, openTemp(t, tempDir), contents1)
+	writeString(t, openTemp(t, tempDir), contents2)
+
+	// The global limit is shared by both files
+	operator.poll(context.Background())
+	received := map[string]int{}
+LOOP:
+	for {
+		select {
+		case e := <-logReceived:
+			received[e.Record.(string)[:6]]++
+		case <-time.After(200 * time.Millisecond):
+			break LOOP
+		}
+	}
+	require.True(t, received["file1_"]+received["file2_"] <= 10)
+	require.True(t, received["file1_"] >= 4, "file1 read %d entries", received["file1_"])
+	require.True(t, received["file2_"] >= 4, "file2 read %d entries", received["file2_"])
+}
+
+func TestPrioritizeInclude(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *InputConfig) {
+		cfg.StartAt = "beginning"
+		cfg.MaxReadRate = 50
+		cfg.PrioritizeInclude = true
+	}, nil)
+	operator.Include = []string{filepath.Join(tempDir, "critical*"), filepath.Join(tempDir, "debug*")}
+
+	debug, _ := tenByteLines("debug_", 0, 5)
+	critical, criticalLines := tenByteLines("crit__", 0, 5)
+	writeString(t, openFile(t, filepath.Join(tempDir, "debug.log")), debug)
+	writeString(t, openFile(t, filepath.Join(tempDir, "critical.log")), critical)
+
+	// Files of the first include pattern use the global limit first
+	operator.poll(context.Background())
+	for _, line := range criticalLines {
+		waitForMessage(t, logReceived, line)
+	}
+	expectNoMessages(t, logReceived)
+
+	time.Sleep(time.Second)
+	operator.poll(context.Background())
+	waitForMessage(t, logReceived, "debug_000")
+}
@@ -127,6 +127,16 @@ func waitForN(t *testing.T, c chan *entry.Entry, n int) []string {
 	return messages
 }
 
+func waitForEntry(t *testing.T, c chan *entry.Entry) *entry.Entry {
+	select {
+	case e := <-c:
+		return e
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
 func waitForMessage(t *testing.T, c chan *entry.Entry, expected string) {
 	select {
 	case e := <-c:
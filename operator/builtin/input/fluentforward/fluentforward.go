@@ -0,0 +1,290 @@
+package fluentforward
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListenAddress = ":24224"
+	defaultTagLabel      = "fluent_tag"
+	handshakeTimeout     = 10 * time.Second
+
+	// defaultMaxMessageSize is the default limit of the size of packed entries, after they are decompressed
+	defaultMaxMessageSize = 20 * 1024 * 1024
+)
+
+func init() {
+	operator.Register("fluent_forward_input", func() operator.Builder { return NewFluentForwardInputConfig("") })
+}
+
+// NewFluentForwardInputConfig creates a new fluent forward input config with default values
+func NewFluentForwardInputConfig(operatorID string) *FluentForwardInputConfig {
+	return &FluentForwardInputConfig{
+		InputConfig:    helper.NewInputConfig(operatorID, "fluent_forward_input"),
+		ListenAddress:  defaultListenAddress,
+		TagLabel:       defaultTagLabel,
+		MaxMessageSize: defaultMaxMessageSize,
+	}
+}
+
+// FluentForwardInputConfig is the configuration of a fluent forward input operator
+type FluentForwardInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	ListenAddress  string                 `json:"listen_address,omitempty"   yaml:"listen_address,omitempty"`
+	TLS            helper.TLSServerConfig `json:"tls,omitempty"              yaml:"tls,omitempty"`
+	SharedKey      string                 `json:"shared_key,omitempty"       yaml:"shared_key,omitempty"`
+	SelfHostname   string                 `json:"self_hostname,omitempty"    yaml:"self_hostname,omitempty"`
+	TagLabel       string                 `json:"tag_label,omitempty"        yaml:"tag_label,omitempty"`
+	MaxMessageSize helper.ByteSize        `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
+}
+
+// Build will build a fluent forward input operator
+func (c FluentForwardInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ListenAddress == "" {
+		c.ListenAddress = defaultListenAddress
+	}
+	if _, err := net.ResolveTCPAddr("tcp", c.ListenAddress); err != nil {
+		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
+	}
+
+	if c.TagLabel == "" {
+		c.TagLabel = defaultTagLabel
+	}
+
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = defaultMaxMessageSize
+	}
+
+	if c.SelfHostname == "" {
+		if c.SelfHostname, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to determine self_hostname: %s", err)
+		}
+	}
+
+	tlsConfig, err := c.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	fluentInput := &FluentForwardInput{
+		InputOperator:  inputOperator,
+		address:        c.ListenAddress,
+		tlsConfig:      tlsConfig,
+		sharedKey:      c.SharedKey,
+		selfHostname:   c.SelfHostname,
+		tagLabel:       c.TagLabel,
+		maxMessageSize: int64(c.MaxMessageSize),
+		backoff: backoff.Backoff{
+			Max: 3 * time.Second,
+		},
+	}
+	return []operator.Operator{fluentInput}, nil
+}
+
+// FluentForwardInput is an operator that receives entries with the fluentd forward protocol
+type FluentForwardInput struct {
+	helper.InputOperator
+	address        string
+	tlsConfig      *tls.Config
+	sharedKey      string
+	selfHostname   string
+	tagLabel       string
+	maxMessageSize int64
+	backoff        backoff.Backoff
+
+	listener net.Listener
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Start will start listening for forward connections
+func (f *FluentForwardInput) Start() error {
+	listener, err := net.Listen("tcp", f.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on interface: %w", err)
+	}
+	if f.tlsConfig != nil {
+		listener = tls.NewListener(listener, f.tlsConfig)
+	}
+	f.listener = listener
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.goListen(ctx)
+	return nil
+}
+
+// goListen will listen for connections
+func (f *FluentForwardInput) goListen(ctx context.Context) {
+	f.wg.Add(1)
+
+	go func() {
+		defer f.wg.Done()
+
+		for {
+			conn, err := f.listener.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					f.Debugw("Listener accept error", zap.Error(err))
+					time.Sleep(f.backoff.Duration())
+					continue
+				}
+			}
+			f.backoff.Reset()
+
+			f.Debugf("Received connection: %s", conn.RemoteAddr().String())
+			subctx, cancel := context.WithCancel(ctx)
+			f.goHandleClose(subctx, conn)
+			f.goHandleMessages(subctx, conn, cancel)
+		}
+	}()
+}
+
+// goHandleClose will wait for the context to finish before closing a connection
+func (f *FluentForwardInput) goHandleClose(ctx context.Context, conn net.Conn) {
+	f.wg.Add(1)
+
+	go func() {
+		defer f.wg.Done()
+		<-ctx.Done()
+		f.Debugf("Closing connection: %s", conn.RemoteAddr().String())
+		if err := conn.Close(); err != nil {
+			f.Errorf("Failed to close connection: %s", err)
+		}
+	}()
+}
+
+// goHandleMessages will handle messages from a connection
+func (f *FluentForwardInput) goHandleMessages(ctx context.Context, conn net.Conn, cancel context.CancelFunc) {
+	f.wg.Add(1)
+
+	go func() {
+		defer f.wg.Done()
+		defer cancel()
+
+		dec := msgpack.NewDecoder(bufio.NewReader(conn))
+		enc := msgpack.NewEncoder(conn)
+
+		if f.sharedKey != "" {
+			if err := f.handshake(conn, dec, enc); err != nil {
+				f.Warnw("Handshake failed", zap.Error(err), "remote_addr", conn.RemoteAddr().String())
+				return
+			}
+		}
+
+		for {
+			msg, err := decodeMessage(dec, f.maxMessageSize)
+			if err != nil {
+				if !isClosedError(ctx, err) {
+					f.Errorw("Failed to decode message", zap.Error(err), "remote_addr", conn.RemoteAddr().String())
+				}
+				return
+			}
+
+			for _, e := range msg.events {
+				entry, err := f.NewEntry(e.record)
+				if err != nil {
+					f.Errorw("Failed to create entry", zap.Error(err))
+					continue
+				}
+				entry.Timestamp = e.time
+				entry.AddLabel(f.tagLabel, msg.tag)
+				f.Write(ctx, entry)
+			}
+
+			if msg.chunk != "" {
+				if err := writeAck(enc, msg.chunk); err != nil {
+					f.Errorw("Failed to acknowledge chunk", zap.Error(err))
+					return
+				}
+			}
+		}
+	}()
+}
+
+// handshake authenticates the client with the shared key
+func (f *FluentForwardInput) handshake(conn net.Conn, dec *msgpack.Decoder, enc *msgpack.Encoder) error {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	if err := writeHelo(enc, nonce); err != nil {
+		return fmt.Errorf("send HELO: %s", err)
+	}
+
+	p, err := readPing(dec)
+	if err != nil {
+		return fmt.Errorf("read PING: %s", err)
+	}
+
+	expected := sharedKeyDigest(p.sharedKeySalt, p.hostname, nonce, f.sharedKey)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(p.digest)) != 1 {
+		_ = writePong(enc, false, "shared_key mismatch", f.selfHostname, "")
+		return fmt.Errorf("shared_key mismatch from '%s'", p.hostname)
+	}
+
+	digest := sharedKeyDigest(p.sharedKeySalt, f.selfHostname, nonce, f.sharedKey)
+	if err := writePong(enc, true, "", f.selfHostname, digest); err != nil {
+		return fmt.Errorf("send PONG: %s", err)
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// isClosedError returns true if the error is expected when a connection ends
+func isClosedError(ctx context.Context, err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if strings.Contains(err.Error(), "use of closed network connection") {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// Stop will stop listening for connections
+func (f *FluentForwardInput) Stop() error {
+	f.cancel()
+
+	if err := f.listener.Close(); err != nil {
+		return err
+	}
+
+	f.wg.Wait()
+	return nil
+}
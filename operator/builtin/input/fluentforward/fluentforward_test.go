@@ -0,0 +1,370 @@
+package fluentforward
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testClient struct {
+	conn net.Conn
+	enc  *msgpack.Encoder
+	dec  *msgpack.Decoder
+}
+
+func newTestClient(t *testing.T, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(2*time.Second)))
+
+	return &testClient{
+		conn: conn,
+		enc:  msgpack.NewEncoder(conn),
+		dec:  msgpack.NewDecoder(bufio.NewReader(conn)),
+	}
+}
+
+// eventTime is an EventTime that can be encoded by msgpack.Encoder
+type eventTime time.Time
+
+var _ msgpack.CustomEncoder = eventTime{}
+
+func (e eventTime) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeExtHeader(eventTimeExtID, eventTimeLen); err != nil {
+		return err
+	}
+	var b [eventTimeLen]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(time.Time(e).Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(time.Time(e).Nanosecond()))
+	_, err := enc.Writer().Write(b[:])
+	return err
+}
+
+func packEntries(t *testing.T, entries ...[]interface{}) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, e := range entries {
+		require.NoError(t, enc.Encode(e))
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func expectEntry(t *testing.T, fake *testutil.FakeOutput, tag string, ts time.Time, record interface{}) {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, tag, e.Labels[defaultTagLabel])
+		require.True(t, ts.Equal(e.Timestamp), "expected %s, got %s", ts, e.Timestamp)
+		require.Equal(t, record, e.Record)
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+	}
+}
+
+func TestFluentForwardInputBuild(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		cfg := NewFluentForwardInputConfig("test")
+		cfg.OutputIDs = []string{"fake"}
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+	})
+
+	t.Run("InvalidAddress", func(t *testing.T) {
+		cfg := NewFluentForwardInputConfig("test")
+		cfg.ListenAddress = "invalid:port"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+
+	t.Run("TLSMissingCertificate", func(t *testing.T) {
+		cfg := NewFluentForwardInputConfig("test")
+		cfg.TLS.Enable = true
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+}
+
+func TestFluentForwardInputModes(t *testing.T) {
+	ts := time.Date(2021, 7, 20, 10, 0, 0, 123456789, time.UTC)
+	record1 := map[string]interface{}{"log": "test1"}
+	record2 := map[string]interface{}{"log": "test2"}
+	packed := packEntries(t,
+		[]interface{}{eventTime(ts), record1},
+		[]interface{}{eventTime(ts), record2},
+	)
+
+	cases := []struct {
+		name     string
+		message  []interface{}
+		expected []map[string]interface{}
+	}{
+		{
+			"Message",
+			[]interface{}{"app.test", eventTime(ts), record1},
+			[]map[string]interface{}{record1},
+		},
+		{
+			"MessageWithOptions",
+			[]interface{}{"app.test", eventTime(ts), record1, map[string]interface{}{"chunk": "abc"}},
+			[]map[string]interface{}{record1},
+		},
+		{
+			"Forward",
+			[]interface{}{"app.test", []interface{}{
+				[]interface{}{eventTime(ts), record1},
+				[]interface{}{eventTime(ts), record2},
+			}, map[string]interface{}{"chunk": "abc"}},
+			[]map[string]interface{}{record1, record2},
+		},
+		{
+			"PackedForward",
+			[]interface{}{"app.test", packed, map[string]interface{}{"chunk": "abc", "size": 2}},
+			[]map[string]interface{}{record1, record2},
+		},
+		{
+			"PackedForwardString",
+			[]interface{}{"app.test", string(packed)},
+			[]map[string]interface{}{record1, record2},
+		},
+		{
+			"CompressedPackedForward",
+			[]interface{}{"app.test", gzipBytes(t, packed), map[string]interface{}{"chunk": "abc", "compressed": "gzip"}},
+			[]map[string]interface{}{record1, record2},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFluentForwardInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.SelfHostname = "server"
+			cfg.OutputIDs = []string{"fake"}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			fluentInput := ops[0].(*FluentForwardInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, fluentInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, fluentInput.Start())
+			defer fluentInput.Stop()
+
+			client := newTestClient(t, fluentInput.listener.Addr().String())
+
+			require.NoError(t, client.enc.Encode(tc.message))
+			for _, record := range tc.expected {
+				expectEntry(t, fake, "app.test", ts, record)
+			}
+
+			if len(tc.message) > 2 {
+				if options, ok := tc.message[len(tc.message)-1].(map[string]interface{}); ok && options["chunk"] != nil {
+					ack, err := client.dec.DecodeMap()
+					require.NoError(t, err)
+					require.Equal(t, "abc", ack["ack"])
+				}
+			}
+		})
+	}
+}
+
+func TestFluentForwardInputIntegerTime(t *testing.T) {
+	cfg := NewFluentForwardInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.SelfHostname = "server"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	fluentInput := ops[0].(*FluentForwardInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, fluentInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, fluentInput.Start())
+	defer fluentInput.Stop()
+
+	client := newTestClient(t, fluentInput.listener.Addr().String())
+
+	ts := time.Unix(1626775200, 0)
+	require.NoError(t, client.enc.Encode([]interface{}{"app.test", ts.Unix(), map[string]interface{}{"log": []byte("binary")}}))
+	expectEntry(t, fake, "app.test", ts, map[string]interface{}{"log": "binary"})
+}
+
+func TestFluentForwardInputInvalidMessage(t *testing.T) {
+	cfg := NewFluentForwardInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.SelfHostname = "server"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	fluentInput := ops[0].(*FluentForwardInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, fluentInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, fluentInput.Start())
+	defer fluentInput.Stop()
+
+	client := newTestClient(t, fluentInput.listener.Addr().String())
+
+	require.NoError(t, client.enc.Encode([]interface{}{"app.test"}))
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+
+	// The connection is closed after an invalid message
+	_, err = client.dec.DecodeInterface()
+	require.Error(t, err)
+}
+
+func TestFluentForwardInputHandshake(t *testing.T) {
+	cases := []struct {
+		name      string
+		clientKey string
+		expectOK  bool
+	}{
+		{"Success", "secret", true},
+		{"Mismatch", "wrong", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFluentForwardInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.SelfHostname = "server"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.SharedKey = "secret"
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			fluentInput := ops[0].(*FluentForwardInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, fluentInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, fluentInput.Start())
+			defer fluentInput.Stop()
+
+			client := newTestClient(t, fluentInput.listener.Addr().String())
+
+			var helo []interface{}
+			require.NoError(t, client.dec.Decode(&helo))
+			require.Equal(t, "HELO", helo[0])
+			nonce := helo[1].(map[string]interface{})["nonce"].([]byte)
+
+			digest := sharedKeyDigest("salt", "client", nonce, tc.clientKey)
+			require.NoError(t, client.enc.Encode([]interface{}{"PING", "client", "salt", digest, "", ""}))
+
+			var pong []interface{}
+			require.NoError(t, client.dec.Decode(&pong))
+			require.Equal(t, "PONG", pong[0])
+			require.Equal(t, tc.expectOK, pong[1])
+
+			if !tc.expectOK {
+				_, err := client.dec.DecodeInterface()
+				require.Error(t, err)
+				return
+			}
+
+			require.Equal(t, "server", pong[3])
+			require.Equal(t, sharedKeyDigest("salt", "server", nonce, "secret"), pong[4])
+
+			ts := time.Unix(1626775200, 0)
+			require.NoError(t, client.enc.Encode([]interface{}{"app.test", ts.Unix(), map[string]interface{}{"log": "test"}}))
+			expectEntry(t, fake, "app.test", ts, map[string]interface{}{"log": "test"})
+		})
+	}
+}
+
+func TestFluentForwardInputMaxMessageSize(t *testing.T) {
+	ts := time.Date(2021, 7, 20, 10, 0, 0, 0, time.UTC)
+	packed := packEntries(t,
+		[]interface{}{eventTime(ts), map[string]interface{}{"log": strings.Repeat("a", 2048)}},
+	)
+
+	cases := []struct {
+		name    string
+		message []interface{}
+	}{
+		{
+			"PackedForward",
+			[]interface{}{"app.test", packed},
+		},
+		{
+			"CompressedPackedForward",
+			[]interface{}{"app.test", gzipBytes(t, packed), map[string]interface{}{"compressed": "gzip"}},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFluentForwardInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.SelfHostname = "server"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.MaxMessageSize = 1024
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			fluentInput := ops[0].(*FluentForwardInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, fluentInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, fluentInput.Start())
+			defer fluentInput.Stop()
+
+			client := newTestClient(t, fluentInput.listener.Addr().String())
+
+			require.NoError(t, client.enc.Encode(tc.message))
+			fake.ExpectNoEntry(t, 100*time.Millisecond)
+
+			// The connection is closed after a message that is too large
+			_, err = client.dec.DecodeInterface()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeEventsLengthExceedsData(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	require.NoError(t, enc.EncodeArrayLen(1<<31-1))
+	require.NoError(t, enc.EncodeArrayLen(2))
+	require.NoError(t, enc.EncodeInt(1))
+	require.NoError(t, enc.EncodeMap(map[string]interface{}{"message": "test"}))
+
+	_, err := decodeEvents(msgpack.NewDecoder(&buf))
+	require.Error(t, err)
+}
+
+func TestNormalize(t *testing.T) {
+	value := map[string]interface{}{
+		"bin":    []byte("value"),
+		"nested": map[string]interface{}{"bin": []byte("nested")},
+		"list":   []interface{}{[]byte("item"), int8(1)},
+	}
+	expected := map[string]interface{}{
+		"bin":    "value",
+		"nested": map[string]interface{}{"bin": "nested"},
+		"list":   []interface{}{"item", int8(1)},
+	}
+	require.Equal(t, expected, normalize(value))
+}
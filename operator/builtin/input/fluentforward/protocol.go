@@ -0,0 +1,343 @@
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// The Forward protocol is described in the fluentd wiki
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+
+const (
+	// eventTimeExtID is the msgpack extension type used for EventTime
+	eventTimeExtID = 0
+	eventTimeLen   = 8
+)
+
+// event is a single log event of a forward message
+type event struct {
+	time   time.Time
+	record interface{}
+}
+
+// message is a forward message in any of the Message, Forward,
+// PackedForward or CompressedPackedForward modes
+type message struct {
+	tag    string
+	events []event
+	chunk  string
+}
+
+// decodeMessage decodes the next message from the decoder. Packed entries
+// larger than maxSize, before or after decompression, are rejected.
+func decodeMessage(dec *msgpack.Decoder, maxSize int64) (*message, error) {
+	length, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if length < 2 || length > 4 {
+		return nil, fmt.Errorf("invalid message length %d", length)
+	}
+
+	tag, err := dec.DecodeString()
+	if err != nil {
+		return nil, fmt.Errorf("decode tag: %s", err)
+	}
+	msg := &message{tag: tag}
+
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	var optionIndex int
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		// Forward mode: [tag, [[time, record], ...], option]
+		msg.events, err = decodeEvents(dec)
+		optionIndex = 2
+	case msgpcode.IsString(code) || msgpcode.IsBin(code):
+		// PackedForward mode: [tag, msgpack stream of [time, record], option]
+		var packed []byte
+		packed, err = decodePacked(dec, maxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		var options map[string]interface{}
+		if length > 2 {
+			if options, err = dec.DecodeMap(); err != nil {
+				return nil, fmt.Errorf("decode options: %s", err)
+			}
+		}
+		msg.chunk = chunkOption(options)
+
+		compressed, _ := options["compressed"].(string)
+		msg.events, err = decodePackedEvents(packed, compressed, maxSize)
+		return msg, err
+	default:
+		// Message mode: [tag, time, record, option]
+		var e event
+		e, err = decodeEventFields(dec)
+		msg.events = []event{e}
+		optionIndex = 3
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if length > optionIndex {
+		options, err := dec.DecodeMap()
+		if err != nil {
+			return nil, fmt.Errorf("decode options: %s", err)
+		}
+		msg.chunk = chunkOption(options)
+	}
+
+	return msg, nil
+}
+
+// chunkOption returns the chunk id that must be acknowledged, if any
+func chunkOption(options map[string]interface{}) string {
+	chunk, _ := options["chunk"].(string)
+	return chunk
+}
+
+// decodeEvents decodes an array of [time, record] entries
+func decodeEvents(dec *msgpack.Decoder) ([]event, error) {
+	length, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	// The length is not used to preallocate the events, as it is read from the client
+	var events []event
+	for i := 0; i < length; i++ {
+		e, err := decodeEvent(dec)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// decodePacked decodes the packed entries of a PackedForward message,
+// checking their length before they are read
+func decodePacked(dec *msgpack.Decoder, maxSize int64) ([]byte, error) {
+	n, err := dec.DecodeBytesLen()
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	if int64(n) > maxSize {
+		return nil, fmt.Errorf("packed entries of %d bytes exceed the maximum of %d bytes", n, maxSize)
+	}
+
+	packed := make([]byte, n)
+	if err := dec.ReadFull(packed); err != nil {
+		return nil, err
+	}
+	return packed, nil
+}
+
+// decodePackedEvents decodes a stream of [time, record] entries,
+// which may be compressed
+func decodePackedEvents(packed []byte, compressed string, maxSize int64) ([]event, error) {
+	var r io.Reader = bytes.NewReader(packed)
+	switch compressed {
+	case "":
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("decompress entries: %s", err)
+		}
+		r = helper.NewLimitedReader(gz, maxSize)
+	default:
+		return nil, fmt.Errorf("unsupported compression '%s'", compressed)
+	}
+
+	// Read the whole stream first, so that a truncated
+	// entry can be distinguished from the end of the stream
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompress entries: %s", err)
+	}
+
+	reader := bytes.NewReader(b)
+	dec := msgpack.NewDecoder(reader)
+	var events []event
+	for reader.Len() > 0 {
+		e, err := decodeEvent(dec)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// decodeEvent decodes a [time, record] entry
+func decodeEvent(dec *msgpack.Decoder) (event, error) {
+	length, err := dec.DecodeArrayLen()
+	if err != nil {
+		return event{}, err
+	}
+	if length != 2 {
+		return event{}, fmt.Errorf("invalid entry length %d", length)
+	}
+	return decodeEventFields(dec)
+}
+
+// decodeEventFields decodes the time and record of an event
+func decodeEventFields(dec *msgpack.Decoder) (event, error) {
+	t, err := decodeTime(dec)
+	if err != nil {
+		return event{}, fmt.Errorf("decode time: %s", err)
+	}
+
+	record, err := dec.DecodeInterface()
+	if err != nil {
+		return event{}, fmt.Errorf("decode record: %s", err)
+	}
+
+	return event{time: t, record: normalize(record)}, nil
+}
+
+// decodeTime decodes either an EventTime or an integer of unix seconds
+func decodeTime(dec *msgpack.Decoder) (time.Time, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !msgpcode.IsExt(code) {
+		seconds, err := dec.DecodeInt64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0), nil
+	}
+
+	id, length, err := dec.DecodeExtHeader()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if id != eventTimeExtID || length != eventTimeLen {
+		return time.Time{}, fmt.Errorf("unexpected extension type %d with length %d", id, length)
+	}
+
+	var b [eventTimeLen]byte
+	if err := dec.ReadFull(b[:]); err != nil {
+		return time.Time{}, err
+	}
+	seconds := binary.BigEndian.Uint32(b[:4])
+	nanos := binary.BigEndian.Uint32(b[4:])
+	return time.Unix(int64(seconds), int64(nanos)), nil
+}
+
+// normalize converts binary values to strings, since
+// some clients encode all strings as msgpack bin
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case map[string]interface{}:
+		for key, nested := range v {
+			v[key] = normalize(nested)
+		}
+		return v
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = normalize(nested)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// writeAck acknowledges a chunk
+func writeAck(enc *msgpack.Encoder, chunk string) error {
+	return enc.Encode(map[string]string{"ack": chunk})
+}
+
+// writeHelo starts the shared key handshake. User authentication is not
+// supported, so the auth salt is empty.
+func writeHelo(enc *msgpack.Encoder, nonce []byte) error {
+	return enc.Encode([]interface{}{
+		"HELO",
+		map[string]interface{}{
+			"nonce":     nonce,
+			"auth":      []byte{},
+			"keepalive": true,
+		},
+	})
+}
+
+// ping is the client's response to HELO
+type ping struct {
+	hostname      string
+	sharedKeySalt string
+	digest        string
+}
+
+func readPing(dec *msgpack.Decoder) (*ping, error) {
+	var fields []interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid PING length %d", len(fields))
+	}
+
+	values := make([]string, 4)
+	for i := range values {
+		switch v := fields[i].(type) {
+		case string:
+			values[i] = v
+		case []byte:
+			values[i] = string(v)
+		default:
+			return nil, fmt.Errorf("invalid PING field %d of type %T", i, fields[i])
+		}
+	}
+
+	if values[0] != "PING" {
+		return nil, fmt.Errorf("expected PING, got '%s'", values[0])
+	}
+
+	return &ping{
+		hostname:      values[1],
+		sharedKeySalt: values[2],
+		digest:        values[3],
+	}, nil
+}
+
+func writePong(enc *msgpack.Encoder, authenticated bool, reason, hostname, digest string) error {
+	return enc.Encode([]interface{}{"PONG", authenticated, reason, hostname, digest})
+}
+
+// sharedKeyDigest is the digest used by both sides of the handshake to prove
+// that they know the shared key
+func sharedKeyDigest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -1,11 +1,17 @@
 package forward
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/errors"
 	"github.com/observiq/stanza/operator"
@@ -13,6 +19,14 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// sharedSecretHeader is the header that forward_output uses to send a shared secret
+	sharedSecretHeader = "X-Stanza-Secret"
+
+	// defaultMaxBodySize is the default limit of the decompressed size of a request body
+	defaultMaxBodySize = 20 * 1024 * 1024
+)
+
 func init() {
 	operator.Register("forward_input", func() operator.Builder { return NewForwardInputConfig("") })
 }
@@ -21,14 +35,18 @@ func init() {
 func NewForwardInputConfig(operatorID string) *ForwardInputConfig {
 	return &ForwardInputConfig{
 		InputConfig: helper.NewInputConfig(operatorID, "stdin"),
+		MaxBodySize: defaultMaxBodySize,
 	}
 }
 
 // ForwardInputConfig is the configuration of a forward input operator
 type ForwardInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
-	ListenAddress      string     `json:"listen_address" yaml:"listen_address"`
-	TLS                *TLSConfig `json:"tls"            yaml:"tls"`
+	ListenAddress      string          `json:"listen_address"          yaml:"listen_address"`
+	TLS                *TLSConfig      `json:"tls"                     yaml:"tls"`
+	SharedSecret       string          `json:"shared_secret,omitempty" yaml:"shared_secret,omitempty"`
+	BearerToken        string          `json:"bearer_token,omitempty"  yaml:"bearer_token,omitempty"`
+	MaxBodySize        helper.ByteSize `json:"max_body_size,omitempty" yaml:"max_body_size,omitempty"`
 }
 
 // TLSConfig is a configuration struct for forward input TLS
@@ -44,9 +62,20 @@ func (c *ForwardInputConfig) Build(context operator.BuildContext) ([]operator.Op
 		return nil, err
 	}
 
+	if c.SharedSecret != "" && c.BearerToken != "" {
+		return nil, fmt.Errorf("only one of 'shared_secret' or 'bearer_token' can be set")
+	}
+
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = defaultMaxBodySize
+	}
+
 	forwardInput := &ForwardInput{
 		InputOperator: inputOperator,
 		tls:           c.TLS,
+		sharedSecret:  c.SharedSecret,
+		bearerToken:   c.BearerToken,
+		maxBodySize:   int64(c.MaxBodySize),
 	}
 
 	forwardInput.srv = &http.Server{
@@ -61,9 +90,12 @@ func (c *ForwardInputConfig) Build(context operator.BuildContext) ([]operator.Op
 type ForwardInput struct {
 	helper.InputOperator
 
-	srv *http.Server
-	ln  net.Listener
-	tls *TLSConfig
+	srv          *http.Server
+	ln           net.Listener
+	tls          *TLSConfig
+	sharedSecret string
+	bearerToken  string
+	maxBodySize  int64
 }
 
 // Start will start generating log entries.
@@ -101,10 +133,27 @@ func (f *ForwardInput) Stop() error {
 }
 
 func (f *ForwardInput) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
-	dec := json.NewDecoder(req.Body)
+	if !f.authorized(req) {
+		wr.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := decompressBody(req, f.maxBodySize)
+	if err != nil {
+		f.Debugw("Failed to read request body", zap.Error(err))
+		wr.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
 
 	var entries []*entry.Entry
 	if err := dec.Decode(&entries); err != nil {
+		if _, ok := err.(helper.SizeLimitError); ok {
+			wr.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
 		wr.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -113,3 +162,53 @@ func (f *ForwardInput) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
 		f.Write(req.Context(), entry)
 	}
 }
+
+// authorized returns true if the request has the configured credentials
+func (f *ForwardInput) authorized(req *http.Request) bool {
+	switch {
+	case f.sharedSecret != "":
+		return secureCompare(req.Header.Get(sharedSecretHeader), f.sharedSecret)
+	case f.bearerToken != "":
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return false
+		}
+		return secureCompare(strings.TrimPrefix(auth, "Bearer "), f.bearerToken)
+	default:
+		return true
+	}
+}
+
+func secureCompare(actual, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+// decompressBody returns a reader for the request body according to its Content-Encoding.
+// The decompressed size is limited to maxBodySize, whether or not the body is compressed.
+func decompressBody(req *http.Request, maxBodySize int64) (io.ReadCloser, error) {
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return limitedReadCloser{helper.NewLimitedReader(req.Body, maxBodySize), req.Body}, nil
+	case "gzip":
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return limitedReadCloser{helper.NewLimitedReader(gz, maxBodySize), gz}, nil
+	case "zstd":
+		dec, err := zstd.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		rc := dec.IOReadCloser()
+		return limitedReadCloser{helper.NewLimitedReader(rc, maxBodySize), rc}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+}
+
+// limitedReadCloser limits the size read from a body reader, and closes it
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
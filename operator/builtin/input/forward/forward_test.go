@@ -2,6 +2,7 @@ package forward
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -10,9 +11,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/operator"
 	"github.com/observiq/stanza/testutil"
@@ -120,6 +123,225 @@ func TestForwardInputTLS(t *testing.T) {
 	}
 }
 
+func encodeTestEntries(t *testing.T, records ...string) []byte {
+	var entries []*entry.Entry
+	for _, record := range records {
+		e := entry.New()
+		e.Record = record
+		entries = append(entries, e)
+	}
+	b, err := json.Marshal(entries)
+	require.NoError(t, err)
+	return b
+}
+
+func postEntries(t *testing.T, url string, body []byte, header map[string]string) int {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	require.NoError(t, err)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestForwardInputBuildMultipleAuth(t *testing.T) {
+	cfg := NewForwardInputConfig("test")
+	cfg.SharedSecret = "secret"
+	cfg.BearerToken = "token"
+	_, err := cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+}
+
+func TestForwardInputAuth(t *testing.T) {
+	cases := []struct {
+		name           string
+		modify         func(*ForwardInputConfig)
+		header         map[string]string
+		expectedStatus int
+	}{
+		{
+			"SharedSecret",
+			func(cfg *ForwardInputConfig) { cfg.SharedSecret = "secret" },
+			map[string]string{sharedSecretHeader: "secret"},
+			http.StatusOK,
+		},
+		{
+			"WrongSharedSecret",
+			func(cfg *ForwardInputConfig) { cfg.SharedSecret = "secret" },
+			map[string]string{sharedSecretHeader: "wrong"},
+			http.StatusUnauthorized,
+		},
+		{
+			"MissingSharedSecret",
+			func(cfg *ForwardInputConfig) { cfg.SharedSecret = "secret" },
+			nil,
+			http.StatusUnauthorized,
+		},
+		{
+			"BearerToken",
+			func(cfg *ForwardInputConfig) { cfg.BearerToken = "token" },
+			map[string]string{"Authorization": "Bearer token"},
+			http.StatusOK,
+		},
+		{
+			"WrongBearerToken",
+			func(cfg *ForwardInputConfig) { cfg.BearerToken = "token" },
+			map[string]string{"Authorization": "Bearer wrong"},
+			http.StatusUnauthorized,
+		},
+		{
+			"BearerTokenWithoutScheme",
+			func(cfg *ForwardInputConfig) { cfg.BearerToken = "token" },
+			map[string]string{"Authorization": "token"},
+			http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewForwardInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			forwardInput := ops[0].(*ForwardInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, forwardInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, forwardInput.Start())
+			defer forwardInput.Stop()
+
+			url := fmt.Sprintf("http://%s", forwardInput.ln.Addr().String())
+
+			status := postEntries(t, url, encodeTestEntries(t, "test"), tc.header)
+			require.Equal(t, tc.expectedStatus, status)
+
+			if tc.expectedStatus == http.StatusOK {
+				fake.ExpectRecord(t, "test")
+			} else {
+				fake.ExpectNoEntry(t, 100*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestForwardInputCompression(t *testing.T) {
+	body := encodeTestEntries(t, "test1", "test2")
+
+	var gzipBody bytes.Buffer
+	gz := gzip.NewWriter(&gzipBody)
+	_, err := gz.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	zstdBody := enc.EncodeAll(body, nil)
+
+	cases := []struct {
+		encoding       string
+		body           []byte
+		expectedStatus int
+	}{
+		{"", body, http.StatusOK},
+		{"gzip", gzipBody.Bytes(), http.StatusOK},
+		{"zstd", zstdBody, http.StatusOK},
+		{"br", body, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.encoding, func(t *testing.T) {
+			cfg := NewForwardInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			forwardInput := ops[0].(*ForwardInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, forwardInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, forwardInput.Start())
+			defer forwardInput.Stop()
+
+			url := fmt.Sprintf("http://%s", forwardInput.ln.Addr().String())
+
+			status := postEntries(t, url, tc.body, map[string]string{"Content-Encoding": tc.encoding})
+			require.Equal(t, tc.expectedStatus, status)
+
+			if tc.expectedStatus == http.StatusOK {
+				fake.ExpectRecord(t, "test1")
+				fake.ExpectRecord(t, "test2")
+			} else {
+				fake.ExpectNoEntry(t, 100*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestForwardInputMaxBodySize(t *testing.T) {
+	body := encodeTestEntries(t, strings.Repeat("a", 2048))
+
+	var gzipBody bytes.Buffer
+	gz := gzip.NewWriter(&gzipBody)
+	_, err := gz.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	zstdBody := enc.EncodeAll(body, nil)
+
+	cases := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"uncompressed", "", body},
+		{"gzip", "gzip", gzipBody.Bytes()},
+		{"zstd", "zstd", zstdBody},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewForwardInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.MaxBodySize = 1024
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			forwardInput := ops[0].(*ForwardInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, forwardInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, forwardInput.Start())
+			defer forwardInput.Stop()
+
+			url := fmt.Sprintf("http://%s", forwardInput.ln.Addr().String())
+
+			headers := map[string]string{}
+			if tc.encoding != "" {
+				require.Less(t, len(tc.body), 1024)
+				headers["Content-Encoding"] = tc.encoding
+			}
+			status := postEntries(t, url, tc.body, headers)
+			require.Equal(t, http.StatusRequestEntityTooLarge, status)
+			fake.ExpectNoEntry(t, 100*time.Millisecond)
+		})
+	}
+}
+
 func createCertFiles(t *testing.T) (cert, key string) {
 	tempDir := testutil.NewTempDir(t)
 
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/observiq/stanza/operator/helper"
+)
+
+// jsonContentTypes are the content types that are parsed as a stream of
+// JSON values, which covers both NDJSON and JSON arrays
+var jsonContentTypes = map[string]bool{
+	"application/json":     true,
+	"application/x-ndjson": true,
+	"application/ndjson":   true,
+	"application/jsonl":    true,
+}
+
+// parseBody returns the values of a request body. JSON bodies may contain any
+// number of JSON values, and each element of a top level array is a separate
+// value. All other bodies are parsed as text, with one value per line.
+func parseBody(contentType string, body []byte) ([]interface{}, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if jsonContentTypes[mediaType] {
+		return parseJSON(body)
+	}
+	return parseText(body), nil
+}
+
+func parseJSON(body []byte) ([]interface{}, error) {
+	var values []interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var value interface{}
+		err := dec.Decode(&value)
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid json: %s", err)
+		}
+
+		if arr, ok := value.([]interface{}); ok {
+			values = append(values, arr...)
+		} else {
+			values = append(values, value)
+		}
+	}
+}
+
+func parseText(body []byte) []interface{} {
+	var values []interface{}
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		values = append(values, string(line))
+	}
+	return values
+}
+
+// unsupportedEncodingError is returned for an unknown Content-Encoding
+type unsupportedEncodingError string
+
+func (e unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding '%s'", string(e))
+}
+
+// readBody reads the decompressed request body. Both the compressed and
+// decompressed sizes are limited to the maximum body size.
+func readBody(req *http.Request, maxBodySize int64) ([]byte, error) {
+	var body io.Reader = helper.NewLimitedReader(req.Body, maxBodySize)
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = helper.NewLimitedReader(gz, maxBodySize)
+	default:
+		return nil, unsupportedEncodingError(encoding)
+	}
+
+	return ioutil.ReadAll(body)
+}
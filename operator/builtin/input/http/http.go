@@ -0,0 +1,310 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	defaultListenAddress = ":8080"
+	defaultPath          = "/"
+	defaultMaxBodySize   = 10 * 1024 * 1024
+	defaultMaxQueueSize  = 10000
+
+	// retryAfterSeconds is suggested to clients when the queue is full
+	retryAfterSeconds = "1"
+)
+
+func init() {
+	operator.Register("http_input", func() operator.Builder { return NewHTTPInputConfig("") })
+}
+
+// NewHTTPInputConfig creates a new http input config with default values
+func NewHTTPInputConfig(operatorID string) *HTTPInputConfig {
+	return &HTTPInputConfig{
+		InputConfig:   helper.NewInputConfig(operatorID, "http_input"),
+		ListenAddress: defaultListenAddress,
+		Path:          defaultPath,
+		MaxBodySize:   defaultMaxBodySize,
+		MaxQueueSize:  defaultMaxQueueSize,
+	}
+}
+
+// HTTPInputConfig is the configuration of an http input operator
+type HTTPInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	ListenAddress string                 `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	Path          string                 `json:"path,omitempty"           yaml:"path,omitempty"`
+	TLS           helper.TLSServerConfig `json:"tls,omitempty"            yaml:"tls,omitempty"`
+	BearerToken   string                 `json:"bearer_token,omitempty"   yaml:"bearer_token,omitempty"`
+	BasicAuth     *BasicAuthConfig       `json:"basic_auth,omitempty"     yaml:"basic_auth,omitempty"`
+	HeaderLabels  map[string]string      `json:"header_labels,omitempty"  yaml:"header_labels,omitempty"`
+	MaxBodySize   helper.ByteSize        `json:"max_body_size,omitempty"  yaml:"max_body_size,omitempty"`
+	MaxQueueSize  int                    `json:"max_queue_size,omitempty" yaml:"max_queue_size,omitempty"`
+}
+
+// BasicAuthConfig is the configuration for basic authentication
+type BasicAuthConfig struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// Build will build an http input operator
+func (c HTTPInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ListenAddress == "" {
+		c.ListenAddress = defaultListenAddress
+	}
+	if _, err := net.ResolveTCPAddr("tcp", c.ListenAddress); err != nil {
+		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
+	}
+
+	if c.Path == "" {
+		c.Path = defaultPath
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return nil, fmt.Errorf("path '%s' must start with '/'", c.Path)
+	}
+
+	if c.BearerToken != "" && c.BasicAuth != nil {
+		return nil, fmt.Errorf("only one of 'bearer_token' or 'basic_auth' can be set")
+	}
+	if c.BasicAuth != nil && c.BasicAuth.Username == "" {
+		return nil, fmt.Errorf("missing required parameter 'username' for basic_auth")
+	}
+
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = defaultMaxBodySize
+	}
+	if c.MaxQueueSize <= 0 {
+		c.MaxQueueSize = defaultMaxQueueSize
+	}
+
+	tlsConfig, err := c.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	httpInput := &HTTPInput{
+		InputOperator: inputOperator,
+		address:       c.ListenAddress,
+		path:          c.Path,
+		tlsConfig:     tlsConfig,
+		bearerToken:   c.BearerToken,
+		basicAuth:     c.BasicAuth,
+		headerLabels:  c.HeaderLabels,
+		maxBodySize:   int64(c.MaxBodySize),
+		maxQueueSize:  int64(c.MaxQueueSize),
+	}
+	return []operator.Operator{httpInput}, nil
+}
+
+// HTTPInput is an operator that receives entries from HTTP requests
+type HTTPInput struct {
+	helper.InputOperator
+	address      string
+	path         string
+	tlsConfig    *tls.Config
+	bearerToken  string
+	basicAuth    *BasicAuthConfig
+	headerLabels map[string]string
+	maxBodySize  int64
+	maxQueueSize int64
+
+	listener net.Listener
+	server   *http.Server
+	queue    chan *entry.Entry
+	sem      *semaphore.Weighted
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Start will start listening for requests
+func (h *HTTPInput) Start() error {
+	listener, err := net.Listen("tcp", h.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on interface: %w", err)
+	}
+	if h.tlsConfig != nil {
+		listener = tls.NewListener(listener, h.tlsConfig)
+	}
+	h.listener = listener
+
+	// Entries are queued before being written, so that requests can be
+	// rejected rather than blocked when the pipeline is not keeping up
+	h.queue = make(chan *entry.Entry, h.maxQueueSize)
+	h.sem = semaphore.NewWeighted(h.maxQueueSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.goWriteEntries(ctx)
+
+	h.server = &http.Server{Handler: h}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := h.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			h.Errorw("Serve error", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// goWriteEntries will write queued entries until the queue is closed
+func (h *HTTPInput) goWriteEntries(ctx context.Context) {
+	h.wg.Add(1)
+
+	go func() {
+		defer h.wg.Done()
+		for e := range h.queue {
+			h.Write(ctx, e)
+			h.sem.Release(1)
+		}
+	}()
+}
+
+// Stop will stop listening for requests
+func (h *HTTPInput) Stop() error {
+	// Shutdown waits for active requests, so nothing is queued after it returns
+	err := h.server.Shutdown(context.Background())
+	close(h.queue)
+	h.wg.Wait()
+	h.cancel()
+	return err
+}
+
+// ServeHTTP handles a request containing entries
+func (h *HTTPInput) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != h.path {
+		http.NotFound(wr, req)
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		wr.Header().Set("Allow", http.MethodPost)
+		http.Error(wr, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(req) {
+		if h.basicAuth != nil {
+			wr.Header().Set("WWW-Authenticate", `Basic realm="stanza"`)
+		}
+		http.Error(wr, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := readBody(req, h.maxBodySize)
+	if err != nil {
+		h.Debugw("Failed to read request body", zap.Error(err))
+		switch err.(type) {
+		case unsupportedEncodingError:
+			http.Error(wr, err.Error(), http.StatusUnsupportedMediaType)
+		case helper.SizeLimitError:
+			http.Error(wr, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(wr, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	values, err := parseBody(req.Header.Get("Content-Type"), body)
+	if err != nil {
+		h.Debugw("Failed to parse request body", zap.Error(err))
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.newEntries(req, values)
+	if err != nil {
+		h.Errorw("Failed to create entries", zap.Error(err))
+		http.Error(wr, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if int64(len(entries)) > h.maxQueueSize {
+		http.Error(wr, fmt.Sprintf("request contains %d entries, more than the maximum of %d", len(entries), h.maxQueueSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Either all of the entries of a request are queued, or none are
+	if !h.sem.TryAcquire(int64(len(entries))) {
+		h.Debugw("Queue is full, rejecting request", "entries", len(entries))
+		wr.Header().Set("Retry-After", retryAfterSeconds)
+		http.Error(wr, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	for _, e := range entries {
+		h.queue <- e
+	}
+
+	wr.WriteHeader(http.StatusOK)
+}
+
+// newEntries creates an entry for each value, with labels from the request headers
+func (h *HTTPInput) newEntries(req *http.Request, values []interface{}) ([]*entry.Entry, error) {
+	labels := make(map[string]string, len(h.headerLabels))
+	for header, label := range h.headerLabels {
+		if value := req.Header.Get(header); value != "" {
+			labels[label] = value
+		}
+	}
+
+	entries := make([]*entry.Entry, 0, len(values))
+	for _, value := range values {
+		e, err := h.NewEntry(value)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range labels {
+			e.AddLabel(k, v)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// authorized returns true if the request has the configured credentials
+func (h *HTTPInput) authorized(req *http.Request) bool {
+	switch {
+	case h.bearerToken != "":
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return false
+		}
+		return secureCompare(strings.TrimPrefix(auth, "Bearer "), h.bearerToken)
+	case h.basicAuth != nil:
+		username, password, ok := req.BasicAuth()
+		if !ok {
+			return false
+		}
+		// Compare both, so that the response time does not reveal which one is wrong
+		usernameOK := secureCompare(username, h.basicAuth.Username)
+		passwordOK := secureCompare(password, h.basicAuth.Password)
+		return usernameOK && passwordOK
+	default:
+		return true
+	}
+}
+
+func secureCompare(actual, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
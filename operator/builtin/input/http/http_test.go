@@ -0,0 +1,369 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRequest(t *testing.T, httpInput *HTTPInput, path, contentType string, body []byte) *http.Request {
+	url := fmt.Sprintf("http://%s%s", httpInput.listener.Addr().String(), path)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func doRequest(t *testing.T, req *http.Request) *http.Response {
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestHTTPInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*HTTPInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *HTTPInputConfig) {}, false},
+		{"InvalidAddress", func(cfg *HTTPInputConfig) { cfg.ListenAddress = "invalid:port" }, true},
+		{"RelativePath", func(cfg *HTTPInputConfig) { cfg.Path = "logs" }, true},
+		{"BearerAndBasic", func(cfg *HTTPInputConfig) {
+			cfg.BearerToken = "token"
+			cfg.BasicAuth = &BasicAuthConfig{Username: "user", Password: "pass"}
+		}, true},
+		{"BasicMissingUsername", func(cfg *HTTPInputConfig) { cfg.BasicAuth = &BasicAuthConfig{Password: "pass"} }, true},
+		{"TLSMissingCertificate", func(cfg *HTTPInputConfig) { cfg.TLS.Enable = true }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewHTTPInputConfig("test")
+			cfg.OutputIDs = []string{"fake"}
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPInputFormats(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    []interface{}
+	}{
+		{
+			"NDJSON",
+			"application/x-ndjson",
+			"{\"message\":\"a\"}\n{\"message\":\"b\"}\n",
+			[]interface{}{map[string]interface{}{"message": "a"}, map[string]interface{}{"message": "b"}},
+		},
+		{
+			"JSONArray",
+			"application/json",
+			`[{"message":"a"},{"message":"b"}]`,
+			[]interface{}{map[string]interface{}{"message": "a"}, map[string]interface{}{"message": "b"}},
+		},
+		{
+			"JSONObject",
+			"application/json; charset=utf-8",
+			`{"message":"a"}`,
+			[]interface{}{map[string]interface{}{"message": "a"}},
+		},
+		{
+			"Text",
+			"text/plain",
+			"line 1\r\nline 2\n\nline 3",
+			[]interface{}{"line 1", "line 2", "line 3"},
+		},
+		{
+			"NoContentType",
+			"",
+			"line 1\n",
+			[]interface{}{"line 1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewHTTPInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			httpInput := ops[0].(*HTTPInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, httpInput.Start())
+			defer httpInput.Stop()
+
+			resp := doRequest(t, newTestRequest(t, httpInput, "/", tc.contentType, []byte(tc.body)))
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			for _, record := range tc.expected {
+				fake.ExpectRecord(t, record)
+			}
+			fake.ExpectNoEntry(t, 50*time.Millisecond)
+		})
+	}
+}
+
+func TestHTTPInputGzip(t *testing.T) {
+	cfg := NewHTTPInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	httpInput := ops[0].(*HTTPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, httpInput.Start())
+	defer httpInput.Stop()
+
+	req := newTestRequest(t, httpInput, "/", "text/plain", gzipBytes(t, []byte("compressed")))
+	req.Header.Set("Content-Encoding", "gzip")
+	resp := doRequest(t, req)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	fake.ExpectRecord(t, "compressed")
+}
+
+func TestHTTPInputHeaderLabels(t *testing.T) {
+	cfg := NewHTTPInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+	cfg.HeaderLabels = map[string]string{
+		"X-Source":  "source",
+		"X-Missing": "missing",
+	}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	httpInput := ops[0].(*HTTPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, httpInput.Start())
+	defer httpInput.Stop()
+
+	req := newTestRequest(t, httpInput, "/", "text/plain", []byte("test"))
+	req.Header.Set("X-Source", "app1")
+	resp := doRequest(t, req)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, map[string]string{"source": "app1"}, e.Labels)
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+	}
+}
+
+func TestHTTPInputAuth(t *testing.T) {
+	cases := []struct {
+		name     string
+		modify   func(*HTTPInputConfig)
+		setAuth  func(*http.Request)
+		expected int
+	}{
+		{
+			"BearerValid",
+			func(cfg *HTTPInputConfig) { cfg.BearerToken = "token" },
+			func(req *http.Request) { req.Header.Set("Authorization", "Bearer token") },
+			http.StatusOK,
+		},
+		{
+			"BearerInvalid",
+			func(cfg *HTTPInputConfig) { cfg.BearerToken = "token" },
+			func(req *http.Request) { req.Header.Set("Authorization", "Bearer wrong") },
+			http.StatusUnauthorized,
+		},
+		{
+			"BearerMissing",
+			func(cfg *HTTPInputConfig) { cfg.BearerToken = "token" },
+			func(req *http.Request) {},
+			http.StatusUnauthorized,
+		},
+		{
+			"BasicValid",
+			func(cfg *HTTPInputConfig) { cfg.BasicAuth = &BasicAuthConfig{Username: "user", Password: "pass"} },
+			func(req *http.Request) { req.SetBasicAuth("user", "pass") },
+			http.StatusOK,
+		},
+		{
+			"BasicInvalid",
+			func(cfg *HTTPInputConfig) { cfg.BasicAuth = &BasicAuthConfig{Username: "user", Password: "pass"} },
+			func(req *http.Request) { req.SetBasicAuth("user", "wrong") },
+			http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewHTTPInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			httpInput := ops[0].(*HTTPInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, httpInput.Start())
+			defer httpInput.Stop()
+
+			req := newTestRequest(t, httpInput, "/", "text/plain", []byte("test"))
+			tc.setAuth(req)
+			resp := doRequest(t, req)
+			require.Equal(t, tc.expected, resp.StatusCode)
+
+			if tc.expected == http.StatusOK {
+				fake.ExpectRecord(t, "test")
+			} else {
+				fake.ExpectNoEntry(t, 50*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestHTTPInputErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		encoding    string
+		body        []byte
+		expected    int
+	}{
+		{"WrongPath", http.MethodPost, "/other", "text/plain", "", []byte("test"), http.StatusNotFound},
+		{"WrongMethod", http.MethodGet, "/logs", "text/plain", "", nil, http.StatusMethodNotAllowed},
+		{"InvalidJSON", http.MethodPost, "/logs", "application/json", "", []byte(`{"message":`), http.StatusBadRequest},
+		{"UnsupportedEncoding", http.MethodPost, "/logs", "text/plain", "br", []byte("test"), http.StatusUnsupportedMediaType},
+		{"InvalidGzip", http.MethodPost, "/logs", "text/plain", "gzip", []byte("test"), http.StatusBadRequest},
+		{"TooLarge", http.MethodPost, "/logs", "text/plain", "", bytes.Repeat([]byte("a"), 2048), http.StatusRequestEntityTooLarge},
+		{"TooLargeDecompressed", http.MethodPost, "/logs", "text/plain", "gzip", gzipBytes(t, bytes.Repeat([]byte("a"), 2048)), http.StatusRequestEntityTooLarge},
+		{"TooManyEntries", http.MethodPost, "/logs", "text/plain", "", bytes.Repeat([]byte("a\n"), 11), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewHTTPInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.Path = "/logs"
+			cfg.MaxBodySize = 1024
+			cfg.MaxQueueSize = 10
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			httpInput := ops[0].(*HTTPInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, httpInput.Start())
+			defer httpInput.Stop()
+
+			req := newTestRequest(t, httpInput, tc.path, tc.contentType, tc.body)
+			req.Method = tc.method
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			resp := doRequest(t, req)
+			require.Equal(t, tc.expected, resp.StatusCode)
+			fake.ExpectNoEntry(t, 50*time.Millisecond)
+		})
+	}
+}
+
+func TestHTTPInputQueueFull(t *testing.T) {
+	cfg := NewHTTPInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+	cfg.MaxQueueSize = 2
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	httpInput := ops[0].(*HTTPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, httpInput.Start())
+	defer httpInput.Stop()
+
+	// Simulate a saturated pipeline by filling the queue
+	require.True(t, httpInput.sem.TryAcquire(2))
+
+	resp := doRequest(t, newTestRequest(t, httpInput, "/", "text/plain", []byte("test")))
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, retryAfterSeconds, resp.Header.Get("Retry-After"))
+	fake.ExpectNoEntry(t, 50*time.Millisecond)
+
+	httpInput.sem.Release(2)
+	resp = doRequest(t, newTestRequest(t, httpInput, "/", "text/plain", []byte("test")))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	fake.ExpectRecord(t, "test")
+}
+
+func TestHTTPInputBlockedPipeline(t *testing.T) {
+	cfg := NewHTTPInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+	cfg.MaxQueueSize = 2
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	httpInput := ops[0].(*HTTPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, httpInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, httpInput.Start())
+	defer httpInput.Stop()
+
+	// The fake output blocks once its channel is full, so requests
+	// are eventually rejected because the queue is not drained
+	require.Eventually(t, func() bool {
+		resp := doRequest(t, newTestRequest(t, httpInput, "/", "text/plain", []byte("test")))
+		return resp.StatusCode == http.StatusTooManyRequests
+	}, 5*time.Second, time.Millisecond)
+
+	// Unblock the pipeline before the input is stopped
+	go func() {
+		for range fake.Received {
+		}
+	}()
+}
@@ -7,9 +7,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,9 +39,15 @@ func NewJournaldInputConfig(operatorID string) *JournaldInputConfig {
 type JournaldInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	Directory *string  `json:"directory,omitempty" yaml:"directory,omitempty"`
-	Files     []string `json:"files,omitempty"     yaml:"files,omitempty"`
-	StartAt   string   `json:"start_at,omitempty"  yaml:"start_at,omitempty"`
+	Directory   *string             `json:"directory,omitempty"   yaml:"directory,omitempty"`
+	Files       []string            `json:"files,omitempty"       yaml:"files,omitempty"`
+	StartAt     string              `json:"start_at,omitempty"    yaml:"start_at,omitempty"`
+	Units       []string            `json:"units,omitempty"       yaml:"units,omitempty"`
+	Identifiers []string            `json:"identifiers,omitempty" yaml:"identifiers,omitempty"`
+	Priority    string              `json:"priority,omitempty"    yaml:"priority,omitempty"`
+	Matches     []map[string]string `json:"matches,omitempty"     yaml:"matches,omitempty"`
+	Namespace   string              `json:"namespace,omitempty"   yaml:"namespace,omitempty"`
+	Dmesg       bool                `json:"dmesg,omitempty"       yaml:"dmesg,omitempty"`
 }
 
 // Build will build a journald input operator from the supplied configuration
@@ -75,14 +85,23 @@ func (c JournaldInputConfig) Build(buildContext operator.BuildContext) ([]operat
 		}
 	}
 
+	filterArgs, matchArgs, err := c.filterArgs()
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, filterArgs...)
+
 	journaldInput := &JournaldInput{
 		InputOperator: inputOperator,
 		persist:       helper.NewScopedDBPersister(buildContext.Database, c.ID()),
+		cursorKey:     cursorKey(filterArgs, matchArgs),
 		newCmd: func(ctx context.Context, cursor []byte) cmd {
+			cmdArgs := append([]string{}, args...)
 			if cursor != nil {
-				args = append(args, "--after-cursor", string(cursor))
+				cmdArgs = append(cmdArgs, "--after-cursor", string(cursor))
 			}
-			return exec.CommandContext(ctx, "journalctl", args...) // #nosec - ...
+			cmdArgs = append(cmdArgs, matchArgs...)
+			return exec.CommandContext(ctx, "journalctl", cmdArgs...) // #nosec - ...
 			// journalctl is an executable that is required for this operator to function
 		},
 		json: jsoniter.ConfigFastest,
@@ -90,16 +109,131 @@ func (c JournaldInputConfig) Build(buildContext operator.BuildContext) ([]operat
 	return []operator.Operator{journaldInput}, nil
 }
 
+// matchFieldRegex matches the names of journal fields
+var matchFieldRegex = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// priorities are the names of journal priorities, in order of their levels
+var priorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// filterArgs returns the journalctl options and the match arguments that
+// select the entries to read
+func (c JournaldInputConfig) filterArgs() ([]string, []string, error) {
+	args := make([]string, 0, 10)
+
+	if c.Namespace != "" {
+		args = append(args, "--namespace", c.Namespace)
+	}
+
+	if c.Dmesg {
+		args = append(args, "--dmesg")
+	}
+
+	for _, unit := range c.Units {
+		if unit == "" {
+			return nil, nil, errors.New("`units` must not contain empty units")
+		}
+		args = append(args, "--unit", unit)
+	}
+
+	for _, identifier := range c.Identifiers {
+		if identifier == "" {
+			return nil, nil, errors.New("`identifiers` must not contain empty identifiers")
+		}
+		args = append(args, "--identifier", identifier)
+	}
+
+	if c.Priority != "" {
+		priority, err := parsePriority(c.Priority)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, "--priority", priority)
+	}
+
+	// The fields of a match are ANDed, and the matches are ORed
+	matchArgs := make([]string, 0, len(c.Matches)*2)
+	for i, match := range c.Matches {
+		if len(match) == 0 {
+			return nil, nil, errors.New("`matches` must not contain empty matches")
+		}
+		if i > 0 {
+			matchArgs = append(matchArgs, "+")
+		}
+
+		fields := make([]string, 0, len(match))
+		for field := range match {
+			if !matchFieldRegex.MatchString(field) {
+				return nil, nil, fmt.Errorf("invalid match field '%s', must contain only uppercase letters, digits and underscores", field)
+			}
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			matchArgs = append(matchArgs, field+"="+match[field])
+		}
+	}
+
+	return args, matchArgs, nil
+}
+
+// parsePriority parses a priority, or a range of priorities separated by `..`,
+// where each priority is either a name or a level from 0 to 7
+func parsePriority(priority string) (string, error) {
+	bounds := strings.Split(priority, "..")
+	if len(bounds) > 2 {
+		return "", fmt.Errorf("invalid priority '%s'", priority)
+	}
+
+	levels := make([]string, 0, len(bounds))
+	for _, bound := range bounds {
+		level, err := priorityLevel(bound)
+		if err != nil {
+			return "", err
+		}
+		levels = append(levels, strconv.Itoa(level))
+	}
+	return strings.Join(levels, ".."), nil
+}
+
+func priorityLevel(priority string) (int, error) {
+	for level, name := range priorities {
+		if priority == name {
+			return level, nil
+		}
+	}
+
+	level, err := strconv.Atoi(priority)
+	if err != nil || level < 0 || level >= len(priorities) {
+		return 0, fmt.Errorf("invalid priority '%s', must be a level from 0 to 7 or one of %s", priority, strings.Join(priorities, ", "))
+	}
+	return level, nil
+}
+
+// cursorKey returns the key of the cursor of a set of filters, so that
+// changing the filters does not resume from the cursor of other filters
+func cursorKey(filterArgs, matchArgs []string) string {
+	if len(filterArgs) == 0 && len(matchArgs) == 0 {
+		return lastReadCursorKey
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.Join(filterArgs, "\x00")))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(strings.Join(matchArgs, "\x00")))
+	return fmt.Sprintf("%s.%016x", lastReadCursorKey, h.Sum64())
+}
+
 // JournaldInput is an operator that process logs using journald
 type JournaldInput struct {
 	helper.InputOperator
 
 	newCmd func(ctx context.Context, cursor []byte) cmd
 
-	persist helper.Persister
-	json    jsoniter.API
-	cancel  context.CancelFunc
-	wg      sync.WaitGroup
+	persist   helper.Persister
+	cursorKey string
+	json      jsoniter.API
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
 }
 
 type cmd interface {
@@ -120,7 +254,7 @@ func (operator *JournaldInput) Start() error {
 	}
 
 	// Start from a cursor if there is a saved offset
-	cursor := operator.persist.Get(lastReadCursorKey)
+	cursor := operator.persist.Get(operator.cursorKey)
 
 	// Start journalctl
 	cmd := operator.newCmd(ctx, cursor)
@@ -169,7 +303,7 @@ func (operator *JournaldInput) Start() error {
 				operator.Warnw("Failed to parse journal entry", zap.Error(err))
 				continue
 			}
-			operator.persist.Set(lastReadCursorKey, []byte(cursor))
+			operator.persist.Set(operator.cursorKey, []byte(cursor))
 			operator.Write(ctx, entry)
 		}
 	}()
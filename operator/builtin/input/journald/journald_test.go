@@ -7,6 +7,7 @@ import (
 	"context"
 	"io"
 	"io/ioutil"
+	"os/exec"
 	"testing"
 	"time"
 
@@ -99,3 +100,145 @@ func TestInputJournald(t *testing.T) {
 		require.FailNow(t, "Timed out waiting for entry to be read")
 	}
 }
+
+func TestInputJournaldArgs(t *testing.T) {
+	directory := "/var/log/journal"
+	cases := []struct {
+		name     string
+		modify   func(*JournaldInputConfig)
+		expected []string
+	}{
+		{
+			"Default",
+			func(cfg *JournaldInputConfig) {},
+			[]string{"--utc", "--output=json", "--follow"},
+		},
+		{
+			"Directory",
+			func(cfg *JournaldInputConfig) { cfg.Directory = &directory },
+			[]string{"--utc", "--output=json", "--follow", "--directory", directory},
+		},
+		{
+			"Units",
+			func(cfg *JournaldInputConfig) { cfg.Units = []string{"ssh", "cron.service"} },
+			[]string{"--utc", "--output=json", "--follow", "--unit", "ssh", "--unit", "cron.service"},
+		},
+		{
+			"Identifiers",
+			func(cfg *JournaldInputConfig) { cfg.Identifiers = []string{"sudo"} },
+			[]string{"--utc", "--output=json", "--follow", "--identifier", "sudo"},
+		},
+		{
+			"PriorityName",
+			func(cfg *JournaldInputConfig) { cfg.Priority = "warning" },
+			[]string{"--utc", "--output=json", "--follow", "--priority", "4"},
+		},
+		{
+			"PriorityRange",
+			func(cfg *JournaldInputConfig) { cfg.Priority = "crit..5" },
+			[]string{"--utc", "--output=json", "--follow", "--priority", "2..5"},
+		},
+		{
+			"Matches",
+			func(cfg *JournaldInputConfig) {
+				cfg.Matches = []map[string]string{
+					{"_SYSTEMD_UNIT": "ssh.service", "_UID": "0"},
+					{"_TRANSPORT": "kernel"},
+				}
+			},
+			[]string{"--utc", "--output=json", "--follow", "_SYSTEMD_UNIT=ssh.service", "_UID=0", "+", "_TRANSPORT=kernel"},
+		},
+		{
+			"Namespace",
+			func(cfg *JournaldInputConfig) { cfg.Namespace = "app" },
+			[]string{"--utc", "--output=json", "--follow", "--namespace", "app"},
+		},
+		{
+			"Dmesg",
+			func(cfg *JournaldInputConfig) {
+				cfg.Dmesg = true
+				cfg.StartAt = "beginning"
+			},
+			[]string{"--utc", "--output=json", "--follow", "--no-tail", "--dmesg"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewJournaldInputConfig("my_journald_input")
+			tc.modify(cfg)
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+
+			cmd := ops[0].(*JournaldInput).newCmd(context.Background(), nil).(*exec.Cmd)
+			require.Equal(t, tc.expected, cmd.Args[1:])
+		})
+	}
+}
+
+func TestInputJournaldArgsCursor(t *testing.T) {
+	cfg := NewJournaldInputConfig("my_journald_input")
+	cfg.Units = []string{"ssh"}
+	cfg.Matches = []map[string]string{{"_UID": "0"}}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	// Match arguments follow all options
+	cmd := ops[0].(*JournaldInput).newCmd(context.Background(), []byte("s=1")).(*exec.Cmd)
+	expected := []string{"--utc", "--output=json", "--follow", "--unit", "ssh", "--after-cursor", "s=1", "_UID=0"}
+	require.Equal(t, expected, cmd.Args[1:])
+
+	// The arguments are not changed by previous commands
+	cmd = ops[0].(*JournaldInput).newCmd(context.Background(), []byte("s=2")).(*exec.Cmd)
+	expected = []string{"--utc", "--output=json", "--follow", "--unit", "ssh", "--after-cursor", "s=2", "_UID=0"}
+	require.Equal(t, expected, cmd.Args[1:])
+}
+
+func TestInputJournaldInvalidFilters(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*JournaldInputConfig)
+	}{
+		{"EmptyUnit", func(cfg *JournaldInputConfig) { cfg.Units = []string{""} }},
+		{"EmptyIdentifier", func(cfg *JournaldInputConfig) { cfg.Identifiers = []string{""} }},
+		{"InvalidPriority", func(cfg *JournaldInputConfig) { cfg.Priority = "loud" }},
+		{"PriorityOutOfRange", func(cfg *JournaldInputConfig) { cfg.Priority = "8" }},
+		{"InvalidPriorityRange", func(cfg *JournaldInputConfig) { cfg.Priority = "0..3..5" }},
+		{"EmptyMatch", func(cfg *JournaldInputConfig) { cfg.Matches = []map[string]string{{}} }},
+		{"LowercaseMatchField", func(cfg *JournaldInputConfig) { cfg.Matches = []map[string]string{{"_uid": "0"}} }},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewJournaldInputConfig("my_journald_input")
+			tc.modify(cfg)
+
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestInputJournaldCursorKey(t *testing.T) {
+	build := func(modify func(*JournaldInputConfig)) string {
+		cfg := NewJournaldInputConfig("my_journald_input")
+		modify(cfg)
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		return ops[0].(*JournaldInput).cursorKey
+	}
+
+	// Without filters, the cursor of previous versions is used
+	require.Equal(t, lastReadCursorKey, build(func(cfg *JournaldInputConfig) {}))
+	require.Equal(t, lastReadCursorKey, build(func(cfg *JournaldInputConfig) { cfg.StartAt = "beginning" }))
+
+	ssh := build(func(cfg *JournaldInputConfig) { cfg.Units = []string{"ssh"} })
+	cron := build(func(cfg *JournaldInputConfig) { cfg.Units = []string{"cron"} })
+	match := build(func(cfg *JournaldInputConfig) { cfg.Matches = []map[string]string{{"_SYSTEMD_UNIT": "ssh"}} })
+	require.NotEqual(t, lastReadCursorKey, ssh)
+	require.NotEqual(t, ssh, cron)
+	require.NotEqual(t, ssh, match)
+	require.Equal(t, ssh, build(func(cfg *JournaldInputConfig) { cfg.Units = []string{"ssh"} }))
+}
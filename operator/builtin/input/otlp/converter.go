@@ -0,0 +1,218 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+const (
+	traceIDLabel    = "trace_id"
+	spanIDLabel     = "span_id"
+	traceFlagsLabel = "trace_flags"
+
+	// traceFlagsMask selects the W3C trace flags from the flags of a log record
+	traceFlagsMask = 0xff
+
+	traceIDLen = 16
+	spanIDLen  = 8
+)
+
+// conversion is the result of converting an export request to entries
+type conversion struct {
+	entries  []*entry.Entry
+	rejected int64
+	errMsg   string
+}
+
+// reject records a log record that could not be converted
+func (c *conversion) reject(err error) {
+	if c.rejected == 0 {
+		c.errMsg = err.Error()
+	}
+	c.rejected++
+}
+
+// convert converts the log records of an export request to entries. Log
+// records that cannot be converted are counted as rejected, with the error
+// message of the first rejection.
+func (o *OTLPInput) convert(request *collogs.ExportLogsServiceRequest) *conversion {
+	result := &conversion{}
+	for _, rls := range request.GetResourceLogs() {
+		resource := attributesToStrings(rls.GetResource().GetAttributes())
+		for _, ills := range rls.GetInstrumentationLibraryLogs() {
+			for _, lr := range ills.GetLogs() {
+				e, err := o.convertLogRecord(lr, resource)
+				if err != nil {
+					result.reject(err)
+					continue
+				}
+				result.entries = append(result.entries, e)
+			}
+		}
+	}
+	return result
+}
+
+// convertLogRecord converts a single log record to an entry
+func (o *OTLPInput) convertLogRecord(lr *logs.LogRecord, resource map[string]string) (*entry.Entry, error) {
+	if id := lr.GetTraceId(); len(id) != 0 && len(id) != traceIDLen {
+		return nil, fmt.Errorf("invalid trace_id length %d", len(id))
+	}
+	if id := lr.GetSpanId(); len(id) != 0 && len(id) != spanIDLen {
+		return nil, fmt.Errorf("invalid span_id length %d", len(id))
+	}
+
+	var record interface{}
+	body := anyValueToInterface(lr.GetBody())
+	if o.attributes == attributesRecord {
+		record = map[string]interface{}{
+			"body":       body,
+			"attributes": keyValuesToMap(lr.GetAttributes()),
+		}
+	} else {
+		record = body
+	}
+
+	e, err := o.NewEntry(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if lr.GetTimeUnixNano() != 0 {
+		e.Timestamp = time.Unix(0, int64(lr.GetTimeUnixNano()))
+	}
+	e.Severity = convertSeverity(lr.GetSeverityNumber())
+	e.SeverityText = lr.GetSeverityText()
+
+	for k, v := range resource {
+		e.AddResourceKey(k, v)
+	}
+
+	if o.attributes != attributesRecord {
+		for k, v := range attributesToStrings(lr.GetAttributes()) {
+			e.AddLabel(k, v)
+		}
+	}
+
+	if id := lr.GetTraceId(); len(id) != 0 {
+		e.AddLabel(traceIDLabel, hex.EncodeToString(id))
+	}
+	if id := lr.GetSpanId(); len(id) != 0 {
+		e.AddLabel(spanIDLabel, hex.EncodeToString(id))
+	}
+	if flags := lr.GetFlags() & traceFlagsMask; flags != 0 {
+		e.AddLabel(traceFlagsLabel, fmt.Sprintf("%02x", flags))
+	}
+
+	return e, nil
+}
+
+// anyValueToInterface converts an attribute value to its go equivalent
+func anyValueToInterface(value *common.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_BoolValue:
+		return v.BoolValue
+	case *common.AnyValue_IntValue:
+		return v.IntValue
+	case *common.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *common.AnyValue_BytesValue:
+		return v.BytesValue
+	case *common.AnyValue_ArrayValue:
+		values := v.ArrayValue.GetValues()
+		arr := make([]interface{}, 0, len(values))
+		for _, item := range values {
+			arr = append(arr, anyValueToInterface(item))
+		}
+		return arr
+	case *common.AnyValue_KvlistValue:
+		return keyValuesToMap(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+func keyValuesToMap(kvs []*common.KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[kv.GetKey()] = anyValueToInterface(kv.GetValue())
+	}
+	return m
+}
+
+// attributesToStrings converts attributes to a string map, such as labels or
+// resource. Arrays and maps are encoded as json.
+func attributesToStrings(kvs []*common.KeyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.GetKey()] = anyValueToString(kv.GetValue())
+	}
+	return m
+}
+
+func anyValueToString(value *common.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *common.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *common.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		b, err := json.Marshal(anyValueToInterface(value))
+		if err != nil {
+			return "" // not expected to ever happen
+		}
+		return string(b)
+	}
+}
+
+// severityLevels maps each OTLP severity number to a stanza severity
+var severityLevels = map[logs.SeverityNumber]entry.Severity{
+	logs.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED: entry.Default,
+	logs.SeverityNumber_SEVERITY_NUMBER_TRACE:       entry.Trace,
+	logs.SeverityNumber_SEVERITY_NUMBER_TRACE2:      entry.Trace2,
+	logs.SeverityNumber_SEVERITY_NUMBER_TRACE3:      entry.Trace3,
+	logs.SeverityNumber_SEVERITY_NUMBER_TRACE4:      entry.Trace4,
+	logs.SeverityNumber_SEVERITY_NUMBER_DEBUG:       entry.Debug,
+	logs.SeverityNumber_SEVERITY_NUMBER_DEBUG2:      entry.Debug2,
+	logs.SeverityNumber_SEVERITY_NUMBER_DEBUG3:      entry.Debug3,
+	logs.SeverityNumber_SEVERITY_NUMBER_DEBUG4:      entry.Debug4,
+	logs.SeverityNumber_SEVERITY_NUMBER_INFO:        entry.Info,
+	logs.SeverityNumber_SEVERITY_NUMBER_INFO2:       entry.Info2,
+	logs.SeverityNumber_SEVERITY_NUMBER_INFO3:       entry.Info3,
+	logs.SeverityNumber_SEVERITY_NUMBER_INFO4:       entry.Info4,
+	logs.SeverityNumber_SEVERITY_NUMBER_WARN:        entry.Warning,
+	logs.SeverityNumber_SEVERITY_NUMBER_WARN2:       entry.Warning2,
+	logs.SeverityNumber_SEVERITY_NUMBER_WARN3:       entry.Warning3,
+	logs.SeverityNumber_SEVERITY_NUMBER_WARN4:       entry.Warning4,
+	logs.SeverityNumber_SEVERITY_NUMBER_ERROR:       entry.Error,
+	logs.SeverityNumber_SEVERITY_NUMBER_ERROR2:      entry.Error2,
+	logs.SeverityNumber_SEVERITY_NUMBER_ERROR3:      entry.Error3,
+	logs.SeverityNumber_SEVERITY_NUMBER_ERROR4:      entry.Error4,
+	logs.SeverityNumber_SEVERITY_NUMBER_FATAL:       entry.Emergency,
+	logs.SeverityNumber_SEVERITY_NUMBER_FATAL2:      entry.Emergency2,
+	logs.SeverityNumber_SEVERITY_NUMBER_FATAL3:      entry.Emergency3,
+	logs.SeverityNumber_SEVERITY_NUMBER_FATAL4:      entry.Emergency4,
+}
+
+func convertSeverity(s logs.SeverityNumber) entry.Severity {
+	if sev, ok := severityLevels[s]; ok {
+		return sev
+	}
+	return entry.Default
+}
@@ -0,0 +1,203 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	otlpoutput "github.com/observiq/stanza/operator/builtin/output/otlp"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestConverter(t *testing.T, attributes string) *OTLPInput {
+	cfg := NewOTLPInputConfig("test")
+	cfg.Attributes = attributes
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	return ops[0].(*OTLPInput)
+}
+
+func stringValue(s string) *common.AnyValue {
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: s}}
+}
+
+func newTestRequest(records ...*logs.LogRecord) *collogs.ExportLogsServiceRequest {
+	return &collogs.ExportLogsServiceRequest{
+		ResourceLogs: []*logs.ResourceLogs{
+			{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{
+						{Key: "host.name", Value: stringValue("host1")},
+						{Key: "process.pid", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 1234}}},
+					},
+				},
+				InstrumentationLibraryLogs: []*logs.InstrumentationLibraryLogs{
+					{Logs: records},
+				},
+			},
+		},
+	}
+}
+
+func TestConvert(t *testing.T) {
+	ts := time.Date(2021, 7, 20, 10, 0, 0, 123456789, time.UTC)
+	traceID := []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}
+	spanID := []byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74}
+
+	record := &logs.LogRecord{
+		TimeUnixNano:   uint64(ts.UnixNano()),
+		SeverityNumber: logs.SeverityNumber_SEVERITY_NUMBER_WARN,
+		SeverityText:   "WARNING",
+		Body: &common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{
+			Values: []*common.KeyValue{
+				{Key: "message", Value: stringValue("test")},
+				{Key: "tags", Value: &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+					Values: []*common.AnyValue{stringValue("a"), {Value: &common.AnyValue_BoolValue{BoolValue: true}}},
+				}}}},
+			},
+		}}},
+		Attributes: []*common.KeyValue{
+			{Key: "http.method", Value: stringValue("GET")},
+			{Key: "http.status_code", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 404}}},
+		},
+		Flags:   1,
+		TraceId: traceID,
+		SpanId:  spanID,
+	}
+
+	expectedBody := map[string]interface{}{
+		"message": "test",
+		"tags":    []interface{}{"a", true},
+	}
+
+	t.Run("Labels", func(t *testing.T) {
+		result := newTestConverter(t, attributesLabels).convert(newTestRequest(record))
+		require.Zero(t, result.rejected)
+		require.Len(t, result.entries, 1)
+
+		e := result.entries[0]
+		require.True(t, ts.Equal(e.Timestamp))
+		require.Equal(t, entry.Warning, e.Severity)
+		require.Equal(t, "WARNING", e.SeverityText)
+		require.Equal(t, map[string]string{"host.name": "host1", "process.pid": "1234"}, e.Resource)
+		require.Equal(t, map[string]string{
+			"http.method":      "GET",
+			"http.status_code": "404",
+			"trace_id":         "5b8efff798038103d269b633813fc60c",
+			"span_id":          "eee19b7ec3c1b174",
+			"trace_flags":      "01",
+		}, e.Labels)
+		require.Equal(t, expectedBody, e.Record)
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		result := newTestConverter(t, attributesRecord).convert(newTestRequest(record))
+		require.Len(t, result.entries, 1)
+
+		e := result.entries[0]
+		require.Equal(t, map[string]string{
+			"trace_id":    "5b8efff798038103d269b633813fc60c",
+			"span_id":     "eee19b7ec3c1b174",
+			"trace_flags": "01",
+		}, e.Labels)
+		require.Equal(t, map[string]interface{}{
+			"body": expectedBody,
+			"attributes": map[string]interface{}{
+				"http.method":      "GET",
+				"http.status_code": int64(404),
+			},
+		}, e.Record)
+	})
+}
+
+func TestConvertRejected(t *testing.T) {
+	valid := &logs.LogRecord{Body: stringValue("valid")}
+	invalidTrace := &logs.LogRecord{Body: stringValue("invalid"), TraceId: []byte{1, 2, 3}}
+	invalidSpan := &logs.LogRecord{Body: stringValue("invalid"), SpanId: []byte{1, 2, 3}}
+
+	result := newTestConverter(t, attributesLabels).convert(newTestRequest(valid, invalidTrace, invalidSpan))
+	require.Len(t, result.entries, 1)
+	require.Equal(t, "valid", result.entries[0].Record)
+	require.Equal(t, int64(2), result.rejected)
+	require.Equal(t, "invalid trace_id length 3", result.errMsg)
+}
+
+func TestConvertSeverity(t *testing.T) {
+	cases := []struct {
+		severity logs.SeverityNumber
+		expected entry.Severity
+	}{
+		{logs.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, entry.Default},
+		{logs.SeverityNumber_SEVERITY_NUMBER_TRACE, entry.Trace},
+		{logs.SeverityNumber_SEVERITY_NUMBER_DEBUG2, entry.Debug2},
+		{logs.SeverityNumber_SEVERITY_NUMBER_INFO, entry.Info},
+		{logs.SeverityNumber_SEVERITY_NUMBER_WARN4, entry.Warning4},
+		{logs.SeverityNumber_SEVERITY_NUMBER_ERROR, entry.Error},
+		{logs.SeverityNumber_SEVERITY_NUMBER_FATAL, entry.Emergency},
+		{logs.SeverityNumber(100), entry.Default},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, convertSeverity(tc.severity), tc.severity.String())
+	}
+}
+
+func TestAnyValueToString(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    *common.AnyValue
+		expected string
+	}{
+		{"Nil", nil, ""},
+		{"String", stringValue("test"), "test"},
+		{"Bool", &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}}, "true"},
+		{"Int", &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: -5}}, "-5"},
+		{"Double", &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: 1.5}}, "1.5"},
+		{"Array", &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+			Values: []*common.AnyValue{stringValue("a"), stringValue("b")},
+		}}}, `["a","b"]`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, anyValueToString(tc.value))
+		})
+	}
+}
+
+// TestConvertRoundTrip ensures that entries sent by the otlp output are received unchanged
+func TestConvertRoundTrip(t *testing.T) {
+	ts := time.Date(2021, 7, 20, 10, 0, 0, 123456789, time.UTC)
+	original := &entry.Entry{
+		Timestamp:    ts,
+		Severity:     entry.Error,
+		SeverityText: "ERROR",
+		Labels:       map[string]string{"label": "value"},
+		Resource:     map[string]string{"host": "host1"},
+		Record: map[string]interface{}{
+			"message": "test",
+			"count":   int64(3),
+			"nested":  map[string]interface{}{"ok": false},
+		},
+	}
+
+	b, err := otlpoutput.Convert([]*entry.Entry{original}).ToOtlpProtoBytes()
+	require.NoError(t, err)
+
+	request := &collogs.ExportLogsServiceRequest{}
+	require.NoError(t, proto.Unmarshal(b, request))
+
+	result := newTestConverter(t, attributesLabels).convert(request)
+	require.Len(t, result.entries, 1)
+
+	e := result.entries[0]
+	require.True(t, ts.Equal(e.Timestamp))
+	e.Timestamp = ts
+	require.Equal(t, original, e)
+}
@@ -0,0 +1,111 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/observiq/stanza/operator/helper"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	logsPath = "/v1/logs"
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// handleHTTP handles an OTLP/HTTP export request in either protobuf or JSON
+func (o *OTLPInput) handleHTTP(wr http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		wr.Header().Set("Allow", http.MethodPost)
+		http.Error(wr, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+		http.Error(wr, fmt.Sprintf("unsupported content type, must be '%s' or '%s'", contentTypeProtobuf, contentTypeJSON), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := o.readBody(req)
+	if err != nil {
+		o.Debugw("Failed to read request body", zap.Error(err))
+		switch err.(type) {
+		case unsupportedEncodingError:
+			http.Error(wr, err.Error(), http.StatusUnsupportedMediaType)
+		case helper.SizeLimitError:
+			http.Error(wr, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(wr, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	request := &collogs.ExportLogsServiceRequest{}
+	if contentType == contentTypeJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, request)
+	} else {
+		err = proto.Unmarshal(body, request)
+	}
+	if err != nil {
+		o.Debugw("Failed to decode export request", zap.Error(err))
+		http.Error(wr, fmt.Sprintf("failed to decode request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	result := o.consume(req.Context(), request)
+
+	var response []byte
+	if contentType == contentTypeJSON {
+		response, err = marshalJSONResponse(result)
+	} else {
+		response, err = proto.Marshal(newExportResponse(result))
+	}
+	if err != nil {
+		o.Errorw("Failed to encode export response", zap.Error(err))
+		wr.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Set("Content-Type", contentType)
+	wr.WriteHeader(http.StatusOK)
+	if _, err := wr.Write(response); err != nil {
+		o.Debugw("Failed to write export response", zap.Error(err))
+	}
+}
+
+// unsupportedEncodingError is returned for an unknown Content-Encoding
+type unsupportedEncodingError string
+
+func (e unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding '%s'", string(e))
+}
+
+// readBody reads the decompressed request body. Both the compressed and
+// decompressed sizes are limited to the maximum request size.
+func (o *OTLPInput) readBody(req *http.Request) ([]byte, error) {
+	var body io.Reader = helper.NewLimitedReader(req.Body, o.maxRequestSize)
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = helper.NewLimitedReader(gz, o.maxRequestSize)
+	default:
+		return nil, unsupportedEncodingError(encoding)
+	}
+
+	return ioutil.ReadAll(body)
+}
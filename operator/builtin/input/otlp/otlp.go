@@ -0,0 +1,252 @@
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // register the gzip compressor
+)
+
+const (
+	attributesLabels = "labels"
+	attributesRecord = "record"
+
+	defaultGRPCListenAddress = ":4317"
+	defaultHTTPListenAddress = ":55681"
+	defaultMaxRequestSize    = 20 * 1024 * 1024
+)
+
+func init() {
+	operator.Register("otlp_input", func() operator.Builder { return NewOTLPInputConfig("") })
+}
+
+// NewOTLPInputConfig creates a new otlp input config with default values
+func NewOTLPInputConfig(operatorID string) *OTLPInputConfig {
+	return &OTLPInputConfig{
+		InputConfig: helper.NewInputConfig(operatorID, "otlp_input"),
+		GRPC: ServerConfig{
+			Enable:        true,
+			ListenAddress: defaultGRPCListenAddress,
+		},
+		HTTP: ServerConfig{
+			Enable:        true,
+			ListenAddress: defaultHTTPListenAddress,
+		},
+		Attributes:     attributesLabels,
+		MaxRequestSize: defaultMaxRequestSize,
+	}
+}
+
+// OTLPInputConfig is the configuration of an otlp input operator
+type OTLPInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	GRPC           ServerConfig           `json:"grpc,omitempty"             yaml:"grpc,omitempty"`
+	HTTP           ServerConfig           `json:"http,omitempty"             yaml:"http,omitempty"`
+	TLS            helper.TLSServerConfig `json:"tls,omitempty"              yaml:"tls,omitempty"`
+	Attributes     string                 `json:"attributes,omitempty"       yaml:"attributes,omitempty"`
+	MaxRequestSize helper.ByteSize        `json:"max_request_size,omitempty" yaml:"max_request_size,omitempty"`
+}
+
+// ServerConfig is the configuration of one of the OTLP transports
+type ServerConfig struct {
+	Enable        bool   `json:"enable"                   yaml:"enable"`
+	ListenAddress string `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+}
+
+// Build will build an otlp input operator
+func (c OTLPInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.GRPC.Enable && !c.HTTP.Enable {
+		return nil, fmt.Errorf("at least one of 'grpc' or 'http' must be enabled")
+	}
+
+	if c.GRPC.Enable {
+		if c.GRPC.ListenAddress == "" {
+			c.GRPC.ListenAddress = defaultGRPCListenAddress
+		}
+		if _, err := net.ResolveTCPAddr("tcp", c.GRPC.ListenAddress); err != nil {
+			return nil, fmt.Errorf("failed to resolve grpc listen_address: %s", err)
+		}
+	}
+
+	if c.HTTP.Enable {
+		if c.HTTP.ListenAddress == "" {
+			c.HTTP.ListenAddress = defaultHTTPListenAddress
+		}
+		if _, err := net.ResolveTCPAddr("tcp", c.HTTP.ListenAddress); err != nil {
+			return nil, fmt.Errorf("failed to resolve http listen_address: %s", err)
+		}
+	}
+
+	switch c.Attributes {
+	case "":
+		c.Attributes = attributesLabels
+	case attributesLabels, attributesRecord:
+	default:
+		return nil, fmt.Errorf("invalid attributes '%s', must be one of '%s' or '%s'", c.Attributes, attributesLabels, attributesRecord)
+	}
+
+	if c.MaxRequestSize <= 0 {
+		c.MaxRequestSize = defaultMaxRequestSize
+	}
+
+	tlsConfig, err := c.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	otlpInput := &OTLPInput{
+		InputOperator:  inputOperator,
+		tlsConfig:      tlsConfig,
+		attributes:     c.Attributes,
+		maxRequestSize: int64(c.MaxRequestSize),
+	}
+	if c.GRPC.Enable {
+		otlpInput.grpcAddress = c.GRPC.ListenAddress
+	}
+	if c.HTTP.Enable {
+		otlpInput.httpAddress = c.HTTP.ListenAddress
+	}
+	return []operator.Operator{otlpInput}, nil
+}
+
+// OTLPInput is an operator that receives logs exported with the OpenTelemetry protocol
+type OTLPInput struct {
+	helper.InputOperator
+	grpcAddress    string
+	httpAddress    string
+	tlsConfig      *tls.Config
+	attributes     string
+	maxRequestSize int64
+
+	grpcListener net.Listener
+	grpcServer   *grpc.Server
+	httpListener net.Listener
+	httpServer   *http.Server
+	wg           sync.WaitGroup
+}
+
+// Start will start listening for OTLP exports
+func (o *OTLPInput) Start() error {
+	if o.grpcAddress != "" {
+		if err := o.startGRPC(); err != nil {
+			return err
+		}
+	}
+
+	if o.httpAddress != "" {
+		if err := o.startHTTP(); err != nil {
+			o.stopGRPC()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *OTLPInput) startGRPC() error {
+	listener, err := net.Listen("tcp", o.grpcAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on grpc interface: %w", err)
+	}
+	o.grpcListener = listener
+
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(int(o.maxRequestSize))}
+	if o.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(o.tlsConfig)))
+	}
+	o.grpcServer = grpc.NewServer(opts...)
+	collogs.RegisterLogsServiceServer(o.grpcServer, &logsService{input: o})
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if err := o.grpcServer.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			o.Errorw("gRPC serve error", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (o *OTLPInput) startHTTP() error {
+	listener, err := net.Listen("tcp", o.httpAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on http interface: %w", err)
+	}
+	if o.tlsConfig != nil {
+		listener = tls.NewListener(listener, o.tlsConfig)
+	}
+	o.httpListener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(logsPath, o.handleHTTP)
+	o.httpServer = &http.Server{Handler: mux}
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if err := o.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			o.Errorw("HTTP serve error", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Stop will stop listening for OTLP exports
+func (o *OTLPInput) Stop() error {
+	o.stopGRPC()
+
+	var err error
+	if o.httpServer != nil {
+		err = o.httpServer.Shutdown(context.Background())
+	}
+
+	o.wg.Wait()
+	return err
+}
+
+func (o *OTLPInput) stopGRPC() {
+	if o.grpcServer != nil {
+		o.grpcServer.GracefulStop()
+	}
+}
+
+// consume converts an export request and writes its entries
+func (o *OTLPInput) consume(ctx context.Context, request *collogs.ExportLogsServiceRequest) *conversion {
+	result := o.convert(request)
+	for _, e := range result.entries {
+		o.Write(ctx, e)
+	}
+
+	if result.rejected > 0 {
+		o.Warnw("Rejected log records", "count", result.rejected, "error", result.errMsg)
+	}
+	return result
+}
+
+// logsService implements the OTLP logs gRPC service
+type logsService struct {
+	collogs.UnimplementedLogsServiceServer
+	input *OTLPInput
+}
+
+// Export handles a gRPC export request
+func (s *logsService) Export(ctx context.Context, request *collogs.ExportLogsServiceRequest) (*collogs.ExportLogsServiceResponse, error) {
+	result := s.input.consume(ctx, request)
+	return newExportResponse(result), nil
+}
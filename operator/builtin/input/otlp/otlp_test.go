@@ -0,0 +1,386 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func expectRecord(t *testing.T, fake *testutil.FakeOutput, record interface{}) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, record, e.Record)
+		return e
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+// parsePartialSuccess reads the partial success of a serialized response
+func parsePartialSuccess(t *testing.T, b []byte) (int64, string) {
+	num, typ, n := protowire.ConsumeTag(b)
+	require.True(t, n > 0)
+	require.Equal(t, protowire.Number(responsePartialSuccessField), num)
+	require.Equal(t, protowire.BytesType, typ)
+	partial, n := protowire.ConsumeBytes(b[n:])
+	require.True(t, n > 0)
+
+	num, _, n = protowire.ConsumeTag(partial)
+	require.Equal(t, protowire.Number(partialRejectedRecordsField), num)
+	rejected, m := protowire.ConsumeVarint(partial[n:])
+	partial = partial[n+m:]
+
+	num, _, n = protowire.ConsumeTag(partial)
+	require.Equal(t, protowire.Number(partialErrorMessageField), num)
+	msg, _ := protowire.ConsumeString(partial[n:])
+	return int64(rejected), msg
+}
+
+func TestOTLPInputBuild(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.OutputIDs = []string{"fake"}
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+	})
+
+	t.Run("NoTransports", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.GRPC.Enable = false
+		cfg.HTTP.Enable = false
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+
+	t.Run("InvalidAddress", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.HTTP.ListenAddress = "invalid:port"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+
+	t.Run("InvalidAttributes", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.Attributes = "invalid"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+
+	t.Run("TLSMissingCertificate", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.TLS.Enable = true
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+}
+
+func TestOTLPInputGRPC(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []grpc.CallOption
+	}{
+		{"Uncompressed", nil},
+		{"Gzip", []grpc.CallOption{grpc.UseCompressor(grpcgzip.Name)}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewOTLPInputConfig("test")
+			cfg.GRPC.ListenAddress = "127.0.0.1:0"
+			cfg.HTTP.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.HTTP.Enable = false
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			otlpInput := ops[0].(*OTLPInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, otlpInput.Start())
+			defer otlpInput.Stop()
+
+			conn, err := grpc.Dial(otlpInput.grpcListener.Addr().String(), grpc.WithInsecure())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			client := collogs.NewLogsServiceClient(conn)
+			response, err := client.Export(ctx, newTestRequest(&logs.LogRecord{Body: stringValue("test")}), tc.opts...)
+			require.NoError(t, err)
+			require.Empty(t, response.ProtoReflect().GetUnknown())
+
+			e := expectRecord(t, fake, "test")
+			require.Equal(t, "host1", e.Resource["host.name"])
+		})
+	}
+}
+
+func TestOTLPInputGRPCPartialSuccess(t *testing.T) {
+	cfg := NewOTLPInputConfig("test")
+	cfg.GRPC.ListenAddress = "127.0.0.1:0"
+	cfg.HTTP.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	otlpInput := ops[0].(*OTLPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, otlpInput.Start())
+	defer otlpInput.Stop()
+
+	conn, err := grpc.Dial(otlpInput.grpcListener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	request := newTestRequest(
+		&logs.LogRecord{Body: stringValue("valid")},
+		&logs.LogRecord{Body: stringValue("invalid"), TraceId: []byte{1}},
+	)
+	response, err := collogs.NewLogsServiceClient(conn).Export(ctx, request)
+	require.NoError(t, err)
+
+	rejected, msg := parsePartialSuccess(t, response.ProtoReflect().GetUnknown())
+	require.Equal(t, int64(1), rejected)
+	require.Equal(t, "invalid trace_id length 1", msg)
+
+	expectRecord(t, fake, "valid")
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+}
+
+func postLogs(t *testing.T, otlpInput *OTLPInput, contentType string, body []byte, headers map[string]string) (*http.Response, []byte) {
+	url := fmt.Sprintf("http://%s%s", otlpInput.httpListener.Addr().String(), logsPath)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, respBody
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestOTLPInputHTTP(t *testing.T) {
+	request := newTestRequest(&logs.LogRecord{Body: stringValue("test")})
+	protoBody, err := proto.Marshal(request)
+	require.NoError(t, err)
+	jsonBody, err := protojson.Marshal(request)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		headers     map[string]string
+	}{
+		{"Protobuf", contentTypeProtobuf, protoBody, nil},
+		{"JSON", contentTypeJSON, jsonBody, nil},
+		{"JSONWithCharset", "application/json; charset=utf-8", jsonBody, nil},
+		{"Gzip", contentTypeProtobuf, gzipBytes(t, protoBody), map[string]string{"Content-Encoding": "gzip"}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewOTLPInputConfig("test")
+			cfg.GRPC.ListenAddress = "127.0.0.1:0"
+			cfg.HTTP.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.GRPC.Enable = false
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			otlpInput := ops[0].(*OTLPInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, otlpInput.Start())
+			defer otlpInput.Stop()
+
+			resp, _ := postLogs(t, otlpInput, tc.contentType, tc.body, tc.headers)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			e := expectRecord(t, fake, "test")
+			require.Equal(t, "host1", e.Resource["host.name"])
+		})
+	}
+}
+
+func TestOTLPInputHTTPPartialSuccess(t *testing.T) {
+	request := newTestRequest(
+		&logs.LogRecord{Body: stringValue("valid")},
+		&logs.LogRecord{Body: stringValue("invalid"), SpanId: []byte{1}},
+	)
+
+	t.Run("Protobuf", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.GRPC.ListenAddress = "127.0.0.1:0"
+		cfg.HTTP.ListenAddress = "127.0.0.1:0"
+		cfg.OutputIDs = []string{"fake"}
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		otlpInput := ops[0].(*OTLPInput)
+
+		fake := testutil.NewFakeOutput(t)
+		require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+		require.NoError(t, otlpInput.Start())
+		defer otlpInput.Stop()
+
+		body, err := proto.Marshal(request)
+		require.NoError(t, err)
+
+		resp, respBody := postLogs(t, otlpInput, contentTypeProtobuf, body, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, contentTypeProtobuf, resp.Header.Get("Content-Type"))
+
+		rejected, msg := parsePartialSuccess(t, respBody)
+		require.Equal(t, int64(1), rejected)
+		require.Equal(t, "invalid span_id length 1", msg)
+		expectRecord(t, fake, "valid")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test")
+		cfg.GRPC.ListenAddress = "127.0.0.1:0"
+		cfg.HTTP.ListenAddress = "127.0.0.1:0"
+		cfg.OutputIDs = []string{"fake"}
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		otlpInput := ops[0].(*OTLPInput)
+
+		fake := testutil.NewFakeOutput(t)
+		require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+		require.NoError(t, otlpInput.Start())
+		defer otlpInput.Stop()
+
+		body, err := protojson.Marshal(request)
+		require.NoError(t, err)
+
+		resp, respBody := postLogs(t, otlpInput, contentTypeJSON, body, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, contentTypeJSON, resp.Header.Get("Content-Type"))
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(respBody, &response))
+		require.Equal(t, map[string]interface{}{
+			"partialSuccess": map[string]interface{}{
+				"rejectedLogRecords": "1",
+				"errorMessage":       "invalid span_id length 1",
+			},
+		}, response)
+		expectRecord(t, fake, "valid")
+	})
+}
+
+func TestOTLPInputHTTPErrors(t *testing.T) {
+	body, err := proto.Marshal(newTestRequest(&logs.LogRecord{Body: stringValue("test")}))
+	require.NoError(t, err)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		headers     map[string]string
+		expected    int
+	}{
+		{"UnsupportedContentType", "text/plain", body, nil, http.StatusUnsupportedMediaType},
+		{"UnsupportedEncoding", contentTypeProtobuf, body, map[string]string{"Content-Encoding": "br"}, http.StatusUnsupportedMediaType},
+		{"InvalidProtobuf", contentTypeProtobuf, []byte{0xff}, nil, http.StatusBadRequest},
+		{"InvalidJSON", contentTypeJSON, []byte("{"), nil, http.StatusBadRequest},
+		{"InvalidGzip", contentTypeProtobuf, body, map[string]string{"Content-Encoding": "gzip"}, http.StatusBadRequest},
+		{"TooLarge", contentTypeProtobuf, make([]byte, 2048), nil, http.StatusRequestEntityTooLarge},
+		{"TooLargeDecompressed", contentTypeProtobuf, gzipBytes(t, make([]byte, 2048)), map[string]string{"Content-Encoding": "gzip"}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewOTLPInputConfig("test")
+			cfg.GRPC.ListenAddress = "127.0.0.1:0"
+			cfg.HTTP.ListenAddress = "127.0.0.1:0"
+			cfg.OutputIDs = []string{"fake"}
+			cfg.GRPC.Enable = false
+			cfg.MaxRequestSize = 1024
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			otlpInput := ops[0].(*OTLPInput)
+
+			fake := testutil.NewFakeOutput(t)
+			require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+			require.NoError(t, otlpInput.Start())
+			defer otlpInput.Stop()
+
+			resp, _ := postLogs(t, otlpInput, tc.contentType, tc.body, tc.headers)
+			require.Equal(t, tc.expected, resp.StatusCode)
+			fake.ExpectNoEntry(t, 50*time.Millisecond)
+		})
+	}
+}
+
+func TestOTLPInputHTTPMethodNotAllowed(t *testing.T) {
+	cfg := NewOTLPInputConfig("test")
+	cfg.GRPC.ListenAddress = "127.0.0.1:0"
+	cfg.HTTP.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	otlpInput := ops[0].(*OTLPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, otlpInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, otlpInput.Start())
+	defer otlpInput.Stop()
+
+	url := fmt.Sprintf("http://%s%s", otlpInput.httpListener.Addr().String(), logsPath)
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,63 @@
+package otlp
+
+import (
+	"encoding/json"
+	"strconv"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of ExportLogsServiceResponse and ExportLogsPartialSuccess
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/collector/logs/v1/logs_service.proto
+const (
+	responsePartialSuccessField = 1
+	partialRejectedRecordsField = 1
+	partialErrorMessageField    = 2
+)
+
+// newExportResponse creates the response to an export request. The version
+// of the generated response predates partial success, so it is added to the
+// response as an unknown field, which is serialized with the known fields.
+func newExportResponse(result *conversion) *collogs.ExportLogsServiceResponse {
+	response := &collogs.ExportLogsServiceResponse{}
+	if result.rejected == 0 {
+		return response
+	}
+
+	var partial []byte
+	partial = protowire.AppendTag(partial, partialRejectedRecordsField, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(result.rejected))
+	partial = protowire.AppendTag(partial, partialErrorMessageField, protowire.BytesType)
+	partial = protowire.AppendString(partial, result.errMsg)
+
+	var field []byte
+	field = protowire.AppendTag(field, responsePartialSuccessField, protowire.BytesType)
+	field = protowire.AppendBytes(field, partial)
+
+	response.ProtoReflect().SetUnknown(field)
+	return response
+}
+
+// jsonExportResponse is the JSON encoding of an ExportLogsServiceResponse
+type jsonExportResponse struct {
+	PartialSuccess *jsonPartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+type jsonPartialSuccess struct {
+	// RejectedLogRecords is an int64, which the protobuf JSON mapping encodes as a string
+	RejectedLogRecords string `json:"rejectedLogRecords"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+// marshalJSONResponse encodes the response to an export request as JSON
+func marshalJSONResponse(result *conversion) ([]byte, error) {
+	response := jsonExportResponse{}
+	if result.rejected > 0 {
+		response.PartialSuccess = &jsonPartialSuccess{
+			RejectedLogRecords: strconv.FormatInt(result.rejected, 10),
+			ErrorMessage:       result.errMsg,
+		}
+	}
+	return json.Marshal(response)
+}
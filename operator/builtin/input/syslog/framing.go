@@ -0,0 +1,136 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Framing methods for syslog over TCP, described in RFC6587
+// https://tools.ietf.org/html/rfc6587#section-3.4
+const (
+	framingAuto           = "auto"
+	framingOctetCounting  = "octet_counting"
+	framingNonTransparent = "non_transparent"
+
+	// maxMessageLengthDigits limits the MSG-LEN of an octet counted frame,
+	// which is more than enough for any allowed message size
+	maxMessageLengthDigits = 10
+)
+
+// frameTooLargeError is returned when a frame exceeds the maximum message size
+type frameTooLargeError struct {
+	size int
+}
+
+func (e frameTooLargeError) Error() string {
+	return fmt.Sprintf("message exceeds the maximum size of %d bytes", e.size)
+}
+
+// frameReader reads syslog messages from a stream
+type frameReader struct {
+	r              *bufio.Reader
+	framing        string
+	maxMessageSize int
+}
+
+func newFrameReader(r io.Reader, framing string, maxMessageSize int) *frameReader {
+	return &frameReader{
+		r:              bufio.NewReader(r),
+		framing:        framing,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// next reads the next message. With auto framing, a frame that starts with
+// a digit is octet counted, since a syslog message always starts with '<'.
+func (f *frameReader) next() ([]byte, error) {
+	switch f.framing {
+	case framingOctetCounting:
+		return f.nextOctetCounted()
+	case framingNonTransparent:
+		return f.nextNonTransparent()
+	}
+
+	first, err := f.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] >= '0' && first[0] <= '9' {
+		return f.nextOctetCounted()
+	}
+	return f.nextNonTransparent()
+}
+
+// nextOctetCounted reads a frame in the form MSG-LEN SP SYSLOG-MSG
+func (f *frameReader) nextOctetCounted() ([]byte, error) {
+	var digits []byte
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(digits) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b == ' ' {
+			break
+		}
+		if b < '0' || b > '9' || len(digits) == maxMessageLengthDigits {
+			return nil, fmt.Errorf("invalid octet count")
+		}
+		digits = append(digits, b)
+	}
+
+	length, err := strconv.Atoi(string(digits))
+	if err != nil || length == 0 {
+		return nil, fmt.Errorf("invalid octet count '%s'", digits)
+	}
+	if length > f.maxMessageSize {
+		return nil, frameTooLargeError{f.maxMessageSize}
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(f.r, message); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// nextNonTransparent reads a frame terminated by a newline. A final
+// message that is not terminated is returned at the end of the stream.
+func (f *frameReader) nextNonTransparent() ([]byte, error) {
+	var message []byte
+	for {
+		line, err := f.r.ReadSlice('\n')
+		message = append(message, line...)
+		if len(message) > f.maxMessageSize+1 {
+			return nil, frameTooLargeError{f.maxMessageSize}
+		}
+
+		switch err {
+		case nil:
+			return trimTrailer(message), nil
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			if message = trimTrailer(message); len(message) > 0 {
+				return message, nil
+			}
+			return nil, io.EOF
+		default:
+			return nil, err
+		}
+	}
+}
+
+// trimTrailer removes the trailer of a non-transparent frame, which is
+// usually LF but may be CRLF or NUL
+func trimTrailer(message []byte) []byte {
+	return bytes.TrimRight(message, "\r\n\x00")
+}
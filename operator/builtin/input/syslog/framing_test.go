@@ -0,0 +1,128 @@
+package syslog
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAllFrames(t *testing.T, framing, stream string, maxMessageSize int) ([]string, error) {
+	reader := newFrameReader(strings.NewReader(stream), framing, maxMessageSize)
+	var messages []string
+	for {
+		message, err := reader.next()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, string(message))
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	cases := []struct {
+		name      string
+		framing   string
+		stream    string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			"OctetCounting",
+			framingOctetCounting,
+			"11 <34>1 first12 <34>1 second",
+			[]string{"<34>1 first", "<34>1 second"},
+			false,
+		},
+		{
+			"OctetCountingMultiline",
+			framingOctetCounting,
+			"17 <34>1 line1\nline2",
+			[]string{"<34>1 line1\nline2"},
+			false,
+		},
+		{
+			"OctetCountingInvalidCount",
+			framingOctetCounting,
+			"<34>1 first\n",
+			nil,
+			true,
+		},
+		{
+			"OctetCountingTruncated",
+			framingOctetCounting,
+			"20 <34>1 first",
+			nil,
+			true,
+		},
+		{
+			"OctetCountingTooLarge",
+			framingOctetCounting,
+			"2000 <34>1 first",
+			nil,
+			true,
+		},
+		{
+			"NonTransparent",
+			framingNonTransparent,
+			"<34>1 first\n<34>1 second\r\n<34>1 third",
+			[]string{"<34>1 first", "<34>1 second", "<34>1 third"},
+			false,
+		},
+		{
+			"NonTransparentNul",
+			framingNonTransparent,
+			"<34>1 first\x00\n",
+			[]string{"<34>1 first"},
+			false,
+		},
+		{
+			"NonTransparentTooLarge",
+			framingNonTransparent,
+			"<34>1 " + strings.Repeat("a", 2000) + "\n",
+			nil,
+			true,
+		},
+		{
+			"AutoMixed",
+			framingAuto,
+			"11 <34>1 first<34>1 second\n11 <34>1 third",
+			[]string{"<34>1 first", "<34>1 second", "<34>1 third"},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages, err := readAllFrames(t, tc.framing, tc.stream, 1024)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestDetectProtocol(t *testing.T) {
+	cases := []struct {
+		message  string
+		expected string
+	}{
+		{"<34>1 2003-10-11T22:14:15.003Z mymachine su - ID47 - message", protocolRFC5424},
+		{"<165>12 2003-10-11T22:14:15.003Z host app - - - message", protocolRFC5424},
+		{"<34>Oct 11 22:14:15 mymachine su: message", protocolRFC3164},
+		{"<34>2003-10-11T22:14:15.003Z mymachine su: message", protocolRFC3164},
+		{"<34>0 message", protocolRFC3164},
+		{"no priority", protocolRFC3164},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, detectProtocol([]byte(tc.message)), tc.message)
+	}
+}
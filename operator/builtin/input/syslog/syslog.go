@@ -0,0 +1,405 @@
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	syslogparser "github.com/observiq/stanza/operator/builtin/parser/syslog"
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+)
+
+const (
+	transportTCP = "tcp"
+	transportUDP = "udp"
+
+	protocolAuto    = "auto"
+	protocolRFC3164 = "rfc3164"
+	protocolRFC5424 = "rfc5424"
+
+	defaultMaxMessageSize = 64 * 1024
+
+	// maxDatagramSize is the largest possible UDP payload
+	maxDatagramSize = 65535
+)
+
+func init() {
+	operator.Register("syslog_input", func() operator.Builder { return NewSyslogInputConfig("") })
+}
+
+// NewSyslogInputConfig creates a new syslog input config with default values
+func NewSyslogInputConfig(operatorID string) *SyslogInputConfig {
+	return &SyslogInputConfig{
+		InputConfig:    helper.NewInputConfig(operatorID, "syslog_input"),
+		Transport:      transportTCP,
+		Protocol:       protocolAuto,
+		Framing:        framingAuto,
+		Location:       "UTC",
+		MaxMessageSize: defaultMaxMessageSize,
+	}
+}
+
+// SyslogInputConfig is the configuration of a syslog input operator
+type SyslogInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	ListenAddress  string                 `json:"listen_address,omitempty"   yaml:"listen_address,omitempty"`
+	Transport      string                 `json:"transport,omitempty"        yaml:"transport,omitempty"`
+	TLS            helper.TLSServerConfig `json:"tls,omitempty"              yaml:"tls,omitempty"`
+	Protocol       string                 `json:"protocol,omitempty"         yaml:"protocol,omitempty"`
+	Framing        string                 `json:"framing,omitempty"          yaml:"framing,omitempty"`
+	Location       string                 `json:"location,omitempty"         yaml:"location,omitempty"`
+	MaxMessageSize helper.ByteSize        `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
+}
+
+// Build will build a syslog input operator
+func (c SyslogInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ListenAddress == "" {
+		return nil, fmt.Errorf("missing required parameter 'listen_address'")
+	}
+
+	switch c.Transport {
+	case transportTCP:
+		if _, err := net.ResolveTCPAddr("tcp", c.ListenAddress); err != nil {
+			return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
+		}
+	case transportUDP:
+		if _, err := net.ResolveUDPAddr("udp", c.ListenAddress); err != nil {
+			return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
+		}
+		if c.TLS.Enable {
+			return nil, fmt.Errorf("TLS is not supported with the udp transport")
+		}
+	default:
+		return nil, fmt.Errorf("invalid transport '%s', must be one of '%s' or '%s'", c.Transport, transportTCP, transportUDP)
+	}
+
+	switch c.Protocol {
+	case protocolAuto, protocolRFC3164, protocolRFC5424:
+	default:
+		return nil, fmt.Errorf("invalid protocol '%s', must be one of '%s', '%s' or '%s'", c.Protocol, protocolAuto, protocolRFC3164, protocolRFC5424)
+	}
+
+	switch c.Framing {
+	case framingAuto, framingOctetCounting, framingNonTransparent:
+	default:
+		return nil, fmt.Errorf("invalid framing '%s', must be one of '%s', '%s' or '%s'", c.Framing, framingAuto, framingOctetCounting, framingNonTransparent)
+	}
+
+	location, err := time.LoadLocation(c.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load location '%s': %s", c.Location, err)
+	}
+
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = defaultMaxMessageSize
+	}
+
+	tlsConfig, err := c.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	syslogInput := &SyslogInput{
+		InputOperator:  inputOperator,
+		address:        c.ListenAddress,
+		transport:      c.Transport,
+		tlsConfig:      tlsConfig,
+		protocol:       c.Protocol,
+		framing:        c.Framing,
+		location:       location,
+		maxMessageSize: int(c.MaxMessageSize),
+		backoff: backoff.Backoff{
+			Max: 3 * time.Second,
+		},
+	}
+	return []operator.Operator{syslogInput}, nil
+}
+
+// SyslogInput is an operator that receives and parses syslog messages
+type SyslogInput struct {
+	helper.InputOperator
+	address        string
+	transport      string
+	tlsConfig      *tls.Config
+	protocol       string
+	framing        string
+	location       *time.Location
+	maxMessageSize int
+	backoff        backoff.Backoff
+
+	listener   net.Listener
+	connection net.PacketConn
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// Start will start listening for syslog messages
+func (s *SyslogInput) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	if s.transport == transportUDP {
+		conn, err := net.ListenPacket("udp", s.address)
+		if err != nil {
+			return fmt.Errorf("failed to open connection: %w", err)
+		}
+		s.connection = conn
+		s.goHandlePackets(ctx)
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on interface: %w", err)
+	}
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	s.listener = listener
+	s.goListen(ctx)
+	return nil
+}
+
+// goListen will listen for tcp connections
+func (s *SyslogInput) goListen(ctx context.Context) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					s.Debugw("Listener accept error", zap.Error(err))
+					time.Sleep(s.backoff.Duration())
+					continue
+				}
+			}
+			s.backoff.Reset()
+
+			s.Debugf("Received connection: %s", conn.RemoteAddr().String())
+			subctx, cancel := context.WithCancel(ctx)
+			s.goHandleClose(subctx, conn)
+			s.goHandleMessages(subctx, conn, cancel)
+		}
+	}()
+}
+
+// goHandleClose will wait for the context to finish before closing a connection
+func (s *SyslogInput) goHandleClose(ctx context.Context, conn net.Conn) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		<-ctx.Done()
+		s.Debugf("Closing connection: %s", conn.RemoteAddr().String())
+		if err := conn.Close(); err != nil {
+			s.Errorf("Failed to close connection: %s", err)
+		}
+	}()
+}
+
+// goHandleMessages will read framed messages from a tcp connection
+func (s *SyslogInput) goHandleMessages(ctx context.Context, conn net.Conn, cancel context.CancelFunc) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		reader := newFrameReader(conn, s.framing, s.maxMessageSize)
+		for {
+			message, err := reader.next()
+			if err != nil {
+				if !isClosedError(ctx, err) {
+					s.Errorw("Failed to read message", zap.Error(err), "remote_addr", conn.RemoteAddr().String())
+				}
+				return
+			}
+
+			s.handleMessage(ctx, message, transportTCP, conn.RemoteAddr())
+		}
+	}()
+}
+
+// goHandlePackets will read messages from a udp connection, one per datagram
+func (s *SyslogInput) goHandlePackets(ctx context.Context) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		buffer := make([]byte, maxDatagramSize)
+		for {
+			n, remoteAddr, err := s.connection.ReadFrom(buffer)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					s.Errorw("Failed reading messages", zap.Error(err))
+					continue
+				}
+			}
+
+			message := trimTrailer(buffer[:n])
+			if len(message) == 0 {
+				continue
+			}
+			if len(message) > s.maxMessageSize {
+				s.Warnw("Dropping message that exceeds max_message_size", "size", len(message), "remote_addr", remoteAddr.String())
+				continue
+			}
+
+			// The buffer is reused, so the message must be copied
+			s.handleMessage(ctx, append([]byte(nil), message...), transportUDP, remoteAddr)
+		}
+	}()
+}
+
+// handleMessage parses a message and writes it as an entry. Messages that
+// cannot be parsed are written with the raw message as the record.
+func (s *SyslogInput) handleMessage(ctx context.Context, message []byte, transport string, remoteAddr net.Addr) {
+	e, err := s.newEntry(message)
+	if err != nil {
+		s.Errorw("Failed to create entry", zap.Error(err))
+		return
+	}
+
+	if transport == transportUDP {
+		e.AddLabel("net.transport", "IP.UDP")
+	} else {
+		e.AddLabel("net.transport", "IP.TCP")
+	}
+	if host, port, err := net.SplitHostPort(remoteAddr.String()); err == nil {
+		e.AddLabel("net.peer.ip", host)
+		e.AddLabel("net.peer.port", port)
+	}
+
+	s.Write(ctx, e)
+}
+
+// newEntry creates an entry from a message, promoting its timestamp and severity
+func (s *SyslogInput) newEntry(message []byte) (*entry.Entry, error) {
+	protocol := s.protocol
+	if protocol == protocolAuto {
+		protocol = detectProtocol(message)
+	}
+
+	parsed, err := syslogparser.ParseMessage(message, protocol, s.location)
+	if err != nil {
+		s.Debugw("Failed to parse message", zap.Error(err), "protocol", protocol)
+		return s.NewEntry(string(message))
+	}
+
+	timestamp, hasTimestamp := parsed["timestamp"].(time.Time)
+	delete(parsed, "timestamp")
+	sev, hasSeverity := parsed["severity"].(int)
+	delete(parsed, "severity")
+	if sd, ok := parsed["structured_data"].(map[string]map[string]string); ok {
+		parsed["structured_data"] = structuredDataToMap(sd)
+	}
+
+	e, err := s.NewEntry(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasTimestamp {
+		e.Timestamp = helper.SetTimestampYear(timestamp)
+	}
+	if hasSeverity {
+		if severity, text, err := syslogparser.ConvertSeverity(sev); err == nil {
+			e.Severity = severity
+			e.SeverityText = text
+		}
+	}
+	return e, nil
+}
+
+// detectProtocol returns rfc5424 if the message has a version after its
+// priority, such as "<34>1 ", and rfc3164 otherwise
+func detectProtocol(message []byte) string {
+	end := bytes.IndexByte(message, '>')
+	if end < 0 {
+		return protocolRFC3164
+	}
+
+	rest := message[end+1:]
+	i := 0
+	for i < len(rest) && i < 3 && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	if i > 0 && rest[0] != '0' && i < len(rest) && rest[i] == ' ' {
+		return protocolRFC5424
+	}
+	return protocolRFC3164
+}
+
+// structuredDataToMap converts structured data into nested maps that
+// can be accessed with entry fields
+func structuredDataToMap(sd map[string]map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(sd))
+	for id, params := range sd {
+		paramMap := make(map[string]interface{}, len(params))
+		for k, v := range params {
+			paramMap[k] = v
+		}
+		result[id] = paramMap
+	}
+	return result
+}
+
+// isClosedError returns true if the error is expected when a connection ends
+func isClosedError(ctx context.Context, err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if strings.Contains(err.Error(), "use of closed network connection") {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// Stop will stop listening for syslog messages
+func (s *SyslogInput) Stop() error {
+	s.cancel()
+
+	if s.listener != nil {
+		if err := s.listener.Close(); err != nil {
+			return err
+		}
+	}
+	if s.connection != nil {
+		if err := s.connection.Close(); err != nil {
+			return err
+		}
+	}
+
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,248 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rfc5424Message = `<86>1 2015-08-05T21:58:59.693Z 192.168.2.132 SecureAuth0 23108 ID52020 [SecureAuth@27389 UserID="Tester2" PEN="27389"] Found the user` + "\nfor retrieving user's profile"
+	rfc3164Message = "<34>Jan 12 06:30:00 1.2.3.4 apache_server: test message"
+)
+
+var expectedRFC5424Record = map[string]interface{}{
+	"appname":  "SecureAuth0",
+	"facility": 10,
+	"hostname": "192.168.2.132",
+	"message":  "Found the user\nfor retrieving user's profile",
+	"msg_id":   "ID52020",
+	"priority": 86,
+	"proc_id":  "23108",
+	"structured_data": map[string]interface{}{
+		"SecureAuth@27389": map[string]interface{}{
+			"PEN":    "27389",
+			"UserID": "Tester2",
+		},
+	},
+	"version": 1,
+}
+
+var expectedRFC3164Record = map[string]interface{}{
+	"appname":  "apache_server",
+	"facility": 4,
+	"hostname": "1.2.3.4",
+	"message":  "test message",
+	"priority": 34,
+}
+
+func expectEntry(t *testing.T, fake *testutil.FakeOutput) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		return e
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+func TestSyslogInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*SyslogInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *SyslogInputConfig) {}, false},
+		{"UDP", func(cfg *SyslogInputConfig) { cfg.Transport = transportUDP }, false},
+		{"MissingAddress", func(cfg *SyslogInputConfig) { cfg.ListenAddress = "" }, true},
+		{"InvalidTransport", func(cfg *SyslogInputConfig) { cfg.Transport = "sctp" }, true},
+		{"InvalidProtocol", func(cfg *SyslogInputConfig) { cfg.Protocol = "rfc1234" }, true},
+		{"InvalidFraming", func(cfg *SyslogInputConfig) { cfg.Framing = "invalid" }, true},
+		{"InvalidLocation", func(cfg *SyslogInputConfig) { cfg.Location = "Not/A_Location" }, true},
+		{"UDPWithTLS", func(cfg *SyslogInputConfig) {
+			cfg.Transport = transportUDP
+			cfg.TLS.Enable = true
+		}, true},
+		{"TLSMissingCertificate", func(cfg *SyslogInputConfig) { cfg.TLS.Enable = true }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewSyslogInputConfig("test")
+			cfg.ListenAddress = "127.0.0.1:5140"
+			cfg.OutputIDs = []string{"fake"}
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSyslogInputTCP(t *testing.T) {
+	cfg := NewSyslogInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	syslogInput := ops[0].(*SyslogInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, syslogInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, syslogInput.Start())
+	defer syslogInput.Stop()
+
+	conn, err := net.Dial("tcp", syslogInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// An octet counted rfc5424 message containing a newline,
+	// followed by a non-transparent rfc3164 message
+	_, err = fmt.Fprintf(conn, "%d %s%s\n", len(rfc5424Message), rfc5424Message, rfc3164Message)
+	require.NoError(t, err)
+
+	e := expectEntry(t, fake)
+	require.Equal(t, expectedRFC5424Record, e.Record)
+	require.Equal(t, time.Date(2015, 8, 5, 21, 58, 59, 693000000, time.UTC), e.Timestamp)
+	require.Equal(t, entry.Info, e.Severity)
+	require.Equal(t, "info", e.SeverityText)
+
+	localAddr := conn.LocalAddr().(*net.TCPAddr)
+	require.Equal(t, map[string]string{
+		"net.transport": "IP.TCP",
+		"net.peer.ip":   localAddr.IP.String(),
+		"net.peer.port": fmt.Sprintf("%d", localAddr.Port),
+	}, e.Labels)
+
+	e = expectEntry(t, fake)
+	require.Equal(t, expectedRFC3164Record, e.Record)
+	require.Equal(t, entry.Critical, e.Severity)
+	require.Equal(t, time.January, e.Timestamp.Month())
+	require.True(t, e.Timestamp.Year() > 1970)
+}
+
+func TestSyslogInputUDP(t *testing.T) {
+	cfg := NewSyslogInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Transport = transportUDP
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	syslogInput := ops[0].(*SyslogInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, syslogInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, syslogInput.Start())
+	defer syslogInput.Stop()
+
+	conn, err := net.Dial("udp", syslogInput.connection.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(rfc5424Message + "\n"))
+	require.NoError(t, err)
+
+	e := expectEntry(t, fake)
+	require.Equal(t, expectedRFC5424Record, e.Record)
+	require.Equal(t, "IP.UDP", e.Labels["net.transport"])
+	require.Equal(t, conn.LocalAddr().(*net.UDPAddr).IP.String(), e.Labels["net.peer.ip"])
+}
+
+func TestSyslogInputFixedProtocol(t *testing.T) {
+	cfg := NewSyslogInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Protocol = protocolRFC3164
+	cfg.Framing = framingNonTransparent
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	syslogInput := ops[0].(*SyslogInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, syslogInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, syslogInput.Start())
+	defer syslogInput.Stop()
+
+	conn, err := net.Dial("tcp", syslogInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, "%s\n", rfc3164Message)
+	require.NoError(t, err)
+
+	e := expectEntry(t, fake)
+	require.Equal(t, expectedRFC3164Record, e.Record)
+}
+
+func TestSyslogInputUnparsable(t *testing.T) {
+	cfg := NewSyslogInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	syslogInput := ops[0].(*SyslogInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, syslogInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, syslogInput.Start())
+	defer syslogInput.Stop()
+
+	conn, err := net.Dial("tcp", syslogInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("not a syslog message\n"))
+	require.NoError(t, err)
+
+	e := expectEntry(t, fake)
+	require.Equal(t, "not a syslog message", e.Record)
+	require.Equal(t, entry.Default, e.Severity)
+}
+
+func TestSyslogInputInvalidFrame(t *testing.T) {
+	cfg := NewSyslogInputConfig("test")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Framing = framingOctetCounting
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	syslogInput := ops[0].(*SyslogInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, syslogInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, syslogInput.Start())
+	defer syslogInput.Stop()
+
+	conn, err := net.Dial("tcp", syslogInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, "%s\n", rfc3164Message)
+	require.NoError(t, err)
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+
+	// The connection is closed after an invalid frame
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	require.False(t, isTimeout(err))
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
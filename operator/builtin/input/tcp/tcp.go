@@ -3,7 +3,6 @@ package tcp
 import (
 	"bufio"
 	"context"
-	"crypto/rand"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -16,6 +15,7 @@ import (
 	"github.com/observiq/stanza/operator"
 	"github.com/observiq/stanza/operator/helper"
 	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
 )
 
 const (
@@ -26,6 +26,10 @@ const (
 	// DefaultMaxBufferSize is the max buffer sized used
 	// if MaxBufferSize is not set
 	DefaultMaxBufferSize = 1024 * 1024
+
+	// defaultReloadInterval is how often TLS certificates
+	// are checked for changes
+	defaultReloadInterval = time.Minute
 )
 
 func init() {
@@ -36,6 +40,11 @@ func init() {
 func NewTCPInputConfig(operatorID string) *TCPInputConfig {
 	return &TCPInputConfig{
 		InputConfig: helper.NewInputConfig(operatorID, "tcp_input"),
+		TLS: TLSConfig{
+			ReloadInterval: helper.Duration{Duration: defaultReloadInterval},
+		},
+		Multiline: helper.NewMultilineConfig(),
+		Encoding:  helper.NewEncodingConfig(),
 	}
 }
 
@@ -43,22 +52,15 @@ func NewTCPInputConfig(operatorID string) *TCPInputConfig {
 type TCPInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	MaxBufferSize helper.ByteSize `json:"max_buffer_size,omitempty" yaml:"max_buffer_size,omitempty"`
-	ListenAddress string          `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
-	TLS           TLSConfig       `json:"tls,omitempty" yaml:"tls,omitempty"`
-	AddLabels     bool            `json:"add_labels,omitempty" yaml:"add_labels,omitempty"`
-}
-
-// TLSConfig is the configuration for a TLS listener
-type TLSConfig struct {
-	// Enable forces the user of TLS
-	Enable bool `json:"enable,omitempty" yaml:"enable,omitempty"`
-
-	// Certificate is the file path for the certificate
-	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
-
-	// PrivateKey is the file path for the private key
-	PrivateKey string `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	MaxBufferSize  helper.ByteSize        `json:"max_buffer_size,omitempty" yaml:"max_buffer_size,omitempty"`
+	ListenAddress  string                 `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	TLS            TLSConfig              `json:"tls,omitempty" yaml:"tls,omitempty"`
+	AddLabels      bool                   `json:"add_labels,omitempty" yaml:"add_labels,omitempty"`
+	MaxConnections int                    `json:"max_connections,omitempty" yaml:"max_connections,omitempty"`
+	IdleTimeout    helper.Duration        `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty"`
+	ReadTimeout    helper.Duration        `json:"read_timeout,omitempty" yaml:"read_timeout,omitempty"`
+	Multiline      helper.MultilineConfig `json:"multiline,omitempty" yaml:"multiline,omitempty"`
+	Encoding       helper.EncodingConfig  `json:",inline,omitempty" yaml:",inline,omitempty"`
 }
 
 // Build will build a tcp input operator.
@@ -87,30 +89,49 @@ func (c TCPInputConfig) Build(context operator.BuildContext) ([]operator.Operato
 		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
 	}
 
-	cert := tls.Certificate{}
-	if c.TLS.Enable {
-		if c.TLS.Certificate == "" {
-			return nil, fmt.Errorf("missing required parameter 'certificate', required when TLS is enabled")
-		}
+	if c.MaxConnections < 0 {
+		return nil, fmt.Errorf("invalid value for parameter 'max_connections', must not be negative")
+	}
 
-		if c.TLS.PrivateKey == "" {
-			return nil, fmt.Errorf("missing required parameter 'private_key', required when TLS is enabled")
-		}
+	if c.IdleTimeout.Raw() < 0 || c.ReadTimeout.Raw() < 0 {
+		return nil, fmt.Errorf("invalid value for parameters 'idle_timeout' and 'read_timeout', must not be negative")
+	}
 
-		c, err := tls.LoadX509KeyPair(c.TLS.Certificate, c.TLS.PrivateKey)
+	encoding, err := c.Encoding.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	splitFunc, err := c.Multiline.Build(context, encoding.Encoding, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if c.TLS.Enable {
+		tlsConfig, err = c.TLS.build(inputOperator.SugaredLogger)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+			return nil, err
 		}
-		cert = c
+	}
+
+	var connections *semaphore.Weighted
+	if c.MaxConnections > 0 {
+		connections = semaphore.NewWeighted(int64(c.MaxConnections))
 	}
 
 	tcpInput := &TCPInput{
-		InputOperator: inputOperator,
-		address:       c.ListenAddress,
-		maxBufferSize: int(c.MaxBufferSize),
-		addLabels:     c.AddLabels,
-		tlsEnable:     c.TLS.Enable,
-		tlsKeyPair:    cert,
+		InputOperator:    inputOperator,
+		address:          c.ListenAddress,
+		maxBufferSize:    int(c.MaxBufferSize),
+		addLabels:        c.AddLabels,
+		tlsConfig:        tlsConfig,
+		connections:      connections,
+		idleTimeout:      c.IdleTimeout.Raw(),
+		readTimeout:      c.ReadTimeout.Raw(),
+		encoding:         encoding,
+		splitFunc:        splitFunc,
+		forceFlushPeriod: c.Multiline.ForceFlushPeriod.Raw(),
 		backoff: backoff.Backoff{
 			Min:    100 * time.Millisecond,
 			Max:    3 * time.Second,
@@ -124,12 +145,17 @@ func (c TCPInputConfig) Build(context operator.BuildContext) ([]operator.Operato
 // TCPInput is an operator that listens for log entries over tcp.
 type TCPInput struct {
 	helper.InputOperator
-	address       string
-	maxBufferSize int
-	addLabels     bool
-	tlsEnable     bool
-	tlsKeyPair    tls.Certificate
-	backoff       backoff.Backoff
+	address          string
+	maxBufferSize    int
+	addLabels        bool
+	tlsConfig        *tls.Config
+	connections      *semaphore.Weighted
+	idleTimeout      time.Duration
+	readTimeout      time.Duration
+	encoding         helper.Encoding
+	splitFunc        bufio.SplitFunc
+	forceFlushPeriod time.Duration
+	backoff          backoff.Backoff
 
 	listener net.Listener
 	cancel   context.CancelFunc
@@ -149,7 +175,7 @@ func (t *TCPInput) Start() error {
 }
 
 func (t *TCPInput) configureListener() error {
-	if !t.tlsEnable {
+	if t.tlsConfig == nil {
 		listener, err := net.Listen("tcp", t.address)
 		if err != nil {
 			return fmt.Errorf("failed to configure tcp listener: %w", err)
@@ -158,21 +184,7 @@ func (t *TCPInput) configureListener() error {
 		return nil
 	}
 
-	// TLS 1.0 is the package default since Go 1.2
-	// https://golang.org/pkg/crypto/tls/
-	// An issue has been filed to support modifyingn the minimum version
-	// https://github.com/observIQ/stanza/issues/349
-	var tlsVersion uint16 = tls.VersionTLS10
-
-	// #nosec - Go defaults to TLS 1.0, and some users may require it
-	config := tls.Config{
-		Certificates: []tls.Certificate{t.tlsKeyPair},
-		MinVersion:   tlsVersion,
-	}
-	config.Time = func() time.Time { return time.Now() }
-	config.Rand = rand.Reader
-
-	listener, err := tls.Listen("tcp", t.address, &config)
+	listener, err := tls.Listen("tcp", t.address, t.tlsConfig)
 	if err != nil {
 		return fmt.Errorf("failed to configure tls listener: %w", err)
 	}
@@ -202,6 +214,14 @@ func (t *TCPInput) goListen(ctx context.Context) {
 			}
 			t.backoff.Reset()
 
+			if t.connections != nil && !t.connections.TryAcquire(1) {
+				t.Warnw("Rejecting connection, max_connections reached", "remote_addr", conn.RemoteAddr().String())
+				if err := conn.Close(); err != nil {
+					t.Errorf("Failed to close connection: %s", err)
+				}
+				continue
+			}
+
 			t.Debugf("Received connection: %s", conn.RemoteAddr().String())
 			subctx, cancel := context.WithCancel(ctx)
 			t.goHandleClose(subctx, conn)
@@ -221,6 +241,9 @@ func (t *TCPInput) goHandleClose(ctx context.Context, conn net.Conn) {
 		if err := conn.Close(); err != nil {
 			t.Errorf("Failed to close connection: %s", err)
 		}
+		if t.connections != nil {
+			t.connections.Release(1)
+		}
 	}()
 }
 
@@ -232,31 +255,57 @@ func (t *TCPInput) goHandleMessages(ctx context.Context, conn net.Conn, cancel c
 		defer t.wg.Done()
 		defer cancel()
 
+		timeoutConn := newTimeoutConn(conn, t.idleTimeout, t.readTimeout)
+		flusher := helper.NewFlusher(t.forceFlushPeriod)
+		reader := helper.NewFlushReader(timeoutConn, flusher)
+
 		// Initial buffer size is 64k
 		buf := make([]byte, 0, 64*1024)
-		scanner := bufio.NewScanner(conn)
-		scanner.Buffer(buf, t.maxBufferSize*1024)
-		for scanner.Scan() {
-			entry, err := t.NewEntry(scanner.Text())
-			if err != nil {
-				t.Errorw("Failed to create entry", zap.Error(err))
-				continue
-			}
 
-			if t.addLabels {
-				entry.AddLabel("net.transport", "IP.TCP")
-				if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
-					entry.AddLabel("net.peer.ip", addr.IP.String())
-					entry.AddLabel("net.peer.port", strconv.FormatInt(int64(addr.Port), 10))
+		// Labels are created after the first read, which completes the TLS handshake
+		var labels map[string]string
+		var scanner *bufio.Scanner
+		for {
+			scanner = bufio.NewScanner(reader)
+			scanner.Buffer(buf, t.maxBufferSize*1024)
+			scanner.Split(timeoutConn.split(flusher.SplitFunc(t.splitFunc)))
+
+			for scanner.Scan() {
+				decoded, err := t.encoding.Decode(scanner.Bytes())
+				if err != nil {
+					t.Errorw("Failed to decode message", zap.Error(err))
+					continue
 				}
 
-				if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
-					entry.AddLabel("net.host.ip", addr.IP.String())
-					entry.AddLabel("net.host.port", strconv.FormatInt(int64(addr.Port), 10))
+				entry, err := t.NewEntry(decoded)
+				if err != nil {
+					t.Errorw("Failed to create entry", zap.Error(err))
+					continue
 				}
+
+				if t.addLabels {
+					if labels == nil {
+						labels = connectionLabels(conn)
+					}
+					for k, v := range labels {
+						entry.AddLabel(k, v)
+					}
+				}
+				if reader.ForceFlushed {
+					entry.AddLabel(helper.ForceFlushedLabel, "true")
+				}
+
+				t.Write(ctx, entry)
 			}
 
-			t.Write(ctx, entry)
+			// The connection is read again after an incomplete entry is force flushed
+			if !reader.ForceFlushed || scanner.Err() != nil {
+				break
+			}
+			reader.ForceFlushed = false
+		}
+		if timeoutConn.timedOut {
+			t.Debugf("Connection timed out: %s", conn.RemoteAddr().String())
 		}
 		if err := scanner.Err(); err != nil {
 			// Use of closed network connection is expected if the context is canceled
@@ -272,6 +321,32 @@ func (t *TCPInput) goHandleMessages(ctx context.Context, conn net.Conn, cancel c
 	}()
 }
 
+// connectionLabels returns the labels that describe a connection, including
+// the common name of the client certificate of a TLS connection
+func connectionLabels(conn net.Conn) map[string]string {
+	labels := map[string]string{
+		"net.transport": "IP.TCP",
+	}
+
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		labels["net.peer.ip"] = addr.IP.String()
+		labels["net.peer.port"] = strconv.FormatInt(int64(addr.Port), 10)
+	}
+
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		labels["net.host.ip"] = addr.IP.String()
+		labels["net.host.port"] = strconv.FormatInt(int64(addr.Port), 10)
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			labels["tls.client.common_name"] = certs[0].Subject.CommonName
+		}
+	}
+
+	return labels
+}
+
 // Stop will stop listening for log entries over TCP.
 func (t *TCPInput) Stop() error {
 	t.cancel()
@@ -10,6 +10,7 @@ import (
 
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
 	"github.com/observiq/stanza/testutil"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -390,3 +391,257 @@ func BenchmarkTcpInput(b *testing.B) {
 
 	defer close(done)
 }
+
+func expectRecord(t *testing.T, fake *testutil.FakeOutput, expected interface{}) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, expected, e.Record)
+		return e
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+// expectClosed asserts that the server closes the connection
+func expectClosed(t *testing.T, conn net.Conn) {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	netErr, ok := err.(net.Error)
+	require.False(t, ok && netErr.Timeout(), "connection was not closed")
+}
+
+func TestBuildOptions(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*TCPInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *TCPInputConfig) {}, false},
+		{"Limits", func(cfg *TCPInputConfig) {
+			cfg.MaxConnections = 10
+			cfg.IdleTimeout = helper.Duration{Duration: time.Minute}
+			cfg.ReadTimeout = helper.Duration{Duration: time.Second}
+		}, false},
+		{"NegativeMaxConnections", func(cfg *TCPInputConfig) { cfg.MaxConnections = -1 }, true},
+		{"NegativeIdleTimeout", func(cfg *TCPInputConfig) { cfg.IdleTimeout = helper.Duration{Duration: -time.Second} }, true},
+		{"NegativeReadTimeout", func(cfg *TCPInputConfig) { cfg.ReadTimeout = helper.Duration{Duration: -time.Second} }, true},
+		{"InvalidEncoding", func(cfg *TCPInputConfig) { cfg.Encoding.Encoding = "invalid" }, true},
+		{"InvalidMultiline", func(cfg *TCPInputConfig) {
+			cfg.Multiline.LineStartPattern = "^start"
+			cfg.Multiline.LineEndPattern = "end$"
+		}, true},
+		{"TLSMissingCertificate", func(cfg *TCPInputConfig) { cfg.TLS.Enable = true }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewTCPInputConfig("test_id")
+			cfg.ListenAddress = "10.0.0.1:9000"
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTcpInputMultiline(t *testing.T) {
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.Multiline.LineStartPattern = `^\d{4}-\d{2}-\d{2} `
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("2021-06-01 panic: oops\n\tat main.go:12\n2021-06-01 done\n"))
+	require.NoError(t, err)
+	expectRecord(t, fake, "2021-06-01 panic: oops\n\tat main.go:12\n")
+
+	// The last entry is flushed when the connection is closed
+	require.NoError(t, conn.Close())
+	expectRecord(t, fake, "2021-06-01 done\n")
+}
+
+func TestTcpInputEncoding(t *testing.T) {
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.Encoding = helper.EncodingConfig{Encoding: "utf-16le"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{'t', 0, 'e', 0, 's', 0, 't', 0, '\n', 0})
+	require.NoError(t, err)
+	expectRecord(t, fake, "test")
+}
+
+func TestTcpInputMaxConnections(t *testing.T) {
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.MaxConnections = 1
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	first, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+	_, err = first.Write([]byte("first\n"))
+	require.NoError(t, err)
+	expectRecord(t, fake, "first")
+
+	second, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	expectClosed(t, second)
+
+	// A connection is accepted again once the first one is closed
+	require.NoError(t, first.Close())
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+		if err != nil {
+			return false
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("third\n")); err != nil {
+			return false
+		}
+		select {
+		case e := <-fake.Received:
+			return e.Record == "third"
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestTcpInputIdleTimeout(t *testing.T) {
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.IdleTimeout = helper.Duration{Duration: 200 * time.Millisecond}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("message1\npartial"))
+	require.NoError(t, err)
+	expectRecord(t, fake, "message1")
+
+	// The partial entry is flushed when the idle connection is closed
+	expectRecord(t, fake, "partial")
+	expectClosed(t, conn)
+}
+
+func TestTcpInputReadTimeout(t *testing.T) {
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.ReadTimeout = helper.Duration{Duration: 300 * time.Millisecond}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Complete entries keep the connection open
+	for i := 0; i < 4; i++ {
+		_, err = conn.Write([]byte("message\n"))
+		require.NoError(t, err)
+		expectRecord(t, fake, "message")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// An entry that is sent slowly is flushed when the read timeout expires
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = conn.Write([]byte("slow"))
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+	expectRecord(t, fake, "slowslowslow")
+	require.True(t, time.Since(start) < time.Second)
+	expectClosed(t, conn)
+}
+
+func TestTcpInputForceFlush(t *testing.T) {
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.Multiline.LineStartPattern = `^\d{4}-\d{2}-\d{2} `
+	cfg.Multiline.ForceFlushPeriod = helper.Duration{Duration: 200 * time.Millisecond}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	conn, err := net.Dial("tcp", tcpInput.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("2021-06-01 panic: oops\n\tat main.go:12\n2021-06-01 done\n"))
+	require.NoError(t, err)
+	e := expectRecord(t, fake, "2021-06-01 panic: oops\n\tat main.go:12\n")
+	require.NotContains(t, e.Labels, helper.ForceFlushedLabel)
+
+	// The last entry is flushed once no new data arrives within the period
+	e = expectRecord(t, fake, "2021-06-01 done\n")
+	require.Equal(t, "true", e.Labels[helper.ForceFlushedLabel])
+
+	// The connection is still read after the flush
+	_, err = conn.Write([]byte("2021-06-02 next\n2021-06-02 last\n"))
+	require.NoError(t, err)
+	e = expectRecord(t, fake, "2021-06-02 next\n")
+	require.NotContains(t, e.Labels, helper.ForceFlushedLabel)
+	e = expectRecord(t, fake, "2021-06-02 last\n")
+	require.Equal(t, "true", e.Labels[helper.ForceFlushedLabel])
+}
@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// timeoutConn is a connection with a deadline that closes idle
+// connections, and connections that do not complete an entry in time.
+// A timeout is reported as the end of the stream, so that a partial
+// entry is flushed before the connection is closed. It is read with a
+// helper.FlushReader, whose force flush deadline is applied if earlier.
+type timeoutConn struct {
+	net.Conn
+	idleTimeout time.Duration
+	readTimeout time.Duration
+
+	// entryStart is when the first byte of an incomplete entry was read,
+	// or zero if no incomplete entry is buffered
+	entryStart time.Time
+	deadline   time.Time
+	timedOut   bool
+}
+
+func newTimeoutConn(conn net.Conn, idleTimeout, readTimeout time.Duration) *timeoutConn {
+	return &timeoutConn{
+		Conn:        conn,
+		idleTimeout: idleTimeout,
+		readTimeout: readTimeout,
+	}
+}
+
+// SetReadDeadline sets the earliest of the timeout deadline and the force flush deadline
+func (c *timeoutConn) SetReadDeadline(flushDeadline time.Time) error {
+	c.deadline = c.timeoutDeadline()
+	deadline := c.deadline
+	if !flushDeadline.IsZero() && (deadline.IsZero() || flushDeadline.Before(deadline)) {
+		deadline = flushDeadline
+	}
+	return c.Conn.SetReadDeadline(deadline)
+}
+
+// Read reads from the connection, returning io.EOF if the timeout deadline is exceeded
+func (c *timeoutConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if os.IsTimeout(err) && !c.deadline.IsZero() && !time.Now().Before(c.deadline) {
+		c.timedOut = true
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// timeoutDeadline returns the earliest of the idle and entry deadlines
+func (c *timeoutConn) timeoutDeadline() time.Time {
+	var deadline time.Time
+	if c.idleTimeout > 0 {
+		deadline = time.Now().Add(c.idleTimeout)
+	}
+	if c.readTimeout > 0 && !c.entryStart.IsZero() {
+		entryDeadline := c.entryStart.Add(c.readTimeout)
+		if deadline.IsZero() || entryDeadline.Before(deadline) {
+			deadline = entryDeadline
+		}
+	}
+	return deadline
+}
+
+// split wraps a split func to track when an incomplete entry is buffered
+func (c *timeoutConn) split(splitFunc bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := splitFunc(data, atEOF)
+		switch {
+		case advance >= len(data):
+			c.entryStart = time.Time{}
+		case advance > 0 || c.entryStart.IsZero():
+			c.entryStart = time.Now()
+		}
+		return advance, token, err
+	}
+}
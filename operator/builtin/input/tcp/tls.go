@@ -0,0 +1,156 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+)
+
+// TLSConfig is the configuration for a TLS listener
+type TLSConfig struct {
+	// Enable forces the user of TLS
+	Enable bool `json:"enable,omitempty" yaml:"enable,omitempty"`
+
+	// Certificate is the file path for the certificate
+	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+
+	// PrivateKey is the file path for the private key
+	PrivateKey string `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+
+	// CAFile is the file path for the CA used to verify client certificates
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+
+	// ReloadInterval is how often the certificate and private key are checked for changes
+	ReloadInterval helper.Duration `json:"reload_interval,omitempty" yaml:"reload_interval,omitempty"`
+}
+
+// build creates a tls.Config for a listener
+func (c TLSConfig) build(logger *zap.SugaredLogger) (*tls.Config, error) {
+	if c.Certificate == "" {
+		return nil, fmt.Errorf("missing required parameter 'certificate', required when TLS is enabled")
+	}
+
+	if c.PrivateKey == "" {
+		return nil, fmt.Errorf("missing required parameter 'private_key', required when TLS is enabled")
+	}
+
+	reloader := &certificateReloader{
+		certFile: c.Certificate,
+		keyFile:  c.PrivateKey,
+		interval: c.ReloadInterval.Raw(),
+		logger:   logger,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	// TLS 1.0 is the package default since Go 1.2
+	// https://golang.org/pkg/crypto/tls/
+	// An issue has been filed to support modifyingn the minimum version
+	// https://github.com/observIQ/stanza/issues/349
+	var tlsVersion uint16 = tls.VersionTLS10
+
+	// #nosec - Go defaults to TLS 1.0, and some users may require it
+	config := &tls.Config{
+		GetCertificate: reloader.getCertificate,
+		MinVersion:     tlsVersion,
+	}
+
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca_file '%s'", c.CAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// certificateReloader serves a certificate that is reloaded when its
+// files are modified, so that certificates can be rotated without a restart
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   *zap.SugaredLogger
+
+	mutex     sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// load reads the certificate and private key files
+func (r *certificateReloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	r.lastCheck = time.Now()
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the certificate and private key
+func (r *certificateReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// getCertificate returns the current certificate, reloading it if its files
+// have changed since the last check. If the files cannot be loaded, such as
+// when only one of them has been replaced, the previous certificate is used.
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.interval <= 0 || time.Since(r.lastCheck) < r.interval {
+		return r.cert, nil
+	}
+	r.lastCheck = time.Now()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		r.logger.Warnw("Failed to check tls certificate for changes", zap.Error(err))
+		return r.cert, nil
+	}
+	if modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+
+	if err := r.load(); err != nil {
+		r.logger.Warnw("Failed to reload tls certificate, using the previous certificate", zap.Error(err))
+		return r.cert, nil
+	}
+	r.logger.Infow("Reloaded tls certificate", "certificate", r.certFile)
+	return r.cert, nil
+}
@@ -0,0 +1,261 @@
+package tcp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a PEM encoded certificate and private key signed by the CA
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, contents []byte) {
+	require.NoError(t, ioutil.WriteFile(path, contents, 0600))
+}
+
+func TestTLSConfigBuild(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "server", 2)
+	writeFile(t, filepath.Join(dir, "server.crt"), certPEM)
+	writeFile(t, filepath.Join(dir, "server.key"), keyPEM)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+	writeFile(t, filepath.Join(dir, "invalid.crt"), []byte("not a certificate"))
+
+	cases := []struct {
+		name      string
+		config    TLSConfig
+		expectErr bool
+	}{
+		{"Valid", TLSConfig{Certificate: "server.crt", PrivateKey: "server.key"}, false},
+		{"ValidCA", TLSConfig{Certificate: "server.crt", PrivateKey: "server.key", CAFile: "ca.crt"}, false},
+		{"MissingPrivateKey", TLSConfig{Certificate: "server.crt"}, true},
+		{"MismatchedKeyPair", TLSConfig{Certificate: "ca.crt", PrivateKey: "server.key"}, true},
+		{"MissingCAFile", TLSConfig{Certificate: "server.crt", PrivateKey: "server.key", CAFile: "missing.crt"}, true},
+		{"InvalidCAFile", TLSConfig{Certificate: "server.crt", PrivateKey: "server.key", CAFile: "invalid.crt"}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.config
+			cfg.Enable = true
+			for _, path := range []*string{&cfg.Certificate, &cfg.PrivateKey, &cfg.CAFile} {
+				if *path != "" {
+					*path = filepath.Join(dir, *path)
+				}
+			}
+
+			tlsConfig, err := cfg.build(zap.NewNop().Sugar())
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if cfg.CAFile != "" {
+				require.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestTcpInputMutualTLS(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "server", 2)
+	writeFile(t, filepath.Join(dir, "server.crt"), serverCert)
+	writeFile(t, filepath.Join(dir, "server.key"), serverKey)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+
+	cfg := NewTCPInputConfig("test_id")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.AddLabels = true
+	cfg.TLS.Enable = true
+	cfg.TLS.Certificate = filepath.Join(dir, "server.crt")
+	cfg.TLS.PrivateKey = filepath.Join(dir, "server.key")
+	cfg.TLS.CAFile = filepath.Join(dir, "ca.crt")
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	tcpInput := ops[0].(*TCPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	tcpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, tcpInput.Start())
+	defer tcpInput.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	clientCertPEM, clientKeyPEM := ca.issue(t, "client-1", 3)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	require.NoError(t, err)
+
+	t.Run("ClientCertificate", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", tcpInput.listener.Addr().String(), &tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{clientCert},
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("message\n"))
+		require.NoError(t, err)
+
+		e := expectRecord(t, fake, "message")
+		require.Equal(t, "client-1", e.Labels["tls.client.common_name"])
+		require.Equal(t, "IP.TCP", e.Labels["net.transport"])
+	})
+
+	t.Run("NoClientCertificate", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", tcpInput.listener.Addr().String(), &tls.Config{RootCAs: roots})
+		if err == nil {
+			defer conn.Close()
+			// With TLS 1.3, the client learns that its certificate was rejected on its first read
+			_, _ = conn.Write([]byte("message\n"))
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+			_, err = conn.Read(make([]byte, 1))
+		}
+		require.Error(t, err)
+		fake.ExpectNoEntry(t, 100*time.Millisecond)
+	})
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "server", 2)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: time.Nanosecond,
+		logger:   zap.NewNop().Sugar(),
+	}
+	require.NoError(t, reloader.load())
+
+	commonName := func() string {
+		cert, err := reloader.getCertificate(nil)
+		require.NoError(t, err)
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return parsed.Subject.CommonName
+	}
+	require.Equal(t, "server", commonName())
+
+	// A certificate that does not match the private key is not used
+	rotatedCert, rotatedKey := ca.issue(t, "rotated", 3)
+	writeFile(t, certFile, rotatedCert)
+	touch(t, certFile, time.Now().Add(time.Minute))
+	require.Equal(t, "server", commonName())
+
+	// The certificate is reloaded once both files are replaced
+	writeFile(t, keyFile, rotatedKey)
+	touch(t, keyFile, time.Now().Add(2*time.Minute))
+	require.Equal(t, "rotated", commonName())
+}
+
+// touch sets the modification time of a file, since writes in quick
+// succession may not change it on file systems with coarse timestamps
+func touch(t *testing.T, path string, modTime time.Time) {
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCertificateReloaderDisabled(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "server", 2)
+	writeFile(t, filepath.Join(dir, "server.crt"), certPEM)
+	writeFile(t, filepath.Join(dir, "server.key"), keyPEM)
+
+	cfg := TLSConfig{
+		Enable:         true,
+		Certificate:    filepath.Join(dir, "server.crt"),
+		PrivateKey:     filepath.Join(dir, "server.key"),
+		ReloadInterval: helper.Duration{Duration: 0},
+	}
+	tlsConfig, err := cfg.build(zap.NewNop().Sugar())
+	require.NoError(t, err)
+	cert, err := tlsConfig.GetCertificate(nil)
+	require.NoError(t, err)
+
+	// Removing the files does not affect the loaded certificate
+	require.NoError(t, os.Remove(cfg.Certificate))
+	reloaded, err := tlsConfig.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cert, reloaded)
+}
@@ -0,0 +1,51 @@
+package udp
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropCounterSize is the size of the control message that
+// contains the number of dropped datagrams
+var dropCounterSize = unix.CmsgSpace(4)
+
+// enableDropCounter sets SO_RXQ_OVFL on a socket, so that each datagram
+// includes the number of datagrams dropped by the socket so far
+func enableDropCounter(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RXQ_OVFL, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// parseDropCounter returns the number of dropped datagrams from the
+// control messages of a datagram
+func parseDropCounter(oob []byte) (uint32, bool) {
+	if len(oob) == 0 {
+		return 0, false
+	}
+
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, m := range messages {
+		if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SO_RXQ_OVFL && len(m.Data) >= 4 {
+			// The counter is a native endian uint32
+			return *(*uint32)(unsafe.Pointer(&m.Data[0])), true // #nosec G103
+		}
+	}
+	return 0, false
+}
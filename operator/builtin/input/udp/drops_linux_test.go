@@ -0,0 +1,46 @@
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropCounter(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, enableDropCounter(conn))
+	require.NoError(t, conn.SetReadBuffer(4096))
+
+	sender, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	// Overflow the receive buffer before reading
+	message := make([]byte, 1024)
+	for i := 0; i < 100; i++ {
+		_, err := sender.Write(message)
+		require.NoError(t, err)
+	}
+
+	// The counter is only included with datagrams received after a drop
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buffer := make([]byte, 2048)
+	oob := make([]byte, dropCounterSize)
+	for {
+		_, oobn, _, _, err := conn.ReadMsgUDP(buffer, oob)
+		require.NoError(t, err)
+
+		if dropped, ok := parseDropCounter(oob[:oobn]); ok {
+			require.True(t, dropped > 0)
+			return
+		}
+
+		_, err = sender.Write(message)
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,16 @@
+// +build !linux
+
+package udp
+
+import "net"
+
+// dropCounterSize is zero, since dropped datagrams are only counted on linux
+const dropCounterSize = 0
+
+func enableDropCounter(conn *net.UDPConn) error {
+	return nil
+}
+
+func parseDropCounter(oob []byte) (uint32, bool) {
+	return 0, false
+}
@@ -0,0 +1,14 @@
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package udp
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const reusePortSupported = false
+
+func setReusePort(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on this platform")
+}
@@ -1,17 +1,34 @@
 package udp
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/observiq/stanza/operator"
 	"github.com/observiq/stanza/operator/helper"
 	"go.uber.org/zap"
 )
 
+const (
+	// defaultMaxMessageSize is the size of the buffer
+	// used to read each datagram
+	defaultMaxMessageSize = 8192
+
+	// maxDatagramSize is the largest possible UDP payload
+	maxDatagramSize = 65535
+
+	// reportInterval is how often truncated and dropped
+	// datagrams are reported
+	reportInterval = time.Minute
+)
+
 func init() {
 	operator.Register("udp_input", func() operator.Builder { return NewUDPInputConfig("") })
 }
@@ -19,7 +36,12 @@ func init() {
 // NewUDPInputConfig creates a new UDP input config with default values
 func NewUDPInputConfig(operatorID string) *UDPInputConfig {
 	return &UDPInputConfig{
-		InputConfig: helper.NewInputConfig(operatorID, "udp_input"),
+		InputConfig:    helper.NewInputConfig(operatorID, "udp_input"),
+		MaxMessageSize: defaultMaxMessageSize,
+		Sockets:        1,
+		Readers:        1,
+		Multiline:      helper.NewMultilineConfig(),
+		Encoding:       helper.NewEncodingConfig(),
 	}
 }
 
@@ -27,8 +49,15 @@ func NewUDPInputConfig(operatorID string) *UDPInputConfig {
 type UDPInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	ListenAddress string `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
-	AddLabels     bool   `json:"add_labels,omitempty" yaml:"add_labels,omitempty"`
+	ListenAddress     string                 `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	AddLabels         bool                   `json:"add_labels,omitempty" yaml:"add_labels,omitempty"`
+	MaxMessageSize    helper.ByteSize        `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
+	ReceiveBufferSize helper.ByteSize        `json:"receive_buffer_size,omitempty" yaml:"receive_buffer_size,omitempty"`
+	Sockets           int                    `json:"sockets,omitempty" yaml:"sockets,omitempty"`
+	Readers           int                    `json:"readers,omitempty" yaml:"readers,omitempty"`
+	ReusePort         bool                   `json:"reuse_port,omitempty" yaml:"reuse_port,omitempty"`
+	Multiline         helper.MultilineConfig `json:"multiline,omitempty" yaml:"multiline,omitempty"`
+	Encoding          helper.EncodingConfig  `json:",inline,omitempty" yaml:",inline,omitempty"`
 }
 
 // Build will build a udp input operator.
@@ -47,25 +76,91 @@ func (c UDPInputConfig) Build(context operator.BuildContext) ([]operator.Operato
 		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
 	}
 
+	if c.MaxMessageSize == 0 {
+		c.MaxMessageSize = defaultMaxMessageSize
+	}
+	if c.MaxMessageSize < 0 || c.MaxMessageSize > maxDatagramSize {
+		return nil, fmt.Errorf("invalid value for parameter 'max_message_size', must be between 1 and %d bytes", maxDatagramSize)
+	}
+
+	if c.ReceiveBufferSize < 0 {
+		return nil, fmt.Errorf("invalid value for parameter 'receive_buffer_size', must not be negative")
+	}
+
+	if c.Sockets == 0 {
+		c.Sockets = 1
+	}
+	if c.Sockets < 0 {
+		return nil, fmt.Errorf("invalid value for parameter 'sockets', must be positive")
+	}
+
+	if c.Readers == 0 {
+		c.Readers = 1
+	}
+	if c.Readers < 0 {
+		return nil, fmt.Errorf("invalid value for parameter 'readers', must be positive")
+	}
+
+	// Several sockets can only listen on the same address with SO_REUSEPORT
+	reusePort := c.ReusePort || c.Sockets > 1
+	if reusePort && !reusePortSupported {
+		return nil, fmt.Errorf("reuse_port and multiple sockets are not supported on this platform")
+	}
+
+	encoding, err := c.Encoding.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	var splitFunc bufio.SplitFunc
+	if c.Multiline.LineStartPattern != "" || c.Multiline.LineEndPattern != "" {
+		splitFunc, err = c.Multiline.Build(context, encoding.Encoding, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	udpInput := &UDPInput{
-		InputOperator: inputOperator,
-		address:       address,
-		buffer:        make([]byte, 8192),
-		addLabels:     c.AddLabels,
+		InputOperator:     inputOperator,
+		address:           address,
+		addLabels:         c.AddLabels,
+		maxMessageSize:    int(c.MaxMessageSize),
+		receiveBufferSize: int(c.ReceiveBufferSize),
+		sockets:           c.Sockets,
+		readers:           c.Readers,
+		reusePort:         reusePort,
+		encoding:          encoding,
+		splitFunc:         splitFunc,
 	}
 	return []operator.Operator{udpInput}, nil
 }
 
 // UDPInput is an operator that listens to a socket for log entries.
 type UDPInput struct {
-	buffer []byte
 	helper.InputOperator
-	address   *net.UDPAddr
-	addLabels bool
+	address           *net.UDPAddr
+	addLabels         bool
+	maxMessageSize    int
+	receiveBufferSize int
+	sockets           int
+	readers           int
+	reusePort         bool
+	encoding          helper.Encoding
+	splitFunc         bufio.SplitFunc
+
+	connections []*connection
+	truncated   uint64
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
 
-	connection net.PacketConn
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
+// connection is a socket that is read by one or more readers
+type connection struct {
+	*net.UDPConn
+
+	// dropped is the number of datagrams the kernel dropped because the
+	// receive buffer was full. It is only available on linux.
+	dropped uint32
 }
 
 // Start will start listening for messages on a socket.
@@ -73,79 +168,225 @@ func (u *UDPInput) Start() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	u.cancel = cancel
 
-	conn, err := net.ListenUDP("udp", u.address)
-	if err != nil {
-		return fmt.Errorf("failed to open connection: %s", err)
+	address := u.address
+	for i := 0; i < u.sockets; i++ {
+		conn, err := u.listen(ctx, address)
+		if err != nil {
+			u.closeConnections()
+			cancel()
+			return fmt.Errorf("failed to open connection: %s", err)
+		}
+		u.connections = append(u.connections, &connection{UDPConn: conn})
+
+		// Additional sockets listen on the port that was assigned to the first
+		address = conn.LocalAddr().(*net.UDPAddr)
 	}
-	u.connection = conn
 
-	u.goHandleMessages(ctx)
+	for _, conn := range u.connections {
+		for i := 0; i < u.readers; i++ {
+			u.goHandleMessages(ctx, conn)
+		}
+	}
+	u.goReportDropped(ctx)
 	return nil
 }
 
+// listen opens a socket with the configured socket options
+func (u *UDPInput) listen(ctx context.Context, address *net.UDPAddr) (*net.UDPConn, error) {
+	listenConfig := net.ListenConfig{}
+	if u.reusePort {
+		listenConfig.Control = setReusePort
+	}
+
+	packetConn, err := listenConfig.ListenPacket(ctx, "udp", address.String())
+	if err != nil {
+		return nil, err
+	}
+	conn := packetConn.(*net.UDPConn)
+
+	if u.receiveBufferSize > 0 {
+		if err := conn.SetReadBuffer(u.receiveBufferSize); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("set receive buffer size: %s", err)
+		}
+	}
+
+	if err := enableDropCounter(conn); err != nil {
+		u.Debugw("Failed to enable the dropped datagram counter", zap.Error(err))
+	}
+	return conn, nil
+}
+
 // goHandleMessages will handle messages from a udp connection.
-func (u *UDPInput) goHandleMessages(ctx context.Context) {
+func (u *UDPInput) goHandleMessages(ctx context.Context, conn *connection) {
 	u.wg.Add(1)
 
 	go func() {
 		defer u.wg.Done()
 
+		// One extra byte is used to detect datagrams that exceed max_message_size
+		buffer := make([]byte, u.maxMessageSize+1)
+		oob := make([]byte, dropCounterSize)
 		for {
-			message, remoteAddr, err := u.readMessage()
+			n, oobn, _, remoteAddr, err := conn.ReadMsgUDP(buffer, oob)
 			if err != nil {
 				select {
 				case <-ctx.Done():
 					return
 				default:
 					u.Errorw("Failed reading messages", zap.Error(err))
+					continue
 				}
-				break
 			}
 
-			entry, err := u.NewEntry(message)
-			if err != nil {
-				u.Errorw("Failed to create entry", zap.Error(err))
-				continue
+			if dropped, ok := parseDropCounter(oob[:oobn]); ok {
+				conn.updateDropped(dropped)
 			}
 
-			if u.addLabels {
-				entry.AddLabel("net.transport", "IP.UDP")
-				if addr, ok := u.connection.LocalAddr().(*net.UDPAddr); ok {
-					entry.AddLabel("net.host.ip", addr.IP.String())
-					entry.AddLabel("net.host.port", strconv.FormatInt(int64(addr.Port), 10))
-				}
-
-				if addr, ok := remoteAddr.(*net.UDPAddr); ok {
-					entry.AddLabel("net.peer.ip", addr.IP.String())
-					entry.AddLabel("net.peer.port", strconv.FormatInt(int64(addr.Port), 10))
-				}
+			if n > u.maxMessageSize {
+				atomic.AddUint64(&u.truncated, 1)
+				n = u.maxMessageSize
 			}
 
-			u.Write(ctx, entry)
+			u.handleMessage(ctx, buffer[:n], conn, remoteAddr)
 		}
 	}()
 }
 
-// readMessage will read log messages from the connection.
-func (u *UDPInput) readMessage() (string, net.Addr, error) {
-	n, addr, err := u.connection.ReadFrom(u.buffer)
+// handleMessage writes the entries of a datagram. The datagram is a
+// single entry unless multiline is configured.
+func (u *UDPInput) handleMessage(ctx context.Context, message []byte, conn *connection, remoteAddr *net.UDPAddr) {
+	if u.splitFunc == nil {
+		u.writeEntry(ctx, message, conn, remoteAddr)
+		return
+	}
+
+	for len(message) > 0 {
+		// The end of the datagram is only signaled once no complete entry
+		// remains, since split funcs flush all remaining data at EOF
+		advance, token, err := u.splitFunc(message, false)
+		if err == nil && advance == 0 && token == nil {
+			advance, token, err = u.splitFunc(message, true)
+		}
+		if err != nil {
+			u.Errorw("Failed to split message", zap.Error(err))
+			return
+		}
+
+		// Data that the split func does not consume is a single entry
+		if advance <= 0 || advance > len(message) {
+			token, advance = message, len(message)
+		}
+		if token != nil {
+			u.writeEntry(ctx, token, conn, remoteAddr)
+		}
+		message = message[advance:]
+	}
+}
+
+// writeEntry decodes a message and writes it as an entry
+func (u *UDPInput) writeEntry(ctx context.Context, message []byte, conn *connection, remoteAddr *net.UDPAddr) {
+	decoded, err := u.encoding.Decode(message)
 	if err != nil {
-		return "", nil, err
+		u.Errorw("Failed to decode message", zap.Error(err))
+		return
 	}
 
 	// Remove trailing characters and NULs
-	for ; (n > 0) && (u.buffer[n-1] < 32); n-- {
+	decoded = strings.TrimRightFunc(decoded, func(r rune) bool { return r < 32 })
+
+	entry, err := u.NewEntry(decoded)
+	if err != nil {
+		u.Errorw("Failed to create entry", zap.Error(err))
+		return
+	}
+
+	if u.addLabels {
+		entry.AddLabel("net.transport", "IP.UDP")
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			entry.AddLabel("net.host.ip", addr.IP.String())
+			entry.AddLabel("net.host.port", strconv.FormatInt(int64(addr.Port), 10))
+		}
+
+		if remoteAddr != nil {
+			entry.AddLabel("net.peer.ip", remoteAddr.IP.String())
+			entry.AddLabel("net.peer.port", strconv.FormatInt(int64(remoteAddr.Port), 10))
+		}
+	}
+
+	u.Write(ctx, entry)
+}
+
+// updateDropped records the kernel's count of dropped datagrams, which
+// is cumulative and may be reported out of order by concurrent readers
+func (c *connection) updateDropped(dropped uint32) {
+	for {
+		current := atomic.LoadUint32(&c.dropped)
+		if dropped <= current || atomic.CompareAndSwapUint32(&c.dropped, current, dropped) {
+			return
+		}
 	}
+}
 
-	return string(u.buffer[:n]), addr, nil
+// counts returns the number of truncated and dropped datagrams
+func (u *UDPInput) counts() (truncated, dropped uint64) {
+	for _, conn := range u.connections {
+		dropped += uint64(atomic.LoadUint32(&conn.dropped))
+	}
+	return atomic.LoadUint64(&u.truncated), dropped
+}
+
+// goReportDropped will periodically log the number of truncated and dropped datagrams
+func (u *UDPInput) goReportDropped(ctx context.Context) {
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+
+		ticker := time.NewTicker(reportInterval)
+		defer ticker.Stop()
+
+		var lastTruncated, lastDropped uint64
+		report := func() {
+			truncated, dropped := u.counts()
+			if truncated == lastTruncated && dropped == lastDropped {
+				return
+			}
+			u.Warnw("Datagrams were truncated or dropped",
+				"truncated", truncated-lastTruncated,
+				"dropped", dropped-lastDropped,
+				"total_truncated", truncated,
+				"total_dropped", dropped,
+			)
+			lastTruncated, lastDropped = truncated, dropped
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				report()
+				return
+			case <-ticker.C:
+				report()
+			}
+		}
+	}()
+}
+
+// closeConnections closes all open sockets
+func (u *UDPInput) closeConnections() {
+	for _, conn := range u.connections {
+		if err := conn.Close(); err != nil {
+			u.Errorf("failed to close connection, got error: %s", err)
+		}
+	}
 }
 
 // Stop will stop listening for udp messages.
 func (u *UDPInput) Stop() error {
 	u.cancel()
-	if err := u.connection.Close(); err != nil {
-		u.Errorf("failed to close connection, got error: %s", err)
-	}
+	u.closeConnections()
 	u.wg.Wait()
+	u.connections = nil
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
 	"github.com/observiq/stanza/testutil"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -37,7 +38,7 @@ func udpInputTest(input []byte, expected []string) func(t *testing.T) {
 		require.NoError(t, err)
 		defer udpInput.Stop()
 
-		conn, err := net.Dial("udp", udpInput.connection.LocalAddr().String())
+		conn, err := net.Dial("udp", udpInput.connections[0].LocalAddr().String())
 		require.NoError(t, err)
 		defer conn.Close()
 
@@ -87,7 +88,7 @@ func udpInputLabelsTest(input []byte, expected []string) func(t *testing.T) {
 		require.NoError(t, err)
 		defer udpInput.Stop()
 
-		conn, err := net.Dial("udp", udpInput.connection.LocalAddr().String())
+		conn, err := net.Dial("udp", udpInput.connections[0].LocalAddr().String())
 		require.NoError(t, err)
 		defer conn.Close()
 
@@ -100,8 +101,8 @@ func udpInputLabelsTest(input []byte, expected []string) func(t *testing.T) {
 				expectedLabels := map[string]string{
 					"net.transport": "IP.UDP",
 				}
-				// LocalAddr for udpInput.connection is a server address
-				if addr, ok := udpInput.connection.LocalAddr().(*net.UDPAddr); ok {
+				// LocalAddr for udpInput.connections is a server address
+				if addr, ok := udpInput.connections[0].LocalAddr().(*net.UDPAddr); ok {
 					expectedLabels["net.host.ip"] = addr.IP.String()
 					expectedLabels["net.host.port"] = strconv.FormatInt(int64(addr.Port), 10)
 				}
@@ -157,7 +158,7 @@ func BenchmarkUdpInput(b *testing.B) {
 
 	done := make(chan struct{})
 	go func() {
-		conn, err := net.Dial("udp", udpInput.connection.LocalAddr().String())
+		conn, err := net.Dial("udp", udpInput.connections[0].LocalAddr().String())
 		require.NoError(b, err)
 		defer udpInput.Stop()
 		defer conn.Close()
@@ -179,3 +180,189 @@ func BenchmarkUdpInput(b *testing.B) {
 
 	defer close(done)
 }
+
+func sendDatagram(t *testing.T, udpInput *UDPInput, message []byte) {
+	conn, err := net.Dial("udp", udpInput.connections[0].LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write(message)
+	require.NoError(t, err)
+}
+
+func expectRecords(t *testing.T, fake *testutil.FakeOutput, expected ...string) {
+	for _, record := range expected {
+		select {
+		case e := <-fake.Received:
+			require.Equal(t, record, e.Record)
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for entry")
+		}
+	}
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+}
+
+func TestUDPInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*UDPInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *UDPInputConfig) {}, false},
+		{"Options", func(cfg *UDPInputConfig) {
+			cfg.MaxMessageSize = maxDatagramSize
+			cfg.ReceiveBufferSize = 4 * 1024 * 1024
+			cfg.Readers = 4
+		}, false},
+		{"MissingAddress", func(cfg *UDPInputConfig) { cfg.ListenAddress = "" }, true},
+		{"MaxMessageSizeTooLarge", func(cfg *UDPInputConfig) { cfg.MaxMessageSize = maxDatagramSize + 1 }, true},
+		{"NegativeReceiveBufferSize", func(cfg *UDPInputConfig) { cfg.ReceiveBufferSize = -1 }, true},
+		{"NegativeSockets", func(cfg *UDPInputConfig) { cfg.Sockets = -1 }, true},
+		{"NegativeReaders", func(cfg *UDPInputConfig) { cfg.Readers = -1 }, true},
+		{"InvalidEncoding", func(cfg *UDPInputConfig) { cfg.Encoding.Encoding = "invalid" }, true},
+		{"InvalidMultiline", func(cfg *UDPInputConfig) {
+			cfg.Multiline.LineStartPattern = "^start"
+			cfg.Multiline.LineEndPattern = "end$"
+		}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewUDPInputConfig("test_input")
+			cfg.ListenAddress = "127.0.0.1:0"
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUDPInputMultiline(t *testing.T) {
+	t.Run("LineEnd", func(t *testing.T) {
+		cfg := NewUDPInputConfig("test_input")
+		cfg.ListenAddress = "127.0.0.1:0"
+		cfg.Multiline.LineEndPattern = `\n`
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		udpInput := ops[0].(*UDPInput)
+
+		fake := testutil.NewFakeOutput(t)
+		udpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+		require.NoError(t, udpInput.Start())
+		defer udpInput.Stop()
+
+		sendDatagram(t, udpInput, []byte("message1\nmessage2\r\nmessage3"))
+		expectRecords(t, fake, "message1", "message2", "message3")
+	})
+
+	t.Run("LineStart", func(t *testing.T) {
+		cfg := NewUDPInputConfig("test_input")
+		cfg.ListenAddress = "127.0.0.1:0"
+		cfg.Multiline.LineStartPattern = `^\d{4}-\d{2}-\d{2} `
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		udpInput := ops[0].(*UDPInput)
+
+		fake := testutil.NewFakeOutput(t)
+		udpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+		require.NoError(t, udpInput.Start())
+		defer udpInput.Stop()
+
+		sendDatagram(t, udpInput, []byte("2021-06-01 panic: oops\n\tat main.go:12\n2021-06-01 second\n2021-06-01 third\n"))
+		expectRecords(t, fake, "2021-06-01 panic: oops\n\tat main.go:12", "2021-06-01 second", "2021-06-01 third")
+	})
+}
+
+func TestUDPInputEncoding(t *testing.T) {
+	cfg := NewUDPInputConfig("test_input")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.Encoding = helper.EncodingConfig{Encoding: "utf-16le"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	udpInput := ops[0].(*UDPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	udpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, udpInput.Start())
+	defer udpInput.Stop()
+
+	sendDatagram(t, udpInput, []byte{'t', 0, 'e', 0, 's', 0, 't', 0, '\n', 0})
+	expectRecords(t, fake, "test")
+}
+
+func TestUDPInputTruncated(t *testing.T) {
+	cfg := NewUDPInputConfig("test_input")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.MaxMessageSize = 8
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	udpInput := ops[0].(*UDPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	udpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, udpInput.Start())
+	defer udpInput.Stop()
+
+	sendDatagram(t, udpInput, []byte("message1"))
+	expectRecords(t, fake, "message1")
+	truncated, _ := udpInput.counts()
+	require.Equal(t, uint64(0), truncated)
+
+	sendDatagram(t, udpInput, []byte("message12345"))
+	expectRecords(t, fake, "message1")
+	truncated, _ = udpInput.counts()
+	require.Equal(t, uint64(1), truncated)
+}
+
+func TestUDPInputSockets(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("SO_REUSEPORT is not supported on this platform")
+	}
+
+	cfg := NewUDPInputConfig("test_input")
+	cfg.ListenAddress = "127.0.0.1:0"
+	cfg.Sockets = 3
+	cfg.Readers = 2
+	cfg.ReceiveBufferSize = 1024 * 1024
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	udpInput := ops[0].(*UDPInput)
+
+	fake := testutil.NewFakeOutput(t)
+	udpInput.InputOperator.OutputOperators = []operator.Operator{fake}
+	require.NoError(t, udpInput.Start())
+	defer udpInput.Stop()
+
+	require.Len(t, udpInput.connections, 3)
+
+	port := udpInput.connections[0].LocalAddr().(*net.UDPAddr).Port
+	for _, conn := range udpInput.connections {
+		require.Equal(t, port, conn.LocalAddr().(*net.UDPAddr).Port)
+	}
+
+	// Datagrams from different senders are distributed across the sockets
+	const count = 50
+	for i := 0; i < count; i++ {
+		sendDatagram(t, udpInput, []byte("message"))
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case e := <-fake.Received:
+			require.Equal(t, "message", e.Record)
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for entry")
+		}
+	}
+
+	require.NoError(t, udpInput.Stop())
+	require.Empty(t, udpInput.connections)
+}
@@ -0,0 +1,84 @@
+// +build !windows
+
+package unix
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// parsePermissions parses an octal file mode, such as "0660"
+func parsePermissions(permissions string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(permissions, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid socket_permissions '%s', must be an octal file mode such as '0660'", permissions)
+	}
+	return os.FileMode(mode), nil
+}
+
+// lookupUID returns the uid of a user name or numeric id. An empty
+// owner returns -1, which leaves the owner unchanged.
+func lookupUID(owner string) (int, error) {
+	if owner == "" {
+		return -1, nil
+	}
+	if uid, err := strconv.Atoi(owner); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, fmt.Errorf("lookup socket_owner '%s': %s", owner, err)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGID returns the gid of a group name or numeric id. An empty
+// group returns -1, which leaves the group unchanged.
+func lookupGID(group string) (int, error) {
+	if group == "" {
+		return -1, nil
+	}
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("lookup socket_group '%s': %s", group, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// removeStaleSocket removes a socket file left behind by a process that
+// is no longer running. A socket that still accepts connections, or a
+// path that is not a socket, is never removed.
+func removeStaleSocket(path, network string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat socket: %s", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("'%s' already exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial(network, path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket '%s' is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("check existing socket: %s", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove stale socket: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+// +build !windows
+
+package unix
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+)
+
+const (
+	socketTypeStream   = "stream"
+	socketTypeDatagram = "datagram"
+
+	defaultMaxLogSize = 1024 * 1024
+)
+
+func init() {
+	operator.Register("unix_input", func() operator.Builder { return NewUnixInputConfig("") })
+}
+
+// NewUnixInputConfig creates a new unix input config with default values
+func NewUnixInputConfig(operatorID string) *UnixInputConfig {
+	return &UnixInputConfig{
+		InputConfig: helper.NewInputConfig(operatorID, "unix_input"),
+		SocketType:  socketTypeStream,
+		Multiline:   helper.NewMultilineConfig(),
+		MaxLogSize:  defaultMaxLogSize,
+		Encoding:    helper.NewEncodingConfig(),
+	}
+}
+
+// UnixInputConfig is the configuration of a unix input operator
+type UnixInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	SocketPath        string                 `json:"socket_path,omitempty"        yaml:"socket_path,omitempty"`
+	SocketType        string                 `json:"socket_type,omitempty"        yaml:"socket_type,omitempty"`
+	SocketPermissions string                 `json:"socket_permissions,omitempty" yaml:"socket_permissions,omitempty"`
+	SocketOwner       string                 `json:"socket_owner,omitempty"       yaml:"socket_owner,omitempty"`
+	SocketGroup       string                 `json:"socket_group,omitempty"       yaml:"socket_group,omitempty"`
+	Multiline         helper.MultilineConfig `json:"multiline,omitempty"          yaml:"multiline,omitempty"`
+	MaxLogSize        helper.ByteSize        `json:"max_log_size,omitempty"       yaml:"max_log_size,omitempty"`
+	Encoding          helper.EncodingConfig  `json:",inline,omitempty"            yaml:",inline,omitempty"`
+}
+
+// Build will build a unix input operator
+func (c UnixInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.SocketPath == "" {
+		return nil, fmt.Errorf("missing required parameter 'socket_path'")
+	}
+
+	var network string
+	switch c.SocketType {
+	case socketTypeStream:
+		network = "unix"
+	case socketTypeDatagram:
+		network = "unixgram"
+		if c.Multiline.LineStartPattern != "" || c.Multiline.LineEndPattern != "" {
+			return nil, fmt.Errorf("multiline is not supported with the datagram socket_type")
+		}
+	default:
+		return nil, fmt.Errorf("invalid socket_type '%s', must be one of '%s' or '%s'", c.SocketType, socketTypeStream, socketTypeDatagram)
+	}
+
+	var permissions *os.FileMode
+	if c.SocketPermissions != "" {
+		mode, err := parsePermissions(c.SocketPermissions)
+		if err != nil {
+			return nil, err
+		}
+		permissions = &mode
+	}
+
+	uid, err := lookupUID(c.SocketOwner)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := lookupGID(c.SocketGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.MaxLogSize <= 0 {
+		return nil, fmt.Errorf("`max_log_size` must be positive")
+	}
+
+	encoding, err := c.Encoding.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	splitFunc, err := c.Multiline.Build(context, encoding.Encoding, true)
+	if err != nil {
+		return nil, err
+	}
+
+	unixInput := &UnixInput{
+		InputOperator:    inputOperator,
+		path:             c.SocketPath,
+		network:          network,
+		permissions:      permissions,
+		uid:              uid,
+		gid:              gid,
+		maxLogSize:       int(c.MaxLogSize),
+		encoding:         encoding,
+		splitFunc:        splitFunc,
+		forceFlushPeriod: c.Multiline.ForceFlushPeriod.Raw(),
+		backoff: backoff.Backoff{
+			Max: 3 * time.Second,
+		},
+	}
+	return []operator.Operator{unixInput}, nil
+}
+
+// UnixInput is an operator that receives logs from a unix socket
+type UnixInput struct {
+	helper.InputOperator
+	path             string
+	network          string
+	permissions      *os.FileMode
+	uid              int
+	gid              int
+	maxLogSize       int
+	encoding         helper.Encoding
+	splitFunc        bufio.SplitFunc
+	forceFlushPeriod time.Duration
+	backoff          backoff.Backoff
+
+	listener   net.Listener
+	connection net.PacketConn
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// Start will create the socket and start receiving logs
+func (u *UnixInput) Start() error {
+	if err := removeStaleSocket(u.path, u.network); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u.cancel = cancel
+
+	if u.network == "unixgram" {
+		conn, err := net.ListenPacket(u.network, u.path)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to open socket: %w", err)
+		}
+		u.connection = conn
+	} else {
+		listener, err := net.Listen(u.network, u.path)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to listen on socket: %w", err)
+		}
+		u.listener = listener
+	}
+
+	if err := u.setSocketAttributes(); err != nil {
+		u.Stop()
+		return err
+	}
+
+	if u.connection != nil {
+		u.goHandleDatagrams(ctx)
+	} else {
+		u.goListen(ctx)
+	}
+	return nil
+}
+
+// setSocketAttributes sets the permissions and ownership of the socket file
+func (u *UnixInput) setSocketAttributes() error {
+	if u.permissions != nil {
+		if err := os.Chmod(u.path, *u.permissions); err != nil {
+			return fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+	}
+	if u.uid != -1 || u.gid != -1 {
+		if err := os.Chown(u.path, u.uid, u.gid); err != nil {
+			return fmt.Errorf("failed to set socket ownership: %w", err)
+		}
+	}
+	return nil
+}
+
+// goListen will listen for stream connections
+func (u *UnixInput) goListen(ctx context.Context) {
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+
+		for {
+			conn, err := u.listener.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					u.Debugw("Listener accept error", zap.Error(err))
+					time.Sleep(u.backoff.Duration())
+					continue
+				}
+			}
+			u.backoff.Reset()
+
+			u.Debugf("Received connection on %s", u.path)
+			subctx, cancel := context.WithCancel(ctx)
+			u.goHandleClose(subctx, conn)
+			u.goHandleMessages(subctx, conn, cancel)
+		}
+	}()
+}
+
+// goHandleClose will wait for the context to finish before closing a connection
+func (u *UnixInput) goHandleClose(ctx context.Context, conn net.Conn) {
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+		<-ctx.Done()
+		if err := conn.Close(); err != nil {
+			u.Errorf("Failed to close connection: %s", err)
+		}
+	}()
+}
+
+// goHandleMessages will split a stream connection into log entries
+func (u *UnixInput) goHandleMessages(ctx context.Context, conn net.Conn, cancel context.CancelFunc) {
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+		defer cancel()
+
+		bufferSize := 16 * 1024
+		if u.maxLogSize < bufferSize {
+			bufferSize = u.maxLogSize
+		}
+
+		flusher := helper.NewFlusher(u.forceFlushPeriod)
+		reader := helper.NewFlushReader(conn, flusher)
+		buf := make([]byte, 0, bufferSize)
+
+		var scanner *bufio.Scanner
+		for {
+			scanner = bufio.NewScanner(reader)
+			scanner.Buffer(buf, u.maxLogSize)
+			scanner.Split(flusher.SplitFunc(u.splitFunc))
+
+			for scanner.Scan() {
+				decoded, err := u.encoding.Decode(scanner.Bytes())
+				if err != nil {
+					u.Errorw("Failed to decode message", zap.Error(err))
+					continue
+				}
+				u.handleMessage(ctx, decoded, reader.ForceFlushed)
+			}
+
+			// The connection is read again after an incomplete entry is force flushed
+			if !reader.ForceFlushed || scanner.Err() != nil {
+				break
+			}
+			reader.ForceFlushed = false
+		}
+
+		if err := scanner.Err(); err != nil && !isClosedError(ctx, err) {
+			u.Errorw("Scanner error", zap.Error(err))
+		}
+	}()
+}
+
+// goHandleDatagrams will read log entries from a datagram socket, one per datagram
+func (u *UnixInput) goHandleDatagrams(ctx context.Context) {
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+
+		// One extra byte is used to detect datagrams that exceed max_log_size
+		buffer := make([]byte, u.maxLogSize+1)
+		for {
+			n, _, err := u.connection.ReadFrom(buffer)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					u.Errorw("Failed reading messages", zap.Error(err))
+					continue
+				}
+			}
+
+			if n > u.maxLogSize {
+				u.Warnw("Dropping message that exceeds max_log_size", "max_log_size", u.maxLogSize)
+				continue
+			}
+
+			decoded, err := u.encoding.Decode(buffer[:n])
+			if err != nil {
+				u.Errorw("Failed to decode message", zap.Error(err))
+				continue
+			}
+
+			// Local syslog clients may terminate a datagram with a newline or NUL
+			u.handleMessage(ctx, strings.TrimRight(decoded, "\r\n\x00"), false)
+		}
+	}()
+}
+
+// handleMessage writes a decoded message as an entry
+func (u *UnixInput) handleMessage(ctx context.Context, message string, forceFlushed bool) {
+	if message == "" {
+		return
+	}
+
+	e, err := u.NewEntry(message)
+	if err != nil {
+		u.Errorw("Failed to create entry", zap.Error(err))
+		return
+	}
+
+	e.AddLabel("net.transport", "Unix")
+	if forceFlushed {
+		e.AddLabel(helper.ForceFlushedLabel, "true")
+	}
+	u.Write(ctx, e)
+}
+
+// isClosedError returns true if the error is expected when a connection ends
+func isClosedError(ctx context.Context, err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if strings.Contains(err.Error(), "use of closed network connection") {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// Stop will stop receiving logs and remove the socket
+func (u *UnixInput) Stop() error {
+	u.cancel()
+
+	if u.listener != nil {
+		if err := u.listener.Close(); err != nil {
+			return err
+		}
+	}
+	if u.connection != nil {
+		if err := u.connection.Close(); err != nil {
+			return err
+		}
+	}
+
+	u.wg.Wait()
+
+	// Stream listeners remove the socket when closed, but datagram sockets do not
+	if err := os.Remove(u.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+// +build !windows
+
+package unix
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func expectRecord(t *testing.T, fake *testutil.FakeOutput, expected interface{}) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, expected, e.Record)
+		return e
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+func TestUnixInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*UnixInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *UnixInputConfig) {}, false},
+		{"Datagram", func(cfg *UnixInputConfig) { cfg.SocketType = socketTypeDatagram }, false},
+		{"Permissions", func(cfg *UnixInputConfig) { cfg.SocketPermissions = "0660" }, false},
+		{"NumericOwnership", func(cfg *UnixInputConfig) {
+			cfg.SocketOwner = "0"
+			cfg.SocketGroup = "0"
+		}, false},
+		{"MissingPath", func(cfg *UnixInputConfig) { cfg.SocketPath = "" }, true},
+		{"InvalidSocketType", func(cfg *UnixInputConfig) { cfg.SocketType = "seqpacket" }, true},
+		{"InvalidPermissions", func(cfg *UnixInputConfig) { cfg.SocketPermissions = "rw-rw----" }, true},
+		{"PermissionsOutOfRange", func(cfg *UnixInputConfig) { cfg.SocketPermissions = "1777" }, true},
+		{"UnknownOwner", func(cfg *UnixInputConfig) { cfg.SocketOwner = "no-such-user-stanza" }, true},
+		{"UnknownGroup", func(cfg *UnixInputConfig) { cfg.SocketGroup = "no-such-group-stanza" }, true},
+		{"InvalidEncoding", func(cfg *UnixInputConfig) { cfg.Encoding.Encoding = "invalid" }, true},
+		{"DatagramMultiline", func(cfg *UnixInputConfig) {
+			cfg.SocketType = socketTypeDatagram
+			cfg.Multiline.LineStartPattern = "^start"
+		}, true},
+		{"InvalidMultiline", func(cfg *UnixInputConfig) {
+			cfg.Multiline.LineStartPattern = "^start"
+			cfg.Multiline.LineEndPattern = "end$"
+		}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewUnixInputConfig("test")
+			cfg.SocketPath = "/tmp/stanza.sock"
+			cfg.OutputIDs = []string{"fake"}
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUnixInputStream(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	conn, err := net.Dial("unix", unixInput.path)
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("message1\nmessage2\r\nmessage3"))
+	require.NoError(t, err)
+
+	e := expectRecord(t, fake, "message1")
+	require.Equal(t, map[string]string{"net.transport": "Unix"}, e.Labels)
+	expectRecord(t, fake, "message2")
+
+	// The final message is flushed when the connection is closed
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+	require.NoError(t, conn.Close())
+	expectRecord(t, fake, "message3")
+}
+
+func TestUnixInputStreamMultiline(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Multiline.LineStartPattern = `^\d{4}-\d{2}-\d{2} `
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	conn, err := net.Dial("unix", unixInput.path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("2021-06-01 panic: oops\n\tat main.go:12\n2021-06-01 next\n"))
+	require.NoError(t, err)
+
+	expectRecord(t, fake, "2021-06-01 panic: oops\n\tat main.go:12\n")
+}
+
+func TestUnixInputStreamEncoding(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Encoding = helper.EncodingConfig{Encoding: "utf-16le"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	conn, err := net.Dial("unix", unixInput.path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{'t', 0, 'e', 0, 's', 0, 't', 0, '\n', 0})
+	require.NoError(t, err)
+
+	expectRecord(t, fake, "test")
+}
+
+func TestUnixInputStreamTooLarge(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.MaxLogSize = 16
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	conn, err := net.Dial("unix", unixInput.path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Repeat("a", 32) + "\n"))
+	require.NoError(t, err)
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+
+	// The connection is closed after a message that is too large
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	netErr, ok := err.(net.Error)
+	require.False(t, ok && netErr.Timeout())
+}
+
+func TestUnixInputDatagram(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.SocketType = socketTypeDatagram
+	cfg.MaxLogSize = 40
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	conn, err := net.Dial("unixgram", unixInput.path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<13>Jun  1 12:00:00 app: multi\nline\x00"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(strings.Repeat("a", 64)))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("message2\n"))
+	require.NoError(t, err)
+
+	e := expectRecord(t, fake, "<13>Jun  1 12:00:00 app: multi\nline")
+	require.Equal(t, "Unix", e.Labels["net.transport"])
+
+	// The datagram that exceeds max_log_size is dropped
+	expectRecord(t, fake, "message2")
+}
+
+func TestUnixInputPermissions(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.SocketPermissions = "0600"
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	info, err := os.Stat(unixInput.path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	require.NotZero(t, info.Mode()&os.ModeSocket)
+}
+
+func TestUnixInputRemovesSocket(t *testing.T) {
+	for _, socketType := range []string{socketTypeStream, socketTypeDatagram} {
+		t.Run(socketType, func(t *testing.T) {
+			cfg := NewUnixInputConfig("test")
+			cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+			cfg.SocketType = socketType
+			cfg.OutputIDs = []string{"fake"}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			unixInput := ops[0].(*UnixInput)
+			require.NoError(t, unixInput.SetOutputs([]operator.Operator{testutil.NewFakeOutput(t)}))
+
+			require.NoError(t, unixInput.Start())
+			require.FileExists(t, cfg.SocketPath)
+			require.NoError(t, unixInput.Stop())
+			require.NoFileExists(t, cfg.SocketPath)
+		})
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+
+	t.Run("Missing", func(t *testing.T) {
+		require.NoError(t, removeStaleSocket(filepath.Join(dir, "missing.sock"), "unix"))
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		listener, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, listener.Close())
+		require.FileExists(t, path)
+
+		require.NoError(t, removeStaleSocket(path, "unix"))
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("InUse", func(t *testing.T) {
+		path := filepath.Join(dir, "inuse.sock")
+		listener, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		defer listener.Close()
+
+		require.Error(t, removeStaleSocket(path, "unix"))
+		require.FileExists(t, path)
+	})
+
+	t.Run("NotSocket", func(t *testing.T) {
+		path := filepath.Join(dir, "regular")
+		require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+
+		require.Error(t, removeStaleSocket(path, "unix"))
+		require.FileExists(t, path)
+	})
+}
+
+func TestUnixInputStreamForceFlush(t *testing.T) {
+	cfg := NewUnixInputConfig("test")
+	cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.Multiline.LineStartPattern = `^\d{4}-\d{2}-\d{2} `
+	cfg.Multiline.ForceFlushPeriod = helper.Duration{Duration: 200 * time.Millisecond}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	unixInput := ops[0].(*UnixInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, unixInput.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, unixInput.Start())
+	defer unixInput.Stop()
+
+	conn, err := net.Dial("unix", unixInput.path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("2021-06-01 panic: oops\n\tat main.go:12\n2021-06-01 next\n"))
+	require.NoError(t, err)
+
+	e := expectRecord(t, fake, "2021-06-01 panic: oops\n\tat main.go:12\n")
+	require.NotContains(t, e.Labels, helper.ForceFlushedLabel)
+	e = expectRecord(t, fake, "2021-06-01 next\n")
+	require.Equal(t, "true", e.Labels[helper.ForceFlushedLabel])
+
+	// The connection is still read after the flush
+	_, err = conn.Write([]byte("2021-06-02 last\n"))
+	require.NoError(t, err)
+	expectRecord(t, fake, "2021-06-02 last\n")
+}
@@ -0,0 +1,679 @@
+package windows
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Tokens of binary XML
+const (
+	binXMLEOF                  = 0x00
+	binXMLOpenStartElement     = 0x01
+	binXMLCloseStartElement    = 0x02
+	binXMLCloseEmptyElement    = 0x03
+	binXMLEndElement           = 0x04
+	binXMLValueText            = 0x05
+	binXMLAttribute            = 0x06
+	binXMLCDATASection         = 0x07
+	binXMLCharRef              = 0x08
+	binXMLEntityRef            = 0x09
+	binXMLPITarget             = 0x0a
+	binXMLPIData               = 0x0b
+	binXMLTemplateInstance     = 0x0c
+	binXMLNormalSubstitution   = 0x0d
+	binXMLOptionalSubstitution = 0x0e
+	binXMLFragmentHeader       = 0x0f
+
+	// binXMLMoreBit is set on tokens that are followed by more of the same
+	binXMLMoreBit = 0x40
+)
+
+// Types of binary XML values
+const (
+	binXMLTypeNull       = 0x00
+	binXMLTypeString     = 0x01
+	binXMLTypeANSIString = 0x02
+	binXMLTypeInt8       = 0x03
+	binXMLTypeUint8      = 0x04
+	binXMLTypeInt16      = 0x05
+	binXMLTypeUint16     = 0x06
+	binXMLTypeInt32      = 0x07
+	binXMLTypeUint32     = 0x08
+	binXMLTypeInt64      = 0x09
+	binXMLTypeUint64     = 0x0a
+	binXMLTypeFloat32    = 0x0b
+	binXMLTypeFloat64    = 0x0c
+	binXMLTypeBool       = 0x0d
+	binXMLTypeBinary     = 0x0e
+	binXMLTypeGUID       = 0x0f
+	binXMLTypeSizeT      = 0x10
+	binXMLTypeFileTime   = 0x11
+	binXMLTypeSystemTime = 0x12
+	binXMLTypeSID        = 0x13
+	binXMLTypeHexInt32   = 0x14
+	binXMLTypeHexInt64   = 0x15
+	binXMLTypeBinXML     = 0x21
+
+	// binXMLTypeArray is set on the types of arrays of values
+	binXMLTypeArray = 0x80
+)
+
+// maxBinXMLDepth limits the nesting of elements and binary XML values
+const maxBinXMLDepth = 64
+
+// binXMLValue is a value of a template instance
+type binXMLValue struct {
+	valueType byte
+	offset    int
+	data      []byte
+}
+
+// binXMLReader renders the binary XML of an EVTX chunk as XML text. Names and
+// templates are referenced by their offset in the chunk, and are defined
+// inline the first time they are used.
+type binXMLReader struct {
+	chunk []byte
+	pos   int
+	end   int
+	depth int
+	b     strings.Builder
+}
+
+// renderBinXML renders the binary XML fragment of a chunk between start and end
+func renderBinXML(chunk []byte, start, end int) (string, error) {
+	r := &binXMLReader{chunk: chunk, pos: start, end: end}
+	if err := r.renderFragment(nil); err != nil {
+		return "", err
+	}
+	return r.b.String(), nil
+}
+
+// renderFragment renders tokens until the end of the fragment
+func (r *binXMLReader) renderFragment(values []binXMLValue) error {
+	for r.pos < r.end {
+		token, err := r.readByte()
+		if err != nil {
+			return err
+		}
+
+		switch token &^ binXMLMoreBit {
+		case binXMLEOF:
+			return nil
+		case binXMLFragmentHeader:
+			// Major version, minor version and flags
+			if err := r.skip(3); err != nil {
+				return err
+			}
+		case binXMLTemplateInstance:
+			if err := r.renderTemplateInstance(); err != nil {
+				return err
+			}
+		case binXMLOpenStartElement:
+			if err := r.renderElement(token, values); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected binary xml token 0x%02x at offset %d", token, r.pos-1)
+		}
+	}
+	return nil
+}
+
+// renderTemplateInstance renders a template with the values of its instance
+func (r *binXMLReader) renderTemplateInstance() error {
+	// Unknown byte and template identifier
+	if err := r.skip(5); err != nil {
+		return err
+	}
+	definitionOffset, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+
+	// The definition is the next template offset, the GUID, and the size of its data
+	definition := int(definitionOffset)
+	if definition+24 > len(r.chunk) {
+		return fmt.Errorf("template definition offset %d is out of bounds", definition)
+	}
+	dataStart := definition + 24
+	dataEnd := dataStart + int(binary.LittleEndian.Uint32(r.chunk[definition+20:]))
+	if dataEnd > len(r.chunk) {
+		return fmt.Errorf("template definition at offset %d is out of bounds", definition)
+	}
+	if definition == r.pos {
+		if dataEnd > r.end {
+			return fmt.Errorf("template definition at offset %d is out of bounds", definition)
+		}
+		r.pos = dataEnd
+	}
+
+	count, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	if int(count)*4 > r.end-r.pos {
+		return fmt.Errorf("template instance has too many values: %d", count)
+	}
+
+	values := make([]binXMLValue, count)
+	sizes := make([]int, count)
+	for i := range values {
+		size, err := r.readUint16()
+		if err != nil {
+			return err
+		}
+		valueType, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if err := r.skip(1); err != nil {
+			return err
+		}
+		values[i].valueType = valueType
+		sizes[i] = int(size)
+	}
+	for i := range values {
+		data, err := r.read(sizes[i])
+		if err != nil {
+			return err
+		}
+		values[i].offset = r.pos - sizes[i]
+		values[i].data = data
+	}
+
+	template := &binXMLReader{chunk: r.chunk, pos: dataStart, end: dataEnd, depth: r.depth + 1}
+	if template.depth > maxBinXMLDepth {
+		return fmt.Errorf("binary xml is nested too deeply")
+	}
+	if err := template.renderFragment(values); err != nil {
+		return err
+	}
+	r.b.WriteString(template.b.String())
+	return nil
+}
+
+// renderElement renders an element, its attributes and its content
+func (r *binXMLReader) renderElement(token byte, values []binXMLValue) error {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxBinXMLDepth {
+		return fmt.Errorf("binary xml is nested too deeply")
+	}
+
+	// Dependency identifier and data size
+	if err := r.skip(6); err != nil {
+		return err
+	}
+	name, err := r.readName()
+	if err != nil {
+		return err
+	}
+	if token&binXMLMoreBit != 0 {
+		// Attribute list size
+		if err := r.skip(4); err != nil {
+			return err
+		}
+	}
+
+	r.b.WriteByte('<')
+	r.b.WriteString(name)
+
+	for {
+		next, err := r.peekByte()
+		if err != nil {
+			return err
+		}
+		if next&^binXMLMoreBit != binXMLAttribute {
+			break
+		}
+		r.pos++
+
+		attrName, err := r.readName()
+		if err != nil {
+			return err
+		}
+		var value strings.Builder
+		present, err := r.renderValue(values, &value)
+		if err != nil {
+			return err
+		}
+		if !present {
+			continue
+		}
+		r.b.WriteByte(' ')
+		r.b.WriteString(attrName)
+		r.b.WriteString(`="`)
+		r.b.WriteString(value.String())
+		r.b.WriteByte('"')
+	}
+
+	token, err = r.readByte()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case binXMLCloseEmptyElement:
+		r.b.WriteString("/>")
+		return nil
+	case binXMLCloseStartElement:
+		r.b.WriteByte('>')
+	default:
+		return fmt.Errorf("unexpected binary xml token 0x%02x at offset %d", token, r.pos-1)
+	}
+
+	if err := r.renderContent(values); err != nil {
+		return err
+	}
+
+	r.b.WriteString("</")
+	r.b.WriteString(name)
+	r.b.WriteByte('>')
+	return nil
+}
+
+// renderContent renders the content of an element until its end
+func (r *binXMLReader) renderContent(values []binXMLValue) error {
+	for {
+		token, err := r.peekByte()
+		if err != nil {
+			return err
+		}
+
+		switch token &^ binXMLMoreBit {
+		case binXMLEndElement, binXMLEOF:
+			r.pos++
+			return nil
+		case binXMLOpenStartElement:
+			r.pos++
+			if err := r.renderElement(token, values); err != nil {
+				return err
+			}
+		case binXMLCDATASection:
+			r.pos++
+			text, err := r.readSizedString()
+			if err != nil {
+				return err
+			}
+			r.b.WriteString("<![CDATA[")
+			r.b.WriteString(text)
+			r.b.WriteString("]]>")
+		case binXMLPITarget:
+			r.pos++
+			target, err := r.readName()
+			if err != nil {
+				return err
+			}
+			r.b.WriteString("<?")
+			r.b.WriteString(target)
+		case binXMLPIData:
+			r.pos++
+			data, err := r.readSizedString()
+			if err != nil {
+				return err
+			}
+			r.b.WriteByte(' ')
+			r.b.WriteString(data)
+			r.b.WriteString("?>")
+		default:
+			if _, err := r.renderValue(values, &r.b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// renderValue renders the tokens of a value, such as the text of an element or
+// the value of an attribute. It returns false if the value is an optional
+// substitution without a value.
+func (r *binXMLReader) renderValue(values []binXMLValue, b *strings.Builder) (bool, error) {
+	present := false
+	for {
+		token, err := r.peekByte()
+		if err != nil {
+			return false, err
+		}
+
+		switch token &^ binXMLMoreBit {
+		case binXMLValueText:
+			r.pos++
+			valueType, err := r.readByte()
+			if err != nil {
+				return false, err
+			}
+			if valueType != binXMLTypeString {
+				return false, fmt.Errorf("unsupported binary xml value type 0x%02x", valueType)
+			}
+			text, err := r.readSizedString()
+			if err != nil {
+				return false, err
+			}
+			escapeXML(b, text)
+			present = true
+		case binXMLCharRef:
+			r.pos++
+			char, err := r.readUint16()
+			if err != nil {
+				return false, err
+			}
+			fmt.Fprintf(b, "&#%d;", char)
+			present = true
+		case binXMLEntityRef:
+			r.pos++
+			name, err := r.readName()
+			if err != nil {
+				return false, err
+			}
+			b.WriteString("&" + name + ";")
+			present = true
+		case binXMLNormalSubstitution, binXMLOptionalSubstitution:
+			r.pos++
+			index, err := r.readUint16()
+			if err != nil {
+				return false, err
+			}
+			// Value type, which is also given by the template instance
+			if err := r.skip(1); err != nil {
+				return false, err
+			}
+			if int(index) >= len(values) {
+				return false, fmt.Errorf("substitution %d is out of range of %d values", index, len(values))
+			}
+
+			value := values[index]
+			if token&^binXMLMoreBit == binXMLOptionalSubstitution && (value.valueType == binXMLTypeNull || len(value.data) == 0) {
+				continue
+			}
+			text, err := r.formatValue(value)
+			if err != nil {
+				return false, err
+			}
+
+			// Binary xml values are elements, rather than text
+			if value.valueType == binXMLTypeBinXML {
+				b.WriteString(text)
+			} else {
+				escapeXML(b, text)
+			}
+			present = true
+		default:
+			return present, nil
+		}
+	}
+}
+
+// formatValue formats a value of a template instance as text
+func (r *binXMLReader) formatValue(value binXMLValue) (string, error) {
+	if value.valueType == binXMLTypeBinXML {
+		nested := &binXMLReader{chunk: r.chunk, pos: value.offset, end: value.offset + len(value.data), depth: r.depth + 1}
+		if nested.depth > maxBinXMLDepth {
+			return "", fmt.Errorf("binary xml is nested too deeply")
+		}
+		if err := nested.renderFragment(nil); err != nil {
+			return "", err
+		}
+		return nested.b.String(), nil
+	}
+
+	if value.valueType&binXMLTypeArray != 0 {
+		return formatArray(value.valueType&^binXMLTypeArray, value.data)
+	}
+	return formatScalar(value.valueType, value.data)
+}
+
+// formatArray formats an array of values, separated by commas
+func formatArray(valueType byte, data []byte) (string, error) {
+	var items []string
+	switch valueType {
+	case binXMLTypeString:
+		items = strings.Split(strings.TrimRight(decodeUTF16(data), "\x00"), "\x00")
+	case binXMLTypeANSIString:
+		items = strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	default:
+		size := scalarSize(valueType)
+		if size == 0 || len(data)%size != 0 {
+			return "", fmt.Errorf("unsupported binary xml array type 0x%02x", valueType)
+		}
+		for i := 0; i < len(data); i += size {
+			item, err := formatScalar(valueType, data[i:i+size])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ","), nil
+}
+
+// scalarSize returns the size of a fixed size value type, or zero
+func scalarSize(valueType byte) int {
+	switch valueType {
+	case binXMLTypeInt8, binXMLTypeUint8:
+		return 1
+	case binXMLTypeInt16, binXMLTypeUint16:
+		return 2
+	case binXMLTypeInt32, binXMLTypeUint32, binXMLTypeFloat32, binXMLTypeBool, binXMLTypeHexInt32:
+		return 4
+	case binXMLTypeInt64, binXMLTypeUint64, binXMLTypeFloat64, binXMLTypeFileTime, binXMLTypeHexInt64:
+		return 8
+	case binXMLTypeGUID, binXMLTypeSystemTime:
+		return 16
+	default:
+		return 0
+	}
+}
+
+// formatScalar formats a value that is not an array
+func formatScalar(valueType byte, data []byte) (string, error) {
+	if size := scalarSize(valueType); size != 0 && len(data) < size {
+		return "", fmt.Errorf("binary xml value of type 0x%02x is too short", valueType)
+	}
+
+	switch valueType {
+	case binXMLTypeNull:
+		return "", nil
+	case binXMLTypeString:
+		return strings.TrimRight(decodeUTF16(data), "\x00"), nil
+	case binXMLTypeANSIString:
+		return strings.TrimRight(string(data), "\x00"), nil
+	case binXMLTypeInt8:
+		return strconv.FormatInt(int64(int8(data[0])), 10), nil
+	case binXMLTypeUint8:
+		return strconv.FormatUint(uint64(data[0]), 10), nil
+	case binXMLTypeInt16:
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(data))), 10), nil
+	case binXMLTypeUint16:
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint16(data)), 10), nil
+	case binXMLTypeInt32:
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(data))), 10), nil
+	case binXMLTypeUint32:
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data)), 10), nil
+	case binXMLTypeInt64:
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(data)), 10), nil
+	case binXMLTypeUint64:
+		return strconv.FormatUint(binary.LittleEndian.Uint64(data), 10), nil
+	case binXMLTypeFloat32:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32), nil
+	case binXMLTypeFloat64:
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64), nil
+	case binXMLTypeBool:
+		return strconv.FormatBool(binary.LittleEndian.Uint32(data) != 0), nil
+	case binXMLTypeBinary:
+		return strings.ToUpper(hex.EncodeToString(data)), nil
+	case binXMLTypeGUID:
+		return formatGUID(data), nil
+	case binXMLTypeSizeT, binXMLTypeHexInt32, binXMLTypeHexInt64:
+		switch len(data) {
+		case 4:
+			return fmt.Sprintf("0x%x", binary.LittleEndian.Uint32(data)), nil
+		case 8:
+			return fmt.Sprintf("0x%x", binary.LittleEndian.Uint64(data)), nil
+		default:
+			return "", fmt.Errorf("binary xml value of type 0x%02x has invalid size %d", valueType, len(data))
+		}
+	case binXMLTypeFileTime:
+		return formatFileTime(binary.LittleEndian.Uint64(data)), nil
+	case binXMLTypeSystemTime:
+		return formatSystemTime(data), nil
+	case binXMLTypeSID:
+		return formatSID(data)
+	default:
+		return "", fmt.Errorf("unsupported binary xml value type 0x%02x", valueType)
+	}
+}
+
+// formatGUID formats a GUID as it is rendered by windows
+func formatGUID(data []byte) string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}",
+		binary.LittleEndian.Uint32(data[0:4]),
+		binary.LittleEndian.Uint16(data[4:6]),
+		binary.LittleEndian.Uint16(data[6:8]),
+		data[8:10],
+		data[10:16],
+	)
+}
+
+// fileTimeEpoch is the number of 100 nanosecond intervals between 1601 and 1970
+const fileTimeEpoch = 116444736000000000
+
+// fileTime converts a FILETIME, the number of 100 nanosecond intervals since 1601, to a time
+func fileTime(value uint64) time.Time {
+	intervals := int64(value - fileTimeEpoch)
+	return time.Unix(intervals/1e7, (intervals%1e7)*100).UTC()
+}
+
+// formatFileTime formats a FILETIME as it is rendered by windows
+func formatFileTime(value uint64) string {
+	return fileTime(value).Format("2006-01-02T15:04:05.0000000Z")
+}
+
+// formatSystemTime formats a SYSTEMTIME as it is rendered by windows
+func formatSystemTime(data []byte) string {
+	field := func(i int) int { return int(binary.LittleEndian.Uint16(data[i*2:])) }
+	t := time.Date(field(0), time.Month(field(1)), field(3), field(4), field(5), field(6), field(7)*int(time.Millisecond), time.UTC)
+	return t.Format("2006-01-02T15:04:05.0000000Z")
+}
+
+// formatSID formats a security identifier, such as S-1-5-18
+func formatSID(data []byte) (string, error) {
+	if len(data) < 8 || len(data) < 8+int(data[1])*4 {
+		return "", fmt.Errorf("binary xml SID is too short")
+	}
+
+	var authority uint64
+	for _, b := range data[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "S-%d-%d", data[0], authority)
+	for i := 0; i < int(data[1]); i++ {
+		fmt.Fprintf(&b, "-%d", binary.LittleEndian.Uint32(data[8+i*4:]))
+	}
+	return b.String(), nil
+}
+
+// escapeXML writes text escaped for xml
+func escapeXML(b *strings.Builder, text string) {
+	_ = xml.EscapeText(b, []byte(text))
+}
+
+// decodeUTF16 decodes little endian UTF-16 text
+func decodeUTF16(data []byte) string {
+	chars := make([]uint16, len(data)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+// readName reads the offset of a name, and returns the name at that offset.
+// A name that is defined inline is skipped.
+func (r *binXMLReader) readName() (string, error) {
+	offset, err := r.readUint32()
+	if err != nil {
+		return "", err
+	}
+
+	// The name is the next name offset, a hash, the number of characters,
+	// and the characters terminated by a null character
+	start := int(offset)
+	if start+8 > len(r.chunk) {
+		return "", fmt.Errorf("name offset %d is out of bounds", start)
+	}
+	end := start + 8 + int(binary.LittleEndian.Uint16(r.chunk[start+6:]))*2
+	if end+2 > len(r.chunk) {
+		return "", fmt.Errorf("name at offset %d is out of bounds", start)
+	}
+	if start == r.pos {
+		if end+2 > r.end {
+			return "", fmt.Errorf("name at offset %d is out of bounds", start)
+		}
+		r.pos = end + 2
+	}
+	return decodeUTF16(r.chunk[start+8 : end]), nil
+}
+
+// readSizedString reads a UTF-16 string that is preceded by its number of characters
+func (r *binXMLReader) readSizedString() (string, error) {
+	count, err := r.readUint16()
+	if err != nil {
+		return "", err
+	}
+	data, err := r.read(int(count) * 2)
+	if err != nil {
+		return "", err
+	}
+	return decodeUTF16(data), nil
+}
+
+func (r *binXMLReader) read(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > r.end {
+		return nil, fmt.Errorf("unexpected end of binary xml at offset %d", r.pos)
+	}
+	data := r.chunk[r.pos : r.pos+n]
+	r.pos += n
+	return data, nil
+}
+
+func (r *binXMLReader) skip(n int) error {
+	_, err := r.read(n)
+	return err
+}
+
+func (r *binXMLReader) peekByte() (byte, error) {
+	if r.pos >= r.end {
+		return 0, fmt.Errorf("unexpected end of binary xml at offset %d", r.pos)
+	}
+	return r.chunk[r.pos], nil
+}
+
+func (r *binXMLReader) readByte() (byte, error) {
+	data, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+func (r *binXMLReader) readUint16() (uint16, error) {
+	data, err := r.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(data), nil
+}
+
+func (r *binXMLReader) readUint32() (uint32, error) {
+	data, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
@@ -4,6 +4,8 @@ package windows
 
 import (
 	"fmt"
+
+	"github.com/observiq/stanza/operator/helper/eventxml"
 )
 
 // Event is an event stored in windows event log.
@@ -12,9 +14,9 @@ type Event struct {
 }
 
 // RenderSimple will render the event as EventXML without formatted info.
-func (e *Event) RenderSimple(buffer Buffer) (EventXML, error) {
+func (e *Event) RenderSimple(buffer Buffer) (eventxml.EventXML, error) {
 	if e.handle == 0 {
-		return EventXML{}, fmt.Errorf("event handle does not exist")
+		return eventxml.EventXML{}, fmt.Errorf("event handle does not exist")
 	}
 
 	var bufferUsed, propertyCount uint32
@@ -25,21 +27,21 @@ func (e *Event) RenderSimple(buffer Buffer) (EventXML, error) {
 	}
 
 	if err != nil {
-		return EventXML{}, fmt.Errorf("syscall to 'EvtRender' failed: %s", err)
+		return eventxml.EventXML{}, fmt.Errorf("syscall to 'EvtRender' failed: %s", err)
 	}
 
 	bytes, err := buffer.ReadBytes(bufferUsed)
 	if err != nil {
-		return EventXML{}, fmt.Errorf("failed to read bytes from buffer: %s", err)
+		return eventxml.EventXML{}, fmt.Errorf("failed to read bytes from buffer: %s", err)
 	}
 
-	return unmarshalEventXML(bytes)
+	return eventxml.Unmarshal(bytes)
 }
 
 // RenderFormatted will render the event as EventXML with formatted info.
-func (e *Event) RenderFormatted(buffer Buffer, publisher Publisher) (EventXML, error) {
+func (e *Event) RenderFormatted(buffer Buffer, publisher Publisher) (eventxml.EventXML, error) {
 	if e.handle == 0 {
-		return EventXML{}, fmt.Errorf("event handle does not exist")
+		return eventxml.EventXML{}, fmt.Errorf("event handle does not exist")
 	}
 
 	var bufferUsed uint32
@@ -50,15 +52,15 @@ func (e *Event) RenderFormatted(buffer Buffer, publisher Publisher) (EventXML, e
 	}
 
 	if err != nil {
-		return EventXML{}, fmt.Errorf("syscall to 'EvtFormatMessage' failed: %s", err)
+		return eventxml.EventXML{}, fmt.Errorf("syscall to 'EvtFormatMessage' failed: %s", err)
 	}
 
 	bytes, err := buffer.ReadBytes(bufferUsed)
 	if err != nil {
-		return EventXML{}, fmt.Errorf("failed to read bytes from buffer: %s", err)
+		return eventxml.EventXML{}, fmt.Errorf("failed to read bytes from buffer: %s", err)
 	}
 
-	return unmarshalEventXML(bytes)
+	return eventxml.Unmarshal(bytes)
 }
 
 // Close will close the event handle.
@@ -0,0 +1,156 @@
+package windows
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/observiq/stanza/operator/helper/eventxml"
+)
+
+const (
+	evtxFileSignature   = "ElfFile\x00"
+	evtxChunkSignature  = "ElfChnk\x00"
+	evtxRecordSignature = "\x2a\x2a\x00\x00"
+
+	evtxFileHeaderSize   = 4096
+	evtxChunkSize        = 65536
+	evtxChunkHeaderSize  = 512
+	evtxRecordHeaderSize = 24
+)
+
+// evtxRecord is an event record of an EVTX file
+type evtxRecord struct {
+	ID      uint64
+	Written time.Time
+	XML     string
+}
+
+// evtxChunkRef is the offset of a chunk and the identifiers of its records
+type evtxChunkRef struct {
+	offset  int64
+	firstID uint64
+	lastID  uint64
+}
+
+// readEVTX reads the event records of an EVTX file with an identifier greater
+// than after, in the order of their identifiers. Records that can not be read
+// are reported to onError, and do not stop the rest of the file from being read.
+// It returns the greatest record identifier of the file, which is less than
+// after if the file has been replaced by another.
+func readEVTX(r io.ReaderAt, after uint64, onRecord func(evtxRecord), onError func(error)) (uint64, error) {
+	header := make([]byte, len(evtxFileSignature))
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, fmt.Errorf("failed to read file header: %s", err)
+	}
+	if string(header) != evtxFileSignature {
+		return 0, fmt.Errorf("file is not an evtx file")
+	}
+
+	chunks, err := readEVTXChunkRefs(r)
+	if err != nil {
+		return 0, err
+	}
+
+	// Once a log is full, its oldest chunks are overwritten, so the chunks
+	// are read in the order of their records rather than the order they are stored
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].firstID < chunks[j].firstID })
+
+	var lastID uint64
+	chunk := make([]byte, evtxChunkSize)
+	for _, ref := range chunks {
+		if ref.lastID > lastID {
+			lastID = ref.lastID
+		}
+		if ref.lastID <= after {
+			continue
+		}
+		if n, err := r.ReadAt(chunk, ref.offset); n < len(chunk) {
+			return lastID, fmt.Errorf("failed to read chunk at offset %d: %s", ref.offset, err)
+		}
+		if id := readEVTXChunk(chunk, after, onRecord, onError); id > lastID {
+			lastID = id
+		}
+	}
+	return lastID, nil
+}
+
+// readEVTXChunkRefs reads the headers of the chunks of an EVTX file. The number of
+// chunks in the file header is not updated until the file is closed, so chunks
+// are read until the end of the file.
+func readEVTXChunkRefs(r io.ReaderAt) ([]evtxChunkRef, error) {
+	header := make([]byte, evtxChunkHeaderSize)
+	last := make([]byte, 1)
+	var chunks []evtxChunkRef
+	for offset := int64(evtxFileHeaderSize); ; offset += evtxChunkSize {
+		// A partial chunk at the end of the file is still being written
+		if _, err := r.ReadAt(last, offset+evtxChunkSize-1); err != nil {
+			if err != io.EOF {
+				return nil, fmt.Errorf("failed to read chunk at offset %d: %s", offset, err)
+			}
+			return chunks, nil
+		}
+		if n, err := r.ReadAt(header, offset); n < len(header) {
+			return nil, fmt.Errorf("failed to read chunk at offset %d: %s", offset, err)
+		}
+
+		// Unused chunks are empty
+		if string(header[:len(evtxChunkSignature)]) != evtxChunkSignature {
+			continue
+		}
+
+		chunks = append(chunks, evtxChunkRef{
+			offset:  offset,
+			firstID: binary.LittleEndian.Uint64(header[24:32]),
+			lastID:  binary.LittleEndian.Uint64(header[32:40]),
+		})
+	}
+}
+
+// readEVTXChunk reads the event records of a chunk, and returns the greatest record identifier
+func readEVTXChunk(chunk []byte, after uint64, onRecord func(evtxRecord), onError func(error)) uint64 {
+	freeSpace := int(binary.LittleEndian.Uint32(chunk[48:52]))
+	if freeSpace > len(chunk) {
+		freeSpace = len(chunk)
+	}
+
+	lastID := after
+	for offset := evtxChunkHeaderSize; offset+evtxRecordHeaderSize <= freeSpace; {
+		if string(chunk[offset:offset+4]) != evtxRecordSignature {
+			onError(fmt.Errorf("invalid record signature at chunk offset %d", offset))
+			return lastID
+		}
+
+		size := int(binary.LittleEndian.Uint32(chunk[offset+4:]))
+		if size < evtxRecordHeaderSize+4 || offset+size > len(chunk) {
+			onError(fmt.Errorf("invalid record size %d at chunk offset %d", size, offset))
+			return lastID
+		}
+
+		id := binary.LittleEndian.Uint64(chunk[offset+8:])
+		if id > after {
+			text, err := renderBinXML(chunk, offset+evtxRecordHeaderSize, offset+size-4)
+			if err != nil {
+				onError(fmt.Errorf("failed to render record %d: %s", id, err))
+			} else {
+				onRecord(evtxRecord{
+					ID:      id,
+					Written: fileTime(binary.LittleEndian.Uint64(chunk[offset+16:])),
+					XML:     text,
+				})
+			}
+			if id > lastID {
+				lastID = id
+			}
+		}
+		offset += size
+	}
+	return lastID
+}
+
+// unmarshalEVTXRecord will unmarshal EventXML from the xml of an EVTX record
+func unmarshalEVTXRecord(record evtxRecord) (eventxml.EventXML, error) {
+	return eventxml.UnmarshalWithLevel([]byte(record.XML))
+}
@@ -0,0 +1,291 @@
+package windows
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+)
+
+func init() {
+	operator.Register("evtx_input", func() operator.Builder { return NewEVTXInputConfig("") })
+}
+
+// NewEVTXInputConfig creates a new evtx input config with default values
+func NewEVTXInputConfig(operatorID string) *EVTXInputConfig {
+	return &EVTXInputConfig{
+		InputConfig:     helper.NewInputConfig(operatorID, "evtx_input"),
+		PollInterval:    helper.NewDuration(time.Second),
+		StartAt:         "beginning",
+		IncludeFileName: true,
+	}
+}
+
+// EVTXInputConfig is the configuration of an evtx input operator
+type EVTXInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Include         []string        `json:"include,omitempty"           yaml:"include,omitempty"`
+	Exclude         []string        `json:"exclude,omitempty"           yaml:"exclude,omitempty"`
+	PollInterval    helper.Duration `json:"poll_interval,omitempty"     yaml:"poll_interval,omitempty"`
+	StartAt         string          `json:"start_at,omitempty"          yaml:"start_at,omitempty"`
+	IncludeFileName bool            `json:"include_file_name,omitempty" yaml:"include_file_name,omitempty"`
+	IncludeFilePath bool            `json:"include_file_path,omitempty" yaml:"include_file_path,omitempty"`
+}
+
+// Build will build an evtx input operator
+func (c EVTXInputConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.Include) == 0 {
+		return nil, fmt.Errorf("required argument `include` is empty")
+	}
+
+	// Ensure includes can be parsed as globs
+	for _, include := range c.Include {
+		if _, err := doublestar.PathMatch(include, "matchstring"); err != nil {
+			return nil, fmt.Errorf("parse include glob: %s", err)
+		}
+	}
+
+	// Ensure excludes can be parsed as globs
+	for _, exclude := range c.Exclude {
+		if _, err := doublestar.PathMatch(exclude, "matchstring"); err != nil {
+			return nil, fmt.Errorf("parse exclude glob: %s", err)
+		}
+	}
+
+	if c.PollInterval.Raw() <= 0 {
+		return nil, fmt.Errorf("`poll_interval` must be positive")
+	}
+
+	if c.StartAt != "end" && c.StartAt != "beginning" {
+		return nil, fmt.Errorf("the `start_at` field must be set to `beginning` or `end`")
+	}
+
+	evtxInput := &EVTXInput{
+		InputOperator:    inputOperator,
+		include:          c.Include,
+		exclude:          c.Exclude,
+		pollInterval:     c.PollInterval.Raw(),
+		startAtBeginning: c.StartAt == "beginning",
+		includeFileName:  c.IncludeFileName,
+		includeFilePath:  c.IncludeFilePath,
+		offsets:          helper.NewScopedDBPersister(context.Database, c.ID()),
+		files:            make(map[string]fileState),
+	}
+	return []operator.Operator{evtxInput}, nil
+}
+
+// EVTXInput is an operator that reads events from EVTX files
+type EVTXInput struct {
+	helper.InputOperator
+	include          []string
+	exclude          []string
+	pollInterval     time.Duration
+	startAtBeginning bool
+	includeFileName  bool
+	includeFilePath  bool
+
+	// offsets are the greatest record identifiers read from each file path
+	offsets helper.Persister
+
+	// files are the sizes and modification times of the files when they were
+	// last read, so that files that have not changed are not read again
+	files     map[string]fileState
+	firstPoll bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// fileState is the state of a file when it was last read
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Start will start reading events from EVTX files
+func (e *EVTXInput) Start() error {
+	if err := e.offsets.Load(); err != nil {
+		return fmt.Errorf("failed to load offsets database: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.firstPoll = true
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		ticker := time.NewTicker(e.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			e.poll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop will stop reading events from EVTX files
+func (e *EVTXInput) Stop() error {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+	return nil
+}
+
+// poll reads the new events of the files that match the include patterns
+func (e *EVTXInput) poll(ctx context.Context) {
+	firstPoll := e.firstPoll
+	e.firstPoll = false
+
+	for _, path := range e.findFiles() {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			e.Errorf("Failed to stat file %s: %s", path, err)
+			continue
+		}
+
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if previous, ok := e.files[path]; ok && previous == state {
+			continue
+		}
+
+		if err := e.readFile(ctx, path, firstPoll); err != nil {
+			e.Errorf("Failed to read evtx file %s: %s", path, err)
+			continue
+		}
+		e.files[path] = state
+	}
+}
+
+// findFiles returns the paths of the files that match the include patterns,
+// and do not match the exclude patterns
+func (e *EVTXInput) findFiles() []string {
+	all := make([]string, 0, len(e.include))
+	seen := make(map[string]struct{})
+	for _, include := range e.include {
+		matches, _ := doublestar.Glob(include)
+	INCLUDE:
+		for _, match := range matches {
+			for _, exclude := range e.exclude {
+				if itMatches, _ := doublestar.PathMatch(exclude, match); itMatches {
+					continue INCLUDE
+				}
+			}
+
+			if _, ok := seen[match]; ok {
+				continue
+			}
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			seen[match] = struct{}{}
+			all = append(all, match)
+		}
+	}
+	return all
+}
+
+// readFile reads the events of a file that have not been read yet
+func (e *EVTXInput) readFile(ctx context.Context, path string, firstPoll bool) error {
+	file, err := os.Open(path) // #nosec - operator must read in files defined by user
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	offset := e.offsets.Get(path)
+	var after uint64
+	if offset != nil {
+		if after, err = strconv.ParseUint(string(offset), 10, 64); err != nil {
+			return fmt.Errorf("invalid offset '%s': %s", offset, err)
+		}
+	}
+
+	// Files that are found at startup are skipped to their end if starting at
+	// the end, while files that are found later are read from the beginning
+	skip := offset == nil && firstPoll && !e.startAtBeginning
+
+	onRecord := func(record evtxRecord) {
+		if !skip {
+			e.sendRecord(ctx, path, record)
+		}
+	}
+	onError := func(err error) {
+		e.Warnf("Failed to read event from evtx file %s: %s", path, err)
+	}
+
+	lastID, err := readEVTX(file, after, onRecord, onError)
+	if err != nil {
+		return err
+	}
+
+	// A file whose records all precede the offset has been replaced by another
+	// log, such as when a log is cleared, so it is read from the beginning
+	if lastID < after {
+		after = 0
+		if lastID, err = readEVTX(file, after, onRecord, onError); err != nil {
+			return err
+		}
+	}
+
+	if offset == nil || lastID != after {
+		e.offsets.Set(path, []byte(strconv.FormatUint(lastID, 10)))
+		if err := e.offsets.Sync(); err != nil {
+			e.Errorf("Failed to sync offsets database: %s", err)
+		}
+	}
+	return nil
+}
+
+// sendRecord will send an event record as an entry to the operator's output
+func (e *EVTXInput) sendRecord(ctx context.Context, path string, record evtxRecord) {
+	eventXML, err := unmarshalEVTXRecord(record)
+	if err != nil {
+		e.Errorf("Failed to parse event record %d of %s: %s", record.ID, path, err)
+		return
+	}
+
+	entry, err := e.NewEntry(eventXML.ParseRecord())
+	if err != nil {
+		e.Errorf("Failed to create entry: %s", err)
+		return
+	}
+
+	entry.Timestamp = eventXML.ParseTimestamp()
+	entry.Severity = eventXML.ParseSeverity()
+	if e.includeFileName {
+		entry.AddLabel("file_name", filepath.Base(path))
+	}
+	if e.includeFilePath {
+		entry.AddLabel("file_path", path)
+	}
+	e.Write(ctx, entry)
+}
@@ -0,0 +1,775 @@
+package windows
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// securityTestdata are the security messages of the eventxml package
+var securityTestdata = filepath.Join("..", "..", "..", "helper", "eventxml", "testdata", "security")
+
+// testElement is an element of a binary xml template
+type testElement struct {
+	name     string
+	attrs    []testAttr
+	children []interface{} // *testElement, string or testSub
+}
+
+type testAttr struct {
+	name  string
+	value interface{} // string or testSub
+}
+
+// testSub is a substitution of a template value
+type testSub struct {
+	index    uint16
+	optional bool
+}
+
+type testValue struct {
+	valueType byte
+	data      []byte
+}
+
+// testChunk writes the event records of an EVTX chunk. Names and templates are
+// defined inline the first time they are written, and referenced afterwards.
+type testChunk struct {
+	buf       []byte
+	names     map[string]uint32
+	templates map[string]uint32
+	firstID   uint64
+	lastID    uint64
+	lastPos   int
+}
+
+func newTestChunk() *testChunk {
+	return &testChunk{
+		buf:       make([]byte, evtxChunkHeaderSize),
+		names:     make(map[string]uint32),
+		templates: make(map[string]uint32),
+	}
+}
+
+func (c *testChunk) u8(v byte) { c.buf = append(c.buf, v) }
+
+func (c *testChunk) u16(v uint16) { c.buf = append(c.buf, byte(v), byte(v>>8)) }
+
+func (c *testChunk) u32(v uint32) {
+	c.buf = append(c.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(c.buf[len(c.buf)-4:], v)
+}
+
+func (c *testChunk) u64(v uint64) {
+	c.buf = append(c.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(c.buf[len(c.buf)-8:], v)
+}
+
+func (c *testChunk) patch32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(c.buf[pos:], v)
+}
+
+func (c *testChunk) name(name string) {
+	if offset, ok := c.names[name]; ok {
+		c.u32(offset)
+		return
+	}
+	offset := uint32(len(c.buf) + 4)
+	c.names[name] = offset
+	c.u32(offset)
+
+	chars := utf16.Encode([]rune(name))
+	c.u32(0)
+	c.u16(0)
+	c.u16(uint16(len(chars)))
+	for _, char := range chars {
+		c.u16(char)
+	}
+	c.u16(0)
+}
+
+func (c *testChunk) text(text string) {
+	chars := utf16.Encode([]rune(text))
+	c.u8(binXMLValueText)
+	c.u8(binXMLTypeString)
+	c.u16(uint16(len(chars)))
+	for _, char := range chars {
+		c.u16(char)
+	}
+}
+
+func (c *testChunk) value(value interface{}) {
+	switch v := value.(type) {
+	case string:
+		c.text(v)
+	case testSub:
+		if v.optional {
+			c.u8(binXMLOptionalSubstitution)
+		} else {
+			c.u8(binXMLNormalSubstitution)
+		}
+		c.u16(v.index)
+		c.u8(binXMLTypeString)
+	case *testElement:
+		c.element(v)
+	}
+}
+
+func (c *testChunk) element(e *testElement) {
+	token := byte(binXMLOpenStartElement)
+	if len(e.attrs) > 0 {
+		token |= binXMLMoreBit
+	}
+	c.u8(token)
+	c.u16(0xffff)
+	sizePos := len(c.buf)
+	c.u32(0)
+	c.name(e.name)
+
+	if len(e.attrs) > 0 {
+		attrSizePos := len(c.buf)
+		c.u32(0)
+		for i, attr := range e.attrs {
+			token := byte(binXMLAttribute)
+			if i < len(e.attrs)-1 {
+				token |= binXMLMoreBit
+			}
+			c.u8(token)
+			c.name(attr.name)
+			c.value(attr.value)
+		}
+		c.patch32(attrSizePos, uint32(len(c.buf)-attrSizePos-4))
+	}
+
+	if len(e.children) == 0 {
+		c.u8(binXMLCloseEmptyElement)
+	} else {
+		c.u8(binXMLCloseStartElement)
+		for _, child := range e.children {
+			c.value(child)
+		}
+		c.u8(binXMLEndElement)
+	}
+	c.patch32(sizePos, uint32(len(c.buf)-sizePos-4))
+}
+
+func (c *testChunk) fragmentHeader() {
+	c.buf = append(c.buf, binXMLFragmentHeader, 1, 1, 0)
+}
+
+func (c *testChunk) templateInstance(id string, root *testElement, values []testValue) {
+	c.u8(binXMLTemplateInstance)
+	c.u8(1)
+	c.u32(0)
+	if offset, ok := c.templates[id]; ok {
+		c.u32(offset)
+	} else {
+		offset := uint32(len(c.buf) + 4)
+		c.templates[id] = offset
+		c.u32(offset)
+
+		c.u32(0)
+		c.buf = append(c.buf, make([]byte, 16)...)
+		sizePos := len(c.buf)
+		c.u32(0)
+		c.fragmentHeader()
+		c.element(root)
+		c.u8(binXMLEOF)
+		c.patch32(sizePos, uint32(len(c.buf)-sizePos-4))
+	}
+
+	c.u32(uint32(len(values)))
+	for _, value := range values {
+		c.u16(uint16(len(value.data)))
+		c.u8(value.valueType)
+		c.u8(0)
+	}
+	for _, value := range values {
+		c.buf = append(c.buf, value.data...)
+	}
+}
+
+// record writes an event record of a template instance
+func (c *testChunk) record(id uint64, template string, root *testElement, values []testValue) {
+	start := len(c.buf)
+	c.buf = append(c.buf, evtxRecordSignature...)
+	c.u32(0)
+	c.u64(id)
+	c.u64(fileTimeValue(time.Date(2021, 5, 4, 10, 15, 3, 0, time.UTC)))
+	c.fragmentHeader()
+	c.templateInstance(template, root, values)
+	c.u8(binXMLEOF)
+	c.u32(uint32(len(c.buf) - start + 4))
+	c.patch32(start+4, uint32(len(c.buf)-start))
+
+	if c.firstID == 0 {
+		c.firstID = id
+	}
+	c.lastID = id
+	c.lastPos = start
+}
+
+// bytes returns the chunk with its header
+func (c *testChunk) bytes() []byte {
+	chunk := make([]byte, evtxChunkSize)
+	copy(chunk, c.buf)
+	copy(chunk, evtxChunkSignature)
+	binary.LittleEndian.PutUint64(chunk[8:], 1)
+	binary.LittleEndian.PutUint64(chunk[16:], c.lastID-c.firstID+1)
+	binary.LittleEndian.PutUint64(chunk[24:], c.firstID)
+	binary.LittleEndian.PutUint64(chunk[32:], c.lastID)
+	binary.LittleEndian.PutUint32(chunk[40:], 128)
+	binary.LittleEndian.PutUint32(chunk[44:], uint32(c.lastPos))
+	binary.LittleEndian.PutUint32(chunk[48:], uint32(len(c.buf)))
+	return chunk
+}
+
+// testEVTXFile returns an EVTX file of chunks
+func testEVTXFile(chunks ...[]byte) []byte {
+	file := make([]byte, evtxFileHeaderSize)
+	copy(file, evtxFileSignature)
+	binary.LittleEndian.PutUint32(file[32:], 128)
+	binary.LittleEndian.PutUint16(file[36:], 1)
+	binary.LittleEndian.PutUint16(file[38:], 3)
+	binary.LittleEndian.PutUint16(file[40:], evtxFileHeaderSize)
+	binary.LittleEndian.PutUint16(file[42:], uint16(len(chunks)))
+	for _, chunk := range chunks {
+		file = append(file, chunk...)
+	}
+	return file
+}
+
+func fileTimeValue(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + fileTimeEpoch
+}
+
+func stringValue(s string) testValue {
+	var data []byte
+	for _, char := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(char), byte(char>>8))
+	}
+	return testValue{binXMLTypeString, data}
+}
+
+func uint8Value(v uint8) testValue {
+	return testValue{binXMLTypeUint8, []byte{v}}
+}
+
+func uint16Value(v uint16) testValue {
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data, v)
+	return testValue{binXMLTypeUint16, data}
+}
+
+func uint64Value(v uint64) testValue {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, v)
+	return testValue{binXMLTypeUint64, data}
+}
+
+func fileTimeTestValue(t time.Time) testValue {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, fileTimeValue(t))
+	return testValue{binXMLTypeFileTime, data}
+}
+
+var (
+	testGUID = testValue{binXMLTypeGUID, []byte{
+		0x25, 0x96, 0x84, 0x54, 0x78, 0x54, 0x94, 0x49,
+		0xa5, 0xba, 0x3e, 0x3b, 0x03, 0x28, 0xc3, 0x0d,
+	}}
+	testSID = testValue{binXMLTypeSID, []byte{1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}}
+	null    = testValue{binXMLTypeNull, nil}
+)
+
+// testSystem is the system element of the test templates
+var testSystem = &testElement{name: "System", children: []interface{}{
+	&testElement{name: "Provider", attrs: []testAttr{{"Name", testSub{0, false}}, {"Guid", testSub{1, true}}}},
+	&testElement{name: "EventID", attrs: []testAttr{{"Qualifiers", testSub{2, true}}}, children: []interface{}{testSub{3, false}}},
+	&testElement{name: "Level", children: []interface{}{testSub{4, false}}},
+	&testElement{name: "TimeCreated", attrs: []testAttr{{"SystemTime", testSub{5, false}}}},
+	&testElement{name: "EventRecordID", children: []interface{}{testSub{6, false}}},
+	&testElement{name: "Channel", children: []interface{}{testSub{7, false}}},
+	&testElement{name: "Computer", children: []interface{}{testSub{8, false}}},
+	&testElement{name: "Security", attrs: []testAttr{{"UserID", testSub{9, true}}}},
+}}
+
+// testRenderedTemplate is the template of a forwarded event with its rendering info
+var testRenderedTemplate = &testElement{
+	name:  "Event",
+	attrs: []testAttr{{"xmlns", "http://schemas.microsoft.com/win/2004/08/events/event"}},
+	children: []interface{}{
+		testSystem,
+		&testElement{name: "RenderingInfo", attrs: []testAttr{{"Culture", "en-US"}}, children: []interface{}{
+			&testElement{name: "Message", children: []interface{}{testSub{10, false}}},
+			&testElement{name: "Level", children: []interface{}{testSub{11, false}}},
+			&testElement{name: "Task", children: []interface{}{testSub{12, false}}},
+			&testElement{name: "Opcode", children: []interface{}{"Info"}},
+			&testElement{name: "Keywords", children: []interface{}{
+				&testElement{name: "Keyword", children: []interface{}{testSub{13, false}}},
+			}},
+		}},
+	},
+}
+
+// testTemplate is the template of an event without its rendering info
+var testTemplate = &testElement{
+	name: "Event",
+	children: []interface{}{
+		testSystem,
+		&testElement{name: "EventData", children: []interface{}{testSub{10, false}}},
+	},
+}
+
+func testSystemValues(id uint64, channel string, level uint8) []testValue {
+	return []testValue{
+		stringValue("Microsoft-Windows-Security-Auditing"),
+		testGUID,
+		null,
+		uint16Value(4624),
+		uint8Value(level),
+		fileTimeTestValue(time.Date(2021, 5, 4, 10, 15, 3, 123456700, time.UTC)),
+		uint64Value(id),
+		stringValue(channel),
+		stringValue("WIN-322E2C550UP"),
+		testSID,
+	}
+}
+
+// testChunkBytes returns a chunk of a forwarded security event, an event that
+// reuses its template, and an event of another template with binary xml data
+func testChunkBytes(t *testing.T) []byte {
+	message, err := ioutil.ReadFile(filepath.Join(securityTestdata, "logon", "message.in"))
+	require.NoError(t, err)
+
+	c := newTestChunk()
+	c.record(1, "rendered", testRenderedTemplate, append(testSystemValues(1, "Security", 0),
+		stringValue(string(message)),
+		stringValue("Information"),
+		stringValue("Logon"),
+		stringValue("Audit Success"),
+	))
+	c.record(2, "rendered", testRenderedTemplate, append(testSystemValues(2, "Security", 0),
+		stringValue("Special privileges assigned to new logon."),
+		stringValue("Information"),
+		stringValue("Special Logon"),
+		stringValue("Audit Success"),
+	))
+
+	// The binary xml data references the names that were defined by the template
+	data := newTestChunk()
+	data.buf = c.buf
+	data.names = c.names
+	start := len(data.buf)
+	data.fragmentHeader()
+	data.element(&testElement{name: "Message", children: []interface{}{"disk full"}})
+	data.u8(binXMLEOF)
+	binXML := append([]byte{}, data.buf[start:]...)
+	c.buf = c.buf[:start]
+
+	c.record(3, "plain", testTemplate, append(testSystemValues(3, "Application", 2),
+		testValue{binXMLTypeBinXML, binXML},
+	))
+	return c.bytes()
+}
+
+func TestReadEVTX(t *testing.T) {
+	file := testEVTXFile(testChunkBytes(t))
+
+	var records []evtxRecord
+	lastID, err := readEVTX(newTestReaderAt(file), 0, func(record evtxRecord) {
+		records = append(records, record)
+	}, func(err error) {
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), lastID)
+	require.Len(t, records, 3)
+
+	expected := `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System>` +
+		`<Provider Name="Microsoft-Windows-Security-Auditing" Guid="{54849625-5478-4994-A5BA-3E3B0328C30D}"/>` +
+		`<EventID>4624</EventID><Level>0</Level><TimeCreated SystemTime="2021-05-04T10:15:03.1234567Z"/>` +
+		`<EventRecordID>2</EventRecordID><Channel>Security</Channel><Computer>WIN-322E2C550UP</Computer>` +
+		`<Security UserID="S-1-5-18"/></System><RenderingInfo Culture="en-US">` +
+		`<Message>Special privileges assigned to new logon.</Message><Level>Information</Level>` +
+		`<Task>Special Logon</Task><Opcode>Info</Opcode><Keywords><Keyword>Audit Success</Keyword></Keywords>` +
+		`</RenderingInfo></Event>`
+	require.Equal(t, uint64(2), records[1].ID)
+	require.Equal(t, time.Date(2021, 5, 4, 10, 15, 3, 0, time.UTC), records[1].Written)
+	require.Equal(t, expected, records[1].XML)
+
+	expected = `<Event><System>` +
+		`<Provider Name="Microsoft-Windows-Security-Auditing" Guid="{54849625-5478-4994-A5BA-3E3B0328C30D}"/>` +
+		`<EventID>4624</EventID><Level>2</Level><TimeCreated SystemTime="2021-05-04T10:15:03.1234567Z"/>` +
+		`<EventRecordID>3</EventRecordID><Channel>Application</Channel><Computer>WIN-322E2C550UP</Computer>` +
+		`<Security UserID="S-1-5-18"/></System><EventData><Message>disk full</Message></EventData></Event>`
+	require.Equal(t, expected, records[2].XML)
+}
+
+func TestReadEVTXAfter(t *testing.T) {
+	// Empty chunks, and a partial chunk at the end of the file, are skipped
+	file := testEVTXFile(testChunkBytes(t), make([]byte, evtxChunkSize))
+	file = append(file, evtxChunkSignature...)
+
+	var ids []uint64
+	lastID, err := readEVTX(newTestReaderAt(file), 2, func(record evtxRecord) {
+		ids = append(ids, record.ID)
+	}, func(err error) {
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), lastID)
+	require.Equal(t, []uint64{3}, ids)
+
+	// The greatest identifier of a file is less than after if it was replaced
+	lastID, err = readEVTX(newTestReaderAt(file), 10, func(record evtxRecord) {
+		require.FailNow(t, "Unexpected record")
+	}, func(err error) {
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), lastID)
+}
+
+func TestReadEVTXInvalid(t *testing.T) {
+	_, err := readEVTX(newTestReaderAt([]byte("not an evtx file")), 0, nil, nil)
+	require.Error(t, err)
+
+	// A record that can not be rendered is reported, and the rest are read
+	chunk := testChunkBytes(t)
+	c := newTestChunk()
+	c.buf = chunk[:binary.LittleEndian.Uint32(chunk[48:])]
+	c.record(4, "plain", testTemplate, append(testSystemValues(4, "Application", 3), stringValue("data")))
+	c.buf[c.lastPos+evtxRecordHeaderSize+4] = 0x7f
+	c.record(5, "plain", testTemplate, append(testSystemValues(5, "Application", 3), stringValue("data")))
+	c.firstID = 1
+
+	var ids []uint64
+	var errs []error
+	_, err = readEVTX(newTestReaderAt(testEVTXFile(c.bytes())), 0, func(record evtxRecord) {
+		ids = append(ids, record.ID)
+	}, func(err error) {
+		errs = append(errs, err)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3, 5}, ids)
+	require.Len(t, errs, 1)
+}
+
+func TestReadEVTXFile(t *testing.T) {
+	// The chunk of records 4 and 5 is stored before the chunk of records
+	// 1 to 3, as it is once a full log overwrites its oldest chunk
+	file, err := os.Open(filepath.Join("testdata", "evtx", "wrapped.evtx"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "evtx", "wrapped.xml"))
+	require.NoError(t, err)
+
+	var ids []uint64
+	var rendered string
+	lastID, err := readEVTX(file, 0, func(record evtxRecord) {
+		ids = append(ids, record.ID)
+		rendered += record.XML + "\n"
+	}, func(err error) {
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), lastID)
+	require.Equal(t, []uint64{1, 2, 3, 4, 5}, ids)
+	require.Equal(t, string(expected), rendered)
+}
+
+func TestUnmarshalEVTXRecord(t *testing.T) {
+	file := testEVTXFile(testChunkBytes(t))
+
+	var records []evtxRecord
+	_, err := readEVTX(newTestReaderAt(file), 0, func(record evtxRecord) {
+		records = append(records, record)
+	}, func(err error) {
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+
+	// The message of security events is parsed
+	event, err := unmarshalEVTXRecord(records[0])
+	require.NoError(t, err)
+	record := event.ParseRecord()
+
+	expectedMessage, err := ioutil.ReadFile(filepath.Join(securityTestdata, "logon", "message.out"))
+	require.NoError(t, err)
+	expectedDetails, err := ioutil.ReadFile(filepath.Join(securityTestdata, "logon", "details.out"))
+	require.NoError(t, err)
+	details, err := json.Marshal(record["details"])
+	require.NoError(t, err)
+
+	require.Equal(t, string(expectedMessage), record["message"])
+	require.JSONEq(t, string(expectedDetails), string(details))
+	require.Equal(t, map[string]interface{}{"qualifiers": uint16(0), "id": uint32(4624)}, record["event_id"])
+	require.Equal(t, "{54849625-5478-4994-A5BA-3E3B0328C30D}", record["provider"].(map[string]interface{})["guid"])
+	require.Equal(t, uint64(1), record["record_id"])
+	require.Equal(t, "Information", record["level"])
+	require.Equal(t, []string{"Audit Success"}, record["keywords"])
+	require.Equal(t, entry.Info, event.ParseSeverity())
+	require.Equal(t, time.Date(2021, 5, 4, 10, 15, 3, 123456700, time.UTC), event.ParseTimestamp())
+
+	// The name of the level is used when there is no rendering info
+	event, err = unmarshalEVTXRecord(records[2])
+	require.NoError(t, err)
+	require.Equal(t, "Error", event.Level)
+	require.Equal(t, entry.Error, event.ParseSeverity())
+	require.Equal(t, "", event.Message)
+}
+
+func TestFormatBinXMLValue(t *testing.T) {
+	cases := []struct {
+		name      string
+		valueType byte
+		data      []byte
+		expected  string
+	}{
+		{"Null", binXMLTypeNull, nil, ""},
+		{"String", binXMLTypeString, stringValue("text\x00").data, "text"},
+		{"ANSIString", binXMLTypeANSIString, []byte("text\x00"), "text"},
+		{"Int8", binXMLTypeInt8, []byte{0xff}, "-1"},
+		{"Uint8", binXMLTypeUint8, []byte{0xff}, "255"},
+		{"Int16", binXMLTypeInt16, []byte{0xfe, 0xff}, "-2"},
+		{"Uint16", binXMLTypeUint16, []byte{0x10, 0x27}, "10000"},
+		{"Int32", binXMLTypeInt32, []byte{0xfd, 0xff, 0xff, 0xff}, "-3"},
+		{"Uint32", binXMLTypeUint32, []byte{0xa0, 0x86, 0x01, 0x00}, "100000"},
+		{"Int64", binXMLTypeInt64, []byte{0xfc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "-4"},
+		{"Uint64", binXMLTypeUint64, uint64Value(1 << 40).data, "1099511627776"},
+		{"Float32", binXMLTypeFloat32, []byte{0x00, 0x00, 0xc0, 0x3f}, "1.5"},
+		{"Float64", binXMLTypeFloat64, []byte{0, 0, 0, 0, 0, 0, 0x04, 0x40}, "2.5"},
+		{"Bool", binXMLTypeBool, []byte{1, 0, 0, 0}, "true"},
+		{"Binary", binXMLTypeBinary, []byte{0xde, 0xad, 0xbe, 0xef}, "DEADBEEF"},
+		{"GUID", binXMLTypeGUID, testGUID.data, "{54849625-5478-4994-A5BA-3E3B0328C30D}"},
+		{"SizeT", binXMLTypeSizeT, []byte{0x10, 0, 0, 0, 0, 0, 0, 0}, "0x10"},
+		{"FileTime", binXMLTypeFileTime, fileTimeTestValue(time.Date(2020, 7, 30, 1, 1, 1, 0, time.UTC)).data, "2020-07-30T01:01:01.0000000Z"},
+		{"SystemTime", binXMLTypeSystemTime, []byte{0xe4, 0x07, 7, 0, 4, 0, 30, 0, 1, 0, 1, 0, 1, 0, 0xf4, 0x01}, "2020-07-30T01:01:01.5000000Z"},
+		{"SID", binXMLTypeSID, []byte{1, 2, 0, 0, 0, 0, 0, 5, 32, 0, 0, 0, 0x20, 0x02, 0, 0}, "S-1-5-32-544"},
+		{"HexInt32", binXMLTypeHexInt32, []byte{0xe7, 0x03, 0, 0}, "0x3e7"},
+		{"HexInt64", binXMLTypeHexInt64, []byte{0, 0, 0, 0, 0, 0, 0, 0x80}, "0x8000000000000000"},
+		{"StringArray", binXMLTypeArray | binXMLTypeString, stringValue("a\x00bc\x00").data, "a,bc"},
+		{"Uint16Array", binXMLTypeArray | binXMLTypeUint16, []byte{1, 0, 2, 0}, "1,2"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &binXMLReader{}
+			text, err := r.formatValue(binXMLValue{valueType: tc.valueType, data: tc.data})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, text)
+		})
+	}
+}
+
+func TestFormatBinXMLValueInvalid(t *testing.T) {
+	cases := []struct {
+		name      string
+		valueType byte
+		data      []byte
+	}{
+		{"ShortUint32", binXMLTypeUint32, []byte{1, 0}},
+		{"ShortSID", binXMLTypeSID, []byte{1, 2, 0, 0, 0, 0, 0, 5, 32, 0, 0, 0}},
+		{"InvalidSizeT", binXMLTypeSizeT, []byte{1, 0}},
+		{"InvalidArray", binXMLTypeArray | binXMLTypeUint32, []byte{1, 0}},
+		{"UnknownType", 0x7f, []byte{1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &binXMLReader{}
+			_, err := r.formatValue(binXMLValue{valueType: tc.valueType, data: tc.data})
+			require.Error(t, err)
+		})
+	}
+}
+
+type testReaderAt []byte
+
+func newTestReaderAt(data []byte) testReaderAt {
+	return testReaderAt(data)
+}
+
+func (r testReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	if offset >= int64(len(r)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func expectRecordID(t *testing.T, fake *testutil.FakeOutput, id uint64) *entry.Entry {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, id, e.Record.(map[string]interface{})["record_id"])
+		return e
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+func TestEVTXInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*EVTXInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *EVTXInputConfig) {}, false},
+		{"MissingInclude", func(cfg *EVTXInputConfig) { cfg.Include = nil }, true},
+		{"InvalidInclude", func(cfg *EVTXInputConfig) { cfg.Include = []string{"["} }, true},
+		{"InvalidExclude", func(cfg *EVTXInputConfig) { cfg.Exclude = []string{"["} }, true},
+		{"ZeroPollInterval", func(cfg *EVTXInputConfig) { cfg.PollInterval.Duration = 0 }, true},
+		{"InvalidStartAt", func(cfg *EVTXInputConfig) { cfg.StartAt = "middle" }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewEVTXInputConfig("test")
+			cfg.Include = []string{"*.evtx"}
+			tc.modify(cfg)
+
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEVTXInput(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	path := filepath.Join(dir, "Security.evtx")
+	require.NoError(t, ioutil.WriteFile(path, testEVTXFile(testChunkBytes(t)), 0600))
+
+	buildContext := testutil.NewBuildContext(t)
+
+	cfg := NewEVTXInputConfig("test")
+	cfg.Include = []string{filepath.Join(dir, "*.evtx")}
+	cfg.PollInterval.Duration = 10 * time.Millisecond
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(buildContext)
+	require.NoError(t, err)
+	evtxInput := ops[0].(*EVTXInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, evtxInput.SetOutputs([]operator.Operator{fake}))
+
+	require.NoError(t, evtxInput.Start())
+
+	e := expectRecordID(t, fake, 1)
+	require.Equal(t, "Security.evtx", e.Labels["file_name"])
+	require.Equal(t, entry.Info, e.Severity)
+	require.Equal(t, time.Date(2021, 5, 4, 10, 15, 3, 123456700, time.UTC), e.Timestamp)
+	require.Equal(t, "An account was successfully logged on.", e.Record.(map[string]interface{})["message"])
+	expectRecordID(t, fake, 2)
+	e = expectRecordID(t, fake, 3)
+	require.Equal(t, entry.Error, e.Severity)
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+	require.NoError(t, evtxInput.Stop())
+
+	// Events that were read are not read again after a restart
+	c := newTestChunk()
+	c.record(4, "plain", testTemplate, append(testSystemValues(4, "Application", 3), stringValue("data")))
+	require.NoError(t, ioutil.WriteFile(path, testEVTXFile(testChunkBytes(t), c.bytes()), 0600))
+
+	ops, err = cfg.Build(buildContext)
+	require.NoError(t, err)
+	evtxInput = ops[0].(*EVTXInput)
+
+	fake = testutil.NewFakeOutput(t)
+	require.NoError(t, evtxInput.SetOutputs([]operator.Operator{fake}))
+
+	require.NoError(t, evtxInput.Start())
+	defer evtxInput.Stop()
+
+	e = expectRecordID(t, fake, 4)
+	require.Equal(t, entry.Warning, e.Severity)
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+}
+
+func TestEVTXInputStartAtEnd(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "existing.evtx"), testEVTXFile(testChunkBytes(t)), 0600))
+
+	cfg := NewEVTXInputConfig("test")
+	cfg.Include = []string{filepath.Join(dir, "*.evtx")}
+	cfg.PollInterval.Duration = 10 * time.Millisecond
+	cfg.OutputIDs = []string{"fake"}
+	cfg.StartAt = "end"
+	cfg.IncludeFileName = false
+	cfg.IncludeFilePath = true
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	evtxInput := ops[0].(*EVTXInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, evtxInput.SetOutputs([]operator.Operator{fake}))
+
+	require.NoError(t, evtxInput.Start())
+	defer evtxInput.Stop()
+	fake.ExpectNoEntry(t, 100*time.Millisecond)
+
+	// Files that are found later are read from the beginning
+	path := filepath.Join(dir, "new.evtx")
+	require.NoError(t, ioutil.WriteFile(path, testEVTXFile(testChunkBytes(t)), 0600))
+	e := expectRecordID(t, fake, 1)
+	require.Equal(t, map[string]string{"file_path": path}, e.Labels)
+	expectRecordID(t, fake, 2)
+	expectRecordID(t, fake, 3)
+}
+
+func TestEVTXInputReplacedFile(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	path := filepath.Join(dir, "Application.evtx")
+	c := newTestChunk()
+	for id := uint64(1); id <= 5; id++ {
+		c.record(id, "plain", testTemplate, append(testSystemValues(id, "Application", 4), stringValue("data")))
+	}
+	require.NoError(t, ioutil.WriteFile(path, testEVTXFile(c.bytes()), 0600))
+
+	cfg := NewEVTXInputConfig("test")
+	cfg.Include = []string{path}
+	cfg.PollInterval.Duration = 10 * time.Millisecond
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	evtxInput := ops[0].(*EVTXInput)
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, evtxInput.SetOutputs([]operator.Operator{fake}))
+
+	require.NoError(t, evtxInput.Start())
+	defer evtxInput.Stop()
+	for id := uint64(1); id <= 5; id++ {
+		expectRecordID(t, fake, id)
+	}
+
+	// A cleared log starts again from the first record
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, ioutil.WriteFile(path, testEVTXFile(testChunkBytes(t)), 0600))
+	expectRecordID(t, fake, 1)
+	expectRecordID(t, fake, 2)
+	expectRecordID(t, fake, 3)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/observiq/stanza/operator"
 	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/operator/helper/eventxml"
 )
 
 func init() {
@@ -205,16 +206,16 @@ func (e *EventLogInput) processEvent(ctx context.Context, event Event) {
 }
 
 // sendEvent will send EventXML as an entry to the operator's output.
-func (e *EventLogInput) sendEvent(ctx context.Context, eventXML EventXML) {
-	record := eventXML.parseRecord()
+func (e *EventLogInput) sendEvent(ctx context.Context, eventXML eventxml.EventXML) {
+	record := eventXML.ParseRecord()
 	entry, err := e.NewEntry(record)
 	if err != nil {
 		e.Errorf("Failed to create entry: %s", err)
 		return
 	}
 
-	entry.Timestamp = eventXML.parseTimestamp()
-	entry.Severity = eventXML.parseSeverity()
+	entry.Timestamp = eventXML.ParseTimestamp()
+	entry.Severity = eventXML.ParseSeverity()
 	e.Write(ctx, entry)
 }
 
@@ -0,0 +1,314 @@
+package elastic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	opTypeIndex  = "index"
+	opTypeCreate = "create"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+
+	onRejectedLog        = "log"
+	onRejectedDrop       = "drop"
+	onRejectedDeadLetter = "dead_letter"
+)
+
+// bulkItem is a single document operation in a bulk request
+type bulkItem struct {
+	action   string
+	index    string
+	id       string
+	pipeline string
+	document []byte
+
+	// deadLetter is true if this item is already a rejected document being
+	// sent to the dead letter index, so it is not dead lettered again
+	deadLetter bool
+}
+
+// bulkDirective is the action line that precedes each document in a bulk request
+type bulkDirective struct {
+	Index    string `json:"_index"`
+	ID       string `json:"_id,omitempty"`
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// bulkResponse is the subset of the bulk API response used to find failed items
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+// bulkResponseItem is the result of a single bulk item
+type bulkResponseItem struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// deadLetterDocument wraps a rejected entry for the dead letter index
+type deadLetterDocument struct {
+	Timestamp   time.Time       `json:"@timestamp"`
+	Index       string          `json:"index"`
+	Status      int             `json:"status"`
+	Error       json.RawMessage `json:"error,omitempty"`
+	Document    json.RawMessage `json:"document"`
+	OperationID string          `json:"operation_id,omitempty"`
+}
+
+// newBulkItems creates the bulk items for a chunk of entries. Entries that
+// cannot be converted are logged and dropped, since retrying won't help.
+func (e *ElasticOutput) newBulkItems(entries []*entry.Entry) []*bulkItem {
+	items := make([]*bulkItem, 0, len(entries))
+	for _, entry := range entries {
+		index, err := e.FindIndex(entry)
+		if err != nil {
+			e.Warnw("Failed to find index", zap.Any("error", err))
+			continue
+		}
+
+		var id string
+		// Data streams generate their own IDs unless one is explicitly configured
+		if !e.dataStream || e.idField != nil {
+			id, err = e.FindID(entry)
+			if err != nil {
+				e.Warnw("Failed to find id", zap.Any("error", err))
+				continue
+			}
+		}
+
+		pipeline, err := e.FindPipeline(entry)
+		if err != nil {
+			e.Warnw("Failed to find pipeline", zap.Any("error", err))
+			continue
+		}
+
+		document, err := e.marshalEntry(entry)
+		if err != nil {
+			e.Warnw("Failed to marshal entry JSON", zap.Any("error", err))
+			continue
+		}
+
+		items = append(items, &bulkItem{
+			action:   e.opType,
+			index:    index,
+			id:       id,
+			pipeline: pipeline,
+			document: document,
+		})
+	}
+	return items
+}
+
+// marshalEntry converts an entry to a document. Data streams require an
+// @timestamp field, so it is added to the document when writing to one.
+func (e *ElasticOutput) marshalEntry(entry *entry.Entry) ([]byte, error) {
+	document, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.dataStream {
+		return document, nil
+	}
+
+	timestamp, err := json.Marshal(entry.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"@timestamp":`)
+	buf.Write(timestamp)
+	buf.WriteByte(',')
+	buf.Write(document[1:])
+	return buf.Bytes(), nil
+}
+
+// createRequest creates a bulk request for the given items
+func (e *ElasticOutput) createRequest(items []*bulkItem) (*esapi.BulkRequest, error) {
+	// The bulk API expects newline-delimited json strings, with an operation directive
+	// immediately followed by the document.
+	// https://www.elastic.co/guide/en/elasticsearch/reference/master/docs-bulk.html
+	var body bytes.Buffer
+	var w io.Writer = &body
+	var gz *gzip.Writer
+	if e.compress {
+		gz = gzip.NewWriter(&body)
+		w = gz
+	}
+
+	for _, item := range items {
+		directive := map[string]bulkDirective{
+			item.action: {
+				Index:    item.index,
+				ID:       item.id,
+				Pipeline: item.pipeline,
+			},
+		}
+
+		directiveJSON, err := json.Marshal(directive)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal directive JSON")
+		}
+
+		for _, b := range [][]byte{directiveJSON, []byte("\n"), item.document, []byte("\n")} {
+			if _, err := w.Write(b); err != nil {
+				return nil, errors.Wrap(err, "write request body")
+			}
+		}
+	}
+
+	request := &esapi.BulkRequest{
+		Body:     bytes.NewReader(body.Bytes()),
+		Pipeline: e.pipeline,
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, errors.Wrap(err, "compress request body")
+		}
+		request.Body = bytes.NewReader(body.Bytes())
+		request.Header = http.Header{"Content-Encoding": []string{"gzip"}}
+	}
+
+	return request, nil
+}
+
+// sendBulk sends the items to elasticsearch and inspects the result of each
+// item. It returns the items that should be retried, along with an error if
+// there are any. Items rejected with a non-retryable status are passed to
+// the configured rejection handler.
+func (e *ElasticOutput) sendBulk(ctx context.Context, items []*bulkItem) ([]*bulkItem, error) {
+	req, err := e.createRequest(items)
+	if err != nil {
+		// drop these logs because we couldn't create a request and a retry won't help
+		e.Errorw("Failed to create request", zap.Error(err))
+		return nil, nil
+	}
+
+	res, err := req.Do(ctx, e.client)
+	if err != nil {
+		return items, errors.NewError(
+			"Client failed to submit request to elasticsearch.",
+			"Review the underlying error message to troubleshoot the issue",
+			"underlying_error", err.Error(),
+		)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return items, errors.NewError(
+			"Request to elasticsearch returned a failure code.",
+			"Review status and status code for further details.",
+			"status_code", strconv.Itoa(res.StatusCode),
+			"status", res.Status(),
+		)
+	}
+
+	var bulkRes bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&bulkRes); err != nil {
+		return items, errors.NewError(
+			"Failed to decode the bulk response from elasticsearch.",
+			"Ensure that the configured addresses point to elasticsearch.",
+			"underlying_error", err.Error(),
+		)
+	}
+
+	if !bulkRes.Errors {
+		return nil, nil
+	}
+
+	if len(bulkRes.Items) != len(items) {
+		e.Warnw("Bulk response item count does not match request. Assuming all items succeeded",
+			"expected", len(items), "actual", len(bulkRes.Items))
+		return nil, nil
+	}
+
+	var retry []*bulkItem
+	for i, resItem := range bulkRes.Items {
+		result := resItem[items[i].action]
+		switch {
+		case result.Status < 300:
+			continue
+		case result.Status == http.StatusConflict && items[i].action == opTypeCreate && items[i].id != "":
+			// The document was already created by an earlier attempt
+			continue
+		case isRetryableStatus(result.Status):
+			retry = append(retry, items[i])
+		default:
+			if deadLetter := e.handleRejected(items[i], result); deadLetter != nil {
+				retry = append(retry, deadLetter)
+			}
+		}
+	}
+
+	if len(retry) > 0 {
+		return retry, errors.NewError(
+			"Some items in the bulk request failed.",
+			"Review the elasticsearch logs for further details.",
+			"failed_items", strconv.Itoa(len(retry)),
+		)
+	}
+
+	return nil, nil
+}
+
+// isRetryableStatus returns true if an item that failed with the
+// given status code can succeed on a later attempt
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// handleRejected handles an item that was permanently rejected by elasticsearch.
+// If the item should be sent to the dead letter index, the new item is returned.
+func (e *ElasticOutput) handleRejected(item *bulkItem, result bulkResponseItem) *bulkItem {
+	switch {
+	case e.onRejected == onRejectedDrop:
+		e.Debugw("Dropping item rejected by elasticsearch", "index", item.index, "status", result.Status, "error", string(result.Error))
+		return nil
+	case e.onRejected == onRejectedDeadLetter && !item.deadLetter:
+		document, err := json.Marshal(deadLetterDocument{
+			Timestamp:   time.Now(),
+			Index:       item.index,
+			Status:      result.Status,
+			Error:       result.Error,
+			Document:    item.document,
+			OperationID: item.id,
+		})
+		if err != nil {
+			e.Errorw("Failed to create dead letter document. Dropping item", zap.Error(err))
+			return nil
+		}
+
+		deadLetter := &bulkItem{
+			action:     opTypeIndex,
+			index:      e.deadLetterIndex,
+			document:   document,
+			deadLetter: true,
+		}
+
+		// Skip the request level pipeline, since it may be what rejected the item
+		if e.pipeline != "" {
+			deadLetter.pipeline = "_none"
+		}
+		return deadLetter
+	default:
+		e.Errorw("Item rejected by elasticsearch. Dropping item", "index", item.index, "status", result.Status, "error", string(result.Error))
+		return nil
+	}
+}
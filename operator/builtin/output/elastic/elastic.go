@@ -1,14 +1,11 @@
 package elastic
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"strconv"
+	"fmt"
 	"sync"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v7"
-	"github.com/elastic/go-elasticsearch/v7/esapi"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/errors"
@@ -29,6 +26,8 @@ func NewElasticOutputConfig(operatorID string) *ElasticOutputConfig {
 		OutputConfig:  helper.NewOutputConfig(operatorID, "elastic_output"),
 		BufferConfig:  buffer.NewConfig(),
 		FlusherConfig: flusher.NewConfig(),
+		Compression:   compressionNone,
+		OnRejected:    onRejectedLog,
 	}
 }
 
@@ -38,13 +37,20 @@ type ElasticOutputConfig struct {
 	BufferConfig        buffer.Config  `json:"buffer" yaml:"buffer"`
 	FlusherConfig       flusher.Config `json:"flusher" yaml:"flusher"`
 
-	Addresses  []string     `json:"addresses"             yaml:"addresses,flow"`
-	Username   string       `json:"username"              yaml:"username"`
-	Password   string       `json:"password"              yaml:"password"`
-	CloudID    string       `json:"cloud_id"              yaml:"cloud_id"`
-	APIKey     string       `json:"api_key"               yaml:"api_key"`
-	IndexField *entry.Field `json:"index_field,omitempty" yaml:"index_field,omitempty"`
-	IDField    *entry.Field `json:"id_field,omitempty"    yaml:"id_field,omitempty"`
+	Addresses       []string     `json:"addresses"                   yaml:"addresses,flow"`
+	Username        string       `json:"username"                    yaml:"username"`
+	Password        string       `json:"password"                    yaml:"password"`
+	CloudID         string       `json:"cloud_id"                    yaml:"cloud_id"`
+	APIKey          string       `json:"api_key"                     yaml:"api_key"`
+	IndexField      *entry.Field `json:"index_field,omitempty"       yaml:"index_field,omitempty"`
+	IDField         *entry.Field `json:"id_field,omitempty"          yaml:"id_field,omitempty"`
+	OpType          string       `json:"op_type,omitempty"           yaml:"op_type,omitempty"`
+	DataStream      bool         `json:"data_stream,omitempty"       yaml:"data_stream,omitempty"`
+	Pipeline        string       `json:"pipeline,omitempty"          yaml:"pipeline,omitempty"`
+	PipelineField   *entry.Field `json:"pipeline_field,omitempty"    yaml:"pipeline_field,omitempty"`
+	Compression     string       `json:"compression,omitempty"       yaml:"compression,omitempty"`
+	OnRejected      string       `json:"on_rejected,omitempty"       yaml:"on_rejected,omitempty"`
+	DeadLetterIndex string       `json:"dead_letter_index,omitempty" yaml:"dead_letter_index,omitempty"`
 }
 
 // Build will build an elasticsearch output operator.
@@ -54,6 +60,44 @@ func (c ElasticOutputConfig) Build(bc operator.BuildContext) ([]operator.Operato
 		return nil, err
 	}
 
+	switch c.OpType {
+	case opTypeIndex, opTypeCreate, "":
+	default:
+		return nil, fmt.Errorf("invalid op_type '%s', must be one of '%s' or '%s'", c.OpType, opTypeIndex, opTypeCreate)
+	}
+
+	// Data streams only accept the create operation
+	if c.DataStream && c.OpType == opTypeIndex {
+		return nil, fmt.Errorf("op_type '%s' cannot be used with data streams", opTypeIndex)
+	}
+
+	opType := c.OpType
+	if opType == "" {
+		opType = opTypeIndex
+		if c.DataStream {
+			opType = opTypeCreate
+		}
+	}
+
+	switch c.Compression {
+	case compressionNone, compressionGzip, "":
+	default:
+		return nil, fmt.Errorf("invalid compression '%s', must be one of '%s' or '%s'", c.Compression, compressionNone, compressionGzip)
+	}
+
+	onRejected := c.OnRejected
+	switch onRejected {
+	case onRejectedLog, onRejectedDrop:
+	case onRejectedDeadLetter:
+		if c.DeadLetterIndex == "" {
+			return nil, fmt.Errorf("'dead_letter_index' is required when on_rejected is '%s'", onRejectedDeadLetter)
+		}
+	case "":
+		onRejected = onRejectedLog
+	default:
+		return nil, fmt.Errorf("invalid on_rejected '%s', must be one of '%s', '%s' or '%s'", onRejected, onRejectedLog, onRejectedDrop, onRejectedDeadLetter)
+	}
+
 	cfg := elasticsearch.Config{
 		Addresses: c.Addresses,
 		Username:  c.Username,
@@ -81,14 +125,21 @@ func (c ElasticOutputConfig) Build(bc operator.BuildContext) ([]operator.Operato
 	ctx, cancel := context.WithCancel(context.Background())
 
 	elasticOutput := &ElasticOutput{
-		OutputOperator: outputOperator,
-		buffer:         buffer,
-		client:         client,
-		indexField:     c.IndexField,
-		idField:        c.IDField,
-		flusher:        flusher,
-		ctx:            ctx,
-		cancel:         cancel,
+		OutputOperator:  outputOperator,
+		buffer:          buffer,
+		client:          client,
+		indexField:      c.IndexField,
+		idField:         c.IDField,
+		opType:          opType,
+		dataStream:      c.DataStream,
+		pipeline:        c.Pipeline,
+		pipelineField:   c.PipelineField,
+		compress:        c.Compression == compressionGzip,
+		onRejected:      onRejected,
+		deadLetterIndex: c.DeadLetterIndex,
+		flusher:         flusher,
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 
 	return []operator.Operator{elasticOutput}, nil
@@ -100,9 +151,16 @@ type ElasticOutput struct {
 	buffer  buffer.Buffer
 	flusher *flusher.Flusher
 
-	client     *elasticsearch.Client
-	indexField *entry.Field
-	idField    *entry.Field
+	client          *elasticsearch.Client
+	indexField      *entry.Field
+	idField         *entry.Field
+	opType          string
+	dataStream      bool
+	pipeline        string
+	pipelineField   *entry.Field
+	compress        bool
+	onRejected      string
+	deadLetterIndex string
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -133,59 +191,6 @@ func (e *ElasticOutput) Process(ctx context.Context, entry *entry.Entry) error {
 	return e.buffer.Add(ctx, entry)
 }
 
-// ProcessMulti will send entries to elasticsearch.
-func (e *ElasticOutput) createRequest(entries []*entry.Entry) *esapi.BulkRequest {
-	type indexDirective struct {
-		Index struct {
-			Index string `json:"_index"`
-			ID    string `json:"_id"`
-		} `json:"index"`
-	}
-
-	// The bulk API expects newline-delimited json strings, with an operation directive
-	// immediately followed by the document.
-	// https://www.elastic.co/guide/en/elasticsearch/reference/master/docs-bulk.html
-	var buffer bytes.Buffer
-	var err error
-	for _, entry := range entries {
-		directive := indexDirective{}
-		directive.Index.Index, err = e.FindIndex(entry)
-		if err != nil {
-			e.Warnw("Failed to find index", zap.Any("error", err))
-			continue
-		}
-
-		directive.Index.ID, err = e.FindID(entry)
-		if err != nil {
-			e.Warnw("Failed to find id", zap.Any("error", err))
-			continue
-		}
-
-		directiveJSON, err := json.Marshal(directive)
-		if err != nil {
-			e.Warnw("Failed to marshal directive JSON", zap.Any("error", err))
-			continue
-		}
-
-		entryJSON, err := json.Marshal(entry)
-		if err != nil {
-			e.Warnw("Failed to marshal entry JSON", zap.Any("error", err))
-			continue
-		}
-
-		buffer.Write(directiveJSON)
-		buffer.Write([]byte("\n"))
-		buffer.Write(entryJSON)
-		buffer.Write([]byte("\n"))
-	}
-
-	request := &esapi.BulkRequest{
-		Body: bytes.NewReader(buffer.Bytes()),
-	}
-
-	return request
-}
-
 func (e *ElasticOutput) feedFlusher(ctx context.Context) {
 	for {
 		entries, clearer, err := e.buffer.ReadChunk(ctx)
@@ -196,27 +201,21 @@ func (e *ElasticOutput) feedFlusher(ctx context.Context) {
 			continue
 		}
 
-		e.flusher.Do(func(ctx context.Context) error {
-			req := e.createRequest(entries)
-			res, err := req.Do(ctx, e.client)
-			if err != nil {
-				return errors.NewError(
-					"Client failed to submit request to elasticsearch.",
-					"Review the underlying error message to troubleshoot the issue",
-					"underlying_error", err.Error(),
-				)
-			}
+		// Items are created once per chunk so that retries reuse the same
+		// document IDs. Each attempt only resends items that have not yet
+		// been accepted or permanently rejected.
+		pending := e.newBulkItems(entries)
 
-			if res.IsError() {
-				return errors.NewError(
-					"Request to elasticsearch returned a failure code.",
-					"Review status and status code for further details.",
-					"status_code", strconv.Itoa(res.StatusCode),
-					"status", res.Status(),
-				)
+		e.flusher.Do(func(ctx context.Context) error {
+			if len(pending) > 0 {
+				var err error
+				pending, err = e.sendBulk(ctx, pending)
+				if err != nil {
+					return err
+				}
 			}
 
-			if err = clearer.MarkAllAsFlushed(); err != nil {
+			if err := clearer.MarkAllAsFlushed(); err != nil {
 				e.Errorw("Failed to mark entries as flushed", zap.Error(err))
 			}
 			return nil
@@ -253,3 +252,19 @@ func (e *ElasticOutput) FindID(entry *entry.Entry) (string, error) {
 
 	return value, nil
 }
+
+// FindPipeline will find the ingest pipeline used to process an entry in elasticsearch.
+// An empty pipeline means the request level pipeline is used.
+func (e *ElasticOutput) FindPipeline(entry *entry.Entry) (string, error) {
+	if e.pipelineField == nil {
+		return "", nil
+	}
+
+	var value string
+	err := entry.Read(*e.pipelineField, &value)
+	if err != nil {
+		return "", errors.Wrap(err, "extract pipeline from record")
+	}
+
+	return value, nil
+}
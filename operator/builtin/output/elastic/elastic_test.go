@@ -2,17 +2,23 @@ package elastic
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator/buffer"
+	"github.com/observiq/stanza/operator/helper"
 	"github.com/observiq/stanza/testutil"
 	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func TestFindIndex(t *testing.T) {
@@ -131,3 +137,317 @@ func TestElastic(t *testing.T) {
 		require.Equal(t, "test", entry["record"])
 	}
 }
+
+func TestElasticConfigBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*ElasticOutputConfig)
+		expectErr bool
+	}{
+		{
+			"Default",
+			func(cfg *ElasticOutputConfig) {},
+			false,
+		},
+		{
+			"DataStream",
+			func(cfg *ElasticOutputConfig) { cfg.DataStream = true },
+			false,
+		},
+		{
+			"DataStreamWithIndexOpType",
+			func(cfg *ElasticOutputConfig) {
+				cfg.OpType = opTypeIndex
+				cfg.DataStream = true
+			},
+			true,
+		},
+		{
+			"InvalidOpType",
+			func(cfg *ElasticOutputConfig) { cfg.OpType = "update" },
+			true,
+		},
+		{
+			"InvalidCompression",
+			func(cfg *ElasticOutputConfig) { cfg.Compression = "zip" },
+			true,
+		},
+		{
+			"InvalidOnRejected",
+			func(cfg *ElasticOutputConfig) { cfg.OnRejected = "retry" },
+			true,
+		},
+		{
+			"DeadLetterMissingIndex",
+			func(cfg *ElasticOutputConfig) { cfg.OnRejected = onRejectedDeadLetter },
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewElasticOutputConfig("test")
+			cfg.Addresses = []string{"http://localhost:9200"}
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestElasticConfigDataStreamYAML(t *testing.T) {
+	cfgBytes := []byte(`
+type: elastic_output
+addresses:
+  - http://localhost:9200
+data_stream: true
+`)
+
+	cfg := NewElasticOutputConfig("")
+	require.NoError(t, yaml.Unmarshal(cfgBytes, cfg))
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.Equal(t, opTypeCreate, ops[0].(*ElasticOutput).opType)
+}
+
+type bulkRequestLine struct {
+	action    string
+	directive bulkDirective
+	document  map[string]interface{}
+}
+
+// newBulkServer creates a server that records the bulk requests it receives,
+// and responds to each item with the status returned by status
+func newBulkServer(t *testing.T, status func(document map[string]interface{}) int) (*httptest.Server, chan []bulkRequestLine) {
+	received := make(chan []bulkRequestLine, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = gz
+		}
+
+		lines := []bulkRequestLine{}
+		response := bulkResponse{}
+		dec := json.NewDecoder(body)
+		for dec.More() {
+			var directive map[string]bulkDirective
+			require.NoError(t, dec.Decode(&directive))
+			var document map[string]interface{}
+			require.NoError(t, dec.Decode(&document))
+
+			for action, d := range directive {
+				lines = append(lines, bulkRequestLine{action: action, directive: d, document: document})
+
+				itemStatus := status(document)
+				item := bulkResponseItem{Status: itemStatus}
+				if itemStatus >= 300 {
+					response.Errors = true
+					item.Error = json.RawMessage(`{"type":"test_exception","reason":"test"}`)
+				}
+				response.Items = append(response.Items, map[string]bulkResponseItem{action: item})
+			}
+		}
+
+		received <- lines
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, received
+}
+
+func expectBulkRequest(t *testing.T, received chan []bulkRequestLine) []bulkRequestLine {
+	select {
+	case lines := <-received:
+		return lines
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for request")
+	}
+	return nil
+}
+
+func TestElasticDataStream(t *testing.T) {
+	ts, received := newBulkServer(t, func(map[string]interface{}) int { return 201 })
+
+	cfg := NewElasticOutputConfig("test")
+	cfg.Addresses = []string{ts.URL}
+	memoryCfg := buffer.NewMemoryBufferConfig()
+	memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.BufferConfig = buffer.Config{Builder: memoryCfg}
+	cfg.DataStream = true
+	cfg.Compression = compressionGzip
+	pipelineField := entry.NewLabelField("pipeline")
+	cfg.PipelineField = &pipelineField
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*ElasticOutput)
+
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	e := entry.New()
+	e.Timestamp = time.Date(2021, 7, 20, 10, 0, 0, 0, time.UTC)
+	e.Record = "test"
+	e.Labels = map[string]string{"pipeline": "my-pipeline"}
+	require.NoError(t, op.Process(context.Background(), e))
+
+	lines := expectBulkRequest(t, received)
+	require.Len(t, lines, 1)
+	require.Equal(t, opTypeCreate, lines[0].action)
+	require.Equal(t, "default", lines[0].directive.Index)
+	require.Empty(t, lines[0].directive.ID)
+	require.Equal(t, "my-pipeline", lines[0].directive.Pipeline)
+	require.Equal(t, "2021-07-20T10:00:00Z", lines[0].document["@timestamp"])
+	require.Equal(t, "test", lines[0].document["record"])
+}
+
+func TestElasticItemFailures(t *testing.T) {
+	t.Run("RetryOnlyRetryableItems", func(t *testing.T) {
+		var attempts int32
+		ts, received := newBulkServer(t, func(document map[string]interface{}) int {
+			switch document["record"] {
+			case "retryable":
+				if atomic.AddInt32(&attempts, 1) == 1 {
+					return 429
+				}
+				return 201
+			case "rejected":
+				return 400
+			default:
+				return 201
+			}
+		})
+
+		cfg := NewElasticOutputConfig("test")
+		cfg.Addresses = []string{ts.URL}
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{Builder: memoryCfg}
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*ElasticOutput)
+
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		for _, record := range []string{"ok", "retryable", "rejected"} {
+			e := entry.New()
+			e.Record = record
+			require.NoError(t, op.Process(context.Background(), e))
+		}
+
+		first := expectBulkRequest(t, received)
+		require.Len(t, first, 3)
+
+		second := expectBulkRequest(t, received)
+		require.Len(t, second, 1)
+		require.Equal(t, "retryable", second[0].document["record"])
+		require.Equal(t, first[1].directive.ID, second[0].directive.ID)
+
+		select {
+		case lines := <-received:
+			require.FailNow(t, "Unexpected request", lines)
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+
+	t.Run("RetryUndecodableResponse", func(t *testing.T) {
+		var attempts int32
+		received := make(chan string, 10)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			received <- string(body)
+
+			w.Header().Set("Content-Type", "application/json")
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				_, err = w.Write([]byte(`{"took":`))
+				require.NoError(t, err)
+				return
+			}
+			_, err = w.Write([]byte(`{"errors":false,"items":[{"create":{"status":201}}]}`))
+			require.NoError(t, err)
+		}))
+		defer ts.Close()
+
+		cfg := NewElasticOutputConfig("test")
+		cfg.Addresses = []string{ts.URL}
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{Builder: memoryCfg}
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*ElasticOutput)
+
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		e := entry.New()
+		e.Record = "test"
+		require.NoError(t, op.Process(context.Background(), e))
+
+		// The items are sent again, since their result is unknown
+		var requests []string
+		for i := 0; i < 2; i++ {
+			select {
+			case body := <-received:
+				requests = append(requests, body)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "Timed out waiting for request")
+			}
+		}
+		require.Equal(t, requests[0], requests[1])
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		ts, received := newBulkServer(t, func(document map[string]interface{}) int {
+			if document["record"] == "rejected" {
+				return 400
+			}
+			return 201
+		})
+
+		cfg := NewElasticOutputConfig("test")
+		cfg.Addresses = []string{ts.URL}
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{Builder: memoryCfg}
+		cfg.OnRejected = onRejectedDeadLetter
+		cfg.DeadLetterIndex = "dead-letter"
+		cfg.Pipeline = "my-pipeline"
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*ElasticOutput)
+
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		e := entry.New()
+		e.Record = "rejected"
+		require.NoError(t, op.Process(context.Background(), e))
+
+		first := expectBulkRequest(t, received)
+		require.Len(t, first, 1)
+
+		second := expectBulkRequest(t, received)
+		require.Len(t, second, 1)
+		require.Equal(t, opTypeIndex, second[0].action)
+		require.Equal(t, "dead-letter", second[0].directive.Index)
+		require.Equal(t, "_none", second[0].directive.Pipeline)
+		require.Equal(t, "default", second[0].document["index"])
+		require.Equal(t, float64(400), second[0].document["status"])
+		require.Equal(t, "rejected", second[0].document["document"].(map[string]interface{})["record"])
+	})
+}
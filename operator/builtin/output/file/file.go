@@ -1,12 +1,16 @@
 package file
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	textTemplate "text/template"
+	"time"
 
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/operator"
@@ -17,10 +21,13 @@ func init() {
 	operator.Register("file_output", func() operator.Builder { return NewFileOutputConfig("") })
 }
 
+const defaultMaxOpenFiles = 64
+
 // NewFileOutputConfig creates a new file output config with default values
 func NewFileOutputConfig(operatorID string) *FileOutputConfig {
 	return &FileOutputConfig{
 		OutputConfig: helper.NewOutputConfig(operatorID, "file_output"),
+		MaxOpenFiles: defaultMaxOpenFiles,
 	}
 }
 
@@ -29,7 +36,14 @@ type FileOutputConfig struct {
 	helper.OutputConfig `yaml:",inline"`
 
 	Path   string `json:"path" yaml:"path"`
-	Format string `json:"format,omitempty" path:"format,omitempty"`
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+
+	MaxSize        helper.ByteSize `json:"max_size,omitempty"        yaml:"max_size,omitempty"`
+	RotateInterval helper.Duration `json:"rotate_interval,omitempty" yaml:"rotate_interval,omitempty"`
+	Compress       bool            `json:"compress,omitempty"        yaml:"compress,omitempty"`
+	MaxBackups     int             `json:"max_backups,omitempty"     yaml:"max_backups,omitempty"`
+	MaxBackupAge   helper.Duration `json:"max_backup_age,omitempty"  yaml:"max_backup_age,omitempty"`
+	MaxOpenFiles   int             `json:"max_open_files,omitempty"  yaml:"max_open_files,omitempty"`
 }
 
 // Build will build a file output operator.
@@ -51,10 +65,57 @@ func (c FileOutputConfig) Build(context operator.BuildContext) ([]operator.Opera
 		return nil, fmt.Errorf("must provide a path to output to")
 	}
 
+	var pathTmpl *textTemplate.Template
+	var pathBase string
+	if i := strings.Index(c.Path, "{{"); i >= 0 {
+		pathTmpl, err = textTemplate.New("path").Option("missingkey=zero").Parse(c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("parse path template: %s", err)
+		}
+
+		// Rendered paths must stay within the directory before the first action
+		pathBase = filepath.Dir(c.Path[:i])
+	}
+
+	if c.MaxSize < 0 {
+		return nil, fmt.Errorf("`max_size` must not be negative")
+	}
+
+	if c.RotateInterval.Raw() < 0 {
+		return nil, fmt.Errorf("`rotate_interval` must not be negative")
+	}
+
+	if c.MaxBackups < 0 {
+		return nil, fmt.Errorf("`max_backups` must not be negative")
+	}
+
+	if c.MaxBackupAge.Raw() < 0 {
+		return nil, fmt.Errorf("`max_backup_age` must not be negative")
+	}
+
+	maxOpenFiles := c.MaxOpenFiles
+	if maxOpenFiles == 0 {
+		maxOpenFiles = defaultMaxOpenFiles
+	} else if maxOpenFiles < 0 {
+		return nil, fmt.Errorf("`max_open_files` must be positive")
+	}
+
 	fileOutput := &FileOutput{
 		OutputOperator: outputOperator,
 		path:           c.Path,
+		pathTmpl:       pathTmpl,
+		pathBase:       pathBase,
 		tmpl:           tmpl,
+		rotation: rotationConfig{
+			maxSize:        int64(c.MaxSize),
+			rotateInterval: c.RotateInterval.Raw(),
+			compress:       c.Compress,
+			maxBackups:     c.MaxBackups,
+			maxBackupAge:   c.MaxBackupAge.Raw(),
+		},
+		maxOpenFiles: maxOpenFiles,
+		files:        make(map[string]*managedFile),
+		now:          time.Now,
 	}
 
 	return []operator.Operator{fileOutput}, nil
@@ -64,51 +125,182 @@ func (c FileOutputConfig) Build(context operator.BuildContext) ([]operator.Opera
 type FileOutput struct {
 	helper.OutputOperator
 
-	path    string
-	tmpl    *template.Template
-	encoder *json.Encoder
-	file    *os.File
-	mux     sync.Mutex
+	path     string
+	pathTmpl *textTemplate.Template
+	pathBase string
+	tmpl     *template.Template
+	rotation rotationConfig
+
+	maxOpenFiles int
+	files        map[string]*managedFile
+	mux          sync.Mutex
+
+	// backgroundWg tracks compression and cleanup of rotated files,
+	// and backgroundMux ensures only one cleanup runs at a time
+	backgroundWg  sync.WaitGroup
+	backgroundMux sync.Mutex
+
+	now func() time.Time
 }
 
-// Start will open the output file.
+// Start will open the output file. If the path is a template, files
+// are opened lazily as entries arrive.
 func (fo *FileOutput) Start() error {
-	var err error
-	fo.file, err = os.OpenFile(fo.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
-	if err != nil {
-		return err
+	if fo.pathTmpl != nil {
+		return nil
 	}
 
-	fo.encoder = json.NewEncoder(fo.file)
-	fo.encoder.SetEscapeHTML(false)
-
-	return nil
+	fo.mux.Lock()
+	defer fo.mux.Unlock()
+	_, err := fo.getFile(fo.path)
+	return err
 }
 
-// Stop will close the output file.
+// Stop will close all open output files and wait for any pending
+// compression of rotated files to complete.
 func (fo *FileOutput) Stop() error {
-	if fo.file != nil {
-		return fo.file.Close()
+	fo.mux.Lock()
+	var closeErr error
+	for path, mf := range fo.files {
+		if err := mf.close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+		delete(fo.files, path)
 	}
-	return nil
+	fo.mux.Unlock()
+
+	fo.backgroundWg.Wait()
+	return closeErr
 }
 
 // Process will write an entry to the output file.
 func (fo *FileOutput) Process(ctx context.Context, entry *entry.Entry) error {
-	fo.mux.Lock()
-	defer fo.mux.Unlock()
+	path, err := fo.resolvePath(entry)
+	if err != nil {
+		return err
+	}
 
+	var buf bytes.Buffer
 	if fo.tmpl != nil {
-		err := fo.tmpl.Execute(fo.file, entry)
-		if err != nil {
+		if err := fo.tmpl.Execute(&buf, entry); err != nil {
 			return err
 		}
 	} else {
-		err := fo.encoder.Encode(entry)
-		if err != nil {
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(entry); err != nil {
 			return err
 		}
 	}
 
+	fo.mux.Lock()
+	defer fo.mux.Unlock()
+
+	mf, err := fo.getFile(path)
+	if err != nil {
+		return err
+	}
+
+	if fo.rotation.shouldRotate(mf, int64(buf.Len()), fo.now()) {
+		if err := fo.rotate(mf); err != nil {
+			return fmt.Errorf("rotate %s: %s", path, err)
+		}
+	}
+
+	return mf.write(buf.Bytes(), fo.now())
+}
+
+// resolvePath returns the path an entry should be written to
+func (fo *FileOutput) resolvePath(entry *entry.Entry) (string, error) {
+	if fo.pathTmpl == nil {
+		return fo.path, nil
+	}
+
+	var buf strings.Builder
+	if err := fo.pathTmpl.Execute(&buf, entry); err != nil {
+		return "", fmt.Errorf("render path template: %s", err)
+	}
+
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("path template rendered an empty path")
+	}
+
+	path := filepath.Clean(buf.String())
+	rel, err := filepath.Rel(fo.pathBase, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path template rendered a path outside of %s: %s", fo.pathBase, path)
+	}
+	return path, nil
+}
+
+// getFile returns the open file for a path, opening it if necessary.
+// If too many files are open, the least recently written file is closed.
+// The caller must hold fo.mux.
+func (fo *FileOutput) getFile(path string) (*managedFile, error) {
+	if mf, ok := fo.files[path]; ok {
+		return mf, nil
+	}
+
+	if len(fo.files) >= fo.maxOpenFiles {
+		fo.closeLeastRecentlyUsed()
+	}
+
+	mf, err := openManagedFile(path, fo.now())
+	if err != nil {
+		return nil, err
+	}
+	fo.files[path] = mf
+	return mf, nil
+}
+
+// closeLeastRecentlyUsed closes the open file that was written to the
+// longest time ago. The caller must hold fo.mux.
+func (fo *FileOutput) closeLeastRecentlyUsed() {
+	var oldest *managedFile
+	for _, mf := range fo.files {
+		if oldest == nil || mf.lastWrite.Before(oldest.lastWrite) {
+			oldest = mf
+		}
+	}
+
+	if oldest == nil {
+		return
+	}
+
+	if err := oldest.close(); err != nil {
+		fo.Errorw("Failed to close file", "path", oldest.path, "error", err)
+	}
+	delete(fo.files, oldest.path)
+}
+
+// rotate moves the current file aside and opens a new one in its place.
+// Compression and retention of the rotated file happen in the background.
+// The caller must hold fo.mux.
+func (fo *FileOutput) rotate(mf *managedFile) error {
+	now := fo.now()
+	rotatedPath, err := mf.rotate(now)
+	if err != nil {
+		// The file may have been left closed, so it is reopened on the next write
+		_ = mf.close()
+		delete(fo.files, mf.path)
+		return err
+	}
+
+	fo.backgroundWg.Add(1)
+	go func() {
+		defer fo.backgroundWg.Done()
+		fo.backgroundMux.Lock()
+		defer fo.backgroundMux.Unlock()
+
+		if fo.rotation.compress {
+			if err := compressFile(rotatedPath); err != nil {
+				fo.Errorw("Failed to compress rotated file", "path", rotatedPath, "error", err)
+			}
+		}
+		if err := fo.rotation.removeExpiredBackups(mf.path, now); err != nil {
+			fo.Errorw("Failed to remove expired backups", "path", mf.path, "error", err)
+		}
+	}()
+
 	return nil
 }
@@ -0,0 +1,377 @@
+package file
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEntry(record string, labels map[string]string) *entry.Entry {
+	e := entry.New()
+	e.Record = record
+	e.Labels = labels
+	return e
+}
+
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path) // #nosec - test file
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestFileOutputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*FileOutputConfig)
+		expectErr bool
+	}{
+		{
+			"Default",
+			func(cfg *FileOutputConfig) {},
+			false,
+		},
+		{
+			"MissingPath",
+			func(cfg *FileOutputConfig) { cfg.Path = "" },
+			true,
+		},
+		{
+			"InvalidPathTemplate",
+			func(cfg *FileOutputConfig) { cfg.Path = "/tmp/{{.Labels" },
+			true,
+		},
+		{
+			"NegativeMaxSize",
+			func(cfg *FileOutputConfig) { cfg.MaxSize = -1 },
+			true,
+		},
+		{
+			"NegativeMaxBackups",
+			func(cfg *FileOutputConfig) { cfg.MaxBackups = -1 },
+			true,
+		},
+		{
+			"NegativeMaxOpenFiles",
+			func(cfg *FileOutputConfig) { cfg.MaxOpenFiles = -1 },
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFileOutputConfig("test")
+			cfg.Path = "/tmp/out.log"
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFileOutputJSON(t *testing.T) {
+	cfg := NewFileOutputConfig("test")
+	cfg.Path = filepath.Join(testutil.NewTempDir(t), "out.log")
+	cfg.Format = ""
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	require.NoError(t, op.Start())
+
+	require.NoError(t, op.Process(context.Background(), newTestEntry("<test>", nil)))
+	require.NoError(t, op.Stop())
+
+	require.Contains(t, readFile(t, op.path), `"record":"<test>"`)
+}
+
+func TestFileOutputPathTemplate(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+
+	cfg := NewFileOutputConfig("test")
+	cfg.Format = "{{.Record}}\n"
+	cfg.Path = filepath.Join(tempDir, "{{.Labels.source}}", "out.log")
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	require.NoError(t, op.Start())
+
+	require.NoError(t, op.Process(context.Background(), newTestEntry("a1", map[string]string{"source": "a"})))
+	require.NoError(t, op.Process(context.Background(), newTestEntry("b1", map[string]string{"source": "b"})))
+	require.NoError(t, op.Process(context.Background(), newTestEntry("a2", map[string]string{"source": "a"})))
+	require.NoError(t, op.Stop())
+
+	require.Equal(t, "a1\na2\n", readFile(t, filepath.Join(tempDir, "a", "out.log")))
+	require.Equal(t, "b1\n", readFile(t, filepath.Join(tempDir, "b", "out.log")))
+}
+
+func TestFileOutputPathTemplateOutsideBase(t *testing.T) {
+	cases := []struct {
+		name      string
+		source    string
+		expectErr bool
+	}{
+		{"Nested", "a/b", false},
+		{"ParentWithinBase", "a/../b", false},
+		{"Parent", "..", true},
+		{"ParentDirectory", "../other", true},
+		{"NestedParent", "a/../../other", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := testutil.NewTempDir(t)
+
+			cfg := NewFileOutputConfig("test")
+			cfg.Format = "{{.Record}}\n"
+			cfg.Path = filepath.Join(tempDir, "logs", "{{.Labels.source}}", "out.log")
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			op := ops[0].(*FileOutput)
+
+			require.NoError(t, op.Start())
+			defer op.Stop()
+
+			err = op.Process(context.Background(), newTestEntry("test", map[string]string{"source": tc.source}))
+			if tc.expectErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "outside of")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "test\n", readFile(t, filepath.Join(tempDir, "logs", tc.source, "out.log")))
+		})
+	}
+}
+
+func TestFileOutputMaxOpenFiles(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+
+	cfg := NewFileOutputConfig("test")
+	cfg.Format = "{{.Record}}\n"
+	cfg.Path = filepath.Join(tempDir, "{{.Record}}.log")
+	cfg.MaxOpenFiles = 2
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	require.NoError(t, op.Start())
+
+	for _, record := range []string{"a", "b", "c", "a", "d"} {
+		require.NoError(t, op.Process(context.Background(), newTestEntry(record, nil)))
+		require.LessOrEqual(t, len(op.files), 2)
+	}
+	require.NoError(t, op.Stop())
+
+	require.Equal(t, "a\na\n", readFile(t, filepath.Join(tempDir, "a.log")))
+	require.Equal(t, "d\n", readFile(t, filepath.Join(tempDir, "d.log")))
+}
+
+func TestFileOutputRotateSize(t *testing.T) {
+	cfg := NewFileOutputConfig("test")
+	cfg.Path = filepath.Join(testutil.NewTempDir(t), "out.log")
+	cfg.Format = "{{.Record}}\n"
+	cfg.MaxSize = 10
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	require.NoError(t, op.Start())
+
+	// Each entry is 6 bytes, so every entry after the first forces a rotation
+	for _, record := range []string{"test1", "test2", "test3"} {
+		require.NoError(t, op.Process(context.Background(), newTestEntry(record, nil)))
+	}
+	require.NoError(t, op.Stop())
+
+	require.Equal(t, "test3\n", readFile(t, op.path))
+
+	backups, err := listBackups(op.path)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	require.Equal(t, "test2\n", readFile(t, backups[0].path))
+	require.Equal(t, "test1\n", readFile(t, backups[1].path))
+}
+
+func TestFileOutputRotateReopenFailure(t *testing.T) {
+	dir := filepath.Join(testutil.NewTempDir(t), "logs")
+
+	cfg := NewFileOutputConfig("test")
+	cfg.Format = "{{.Record}}\n"
+	cfg.Path = filepath.Join(dir, "out.log")
+	cfg.MaxSize = 1
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	// Removing the directory makes the rotation fail to reopen the file
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test1", nil)))
+	require.NoError(t, os.RemoveAll(dir))
+	require.Error(t, op.Process(context.Background(), newTestEntry("test2", nil)))
+
+	// The file is reopened by the next write
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test3", nil)))
+	require.Equal(t, "test3\n", readFile(t, op.path))
+}
+
+func TestFileOutputRotateInterval(t *testing.T) {
+	cfg := NewFileOutputConfig("test")
+	cfg.Path = filepath.Join(testutil.NewTempDir(t), "out.log")
+	cfg.Format = "{{.Record}}\n"
+	cfg.RotateInterval = helper.NewDuration(time.Hour)
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	now := time.Date(2021, 7, 20, 10, 15, 0, 0, time.UTC)
+	op.now = func() time.Time { return now }
+	require.NoError(t, op.Start())
+
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test1", nil)))
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test2", nil)))
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test3", nil)))
+	require.NoError(t, op.Stop())
+
+	require.Equal(t, "test3\n", readFile(t, op.path))
+
+	backups, err := listBackups(op.path)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.Equal(t, "test1\ntest2\n", readFile(t, backups[0].path))
+}
+
+func TestFileOutputRotateCompress(t *testing.T) {
+	cfg := NewFileOutputConfig("test")
+	cfg.Path = filepath.Join(testutil.NewTempDir(t), "out.log")
+	cfg.Format = "{{.Record}}\n"
+	cfg.MaxSize = 1
+	cfg.Compress = true
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*FileOutput)
+
+	require.NoError(t, op.Start())
+
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test1", nil)))
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test2", nil)))
+	require.NoError(t, op.Stop())
+
+	backups, err := listBackups(op.path)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.True(t, strings.HasSuffix(backups[0].path, compressSuffix))
+
+	f, err := os.Open(backups[0].path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, "test1\n", string(contents))
+}
+
+func TestFileOutputRetention(t *testing.T) {
+	t.Run("MaxBackups", func(t *testing.T) {
+		cfg := NewFileOutputConfig("test")
+		cfg.Path = filepath.Join(testutil.NewTempDir(t), "out.log")
+		cfg.Format = "{{.Record}}\n"
+		cfg.MaxSize = 1
+		cfg.MaxBackups = 2
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*FileOutput)
+
+		require.NoError(t, op.Start())
+
+		for _, record := range []string{"test1", "test2", "test3", "test4", "test5"} {
+			require.NoError(t, op.Process(context.Background(), newTestEntry(record, nil)))
+		}
+		require.NoError(t, op.Stop())
+
+		backups, err := listBackups(op.path)
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		require.Equal(t, "test4\n", readFile(t, backups[0].path))
+		require.Equal(t, "test3\n", readFile(t, backups[1].path))
+	})
+
+	t.Run("MaxBackupAge", func(t *testing.T) {
+		cfg := NewFileOutputConfig("test")
+		cfg.Path = filepath.Join(testutil.NewTempDir(t), "out.log")
+		cfg.Format = "{{.Record}}\n"
+		cfg.MaxSize = 1
+		cfg.MaxBackupAge = helper.NewDuration(time.Hour)
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*FileOutput)
+
+		now := time.Date(2021, 7, 20, 10, 0, 0, 0, time.UTC)
+		op.now = func() time.Time { return now }
+		require.NoError(t, op.Start())
+
+		for _, record := range []string{"test1", "test2", "test3", "test4"} {
+			require.NoError(t, op.Process(context.Background(), newTestEntry(record, nil)))
+			now = now.Add(45 * time.Minute)
+		}
+		require.NoError(t, op.Stop())
+
+		backups, err := listBackups(op.path)
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		require.Equal(t, "test3\n", readFile(t, backups[0].path))
+		require.Equal(t, "test2\n", readFile(t, backups[1].path))
+	})
+}
+
+func TestListBackupsIgnoresUnrelatedFiles(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+	path := filepath.Join(tempDir, "out.log")
+
+	now := time.Date(2021, 7, 20, 10, 0, 0, 0, time.UTC)
+	for _, name := range []string{
+		"out.log",
+		"out-other.log",
+		"other-2021-07-20T10-00-00.000000000.log",
+		filepath.Base(backupName(path, now)),
+		filepath.Base(backupName(path, now.Add(time.Second))) + compressSuffix,
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, name), nil, 0600))
+	}
+
+	backups, err := listBackups(path)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	require.Equal(t, now.Add(time.Second), backups[0].timestamp)
+	require.Equal(t, now, backups[1].timestamp)
+}
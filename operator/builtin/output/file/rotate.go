@@ -0,0 +1,269 @@
+package file
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp format appended to rotated file names.
+// It is sortable and contains no characters that are invalid in file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+const compressSuffix = ".gz"
+
+// rotationConfig holds the rules for rotating and retaining output files
+type rotationConfig struct {
+	maxSize        int64
+	rotateInterval time.Duration
+	compress       bool
+	maxBackups     int
+	maxBackupAge   time.Duration
+}
+
+// shouldRotate returns true if writing n more bytes to the file at the
+// given time would break either the size or the interval limits. An empty
+// file is never rotated.
+func (c rotationConfig) shouldRotate(mf *managedFile, n int64, now time.Time) bool {
+	if mf.size == 0 {
+		return false
+	}
+
+	if c.maxSize > 0 && mf.size+n > c.maxSize {
+		return true
+	}
+
+	// Interval rotation is aligned to the interval so that every file
+	// contains entries from exactly one window, even across restarts
+	if c.rotateInterval > 0 && now.Truncate(c.rotateInterval).After(mf.lastWrite.Truncate(c.rotateInterval)) {
+		return true
+	}
+
+	return false
+}
+
+// removeExpiredBackups deletes the rotated backups of path that exceed
+// the configured count or age
+func (c rotationConfig) removeExpiredBackups(path string, now time.Time) error {
+	if c.maxBackups == 0 && c.maxBackupAge == 0 {
+		return nil
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		return err
+	}
+
+	var remove []backupFile
+	for i, backup := range backups {
+		switch {
+		case c.maxBackups > 0 && i >= c.maxBackups:
+			remove = append(remove, backup)
+		case c.maxBackupAge > 0 && now.Sub(backup.timestamp) > c.maxBackupAge:
+			remove = append(remove, backup)
+		}
+	}
+
+	var firstErr error
+	for _, backup := range remove {
+		if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// managedFile is an output file along with the state needed to rotate it
+type managedFile struct {
+	path      string
+	file      *os.File
+	size      int64
+	lastWrite time.Time
+}
+
+// openManagedFile opens a file for appending, creating it and any missing
+// parent directories if necessary
+func openManagedFile(path string, now time.Time) (*managedFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("create directory: %s", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600) // #nosec - operator must write to the path defined by the user
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("stat: %s", err)
+	}
+
+	lastWrite := now
+	if info.Size() > 0 {
+		lastWrite = info.ModTime()
+	}
+
+	return &managedFile{
+		path:      path,
+		file:      file,
+		size:      info.Size(),
+		lastWrite: lastWrite,
+	}, nil
+}
+
+// write appends b to the file
+func (mf *managedFile) write(b []byte, now time.Time) error {
+	n, err := mf.file.Write(b)
+	mf.size += int64(n)
+	mf.lastWrite = now
+	return err
+}
+
+// close closes the underlying file
+func (mf *managedFile) close() error {
+	return mf.file.Close()
+}
+
+// rotate closes the file, renames it with a timestamp suffix, and opens a
+// new empty file in its place. It returns the path of the rotated file.
+func (mf *managedFile) rotate(now time.Time) (string, error) {
+	if err := mf.file.Close(); err != nil {
+		return "", fmt.Errorf("close: %s", err)
+	}
+
+	rotatedPath := backupName(mf.path, now)
+	renameErr := os.Rename(mf.path, rotatedPath)
+
+	// Reopen the path even if the rename failed so that writes can continue
+	file, err := os.OpenFile(mf.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600) // #nosec - operator must write to the path defined by the user
+	if err != nil {
+		return "", err
+	}
+	mf.file = file
+
+	if renameErr != nil {
+		return "", fmt.Errorf("rename: %s", renameErr)
+	}
+
+	mf.size = 0
+	mf.lastWrite = now
+	return rotatedPath, nil
+}
+
+// backupName returns the rotated name for path, which places the timestamp
+// between the base name and the extension. For example, /var/log/out.json
+// becomes /var/log/out-2021-07-20T15-04-05.000000000.json
+func backupName(path string, t time.Time) string {
+	dir, prefix, ext := splitBackupPath(path)
+	return filepath.Join(dir, prefix+t.UTC().Format(backupTimeFormat)+ext)
+}
+
+// splitBackupPath returns the directory, the prefix of backup names
+// and the extension of path
+func splitBackupPath(path string) (dir, prefix, ext string) {
+	dir = filepath.Dir(path)
+	base := filepath.Base(path)
+	ext = filepath.Ext(base)
+	prefix = strings.TrimSuffix(base, ext) + "-"
+	return dir, prefix, ext
+}
+
+// backupFile is a rotated file found on disk
+type backupFile struct {
+	path      string
+	timestamp time.Time
+}
+
+// listBackups returns the rotated backups of path, newest first
+func listBackups(path string) ([]backupFile, error) {
+	dir, prefix, ext := splitBackupPath(path)
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]backupFile, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		name := info.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, compressSuffix)
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ext)
+
+		t, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			// Not one of our backups
+			continue
+		}
+
+		backups = append(backups, backupFile{
+			path:      filepath.Join(dir, name),
+			timestamp: t,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	return backups, nil
+}
+
+// compressFile gzips the file at path to path.gz and removes the original
+func compressFile(path string) (err error) {
+	src, err := os.Open(path) // #nosec - path is a file rotated by this operator
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	dstPath := path + compressSuffix
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(dstPath)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	if err = gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	if err = dst.Close(); err != nil {
+		return err
+	}
+
+	// Close the source before removing it so this works on windows
+	if err = src.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,433 @@
+package fluentforward
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/errors"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/buffer"
+	"github.com/observiq/stanza/operator/flusher"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTag     = "stanza"
+	defaultTimeout = 30 * time.Second
+)
+
+func init() {
+	operator.Register("fluent_forward_output", func() operator.Builder { return NewFluentForwardOutputConfig("") })
+}
+
+// NewFluentForwardOutputConfig creates a new fluent forward output config with default values
+func NewFluentForwardOutputConfig(operatorID string) *FluentForwardOutputConfig {
+	return &FluentForwardOutputConfig{
+		OutputConfig:  helper.NewOutputConfig(operatorID, "fluent_forward_output"),
+		BufferConfig:  buffer.NewConfig(),
+		FlusherConfig: flusher.NewConfig(),
+		Tag:           defaultTag,
+		Mode:          modeForward,
+		Timeout:       helper.NewDuration(defaultTimeout),
+	}
+}
+
+// FluentForwardOutputConfig is the configuration of a fluent forward output operator
+type FluentForwardOutputConfig struct {
+	helper.OutputConfig `yaml:",inline"`
+	BufferConfig        buffer.Config  `json:"buffer"  yaml:"buffer"`
+	FlusherConfig       flusher.Config `json:"flusher" yaml:"flusher"`
+
+	Address      string          `json:"address"                 yaml:"address"`
+	TLS          *TLSConfig      `json:"tls,omitempty"           yaml:"tls,omitempty"`
+	SharedKey    string          `json:"shared_key,omitempty"    yaml:"shared_key,omitempty"`
+	SelfHostname string          `json:"self_hostname,omitempty" yaml:"self_hostname,omitempty"`
+	Tag          string          `json:"tag,omitempty"           yaml:"tag,omitempty"`
+	TagField     *entry.Field    `json:"tag_field,omitempty"     yaml:"tag_field,omitempty"`
+	Mode         string          `json:"mode,omitempty"          yaml:"mode,omitempty"`
+	RequireAck   bool            `json:"require_ack,omitempty"   yaml:"require_ack,omitempty"`
+	Timeout      helper.Duration `json:"timeout,omitempty"       yaml:"timeout,omitempty"`
+}
+
+// TLSConfig is the client TLS configuration of a fluent forward output
+type TLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"              yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"            yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"             yaml:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty"          yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// build creates the tls.Config for the client
+func (c TLSConfig) build() (*tls.Config, error) {
+	// #nosec - InsecureSkipVerify is only set when explicitly configured
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read ca_file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca_file '%s'", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("'cert_file' and 'key_file' must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Build will build a fluent forward output operator
+func (c FluentForwardOutputConfig) Build(bc operator.BuildContext) ([]operator.Operator, error) {
+	outputOperator, err := c.OutputConfig.Build(bc)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Address == "" {
+		return nil, errors.NewError("missing required parameter 'address'", "")
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return nil, fmt.Errorf("'address' must be in the form host:port: %s", err)
+	}
+
+	switch c.Mode {
+	case modeMessage, modeForward, modePackedForward, modeCompressedPackedForward:
+	case "":
+		c.Mode = modeForward
+	default:
+		return nil, fmt.Errorf("invalid mode '%s', must be one of '%s', '%s', '%s' or '%s'",
+			c.Mode, modeMessage, modeForward, modePackedForward, modeCompressedPackedForward)
+	}
+
+	if c.Tag == "" {
+		c.Tag = defaultTag
+	}
+
+	if c.SelfHostname == "" {
+		if c.SelfHostname, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to determine self_hostname: %s", err)
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if c.TLS != nil {
+		if tlsConfig, err = c.TLS.build(); err != nil {
+			return nil, err
+		}
+	}
+
+	buffer, err := c.BufferConfig.Build(bc, c.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	flusher := c.FlusherConfig.Build(bc.Logger.SugaredLogger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fluentOutput := &FluentForwardOutput{
+		OutputOperator: outputOperator,
+		buffer:         buffer,
+		flusher:        flusher,
+		address:        c.Address,
+		tlsConfig:      tlsConfig,
+		sharedKey:      c.SharedKey,
+		selfHostname:   c.SelfHostname,
+		tag:            c.Tag,
+		tagField:       c.TagField,
+		mode:           c.Mode,
+		requireAck:     c.RequireAck,
+		timeout:        c.Timeout.Raw(),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+
+	return []operator.Operator{fluentOutput}, nil
+}
+
+// FluentForwardOutput is an operator that sends entries with the fluentd forward protocol
+type FluentForwardOutput struct {
+	helper.OutputOperator
+	buffer  buffer.Buffer
+	flusher *flusher.Flusher
+
+	address      string
+	tlsConfig    *tls.Config
+	sharedKey    string
+	selfHostname string
+	tag          string
+	tagField     *entry.Field
+	mode         string
+	requireAck   bool
+	timeout      time.Duration
+
+	// connMux serializes writes, since each message must be
+	// acknowledged before the next is sent on the connection
+	connMux sync.Mutex
+	conn    net.Conn
+	dec     *msgpack.Decoder
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start signals to the FluentForwardOutput to begin flushing
+func (f *FluentForwardOutput) Start() error {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.feedFlusher(f.ctx)
+	}()
+
+	return nil
+}
+
+// Stop tells the FluentForwardOutput to stop gracefully
+func (f *FluentForwardOutput) Stop() error {
+	f.cancel()
+	f.wg.Wait()
+	f.flusher.Stop()
+
+	f.connMux.Lock()
+	f.closeConn()
+	f.connMux.Unlock()
+
+	return f.buffer.Close()
+}
+
+// Process adds an entry to the outputs buffer
+func (f *FluentForwardOutput) Process(ctx context.Context, entry *entry.Entry) error {
+	return f.buffer.Add(ctx, entry)
+}
+
+func (f *FluentForwardOutput) feedFlusher(ctx context.Context) {
+	for {
+		entries, clearer, err := f.buffer.ReadChunk(ctx)
+		if err != nil && err == context.Canceled {
+			return
+		} else if err != nil {
+			f.Errorf("Failed to read chunk", zap.Error(err))
+			continue
+		}
+
+		messages, chunks, err := f.encodeEntries(entries)
+		if err != nil {
+			f.Errorw("Failed to encode entries", zap.Error(err))
+			// drop these logs because we couldn't encode them and a retry won't help
+			if err := clearer.MarkAllAsFlushed(); err != nil {
+				f.Errorf("Failed to mark entries as flushed after failing to encode them", zap.Error(err))
+			}
+			continue
+		}
+
+		// Messages that were sent successfully are not resent on retry
+		sent := 0
+		f.flusher.Do(func(ctx context.Context) error {
+			for sent < len(messages) {
+				if err := f.send(ctx, messages[sent], chunks[sent]); err != nil {
+					return err
+				}
+				sent++
+			}
+
+			if err := clearer.MarkAllAsFlushed(); err != nil {
+				f.Errorw("Failed to mark entries as flushed", zap.Error(err))
+			}
+			return nil
+		})
+	}
+}
+
+// encodeEntries groups entries by tag and encodes them as forward messages
+func (f *FluentForwardOutput) encodeEntries(entries []*entry.Entry) ([][]byte, []string, error) {
+	var tags []string
+	events := map[string][]event{}
+	for _, e := range entries {
+		tag := f.findTag(e)
+		if _, ok := events[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		events[tag] = append(events[tag], event{time: e.Timestamp, record: toRecord(e.Record)})
+	}
+
+	var messages [][]byte
+	var chunks []string
+	for _, tag := range tags {
+		m, c, err := encodeMessages(f.mode, tag, events[tag], f.requireAck)
+		if err != nil {
+			return nil, nil, err
+		}
+		messages = append(messages, m...)
+		chunks = append(chunks, c...)
+	}
+	return messages, chunks, nil
+}
+
+// findTag returns the fluent tag of an entry
+func (f *FluentForwardOutput) findTag(e *entry.Entry) string {
+	if f.tagField == nil {
+		return f.tag
+	}
+
+	var tag string
+	if err := e.Read(*f.tagField, &tag); err != nil || tag == "" {
+		return f.tag
+	}
+	return tag
+}
+
+// toRecord converts an entry's record to a map, since fluent records are always maps
+func toRecord(record interface{}) interface{} {
+	switch r := record.(type) {
+	case map[string]interface{}, map[string]string:
+		return r
+	default:
+		return map[string]interface{}{"message": r}
+	}
+}
+
+// send writes a message and waits for its acknowledgement, if required.
+// If anything fails, the connection is closed so that it is reestablished
+// on the next attempt.
+func (f *FluentForwardOutput) send(ctx context.Context, message []byte, chunk string) error {
+	f.connMux.Lock()
+	defer f.connMux.Unlock()
+
+	if f.conn == nil {
+		if err := f.connect(ctx); err != nil {
+			return errors.Wrap(err, "connect")
+		}
+	}
+
+	deadline := time.Now().Add(f.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := f.conn.SetDeadline(deadline); err != nil {
+		f.closeConn()
+		return err
+	}
+
+	if _, err := f.conn.Write(message); err != nil {
+		f.closeConn()
+		return errors.Wrap(err, "write message")
+	}
+
+	if chunk != "" {
+		if err := readAck(f.dec, chunk); err != nil {
+			f.closeConn()
+			return errors.Wrap(err, "read ack")
+		}
+	}
+	return nil
+}
+
+// connect dials the server and completes the handshake if a shared key is configured
+func (f *FluentForwardOutput) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: f.timeout}
+
+	var conn net.Conn
+	var err error
+	if f.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", f.address, f.tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", f.address)
+	}
+	if err != nil {
+		return err
+	}
+
+	f.conn = conn
+	f.dec = msgpack.NewDecoder(bufio.NewReader(conn))
+
+	if f.sharedKey != "" {
+		if err := f.handshake(); err != nil {
+			f.closeConn()
+			return errors.Wrap(err, "handshake")
+		}
+	}
+	return nil
+}
+
+// handshake authenticates with the shared key and verifies that the server knows it too
+func (f *FluentForwardOutput) handshake() error {
+	if err := f.conn.SetDeadline(time.Now().Add(f.timeout)); err != nil {
+		return err
+	}
+
+	h, err := readHelo(f.dec)
+	if err != nil {
+		return err
+	}
+	if len(h.auth) > 0 {
+		return fmt.Errorf("server requires user authentication, which is not supported")
+	}
+
+	saltBytes := make([]byte, 16)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return err
+	}
+	salt := hex.EncodeToString(saltBytes)
+
+	digest := sharedKeyDigest(salt, f.selfHostname, h.nonce, f.sharedKey)
+	if err := writePing(msgpack.NewEncoder(f.conn), f.selfHostname, salt, digest); err != nil {
+		return err
+	}
+
+	p, err := readPong(f.dec)
+	if err != nil {
+		return err
+	}
+	if !p.authenticated {
+		return fmt.Errorf("authentication failed: %s", p.reason)
+	}
+
+	expected := sharedKeyDigest(salt, p.hostname, h.nonce, f.sharedKey)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(p.digest)) != 1 {
+		return fmt.Errorf("server '%s' failed to prove it knows the shared key", p.hostname)
+	}
+	return nil
+}
+
+func (f *FluentForwardOutput) closeConn() {
+	if f.conn == nil {
+		return
+	}
+	if err := f.conn.Close(); err != nil {
+		f.Debugw("Failed to close connection", zap.Error(err))
+	}
+	f.conn = nil
+	f.dec = nil
+}
@@ -0,0 +1,307 @@
+package fluentforward
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/buffer"
+	fluentinput "github.com/observiq/stanza/operator/builtin/input/fluentforward"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestReceiver starts a fluent_forward_input to receive entries from the output
+func newTestReceiver(t *testing.T, cfg *fluentinput.FluentForwardInputConfig) (string, *testutil.FakeOutput) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	cfg.ListenAddress = addr
+	cfg.OutputIDs = []string{"fake"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	input := ops[0]
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, input.SetOutputs([]operator.Operator{fake}))
+	require.NoError(t, input.Start())
+	t.Cleanup(func() { input.Stop() })
+	return addr, fake
+}
+
+func newTestEntry(record interface{}) *entry.Entry {
+	e := entry.New()
+	e.Timestamp = time.Date(2021, 7, 20, 10, 0, 0, 123456789, time.UTC)
+	e.Record = record
+	return e
+}
+
+func expectEntry(t *testing.T, fake *testutil.FakeOutput, tag string, record interface{}) {
+	select {
+	case e := <-fake.Received:
+		require.Equal(t, tag, e.Labels["fluent_tag"])
+		require.True(t, newTestEntry(nil).Timestamp.Equal(e.Timestamp))
+		require.Equal(t, record, e.Record)
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+	}
+}
+
+func TestFluentForwardOutputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*FluentForwardOutputConfig)
+		expectErr bool
+	}{
+		{"Default", func(cfg *FluentForwardOutputConfig) {}, false},
+		{"MissingAddress", func(cfg *FluentForwardOutputConfig) { cfg.Address = "" }, true},
+		{"InvalidAddress", func(cfg *FluentForwardOutputConfig) { cfg.Address = "localhost" }, true},
+		{"InvalidMode", func(cfg *FluentForwardOutputConfig) { cfg.Mode = "invalid" }, true},
+		{"MissingCAFile", func(cfg *FluentForwardOutputConfig) { cfg.TLS = &TLSConfig{CAFile: "/does/not/exist"} }, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFluentForwardOutputConfig("test")
+			cfg.Address = "localhost:24224"
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFluentForwardOutputModes(t *testing.T) {
+	modes := []string{modeMessage, modeForward, modePackedForward, modeCompressedPackedForward}
+	for _, mode := range modes {
+		for _, requireAck := range []bool{false, true} {
+			mode, requireAck := mode, requireAck
+			name := mode
+			if requireAck {
+				name += "_ack"
+			}
+			t.Run(name, func(t *testing.T) {
+				addr, fake := newTestReceiver(t, fluentinput.NewFluentForwardInputConfig("receiver"))
+
+				cfg := NewFluentForwardOutputConfig("test")
+				memoryCfg := buffer.NewMemoryBufferConfig()
+				memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+				cfg.BufferConfig = buffer.Config{
+					Builder: memoryCfg,
+				}
+				cfg.Address = addr
+				cfg.Timeout = helper.NewDuration(time.Second)
+				cfg.Mode = mode
+				cfg.RequireAck = requireAck
+
+				ops, err := cfg.Build(testutil.NewBuildContext(t))
+				require.NoError(t, err)
+				fluentOutput := ops[0].(*FluentForwardOutput)
+				require.NoError(t, fluentOutput.Start())
+				defer fluentOutput.Stop()
+
+				require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test1"})))
+				require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry("test2")))
+
+				expectEntry(t, fake, defaultTag, map[string]interface{}{"log": "test1"})
+				expectEntry(t, fake, defaultTag, map[string]interface{}{"message": "test2"})
+			})
+		}
+	}
+}
+
+func TestFluentForwardOutputTagField(t *testing.T) {
+	addr, fake := newTestReceiver(t, fluentinput.NewFluentForwardInputConfig("receiver"))
+	tagField := entry.NewLabelField("tag")
+
+	cfg := NewFluentForwardOutputConfig("test")
+	memoryCfg := buffer.NewMemoryBufferConfig()
+	memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.BufferConfig = buffer.Config{
+		Builder: memoryCfg,
+	}
+	cfg.Address = addr
+	cfg.Timeout = helper.NewDuration(time.Second)
+	cfg.TagField = &tagField
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	fluentOutput := ops[0].(*FluentForwardOutput)
+	require.NoError(t, fluentOutput.Start())
+	defer fluentOutput.Stop()
+
+	e := newTestEntry(map[string]interface{}{"log": "test"})
+	e.AddLabel("tag", "app.test")
+	require.NoError(t, fluentOutput.Process(context.Background(), e))
+	expectEntry(t, fake, "app.test", map[string]interface{}{"log": "test"})
+
+	// Entries without the field use the default tag
+	require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test"})))
+	expectEntry(t, fake, defaultTag, map[string]interface{}{"log": "test"})
+}
+
+func TestFluentForwardOutputSharedKey(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		receiverCfg := fluentinput.NewFluentForwardInputConfig("receiver")
+		receiverCfg.SharedKey = "secret"
+		addr, fake := newTestReceiver(t, receiverCfg)
+
+		cfg := NewFluentForwardOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.Address = addr
+		cfg.Timeout = helper.NewDuration(time.Second)
+		cfg.SharedKey = "secret"
+		cfg.RequireAck = true
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		fluentOutput := ops[0].(*FluentForwardOutput)
+		require.NoError(t, fluentOutput.Start())
+		defer fluentOutput.Stop()
+
+		require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test"})))
+		expectEntry(t, fake, defaultTag, map[string]interface{}{"log": "test"})
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		receiverCfg := fluentinput.NewFluentForwardInputConfig("receiver")
+		receiverCfg.SharedKey = "secret"
+		addr, fake := newTestReceiver(t, receiverCfg)
+
+		cfg := NewFluentForwardOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.Address = addr
+		cfg.Timeout = helper.NewDuration(time.Second)
+		cfg.SharedKey = "wrong"
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		fluentOutput := ops[0].(*FluentForwardOutput)
+		require.NoError(t, fluentOutput.Start())
+		defer fluentOutput.Stop()
+
+		require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test"})))
+		fake.ExpectNoEntry(t, 300*time.Millisecond)
+	})
+}
+
+func TestFluentForwardOutputTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, testutil.NewTempDir(t))
+
+	receiverCfg := fluentinput.NewFluentForwardInputConfig("receiver")
+	receiverCfg.TLS = helper.TLSServerConfig{
+		Enable:      true,
+		Certificate: certFile,
+		PrivateKey:  keyFile,
+	}
+	addr, fake := newTestReceiver(t, receiverCfg)
+
+	cfg := NewFluentForwardOutputConfig("test")
+	memoryCfg := buffer.NewMemoryBufferConfig()
+	memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.BufferConfig = buffer.Config{
+		Builder: memoryCfg,
+	}
+	cfg.Address = addr
+	cfg.Timeout = helper.NewDuration(time.Second)
+	cfg.TLS = &TLSConfig{CAFile: certFile}
+	cfg.RequireAck = true
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	fluentOutput := ops[0].(*FluentForwardOutput)
+	require.NoError(t, fluentOutput.Start())
+	defer fluentOutput.Stop()
+
+	require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test"})))
+	expectEntry(t, fake, defaultTag, map[string]interface{}{"log": "test"})
+}
+
+func TestFluentForwardOutputReconnect(t *testing.T) {
+	addr, fake := newTestReceiver(t, fluentinput.NewFluentForwardInputConfig("receiver"))
+
+	cfg := NewFluentForwardOutputConfig("test")
+	memoryCfg := buffer.NewMemoryBufferConfig()
+	memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.BufferConfig = buffer.Config{
+		Builder: memoryCfg,
+	}
+	cfg.Address = addr
+	cfg.Timeout = helper.NewDuration(time.Second)
+	cfg.RequireAck = true
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	fluentOutput := ops[0].(*FluentForwardOutput)
+	require.NoError(t, fluentOutput.Start())
+	defer fluentOutput.Stop()
+
+	require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test1"})))
+	expectEntry(t, fake, defaultTag, map[string]interface{}{"log": "test1"})
+
+	// Break the connection, which should be reestablished on retry
+	fluentOutput.connMux.Lock()
+	require.NoError(t, fluentOutput.conn.Close())
+	fluentOutput.connMux.Unlock()
+
+	require.NoError(t, fluentOutput.Process(context.Background(), newTestEntry(map[string]interface{}{"log": "test2"})))
+	expectEntry(t, fake, defaultTag, map[string]interface{}{"log": "test2"})
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stanza-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,274 @@
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The Forward protocol is described in the fluentd wiki
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+
+const (
+	modeMessage                 = "message"
+	modeForward                 = "forward"
+	modePackedForward           = "packed_forward"
+	modeCompressedPackedForward = "compressed_packed_forward"
+
+	// eventTimeExtID is the msgpack extension type used for EventTime
+	eventTimeExtID = 0
+	eventTimeLen   = 8
+)
+
+// event is a single log event of a forward message
+type event struct {
+	time   time.Time
+	record interface{}
+}
+
+// encodeEventTime encodes a timestamp as an EventTime, which has nanosecond precision
+func encodeEventTime(enc *msgpack.Encoder, t time.Time) error {
+	if err := enc.EncodeExtHeader(eventTimeExtID, eventTimeLen); err != nil {
+		return err
+	}
+	var b [eventTimeLen]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(t.Nanosecond()))
+	_, err := enc.Writer().Write(b[:])
+	return err
+}
+
+// encodeEvent encodes an event as a [time, record] entry
+func encodeEvent(enc *msgpack.Encoder, e event) error {
+	if err := enc.EncodeArrayLen(2); err != nil {
+		return err
+	}
+	if err := encodeEventTime(enc, e.time); err != nil {
+		return err
+	}
+	return enc.Encode(e.record)
+}
+
+// encodeMessages encodes the events of a tag as one or more forward messages
+// in the given mode. If requireAck is set, each message includes a unique
+// chunk id in its options, which is returned for reading the acknowledgement.
+func encodeMessages(mode, tag string, events []event, requireAck bool) ([][]byte, []string, error) {
+	if mode == modeMessage {
+		messages := make([][]byte, 0, len(events))
+		chunks := make([]string, 0, len(events))
+		for _, e := range events {
+			msg, chunk, err := encodeMessage(mode, tag, []event{e}, requireAck)
+			if err != nil {
+				return nil, nil, err
+			}
+			messages = append(messages, msg)
+			chunks = append(chunks, chunk)
+		}
+		return messages, chunks, nil
+	}
+
+	msg, chunk, err := encodeMessage(mode, tag, events, requireAck)
+	if err != nil {
+		return nil, nil, err
+	}
+	return [][]byte{msg}, []string{chunk}, nil
+}
+
+func encodeMessage(mode, tag string, events []event, requireAck bool) ([]byte, string, error) {
+	options := map[string]interface{}{}
+	var chunk string
+	if requireAck {
+		var err error
+		if chunk, err = newChunkID(); err != nil {
+			return nil, "", err
+		}
+		options["chunk"] = chunk
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+
+	length := 3
+	if mode == modeMessage {
+		length = 4
+	}
+	if err := enc.EncodeArrayLen(length); err != nil {
+		return nil, "", err
+	}
+	if err := enc.EncodeString(tag); err != nil {
+		return nil, "", err
+	}
+
+	switch mode {
+	case modeMessage:
+		e := events[0]
+		if err := encodeEventTime(enc, e.time); err != nil {
+			return nil, "", err
+		}
+		if err := enc.Encode(e.record); err != nil {
+			return nil, "", err
+		}
+	case modeForward:
+		if err := enc.EncodeArrayLen(len(events)); err != nil {
+			return nil, "", err
+		}
+		for _, e := range events {
+			if err := encodeEvent(enc, e); err != nil {
+				return nil, "", err
+			}
+		}
+	case modePackedForward, modeCompressedPackedForward:
+		packed, err := packEvents(events, mode == modeCompressedPackedForward)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := enc.EncodeBytes(packed); err != nil {
+			return nil, "", err
+		}
+		options["size"] = len(events)
+		if mode == modeCompressedPackedForward {
+			options["compressed"] = "gzip"
+		}
+	default:
+		return nil, "", fmt.Errorf("unknown mode '%s'", mode)
+	}
+
+	if err := enc.Encode(options); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), chunk, nil
+}
+
+// packEvents encodes events as a msgpack stream of [time, record] entries
+func packEvents(events []event, compress bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, e := range events {
+		if err := encodeEvent(enc, e); err != nil {
+			return nil, err
+		}
+	}
+
+	if !compress {
+		return buf.Bytes(), nil
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func newChunkID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// readAck reads the acknowledgement of a chunk
+func readAck(dec *msgpack.Decoder, chunk string) error {
+	response, err := dec.DecodeMap()
+	if err != nil {
+		return err
+	}
+	if ack := stringValue(response["ack"]); ack != chunk {
+		return fmt.Errorf("expected ack for chunk '%s', got '%s'", chunk, ack)
+	}
+	return nil
+}
+
+// helo is the server's request to authenticate
+type helo struct {
+	nonce []byte
+	auth  []byte
+}
+
+func readHelo(dec *msgpack.Decoder) (*helo, error) {
+	var fields []interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 2 || stringValue(fields[0]) != "HELO" {
+		return nil, fmt.Errorf("expected HELO")
+	}
+
+	options, ok := fields[1].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid HELO options of type %T", fields[1])
+	}
+
+	return &helo{
+		nonce: []byte(stringValue(options["nonce"])),
+		auth:  []byte(stringValue(options["auth"])),
+	}, nil
+}
+
+// writePing responds to HELO with the shared key digest. User
+// authentication is not supported, so username and password are empty.
+func writePing(enc *msgpack.Encoder, hostname, salt, digest string) error {
+	return enc.Encode([]interface{}{"PING", hostname, salt, digest, "", ""})
+}
+
+// pong is the server's authentication result
+type pong struct {
+	authenticated bool
+	reason        string
+	hostname      string
+	digest        string
+}
+
+func readPong(dec *msgpack.Decoder) (*pong, error) {
+	var fields []interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 5 || stringValue(fields[0]) != "PONG" {
+		return nil, fmt.Errorf("expected PONG")
+	}
+
+	authenticated, _ := fields[1].(bool)
+	return &pong{
+		authenticated: authenticated,
+		reason:        stringValue(fields[2]),
+		hostname:      stringValue(fields[3]),
+		digest:        stringValue(fields[4]),
+	}, nil
+}
+
+// sharedKeyDigest is the digest used by both sides of the handshake to prove
+// that they know the shared key
+func sharedKeyDigest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// stringValue returns a msgpack str or bin value as a string
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
@@ -3,7 +3,10 @@ package forward
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"sync"
@@ -27,6 +30,9 @@ func NewForwardOutputConfig(operatorID string) *ForwardOutputConfig {
 		OutputConfig:  helper.NewOutputConfig(operatorID, "forward_output"),
 		BufferConfig:  buffer.NewConfig(),
 		FlusherConfig: flusher.NewConfig(),
+		LoadBalancing: loadBalancingRoundRobin,
+		Compression:   compressionNone,
+		Timeout:       helper.NewDuration(defaultTimeout),
 	}
 }
 
@@ -35,7 +41,59 @@ type ForwardOutputConfig struct {
 	helper.OutputConfig `yaml:",inline"`
 	BufferConfig        buffer.Config  `json:"buffer"  yaml:"buffer"`
 	FlusherConfig       flusher.Config `json:"flusher" yaml:"flusher"`
-	Address             string         `json:"address" yaml:"address"`
+
+	Address       string          `json:"address,omitempty"        yaml:"address,omitempty"`
+	Addresses     []string        `json:"addresses,omitempty"      yaml:"addresses,omitempty,flow"`
+	LoadBalancing string          `json:"load_balancing,omitempty" yaml:"load_balancing,omitempty"`
+	Compression   string          `json:"compression,omitempty"    yaml:"compression,omitempty"`
+	SharedSecret  string          `json:"shared_secret,omitempty"  yaml:"shared_secret,omitempty"`
+	BearerToken   string          `json:"bearer_token,omitempty"   yaml:"bearer_token,omitempty"`
+	TLS           *TLSConfig      `json:"tls,omitempty"            yaml:"tls,omitempty"`
+	Timeout       helper.Duration `json:"timeout,omitempty"        yaml:"timeout,omitempty"`
+}
+
+// TLSConfig is the client TLS configuration of a forward output
+type TLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"              yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"            yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"             yaml:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty"          yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// build creates the tls.Config for the client
+func (c TLSConfig) build() (*tls.Config, error) {
+	// #nosec - InsecureSkipVerify is only set when explicitly configured
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read ca_file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca_file '%s'", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("'cert_file' and 'key_file' must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
 }
 
 // Build will build an forward output operator.
@@ -50,10 +108,40 @@ func (c ForwardOutputConfig) Build(bc operator.BuildContext) ([]operator.Operato
 		return nil, err
 	}
 
-	if c.Address == "" {
+	addresses := c.Addresses
+	if c.Address != "" {
+		addresses = append([]string{c.Address}, addresses...)
+	}
+	if len(addresses) == 0 {
 		return nil, errors.NewError("missing required parameter 'address'", "")
 	}
 
+	switch c.LoadBalancing {
+	case loadBalancingRoundRobin, loadBalancingFailover:
+	case "":
+		c.LoadBalancing = loadBalancingRoundRobin
+	default:
+		return nil, fmt.Errorf("invalid load_balancing '%s', must be one of '%s' or '%s'", c.LoadBalancing, loadBalancingRoundRobin, loadBalancingFailover)
+	}
+
+	compressor, err := newCompressor(c.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.SharedSecret != "" && c.BearerToken != "" {
+		return nil, fmt.Errorf("only one of 'shared_secret' or 'bearer_token' can be set")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.TLS != nil {
+		tlsConfig, err := c.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
 	flusher := c.FlusherConfig.Build(bc.Logger.SugaredLogger)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -64,8 +152,14 @@ func (c ForwardOutputConfig) Build(bc operator.BuildContext) ([]operator.Operato
 		flusher:        flusher,
 		ctx:            ctx,
 		cancel:         cancel,
-		client:         &http.Client{},
-		address:        c.Address,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   c.Timeout.Raw(),
+		},
+		balancer:     newBalancer(addresses, c.LoadBalancing),
+		compressor:   compressor,
+		sharedSecret: c.SharedSecret,
+		bearerToken:  c.BearerToken,
 	}
 
 	return []operator.Operator{forwardOutput}, nil
@@ -77,8 +171,11 @@ type ForwardOutput struct {
 	buffer  buffer.Buffer
 	flusher *flusher.Flusher
 
-	client  *http.Client
-	address string
+	client       *http.Client
+	balancer     *balancer
+	compressor   compressor
+	sharedSecret string
+	bearerToken  string
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -109,16 +206,68 @@ func (f *ForwardOutput) Process(ctx context.Context, entry *entry.Entry) error {
 	return f.buffer.Add(ctx, entry)
 }
 
-// ProcessMulti will send entries to elasticsearch.
-func (f *ForwardOutput) createRequest(ctx context.Context, entries []*entry.Entry) (*http.Request, error) {
+// encodeEntries will encode and compress a chunk of entries for the request body
+func (f *ForwardOutput) encodeEntries(entries []*entry.Entry) ([]byte, error) {
 	var b bytes.Buffer
-	enc := json.NewEncoder(&b)
-	err := enc.Encode(entries)
+	w := f.compressor.writer(&b)
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(entries); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// createRequest creates a request with the encoded entries for an address
+func (f *ForwardOutput) createRequest(ctx context.Context, address string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", address, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 
-	return http.NewRequestWithContext(ctx, "POST", f.address, &b)
+	req.Header.Set("Content-Type", "application/json")
+	if encoding := f.compressor.encoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	switch {
+	case f.sharedSecret != "":
+		req.Header.Set(sharedSecretHeader, f.sharedSecret)
+	case f.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+f.bearerToken)
+	}
+	return req, nil
+}
+
+// send sends the body to each address chosen by the balancer until one succeeds
+func (f *ForwardOutput) send(ctx context.Context, body []byte) error {
+	var lastErr error
+	for _, address := range f.balancer.order() {
+		req, err := f.createRequest(ctx, address, body)
+		if err != nil {
+			lastErr = errors.Wrap(err, "create request")
+			continue
+		}
+
+		res, err := f.client.Do(req)
+		if err != nil {
+			lastErr = errors.Wrap(err, "send request")
+		} else {
+			lastErr = f.handleResponse(res)
+		}
+
+		if lastErr == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return lastErr
+		}
+		f.Debugw("Failed to send entries", zap.Error(lastErr), "address", address)
+	}
+	return lastErr
 }
 
 func (f *ForwardOutput) feedFlusher(ctx context.Context) {
@@ -131,27 +280,22 @@ func (f *ForwardOutput) feedFlusher(ctx context.Context) {
 			continue
 		}
 
-		f.flusher.Do(func(ctx context.Context) error {
-			req, err := f.createRequest(ctx, entries)
-			if err != nil {
-				f.Errorf("Failed to create request", zap.Error(err))
-				// drop these logs because we couldn't creat a request and a retry won't help
-				if err := clearer.MarkAllAsFlushed(); err != nil {
-					f.Errorf("Failed to mark entries as flushed after failing to create a request", zap.Error(err))
-				}
-				return nil
-			}
-
-			res, err := f.client.Do(req)
-			if err != nil {
-				return errors.Wrap(err, "send request")
+		body, err := f.encodeEntries(entries)
+		if err != nil {
+			f.Errorf("Failed to create request", zap.Error(err))
+			// drop these logs because we couldn't creat a request and a retry won't help
+			if err := clearer.MarkAllAsFlushed(); err != nil {
+				f.Errorf("Failed to mark entries as flushed after failing to create a request", zap.Error(err))
 			}
+			continue
+		}
 
-			if err := f.handleResponse(res); err != nil {
+		f.flusher.Do(func(ctx context.Context) error {
+			if err := f.send(ctx, body); err != nil {
 				return err
 			}
 
-			if err = clearer.MarkAllAsFlushed(); err != nil {
+			if err := clearer.MarkAllAsFlushed(); err != nil {
 				f.Errorw("Failed to mark entries as flushed", zap.Error(err))
 			}
 			return nil
@@ -1,14 +1,19 @@
 package forward
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"encoding/pem"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/observiq/stanza/entry"
 	"github.com/observiq/stanza/operator/buffer"
 	"github.com/observiq/stanza/operator/helper"
@@ -58,3 +63,311 @@ func TestForwardOutput(t *testing.T) {
 		require.Equal(t, newEntry.Resource, e.Resource)
 	}
 }
+
+type receivedRequest struct {
+	header  http.Header
+	entries []*entry.Entry
+}
+
+func newReceiver(t *testing.T, status int, received chan receivedRequest) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body io.Reader = req.Body
+		switch req.Header.Get("Content-Encoding") {
+		case "gzip":
+			gz, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
+			body = gz
+		case "zstd":
+			dec, err := zstd.NewReader(req.Body)
+			require.NoError(t, err)
+			defer dec.Close()
+			body = dec
+		}
+
+		var entries []*entry.Entry
+		require.NoError(t, json.NewDecoder(body).Decode(&entries))
+		received <- receivedRequest{header: req.Header, entries: entries}
+		w.WriteHeader(status)
+	})
+}
+
+func expectRequest(t *testing.T, received chan receivedRequest) receivedRequest {
+	select {
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for server to receive entry")
+	case req := <-received:
+		return req
+	}
+	return receivedRequest{}
+}
+
+func TestForwardOutputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*ForwardOutputConfig)
+		expectErr bool
+	}{
+		{
+			"Address",
+			func(cfg *ForwardOutputConfig) { cfg.Address = "http://localhost:25535" },
+			false,
+		},
+		{
+			"Addresses",
+			func(cfg *ForwardOutputConfig) {
+				cfg.Addresses = []string{"http://relay1:25535", "http://relay2:25535"}
+				cfg.LoadBalancing = loadBalancingFailover
+			},
+			false,
+		},
+		{
+			"MissingAddress",
+			func(cfg *ForwardOutputConfig) {},
+			true,
+		},
+		{
+			"InvalidLoadBalancing",
+			func(cfg *ForwardOutputConfig) {
+				cfg.Address = "http://localhost:25535"
+				cfg.LoadBalancing = "random"
+			},
+			true,
+		},
+		{
+			"InvalidCompression",
+			func(cfg *ForwardOutputConfig) {
+				cfg.Address = "http://localhost:25535"
+				cfg.Compression = "lz4"
+			},
+			true,
+		},
+		{
+			"MultipleAuth",
+			func(cfg *ForwardOutputConfig) {
+				cfg.Address = "http://localhost:25535"
+				cfg.SharedSecret = "secret"
+				cfg.BearerToken = "token"
+			},
+			true,
+		},
+		{
+			"MissingCAFile",
+			func(cfg *ForwardOutputConfig) {
+				cfg.Address = "https://localhost:25535"
+				cfg.TLS = &TLSConfig{CAFile: "/does/not/exist"}
+			},
+			true,
+		},
+		{
+			"CertWithoutKey",
+			func(cfg *ForwardOutputConfig) {
+				cfg.Address = "https://localhost:25535"
+				cfg.TLS = &TLSConfig{CertFile: "/tmp/cert.pem"}
+			},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewForwardOutputConfig("test")
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestForwardOutputCompression(t *testing.T) {
+	for _, compression := range []string{compressionNone, compressionGzip, compressionZstd} {
+		compression := compression
+		t.Run(compression, func(t *testing.T) {
+			received := make(chan receivedRequest, 1)
+			srv := httptest.NewServer(newReceiver(t, http.StatusOK, received))
+			defer srv.Close()
+
+			cfg := NewForwardOutputConfig("test")
+			memoryCfg := buffer.NewMemoryBufferConfig()
+			memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+			cfg.BufferConfig = buffer.Config{
+				Builder: memoryCfg,
+			}
+			cfg.Address = srv.URL
+			cfg.Compression = compression
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			forwardOutput := ops[0].(*ForwardOutput)
+			require.NoError(t, forwardOutput.Start())
+			defer forwardOutput.Stop()
+
+			newEntry := entry.New()
+			newEntry.Record = "test"
+			require.NoError(t, forwardOutput.Process(context.Background(), newEntry))
+
+			req := expectRequest(t, received)
+			require.Len(t, req.entries, 1)
+			require.Equal(t, "test", req.entries[0].Record)
+			if compression != compressionNone {
+				require.Equal(t, compression, req.header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestForwardOutputAuth(t *testing.T) {
+	t.Run("SharedSecret", func(t *testing.T) {
+		received := make(chan receivedRequest, 1)
+		srv := httptest.NewServer(newReceiver(t, http.StatusOK, received))
+		defer srv.Close()
+
+		cfg := NewForwardOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.Address = srv.URL
+		cfg.SharedSecret = "secret"
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		forwardOutput := ops[0].(*ForwardOutput)
+		require.NoError(t, forwardOutput.Start())
+		defer forwardOutput.Stop()
+
+		require.NoError(t, forwardOutput.Process(context.Background(), entry.New()))
+		require.Equal(t, "secret", expectRequest(t, received).header.Get(sharedSecretHeader))
+	})
+
+	t.Run("BearerToken", func(t *testing.T) {
+		received := make(chan receivedRequest, 1)
+		srv := httptest.NewServer(newReceiver(t, http.StatusOK, received))
+		defer srv.Close()
+
+		cfg := NewForwardOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.Address = srv.URL
+		cfg.BearerToken = "token"
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		forwardOutput := ops[0].(*ForwardOutput)
+		require.NoError(t, forwardOutput.Start())
+		defer forwardOutput.Stop()
+
+		require.NoError(t, forwardOutput.Process(context.Background(), entry.New()))
+		require.Equal(t, "Bearer token", expectRequest(t, received).header.Get("Authorization"))
+	})
+}
+
+func TestForwardOutputTLS(t *testing.T) {
+	received := make(chan receivedRequest, 1)
+	srv := httptest.NewTLSServer(newReceiver(t, http.StatusOK, received))
+	defer srv.Close()
+
+	caFile := filepath.Join(testutil.NewTempDir(t), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caFile, caPEM, 0600))
+
+	cfg := NewForwardOutputConfig("test")
+	memoryCfg := buffer.NewMemoryBufferConfig()
+	memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.BufferConfig = buffer.Config{
+		Builder: memoryCfg,
+	}
+	cfg.Address = srv.URL
+	cfg.TLS = &TLSConfig{CAFile: caFile}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	forwardOutput := ops[0].(*ForwardOutput)
+	require.NoError(t, forwardOutput.Start())
+	defer forwardOutput.Stop()
+
+	require.NoError(t, forwardOutput.Process(context.Background(), entry.New()))
+	expectRequest(t, received)
+}
+
+func TestForwardOutputLoadBalancing(t *testing.T) {
+	t.Run("RoundRobin", func(t *testing.T) {
+		received1 := make(chan receivedRequest, 10)
+		srv1 := httptest.NewServer(newReceiver(t, http.StatusOK, received1))
+		defer srv1.Close()
+		received2 := make(chan receivedRequest, 10)
+		srv2 := httptest.NewServer(newReceiver(t, http.StatusOK, received2))
+		defer srv2.Close()
+
+		cfg := NewForwardOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.Addresses = []string{srv1.URL, srv2.URL}
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		forwardOutput := ops[0].(*ForwardOutput)
+		require.NoError(t, forwardOutput.Start())
+		defer forwardOutput.Stop()
+
+		require.NoError(t, forwardOutput.Process(context.Background(), entry.New()))
+		expectRequest(t, received1)
+		require.NoError(t, forwardOutput.Process(context.Background(), entry.New()))
+		expectRequest(t, received2)
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		received1 := make(chan receivedRequest, 10)
+		srv1 := httptest.NewServer(newReceiver(t, http.StatusServiceUnavailable, received1))
+		defer srv1.Close()
+		received2 := make(chan receivedRequest, 10)
+		srv2 := httptest.NewServer(newReceiver(t, http.StatusOK, received2))
+		defer srv2.Close()
+
+		cfg := NewForwardOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.Addresses = []string{srv1.URL, srv2.URL}
+		cfg.LoadBalancing = loadBalancingFailover
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		forwardOutput := ops[0].(*ForwardOutput)
+		require.NoError(t, forwardOutput.Start())
+		defer forwardOutput.Stop()
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, forwardOutput.Process(context.Background(), entry.New()))
+			expectRequest(t, received1)
+			expectRequest(t, received2)
+		}
+	})
+}
+
+func TestBalancerOrder(t *testing.T) {
+	addresses := []string{"a", "b", "c"}
+
+	roundRobin := newBalancer(addresses, loadBalancingRoundRobin)
+	require.Equal(t, []string{"a", "b", "c"}, roundRobin.order())
+	require.Equal(t, []string{"b", "c", "a"}, roundRobin.order())
+	require.Equal(t, []string{"c", "a", "b"}, roundRobin.order())
+	require.Equal(t, []string{"a", "b", "c"}, roundRobin.order())
+
+	failover := newBalancer(addresses, loadBalancingFailover)
+	require.Equal(t, []string{"a", "b", "c"}, failover.order())
+	require.Equal(t, []string{"a", "b", "c"}, failover.order())
+}
@@ -0,0 +1,103 @@
+package forward
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	loadBalancingRoundRobin = "round_robin"
+	loadBalancingFailover   = "failover"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+
+	// sharedSecretHeader is the header checked by forward_input when a shared secret is configured
+	sharedSecretHeader = "X-Stanza-Secret"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// compressor compresses request bodies
+type compressor interface {
+	writer(w io.Writer) io.WriteCloser
+	encoding() string
+}
+
+func newCompressor(compression string) (compressor, error) {
+	switch compression {
+	case compressionNone, "":
+		return noneCompressor{}, nil
+	case compressionGzip:
+		return gzipCompressor{}, nil
+	case compressionZstd:
+		return zstdCompressor{}, nil
+	default:
+		return nil, fmt.Errorf("invalid compression '%s', must be one of '%s', '%s' or '%s'", compression, compressionNone, compressionGzip, compressionZstd)
+	}
+}
+
+type noneCompressor struct{}
+
+func (noneCompressor) writer(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }
+func (noneCompressor) encoding() string                  { return "" }
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) writer(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+func (gzipCompressor) encoding() string                  { return compressionGzip }
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) writer(w io.Writer) io.WriteCloser {
+	// NewWriter only fails on invalid options, and none are used
+	enc, _ := zstd.NewWriter(w)
+	return enc
+}
+func (zstdCompressor) encoding() string { return compressionZstd }
+
+// balancer chooses the order that addresses are tried in for each request
+type balancer struct {
+	addresses []string
+	strategy  string
+
+	mux  sync.Mutex
+	next int
+}
+
+func newBalancer(addresses []string, strategy string) *balancer {
+	return &balancer{
+		addresses: addresses,
+		strategy:  strategy,
+	}
+}
+
+// order returns the addresses in the order they should be tried. With round
+// robin, each call starts with the next address. With failover, the first
+// address is always preferred and the others are only used if it fails.
+func (b *balancer) order() []string {
+	if b.strategy == loadBalancingFailover || len(b.addresses) == 1 {
+		return b.addresses
+	}
+
+	b.mux.Lock()
+	start := b.next
+	b.next = (b.next + 1) % len(b.addresses)
+	b.mux.Unlock()
+
+	ordered := make([]string, 0, len(b.addresses))
+	ordered = append(ordered, b.addresses[start:]...)
+	return append(ordered, b.addresses[:start]...)
+}
@@ -0,0 +1,355 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/errors"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/buffer"
+	"github.com/observiq/stanza/operator/flusher"
+	"github.com/observiq/stanza/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("http_output", func() operator.Builder { return NewHTTPOutputConfig("") })
+}
+
+const (
+	modeChunk = "chunk"
+	modeEntry = "entry"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+)
+
+// NewHTTPOutputConfig creates a new http output config with default values
+func NewHTTPOutputConfig(operatorID string) *HTTPOutputConfig {
+	return &HTTPOutputConfig{
+		OutputConfig:     helper.NewOutputConfig(operatorID, "http_output"),
+		BufferConfig:     buffer.NewConfig(),
+		FlusherConfig:    flusher.NewConfig(),
+		Method:           http.MethodPost,
+		Mode:             modeChunk,
+		Format:           formatJSONArray,
+		Compression:      compressionNone,
+		Timeout:          helper.NewDuration(10 * time.Second),
+		RetryStatusCodes: []int{408, 429, 500, 502, 503, 504},
+	}
+}
+
+// HTTPOutputConfig is the configuration of an http output operator
+type HTTPOutputConfig struct {
+	helper.OutputConfig `yaml:",inline"`
+	BufferConfig        buffer.Config  `json:"buffer"  yaml:"buffer"`
+	FlusherConfig       flusher.Config `json:"flusher" yaml:"flusher"`
+
+	URL              string            `json:"url"                          yaml:"url"`
+	Method           string            `json:"method,omitempty"             yaml:"method,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"            yaml:"headers,omitempty"`
+	Mode             string            `json:"mode,omitempty"               yaml:"mode,omitempty"`
+	Format           string            `json:"format,omitempty"             yaml:"format,omitempty"`
+	BodyTemplate     string            `json:"body_template,omitempty"      yaml:"body_template,omitempty"`
+	ContentType      string            `json:"content_type,omitempty"       yaml:"content_type,omitempty"`
+	Compression      string            `json:"compression,omitempty"        yaml:"compression,omitempty"`
+	BasicAuth        *BasicAuthConfig  `json:"basic_auth,omitempty"         yaml:"basic_auth,omitempty"`
+	BearerToken      string            `json:"bearer_token,omitempty"       yaml:"bearer_token,omitempty"`
+	Timeout          helper.Duration   `json:"timeout,omitempty"            yaml:"timeout,omitempty"`
+	RetryStatusCodes []int             `json:"retry_status_codes,omitempty" yaml:"retry_status_codes,omitempty"`
+}
+
+// BasicAuthConfig is the configuration for http basic authentication
+type BasicAuthConfig struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// Build will build an http output operator
+func (c HTTPOutputConfig) Build(bc operator.BuildContext) ([]operator.Operator, error) {
+	outputOperator, err := c.OutputConfig.Build(bc)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.URL == "" {
+		return nil, errors.NewError("missing required parameter 'url'", "")
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "'url' is not a valid URL")
+	}
+
+	if c.Method == "" {
+		c.Method = http.MethodPost
+	}
+	method := strings.ToUpper(c.Method)
+
+	switch c.Mode {
+	case modeChunk, modeEntry:
+	case "":
+		c.Mode = modeChunk
+	default:
+		return nil, fmt.Errorf("invalid mode '%s', must be one of '%s' or '%s'", c.Mode, modeChunk, modeEntry)
+	}
+
+	encoder, err := newPayloadEncoder(c.Format, c.BodyTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := c.ContentType
+	if contentType == "" {
+		contentType = encoder.contentType()
+	}
+
+	switch c.Compression {
+	case compressionNone, compressionGzip:
+	case "":
+		c.Compression = compressionNone
+	default:
+		return nil, fmt.Errorf("invalid compression '%s', must be one of '%s' or '%s'", c.Compression, compressionNone, compressionGzip)
+	}
+
+	if c.BasicAuth != nil && c.BearerToken != "" {
+		return nil, fmt.Errorf("only one of 'basic_auth' or 'bearer_token' can be set")
+	}
+
+	staticHeaders := http.Header{}
+	headerTemplates := make(map[string]*template.Template)
+	for key, value := range c.Headers {
+		if !strings.Contains(value, "{{") {
+			staticHeaders.Set(key, value)
+			continue
+		}
+
+		tmpl, err := template.New(key).Funcs(templateFuncs).Parse(value)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("parse template for header '%s'", key))
+		}
+		headerTemplates[key] = tmpl
+	}
+
+	retryStatusCodes := make(map[int]struct{}, len(c.RetryStatusCodes))
+	for _, code := range c.RetryStatusCodes {
+		retryStatusCodes[code] = struct{}{}
+	}
+
+	buffer, err := c.BufferConfig.Build(bc, c.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	flusher := c.FlusherConfig.Build(bc.Logger.SugaredLogger)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	httpOutput := &HTTPOutput{
+		OutputOperator:   outputOperator,
+		buffer:           buffer,
+		flusher:          flusher,
+		client:           &http.Client{Timeout: c.Timeout.Raw()},
+		url:              u,
+		method:           method,
+		mode:             c.Mode,
+		encoder:          encoder,
+		contentType:      contentType,
+		compression:      c.Compression,
+		basicAuth:        c.BasicAuth,
+		bearerToken:      c.BearerToken,
+		staticHeaders:    staticHeaders,
+		headerTemplates:  headerTemplates,
+		retryStatusCodes: retryStatusCodes,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+
+	return []operator.Operator{httpOutput}, nil
+}
+
+// HTTPOutput is an operator that sends entries to an arbitrary http endpoint
+type HTTPOutput struct {
+	helper.OutputOperator
+	buffer  buffer.Buffer
+	flusher *flusher.Flusher
+
+	client           *http.Client
+	url              *url.URL
+	method           string
+	mode             string
+	encoder          *payloadEncoder
+	contentType      string
+	compression      string
+	basicAuth        *BasicAuthConfig
+	bearerToken      string
+	staticHeaders    http.Header
+	headerTemplates  map[string]*template.Template
+	retryStatusCodes map[int]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start signals to the HTTPOutput to begin flushing
+func (h *HTTPOutput) Start() error {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.feedFlusher(h.ctx)
+	}()
+
+	return nil
+}
+
+// Stop tells the HTTPOutput to stop gracefully
+func (h *HTTPOutput) Stop() error {
+	h.cancel()
+	h.wg.Wait()
+	h.flusher.Stop()
+	return h.buffer.Close()
+}
+
+// Process adds an entry to the output's buffer
+func (h *HTTPOutput) Process(ctx context.Context, entry *entry.Entry) error {
+	return h.buffer.Add(ctx, entry)
+}
+
+func (h *HTTPOutput) feedFlusher(ctx context.Context) {
+	for {
+		entries, clearer, err := h.buffer.ReadChunk(ctx)
+		if err != nil && err == context.Canceled {
+			return
+		} else if err != nil {
+			h.Errorf("Failed to read chunk", zap.Error(err))
+			continue
+		}
+
+		// In entry mode, each entry is sent in its own request. Track how many
+		// have been sent so that a retry resumes where the last attempt failed
+		batches := [][]*entry.Entry{entries}
+		if h.mode == modeEntry {
+			batches = make([][]*entry.Entry, 0, len(entries))
+			for _, e := range entries {
+				batches = append(batches, []*entry.Entry{e})
+			}
+		}
+		sent := 0
+
+		h.flusher.Do(func(ctx context.Context) error {
+			for ; sent < len(batches); sent++ {
+				if err := h.send(ctx, batches[sent]); err != nil {
+					return err
+				}
+			}
+
+			if err := clearer.MarkAllAsFlushed(); err != nil {
+				h.Errorw("Failed to mark entries as flushed", zap.Error(err))
+			}
+			return nil
+		})
+	}
+}
+
+// send sends a batch of entries in a single request. It only returns an error
+// if the request should be retried. Batches that fail permanently are dropped.
+func (h *HTTPOutput) send(ctx context.Context, entries []*entry.Entry) error {
+	req, err := h.createRequest(ctx, entries)
+	if err != nil {
+		// drop these logs because we couldn't create a request and a retry won't help
+		h.Errorw("Failed to create request. Dropping entries", zap.Error(err), "count", len(entries))
+		return nil
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+
+	return h.handleResponse(res, len(entries))
+}
+
+// createRequest renders entries into an http request
+func (h *HTTPOutput) createRequest(ctx context.Context, entries []*entry.Entry) (*http.Request, error) {
+	data := newPayloadData(entries)
+
+	var body bytes.Buffer
+	if h.compression == compressionGzip {
+		gz := gzip.NewWriter(&body)
+		if err := h.encoder.encode(gz, data); err != nil {
+			return nil, errors.Wrap(err, "encode payload")
+		}
+		if err := gz.Close(); err != nil {
+			return nil, errors.Wrap(err, "compress payload")
+		}
+	} else if err := h.encoder.encode(&body, data); err != nil {
+		return nil, errors.Wrap(err, "encode payload")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, h.method, h.url.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+
+	for key, values := range h.staticHeaders {
+		req.Header[key] = values
+	}
+
+	for key, tmpl := range h.headerTemplates {
+		var value strings.Builder
+		if err := tmpl.Execute(&value, data); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("render header '%s'", key))
+		}
+		req.Header.Set(key, value.String())
+	}
+
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", h.contentType)
+	}
+
+	if h.compression == compressionGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	switch {
+	case h.basicAuth != nil:
+		req.SetBasicAuth(h.basicAuth.Username, h.basicAuth.Password)
+	case h.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+h.bearerToken)
+	}
+
+	return req, nil
+}
+
+// handleResponse returns an error if the response indicates the request
+// should be retried. Permanent failures are logged and dropped.
+func (h *HTTPOutput) handleResponse(res *http.Response, count int) error {
+	body, readErr := ioutil.ReadAll(res.Body)
+	if err := res.Body.Close(); err != nil {
+		h.Errorf(err.Error())
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	if _, ok := h.retryStatusCodes[res.StatusCode]; ok {
+		if readErr != nil {
+			return errors.NewError("retryable status code", "", "status", res.Status)
+		}
+		return errors.NewError("retryable status code", "", "status", res.Status, "body", string(body))
+	}
+
+	h.Errorw("Request failed with a non-retryable status code. Dropping entries", "status", res.Status, "body", string(body), "count", count)
+	return nil
+}
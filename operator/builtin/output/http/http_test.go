@@ -0,0 +1,415 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator/buffer"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+func newTestServer(t *testing.T, status func() int) (*httptest.Server, chan receivedRequest) {
+	received := make(chan receivedRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body []byte
+		var err error
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			gz, gzErr := gzip.NewReader(req.Body)
+			require.NoError(t, gzErr)
+			body, err = ioutil.ReadAll(gz)
+		} else {
+			body, err = ioutil.ReadAll(req.Body)
+		}
+		require.NoError(t, err)
+
+		received <- receivedRequest{header: req.Header, body: string(body)}
+		w.WriteHeader(status())
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func newTestEntry(record interface{}) *entry.Entry {
+	e := entry.New()
+	e.Timestamp = time.Date(2021, 7, 20, 10, 0, 0, 0, time.UTC)
+	e.Record = record
+	e.Labels = map[string]string{"app": "test"}
+	return e
+}
+
+func expectRequest(t *testing.T, received chan receivedRequest) receivedRequest {
+	select {
+	case req := <-received:
+		return req
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for server to receive request")
+	}
+	return receivedRequest{}
+}
+
+func expectNoRequest(t *testing.T, received chan receivedRequest) {
+	select {
+	case req := <-received:
+		require.FailNow(t, "Unexpected request", req.body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHTTPOutputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		modify    func(*HTTPOutputConfig)
+		expectErr bool
+	}{
+		{
+			"Default",
+			func(cfg *HTTPOutputConfig) {},
+			false,
+		},
+		{
+			"MissingURL",
+			func(cfg *HTTPOutputConfig) { cfg.URL = "" },
+			true,
+		},
+		{
+			"InvalidURL",
+			func(cfg *HTTPOutputConfig) { cfg.URL = `%^&*($@)` },
+			true,
+		},
+		{
+			"InvalidMode",
+			func(cfg *HTTPOutputConfig) { cfg.Mode = "invalid" },
+			true,
+		},
+		{
+			"InvalidFormat",
+			func(cfg *HTTPOutputConfig) { cfg.Format = "invalid" },
+			true,
+		},
+		{
+			"TemplateWithoutBody",
+			func(cfg *HTTPOutputConfig) { cfg.Format = formatTemplate },
+			true,
+		},
+		{
+			"BodyWithoutTemplateFormat",
+			func(cfg *HTTPOutputConfig) { cfg.BodyTemplate = "{{.Entry.Record}}" },
+			true,
+		},
+		{
+			"InvalidBodyTemplate",
+			func(cfg *HTTPOutputConfig) {
+				cfg.Format = formatTemplate
+				cfg.BodyTemplate = "{{.Entry"
+			},
+			true,
+		},
+		{
+			"InvalidHeaderTemplate",
+			func(cfg *HTTPOutputConfig) { cfg.Headers = map[string]string{"X-Test": "{{.Entry"} },
+			true,
+		},
+		{
+			"InvalidCompression",
+			func(cfg *HTTPOutputConfig) { cfg.Compression = "zip" },
+			true,
+		},
+		{
+			"MultipleAuth",
+			func(cfg *HTTPOutputConfig) {
+				cfg.BasicAuth = &BasicAuthConfig{Username: "user", Password: "pass"}
+				cfg.BearerToken = "token"
+			},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewHTTPOutputConfig("test")
+			cfg.URL = "http://localhost:8080"
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPOutputFormats(t *testing.T) {
+	cases := []struct {
+		name                string
+		modify              func(*HTTPOutputConfig)
+		expectedBody        string
+		expectedContentType string
+	}{
+		{
+			"JSONArray",
+			nil,
+			`[{"timestamp":"2021-07-20T10:00:00Z","severity":0,"labels":{"app":"test"},"record":"test1"},{"timestamp":"2021-07-20T10:00:00Z","severity":0,"labels":{"app":"test"},"record":"test2"}]` + "\n",
+			"application/json",
+		},
+		{
+			"NDJSON",
+			func(cfg *HTTPOutputConfig) { cfg.Format = formatNDJSON },
+			`{"timestamp":"2021-07-20T10:00:00Z","severity":0,"labels":{"app":"test"},"record":"test1"}` + "\n" +
+				`{"timestamp":"2021-07-20T10:00:00Z","severity":0,"labels":{"app":"test"},"record":"test2"}` + "\n",
+			"application/x-ndjson",
+		},
+		{
+			"Template",
+			func(cfg *HTTPOutputConfig) {
+				cfg.Format = formatTemplate
+				cfg.BodyTemplate = `{"messages":[{{range $i, $e := .Entries}}{{if $i}},{{end}}{{json $e.Record}}{{end}}]}`
+				cfg.ContentType = "application/json"
+			},
+			`{"messages":["test1","test2"]}`,
+			"application/json",
+		},
+		{
+			"Gzip",
+			func(cfg *HTTPOutputConfig) {
+				cfg.Format = formatTemplate
+				cfg.BodyTemplate = `{{range .Entries}}{{.Record}};{{end}}`
+				cfg.Compression = compressionGzip
+			},
+			`test1;test2;`,
+			"text/plain",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			srv, received := newTestServer(t, func() int { return http.StatusOK })
+
+			cfg := NewHTTPOutputConfig("test")
+			memoryCfg := buffer.NewMemoryBufferConfig()
+			memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+			cfg.BufferConfig = buffer.Config{
+				Builder: memoryCfg,
+			}
+			cfg.URL = srv.URL
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			op := ops[0].(*HTTPOutput)
+
+			require.NoError(t, op.Start())
+			defer op.Stop()
+
+			require.NoError(t, op.Process(context.Background(), newTestEntry("test1")))
+			require.NoError(t, op.Process(context.Background(), newTestEntry("test2")))
+
+			req := expectRequest(t, received)
+			require.Equal(t, tc.expectedBody, req.body)
+			require.Equal(t, tc.expectedContentType, req.header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestHTTPOutputEntryMode(t *testing.T) {
+	srv, received := newTestServer(t, func() int { return http.StatusOK })
+
+	cfg := NewHTTPOutputConfig("test")
+	memoryCfg := buffer.NewMemoryBufferConfig()
+	memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.BufferConfig = buffer.Config{
+		Builder: memoryCfg,
+	}
+	cfg.URL = srv.URL
+	cfg.Mode = modeEntry
+	cfg.Format = formatTemplate
+	cfg.BodyTemplate = "{{.Entry.Record}}"
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*HTTPOutput)
+
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test1")))
+	require.NoError(t, op.Process(context.Background(), newTestEntry("test2")))
+
+	require.Equal(t, "test1", expectRequest(t, received).body)
+	require.Equal(t, "test2", expectRequest(t, received).body)
+}
+
+func TestHTTPOutputHeaders(t *testing.T) {
+	cases := []struct {
+		name     string
+		modify   func(*HTTPOutputConfig)
+		expected map[string]string
+	}{
+		{
+			"Static",
+			func(cfg *HTTPOutputConfig) {
+				cfg.Headers = map[string]string{"X-Static": "value"}
+			},
+			map[string]string{"X-Static": "value"},
+		},
+		{
+			"Templated",
+			func(cfg *HTTPOutputConfig) {
+				cfg.Headers = map[string]string{"X-App": "{{.Entry.Labels.app}}"}
+			},
+			map[string]string{"X-App": "test"},
+		},
+		{
+			"BasicAuth",
+			func(cfg *HTTPOutputConfig) {
+				cfg.BasicAuth = &BasicAuthConfig{Username: "user", Password: "pass"}
+			},
+			map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		},
+		{
+			"BearerToken",
+			func(cfg *HTTPOutputConfig) {
+				cfg.BearerToken = "token"
+			},
+			map[string]string{"Authorization": "Bearer token"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			srv, received := newTestServer(t, func() int { return http.StatusOK })
+
+			cfg := NewHTTPOutputConfig("test")
+			memoryCfg := buffer.NewMemoryBufferConfig()
+			memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+			cfg.BufferConfig = buffer.Config{
+				Builder: memoryCfg,
+			}
+			cfg.URL = srv.URL
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+
+			ops, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+			op := ops[0].(*HTTPOutput)
+
+			require.NoError(t, op.Start())
+			defer op.Stop()
+
+			require.NoError(t, op.Process(context.Background(), newTestEntry("test")))
+
+			req := expectRequest(t, received)
+			for key, value := range tc.expected {
+				require.Equal(t, value, req.header.Get(key))
+			}
+		})
+	}
+}
+
+func TestHTTPOutputStatusCodes(t *testing.T) {
+	t.Run("Retryable", func(t *testing.T) {
+		var attempts int32
+		srv, received := newTestServer(t, func() int {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return http.StatusServiceUnavailable
+			}
+			return http.StatusOK
+		})
+
+		cfg := NewHTTPOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.URL = srv.URL
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*HTTPOutput)
+
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		require.NoError(t, op.Process(context.Background(), newTestEntry("test")))
+
+		first := expectRequest(t, received)
+		second := expectRequest(t, received)
+		require.Equal(t, first.body, second.body)
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		srv, received := newTestServer(t, func() int { return http.StatusBadRequest })
+
+		cfg := NewHTTPOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.URL = srv.URL
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*HTTPOutput)
+
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		require.NoError(t, op.Process(context.Background(), newTestEntry("test")))
+
+		expectRequest(t, received)
+		expectNoRequest(t, received)
+	})
+
+	t.Run("CustomRetryable", func(t *testing.T) {
+		var attempts int32
+		srv, received := newTestServer(t, func() int {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return http.StatusConflict
+			}
+			return http.StatusOK
+		})
+
+		cfg := NewHTTPOutputConfig("test")
+		memoryCfg := buffer.NewMemoryBufferConfig()
+		memoryCfg.MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.BufferConfig = buffer.Config{
+			Builder: memoryCfg,
+		}
+		cfg.URL = srv.URL
+		cfg.RetryStatusCodes = []int{http.StatusConflict}
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*HTTPOutput)
+
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		require.NoError(t, op.Process(context.Background(), newTestEntry("test")))
+
+		expectRequest(t, received)
+		expectRequest(t, received)
+	})
+}
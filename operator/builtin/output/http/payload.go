@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/observiq/stanza/entry"
+)
+
+const (
+	formatJSONArray = "json_array"
+	formatNDJSON    = "ndjson"
+	formatTemplate  = "template"
+)
+
+// templateFuncs are the functions available to body and header templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// payloadData is the data available to body and header templates.
+// Entry is the first entry of the request, which is the only entry
+// when the output is in entry mode.
+type payloadData struct {
+	Entries []*entry.Entry
+	Entry   *entry.Entry
+}
+
+func newPayloadData(entries []*entry.Entry) payloadData {
+	data := payloadData{Entries: entries}
+	if len(entries) > 0 {
+		data.Entry = entries[0]
+	}
+	return data
+}
+
+// payloadEncoder writes entries to a request body in the configured format
+type payloadEncoder struct {
+	format string
+	tmpl   *template.Template
+}
+
+func newPayloadEncoder(format, bodyTemplate string) (*payloadEncoder, error) {
+	switch format {
+	case formatJSONArray, formatNDJSON, "":
+		if bodyTemplate != "" {
+			return nil, fmt.Errorf("'body_template' can only be used with format '%s'", formatTemplate)
+		}
+		if format == "" {
+			format = formatJSONArray
+		}
+		return &payloadEncoder{format: format}, nil
+	case formatTemplate:
+		if bodyTemplate == "" {
+			return nil, fmt.Errorf("'body_template' is required with format '%s'", formatTemplate)
+		}
+		tmpl, err := template.New("body").Funcs(templateFuncs).Parse(bodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("parse body_template: %s", err)
+		}
+		return &payloadEncoder{format: format, tmpl: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("invalid format '%s', must be one of '%s', '%s' or '%s'", format, formatJSONArray, formatNDJSON, formatTemplate)
+	}
+}
+
+// contentType returns the default content type for the format
+func (p *payloadEncoder) contentType() string {
+	switch p.format {
+	case formatNDJSON:
+		return "application/x-ndjson"
+	case formatTemplate:
+		return "text/plain"
+	default:
+		return "application/json"
+	}
+}
+
+// encode writes the payload to w
+func (p *payloadEncoder) encode(w io.Writer, data payloadData) error {
+	switch p.format {
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, e := range data.Entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatTemplate:
+		return p.tmpl.Execute(w, data)
+	default:
+		return json.NewEncoder(w).Encode(data.Entries)
+	}
+}
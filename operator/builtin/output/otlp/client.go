@@ -0,0 +1,102 @@
+package otlp
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// logsClient exports serialized OTLP logs to a receiver
+type logsClient interface {
+	start() error
+	stop() error
+	export(ctx context.Context, request []byte) (partialSuccess, error)
+}
+
+// partialSuccess describes the log records that a receiver accepted the
+// request for, but rejected individually. These must not be retried.
+type partialSuccess struct {
+	RejectedLogRecords int64
+	ErrorMessage       string
+}
+
+// permanentError is an export error that will not succeed on retry
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func isPermanent(err error) bool {
+	_, ok := err.(permanentError)
+	return ok
+}
+
+// Field numbers of ExportLogsServiceResponse and ExportLogsPartialSuccess
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/collector/logs/v1/logs_service.proto
+const (
+	responsePartialSuccessField = 1
+	partialRejectedRecordsField = 1
+	partialErrorMessageField    = 2
+)
+
+// parsePartialSuccess reads the partial success from a serialized
+// ExportLogsServiceResponse. Unknown fields are skipped.
+func parsePartialSuccess(response []byte) (partialSuccess, error) {
+	var result partialSuccess
+	err := walkFields(response, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != responsePartialSuccessField || typ != protowire.BytesType {
+			return nil
+		}
+		return walkFields(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+			switch {
+			case num == partialRejectedRecordsField && typ == protowire.VarintType:
+				v, n := protowire.ConsumeVarint(value)
+				if n < 0 {
+					return protowire.ParseError(n)
+				}
+				result.RejectedLogRecords = int64(v)
+			case num == partialErrorMessageField && typ == protowire.BytesType:
+				result.ErrorMessage = string(value)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+// walkFields calls fn for each field in a serialized protobuf message. For
+// varint fields, value is the encoded varint. For bytes fields, value is the
+// content without its length prefix.
+func walkFields(b []byte, fn func(protowire.Number, protowire.Type, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var value []byte
+		if typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(b)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			value, n = v, m
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			value = b[:n]
+		}
+
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -3,12 +3,27 @@ package otlp
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/observiq/stanza/operator/buffer"
+	"github.com/observiq/stanza/operator/flusher"
+	"github.com/observiq/stanza/operator/helper"
 	"go.opentelemetry.io/collector/config/confighttp"
 )
 
+const (
+	protocolHTTP = "http"
+	protocolGRPC = "grpc"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+
+	defaultHTTPEndpoint = "https://localhost:55681/v1/logs"
+	defaultGRPCEndpoint = "localhost:4317"
+)
+
 // HTTPClientConfig makes confighttp.HTTPClientSettings marshallable with json and yaml
 type HTTPClientConfig struct {
 	confighttp.HTTPClientSettings
@@ -18,7 +33,7 @@ type HTTPClientConfig struct {
 func NewHTTPClientConfig() HTTPClientConfig {
 	return HTTPClientConfig{
 		confighttp.HTTPClientSettings{
-			Endpoint: "https://localhost:55681/v1/logs",
+			Endpoint: defaultHTTPEndpoint,
 		},
 	}
 }
@@ -30,12 +45,7 @@ func (c *HTTPClientConfig) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	settings := confighttp.HTTPClientSettings{}
-	if err := mapstructure.Decode(any, &settings); err != nil {
-		return err
-	}
-	c.HTTPClientSettings = settings
-	return nil
+	return c.decode(any)
 }
 
 // UnmarshalYAML will unmarshal json into a HTTPClientConfig struct
@@ -45,8 +55,22 @@ func (c *HTTPClientConfig) UnmarshalYAML(unmarshal func(interface{}) error) erro
 		return err
 	}
 
-	settings := confighttp.HTTPClientSettings{}
-	if err := mapstructure.Decode(any, &settings); err != nil {
+	return c.decode(any)
+}
+
+// decode decodes a generic value into the client settings, keeping any
+// settings that are not present. Durations may be given as strings, such
+// as `timeout: 10s`.
+func (c *HTTPClientConfig) decode(any interface{}) error {
+	settings := c.HTTPClientSettings
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &settings,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(any); err != nil {
 		return err
 	}
 	c.HTTPClientSettings = settings
@@ -72,3 +96,83 @@ func (c *HTTPClientConfig) cleanEndpoint() error {
 
 	return nil
 }
+
+// cleanGRPCEndpoint converts the endpoint to the host:port form expected by gRPC
+func (c *HTTPClientConfig) cleanGRPCEndpoint() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("'endpoint' is required")
+	}
+
+	// The default endpoint is for http, so replace it with the default gRPC port
+	if c.Endpoint == defaultHTTPEndpoint {
+		c.Endpoint = defaultGRPCEndpoint
+		return nil
+	}
+
+	c.Endpoint = strings.TrimPrefix(c.Endpoint, "http://")
+	c.Endpoint = strings.TrimPrefix(c.Endpoint, "https://")
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/v1/logs")
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/")
+
+	if _, _, err := net.SplitHostPort(c.Endpoint); err != nil {
+		return fmt.Errorf("'endpoint' must be in the form host:port when protocol is '%s': %s", protocolGRPC, err)
+	}
+	return nil
+}
+
+// otlpOutputFields are the fields of OTLPOutputConfig that are not part of
+// HTTPClientConfig. They are unmarshalled separately because HTTPClientConfig
+// replaces the default unmarshalling of any struct it is embedded in.
+type otlpOutputFields struct {
+	helper.OutputConfig `yaml:",inline"`
+	BufferConfig        buffer.Config  `json:"buffer" yaml:"buffer"`
+	FlusherConfig       flusher.Config `json:"flusher" yaml:"flusher"`
+	Protocol            string         `json:"protocol,omitempty"    yaml:"protocol,omitempty"`
+	Compression         string         `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+func (c *OTLPOutputConfig) fields() *otlpOutputFields {
+	return &otlpOutputFields{
+		OutputConfig:  c.OutputConfig,
+		BufferConfig:  c.BufferConfig,
+		FlusherConfig: c.FlusherConfig,
+		Protocol:      c.Protocol,
+		Compression:   c.Compression,
+	}
+}
+
+func (c *OTLPOutputConfig) setFields(f *otlpOutputFields) {
+	c.OutputConfig = f.OutputConfig
+	c.BufferConfig = f.BufferConfig
+	c.FlusherConfig = f.FlusherConfig
+	c.Protocol = f.Protocol
+	c.Compression = f.Compression
+}
+
+// UnmarshalJSON will unmarshal json into an OTLPOutputConfig struct
+func (c *OTLPOutputConfig) UnmarshalJSON(data []byte) error {
+	if err := c.HTTPClientConfig.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	f := c.fields()
+	if err := json.Unmarshal(data, f); err != nil {
+		return err
+	}
+	c.setFields(f)
+	return nil
+}
+
+// UnmarshalYAML will unmarshal yaml into an OTLPOutputConfig struct
+func (c *OTLPOutputConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := c.HTTPClientConfig.UnmarshalYAML(unmarshal); err != nil {
+		return err
+	}
+
+	f := c.fields()
+	if err := unmarshal(f); err != nil {
+		return err
+	}
+	c.setFields(f)
+	return nil
+}
@@ -3,6 +3,7 @@ package otlp
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/config/confighttp"
@@ -85,3 +86,74 @@ endpoint: localhost:1234`)
 
 	require.Equal(t, expected, cfg)
 }
+
+func TestUnmarshalOutputConfig(t *testing.T) {
+	expected := NewOTLPOutputConfig("my_otlp")
+	expected.Protocol = protocolGRPC
+	expected.Compression = compressionGzip
+	expected.Endpoint = "collector:4317"
+	expected.Headers = map[string]string{"testKey": "testValue"}
+	expected.Timeout = 10 * time.Second
+	expected.FlusherConfig.MaxConcurrent = 4
+
+	t.Run("JSON", func(t *testing.T) {
+		cfgBytes := []byte(`{
+			"id": "my_otlp",
+			"type": "otlp_output",
+			"protocol": "grpc",
+			"compression": "gzip",
+			"endpoint": "collector:4317",
+			"headers": { "testKey": "testValue" },
+			"timeout": "10s",
+			"flusher": { "max_concurrent": 4 }
+		}`)
+
+		cfg := NewOTLPOutputConfig("")
+		require.NoError(t, json.Unmarshal(cfgBytes, cfg))
+		require.Equal(t, expected, cfg)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		cfgBytes := []byte(`
+id: my_otlp
+type: otlp_output
+protocol: grpc
+compression: gzip
+endpoint: collector:4317
+headers:
+  testKey: testValue
+timeout: 10s
+flusher:
+  max_concurrent: 4
+`)
+
+		cfg := NewOTLPOutputConfig("")
+		require.NoError(t, yaml.Unmarshal(cfgBytes, cfg))
+		require.Equal(t, expected, cfg)
+	})
+
+	t.Run("DefaultEndpoint", func(t *testing.T) {
+		cfg := NewOTLPOutputConfig("")
+		require.NoError(t, yaml.Unmarshal([]byte("type: otlp_output\n"), cfg))
+		require.Equal(t, defaultHTTPEndpoint, cfg.Endpoint)
+	})
+}
+
+func TestCleanGRPCEndpoint(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		expected string
+	}{
+		{defaultHTTPEndpoint, defaultGRPCEndpoint},
+		{"collector:4317", "collector:4317"},
+		{"https://collector:4317", "collector:4317"},
+		{"http://collector:4317/v1/logs", "collector:4317"},
+	}
+
+	for _, tc := range cases {
+		cfg := NewHTTPClientConfig()
+		cfg.Endpoint = tc.endpoint
+		require.NoError(t, cfg.cleanGRPCEndpoint())
+		require.Equal(t, tc.expected, cfg.Endpoint)
+	}
+}
@@ -0,0 +1,137 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/observiq/stanza/errors"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// exportLogsMethod is the full name of the OTLP logs service export method
+const exportLogsMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// grpcLogsClient exports logs with OTLP/gRPC
+type grpcLogsClient struct {
+	endpoint    string
+	dialOptions []grpc.DialOption
+	metadata    metadata.MD
+	timeout     time.Duration
+	conn        *grpc.ClientConn
+}
+
+func newGRPCLogsClient(cfg HTTPClientConfig, compression string) (*grpcLogsClient, error) {
+	if err := cfg.cleanGRPCEndpoint(); err != nil {
+		return nil, err
+	}
+
+	settings := configgrpc.GRPCClientSettings{
+		Endpoint:        cfg.Endpoint,
+		TLSSetting:      cfg.TLSSetting,
+		ReadBufferSize:  cfg.ReadBufferSize,
+		WriteBufferSize: cfg.WriteBufferSize,
+	}
+	if compression == compressionGzip {
+		settings.Compression = compressionGzip
+	}
+
+	dialOptions, err := settings.ToDialOptions()
+	if err != nil {
+		return nil, errors.Wrap(err, "create client")
+	}
+
+	return &grpcLogsClient{
+		endpoint:    cfg.Endpoint,
+		dialOptions: dialOptions,
+		metadata:    metadata.New(cfg.Headers),
+		timeout:     cfg.Timeout,
+	}, nil
+}
+
+func (c *grpcLogsClient) start() error {
+	// Dialing does not block, so an unavailable receiver is handled by retries
+	conn, err := grpc.Dial(c.endpoint, c.dialOptions...)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *grpcLogsClient) stop() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+func (c *grpcLogsClient) export(ctx context.Context, request []byte) (partialSuccess, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, c.metadata)
+
+	var response []byte
+	err := c.conn.Invoke(ctx, exportLogsMethod, &request, &response, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		st := status.Convert(err)
+		err = errors.NewError("export failed", "", "code", st.Code().String(), "message", st.Message())
+		if isRetryableCode(st.Code()) {
+			return partialSuccess{}, err
+		}
+		return partialSuccess{}, permanentError{err}
+	}
+
+	// The request was accepted, so a malformed response is not worth a retry
+	result, err := parsePartialSuccess(response)
+	if err != nil {
+		return partialSuccess{}, nil
+	}
+	return result, nil
+}
+
+// isRetryableCode returns true if the OTLP/gRPC specification
+// allows a request that failed with the code to be retried
+func isRetryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+// rawCodec passes messages through as serialized protobuf. Requests are
+// already serialized by pdata, and responses are parsed with protowire.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name returns the content subtype used for protobuf, so that
+// receivers treat the raw messages as regular protobuf requests
+func (rawCodec) Name() string {
+	return "proto"
+}
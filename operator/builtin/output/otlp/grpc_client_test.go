@@ -0,0 +1,371 @@
+package otlp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator/buffer"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type exportRequest struct {
+	body     []byte
+	metadata metadata.MD
+}
+
+// grpcCollector is a stub of the OTLP logs service
+type grpcCollector struct {
+	server   *grpc.Server
+	addr     string
+	requests chan exportRequest
+	respond  func(attempt int32) ([]byte, error)
+	attempts int32
+}
+
+func newGRPCCollector(t *testing.T, respond func(attempt int32) ([]byte, error), opts ...grpc.ServerOption) *grpcCollector {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	c := &grpcCollector{
+		server:   grpc.NewServer(append(opts, grpc.ForceServerCodec(rawCodec{}))...),
+		addr:     ln.Addr().String(),
+		requests: make(chan exportRequest, 10),
+		respond:  respond,
+	}
+
+	c.server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "opentelemetry.proto.collector.logs.v1.LogsService",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Export",
+			Handler:    c.export,
+		}},
+	}, c)
+
+	go c.server.Serve(ln)
+	t.Cleanup(c.server.Stop)
+	return c
+}
+
+func (c *grpcCollector) export(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	var body []byte
+	if err := dec(&body); err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.requests <- exportRequest{body: body, metadata: md}
+
+	response := []byte{}
+	if c.respond != nil {
+		var err error
+		response, err = c.respond(atomic.AddInt32(&c.attempts, 1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &response, nil
+}
+
+func (c *grpcCollector) expectRequest(t *testing.T) exportRequest {
+	select {
+	case req := <-c.requests:
+		return req
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for export request")
+	}
+	return exportRequest{}
+}
+
+func (c *grpcCollector) expectNoRequest(t *testing.T) {
+	select {
+	case <-c.requests:
+		require.FailNow(t, "Unexpected export request")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func newTestEntries() []*entry.Entry {
+	return []*entry.Entry{{
+		Timestamp: time.Date(2016, 10, 10, 8, 58, 52, 0, time.UTC),
+		Record:    "test1",
+	}, {
+		Timestamp: time.Date(2016, 10, 10, 8, 58, 52, 0, time.UTC),
+		Record:    "test2",
+	}}
+}
+
+func partialSuccessResponse(rejected int64, message string) []byte {
+	var partial []byte
+	partial = protowire.AppendTag(partial, partialRejectedRecordsField, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	partial = protowire.AppendTag(partial, partialErrorMessageField, protowire.BytesType)
+	partial = protowire.AppendString(partial, message)
+
+	var response []byte
+	response = protowire.AppendTag(response, responsePartialSuccessField, protowire.BytesType)
+	return protowire.AppendBytes(response, partial)
+}
+
+func TestOTLPOutputGRPC(t *testing.T) {
+	collector := newGRPCCollector(t, nil)
+
+	cfg := NewOTLPOutputConfig("test")
+	cfg.BufferConfig.Builder.(*buffer.MemoryBufferConfig).MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.Protocol = protocolGRPC
+	cfg.Endpoint = collector.addr
+	cfg.TLSSetting.Insecure = true
+	cfg.Headers = map[string]string{"x-scope-orgid": "stanza"}
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*OTLPOutput)
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	entries := newTestEntries()
+	for _, e := range entries {
+		require.NoError(t, op.Process(context.Background(), e))
+	}
+
+	expected, err := Convert(entries).ToOtlpProtoBytes()
+	require.NoError(t, err)
+
+	req := collector.expectRequest(t)
+	require.Equal(t, expected, req.body)
+	require.Equal(t, []string{"stanza"}, req.metadata.Get("x-scope-orgid"))
+}
+
+// compressionRecorder records the compression of incoming requests
+type compressionRecorder struct {
+	compression chan string
+}
+
+func (r *compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if header, ok := s.(*stats.InHeader); ok {
+		r.compression <- header.Compression
+	}
+}
+
+func (r *compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func TestOTLPOutputGRPCGzip(t *testing.T) {
+	recorder := &compressionRecorder{compression: make(chan string, 10)}
+	collector := newGRPCCollector(t, nil, grpc.StatsHandler(recorder))
+
+	cfg := NewOTLPOutputConfig("test")
+	cfg.BufferConfig.Builder.(*buffer.MemoryBufferConfig).MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.Protocol = protocolGRPC
+	cfg.Endpoint = collector.addr
+	cfg.TLSSetting.Insecure = true
+	cfg.Compression = compressionGzip
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*OTLPOutput)
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	entries := newTestEntries()
+	require.NoError(t, op.Process(context.Background(), entries[0]))
+
+	expected, err := Convert(entries[:1]).ToOtlpProtoBytes()
+	require.NoError(t, err)
+
+	req := collector.expectRequest(t)
+	require.Equal(t, expected, req.body)
+	require.Equal(t, "gzip", <-recorder.compression)
+}
+
+func TestOTLPOutputGRPCTLS(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+	certFile, keyFile := writeTestCertificate(t, tempDir)
+
+	creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+	require.NoError(t, err)
+
+	collector := newGRPCCollector(t, nil, grpc.Creds(creds))
+
+	cfg := NewOTLPOutputConfig("test")
+	cfg.BufferConfig.Builder.(*buffer.MemoryBufferConfig).MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+	cfg.Protocol = protocolGRPC
+	cfg.Endpoint = collector.addr
+	cfg.TLSSetting.Insecure = false
+	cfg.TLSSetting.CAFile = certFile
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0].(*OTLPOutput)
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	require.NoError(t, op.Process(context.Background(), newTestEntries()[0]))
+	collector.expectRequest(t)
+}
+
+func TestOTLPOutputGRPCStatusCodes(t *testing.T) {
+	t.Run("Retryable", func(t *testing.T) {
+		collector := newGRPCCollector(t, func(attempt int32) ([]byte, error) {
+			if attempt == 1 {
+				return nil, status.Error(codes.Unavailable, "try again")
+			}
+			return nil, nil
+		})
+
+		cfg := NewOTLPOutputConfig("test")
+		cfg.BufferConfig.Builder.(*buffer.MemoryBufferConfig).MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.Protocol = protocolGRPC
+		cfg.Endpoint = collector.addr
+		cfg.TLSSetting.Insecure = true
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*OTLPOutput)
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		require.NoError(t, op.Process(context.Background(), newTestEntries()[0]))
+
+		first := collector.expectRequest(t)
+		second := collector.expectRequest(t)
+		require.Equal(t, first.body, second.body)
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		collector := newGRPCCollector(t, func(attempt int32) ([]byte, error) {
+			return nil, status.Error(codes.InvalidArgument, "bad request")
+		})
+
+		cfg := NewOTLPOutputConfig("test")
+		cfg.BufferConfig.Builder.(*buffer.MemoryBufferConfig).MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.Protocol = protocolGRPC
+		cfg.Endpoint = collector.addr
+		cfg.TLSSetting.Insecure = true
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*OTLPOutput)
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		require.NoError(t, op.Process(context.Background(), newTestEntries()[0]))
+
+		collector.expectRequest(t)
+		collector.expectNoRequest(t)
+	})
+
+	t.Run("PartialSuccess", func(t *testing.T) {
+		collector := newGRPCCollector(t, func(attempt int32) ([]byte, error) {
+			return partialSuccessResponse(1, "invalid record"), nil
+		})
+
+		cfg := NewOTLPOutputConfig("test")
+		cfg.BufferConfig.Builder.(*buffer.MemoryBufferConfig).MaxChunkDelay = helper.NewDuration(50 * time.Millisecond)
+		cfg.Protocol = protocolGRPC
+		cfg.Endpoint = collector.addr
+		cfg.TLSSetting.Insecure = true
+
+		ops, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		op := ops[0].(*OTLPOutput)
+		require.NoError(t, op.Start())
+		defer op.Stop()
+
+		require.NoError(t, op.Process(context.Background(), newTestEntries()[0]))
+
+		collector.expectRequest(t)
+		collector.expectNoRequest(t)
+	})
+}
+
+func TestParsePartialSuccess(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		result, err := parsePartialSuccess(nil)
+		require.NoError(t, err)
+		require.Equal(t, partialSuccess{}, result)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		result, err := parsePartialSuccess(partialSuccessResponse(3, "invalid records"))
+		require.NoError(t, err)
+		require.Equal(t, partialSuccess{RejectedLogRecords: 3, ErrorMessage: "invalid records"}, result)
+	})
+
+	t.Run("UnknownFields", func(t *testing.T) {
+		var response []byte
+		response = protowire.AppendTag(response, 7, protowire.VarintType)
+		response = protowire.AppendVarint(response, 42)
+		response = append(response, partialSuccessResponse(2, "")...)
+
+		result, err := parsePartialSuccess(response)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), result.RejectedLogRecords)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := parsePartialSuccess([]byte{0x0a, 0x05, 0x08})
+		require.Error(t, err)
+	})
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stanza-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	_, err = tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return certFile, keyFile
+}
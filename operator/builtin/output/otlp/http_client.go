@@ -0,0 +1,122 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/observiq/stanza/errors"
+)
+
+// httpLogsClient exports logs with OTLP/HTTP
+type httpLogsClient struct {
+	client   *http.Client
+	url      *url.URL
+	compress bool
+}
+
+func newHTTPLogsClient(cfg HTTPClientConfig, compression string) (*httpLogsClient, error) {
+	if err := cfg.cleanEndpoint(); err != nil {
+		return nil, err
+	}
+
+	client, err := cfg.ToClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "create client")
+	}
+
+	url, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "'endpoint' is not a valid URL")
+	}
+
+	return &httpLogsClient{
+		client:   client,
+		url:      url,
+		compress: compression == compressionGzip,
+	}, nil
+}
+
+func (c *httpLogsClient) start() error { return nil }
+
+func (c *httpLogsClient) stop() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *httpLogsClient) export(ctx context.Context, request []byte) (partialSuccess, error) {
+	req, err := c.createRequest(ctx, request)
+	if err != nil {
+		return partialSuccess{}, permanentError{errors.Wrap(err, "create request")}
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return partialSuccess{}, errors.Wrap(err, "send request")
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return partialSuccess{}, errors.Wrap(err, "read response")
+	}
+
+	if !(res.StatusCode >= 200 && res.StatusCode < 300) {
+		err := errors.NewError("non-success status code", "", "status", fmt.Sprint(res.StatusCode), "body", string(body))
+		if isRetryableStatus(res.StatusCode) {
+			return partialSuccess{}, err
+		}
+		return partialSuccess{}, permanentError{err}
+	}
+
+	if res.Header.Get("Content-Type") != "application/x-protobuf" {
+		return partialSuccess{}, nil
+	}
+
+	// The request was accepted, so a malformed response is not worth a retry
+	result, err := parsePartialSuccess(body)
+	if err != nil {
+		return partialSuccess{}, nil
+	}
+	return result, nil
+}
+
+func (c *httpLogsClient) createRequest(ctx context.Context, request []byte) (*http.Request, error) {
+	body := request
+	if c.compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(request); err != nil {
+			return nil, errors.Wrap(err, "compress request")
+		}
+		if err := gz.Close(); err != nil {
+			return nil, errors.Wrap(err, "compress request")
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if c.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req, nil
+}
+
+// isRetryableStatus returns true if the OTLP/HTTP specification
+// allows a request that failed with the status code to be retried
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
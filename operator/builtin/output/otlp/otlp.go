@@ -1,12 +1,8 @@
 package otlp
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
 	"sync"
 
 	"github.com/observiq/stanza/entry"
@@ -29,6 +25,8 @@ func NewOTLPOutputConfig(operatorID string) *OTLPOutputConfig {
 		BufferConfig:     buffer.NewConfig(),
 		FlusherConfig:    flusher.NewConfig(),
 		HTTPClientConfig: NewHTTPClientConfig(),
+		Protocol:         protocolHTTP,
+		Compression:      compressionNone,
 	}
 }
 
@@ -38,6 +36,8 @@ type OTLPOutputConfig struct {
 	BufferConfig        buffer.Config  `json:"buffer" yaml:"buffer"`
 	FlusherConfig       flusher.Config `json:"flusher" yaml:"flusher"`
 	HTTPClientConfig    `yaml:",inline"`
+	Protocol            string `json:"protocol,omitempty"    yaml:"protocol,omitempty"`
+	Compression         string `json:"compression,omitempty" yaml:"compression,omitempty"`
 }
 
 // Build will build a new OTLPOutput
@@ -55,18 +55,25 @@ func (c OTLPOutputConfig) Build(bc operator.BuildContext) ([]operator.Operator,
 
 	flusher := c.FlusherConfig.Build(bc.Logger.SugaredLogger)
 
-	if err := c.cleanEndpoint(); err != nil {
-		return nil, err
+	switch c.Compression {
+	case compressionNone, compressionGzip:
+	case "":
+		c.Compression = compressionNone
+	default:
+		return nil, fmt.Errorf("invalid compression '%s', must be one of '%s' or '%s'", c.Compression, compressionNone, compressionGzip)
 	}
 
-	client, err := c.HTTPClientConfig.ToClient()
-	if err != nil {
-		return nil, errors.Wrap(err, "create client")
+	var client logsClient
+	switch c.Protocol {
+	case protocolHTTP, "":
+		client, err = newHTTPLogsClient(c.HTTPClientConfig, c.Compression)
+	case protocolGRPC:
+		client, err = newGRPCLogsClient(c.HTTPClientConfig, c.Compression)
+	default:
+		return nil, fmt.Errorf("invalid protocol '%s', must be one of '%s' or '%s'", c.Protocol, protocolHTTP, protocolGRPC)
 	}
-
-	url, err := url.Parse(c.HTTPClientConfig.Endpoint)
 	if err != nil {
-		return nil, errors.Wrap(err, "'endpoint' is not a valid URL")
+		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -76,7 +83,6 @@ func (c OTLPOutputConfig) Build(bc operator.BuildContext) ([]operator.Operator,
 		buffer:         buffer,
 		flusher:        flusher,
 		client:         client,
-		url:            url,
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -89,8 +95,7 @@ type OTLPOutput struct {
 	helper.OutputOperator
 	buffer  buffer.Buffer
 	flusher *flusher.Flusher
-	client  *http.Client
-	url     *url.URL
+	client  logsClient
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -99,6 +104,10 @@ type OTLPOutput struct {
 
 // Start flushing entries
 func (o *OTLPOutput) Start() error {
+	if err := o.client.start(); err != nil {
+		return errors.Wrap(err, "start client")
+	}
+
 	o.wg.Add(1)
 	go func() {
 		defer o.wg.Done()
@@ -113,6 +122,9 @@ func (o *OTLPOutput) Stop() error {
 	o.cancel()
 	o.wg.Wait()
 	o.flusher.Stop()
+	if err := o.client.stop(); err != nil {
+		o.Errorw("Failed to stop client", zap.Error(err))
+	}
 	return o.buffer.Close()
 }
 
@@ -128,7 +140,7 @@ func (o *OTLPOutput) feedFlusher(ctx context.Context) {
 		}
 
 		o.flusher.Do(func(ctx context.Context) error {
-			req, err := o.createRequest(ctx, entries)
+			protoBytes, err := Convert(entries).ToOtlpProtoBytes()
 			if err != nil {
 				o.Errorf("Failed to create request", zap.Error(err))
 				// drop these logs because we couldn't creat a request and a retry won't help
@@ -137,13 +149,19 @@ func (o *OTLPOutput) feedFlusher(ctx context.Context) {
 				}
 				return nil
 			}
-			res, err := o.client.Do(req)
-			if err != nil {
-				return errors.Wrap(err, "send request")
-			}
 
-			if err := o.handleResponse(res); err != nil {
-				return err
+			partialSuccess, err := o.client.export(ctx, protoBytes)
+			if err != nil {
+				if !isPermanent(err) {
+					return err
+				}
+				o.Errorw("Failed to export logs with a non-retryable error. Dropping entries", zap.Error(err), "count", len(entries))
+			} else if partialSuccess.RejectedLogRecords > 0 {
+				// Rejected records must not be retried, so log them and move on
+				o.Warnw("Receiver rejected some log records",
+					"rejected", partialSuccess.RejectedLogRecords,
+					"message", partialSuccess.ErrorMessage,
+				)
 			}
 
 			if err = clearer.MarkAllAsFlushed(); err != nil {
@@ -158,34 +176,3 @@ func (o *OTLPOutput) feedFlusher(ctx context.Context) {
 func (o *OTLPOutput) Process(ctx context.Context, entry *entry.Entry) error {
 	return o.buffer.Add(ctx, entry)
 }
-
-// ProcessMulti will send a chunk of entries
-func (o *OTLPOutput) createRequest(ctx context.Context, entries []*entry.Entry) (*http.Request, error) {
-	logs := Convert(entries)
-	protoBytes, err := logs.ToOtlpProtoBytes()
-	if err != nil {
-		return nil, errors.Wrap(err, "convert logs to proto bytes")
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url.String(), bytes.NewReader(protoBytes))
-	if err != nil {
-		return nil, errors.Wrap(err, "create request")
-	}
-	req.Header.Set("Content-Type", "application/x-protobuf")
-	return req, nil
-}
-
-func (o *OTLPOutput) handleResponse(res *http.Response) error {
-	if !(res.StatusCode >= 200 && res.StatusCode < 300) {
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return errors.NewError("non-success status code", "", "status", fmt.Sprint(res.StatusCode))
-		} else {
-			if err := res.Body.Close(); err != nil {
-				o.Errorf(err.Error())
-			}
-			return errors.NewError("non-success status code", "", "status", fmt.Sprint(res.StatusCode), "body", string(body))
-		}
-	}
-	return res.Body.Close()
-}
@@ -1,7 +1,9 @@
 package otlp
 
 import (
+	"compress/gzip"
 	"context"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -39,6 +41,39 @@ func TestOTLPConfigBuild(t *testing.T) {
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "is not a valid URL")
 	})
+
+	t.Run("InvalidProtocol", func(t *testing.T) {
+		cfg := NewOTLPOutputConfig("test")
+		cfg.Protocol = "udp"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid protocol")
+	})
+
+	t.Run("InvalidCompression", func(t *testing.T) {
+		cfg := NewOTLPOutputConfig("test")
+		cfg.Compression = "zstd"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid compression")
+	})
+
+	t.Run("InvalidGRPCEndpoint", func(t *testing.T) {
+		cfg := NewOTLPOutputConfig("test")
+		cfg.Protocol = protocolGRPC
+		cfg.Endpoint = "localhost"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "host:port")
+	})
+
+	t.Run("MissingCAFile", func(t *testing.T) {
+		cfg := NewOTLPOutputConfig("test")
+		cfg.Protocol = protocolGRPC
+		cfg.TLSSetting.CAFile = "/does/not/exist.pem"
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
 }
 
 func TestOTLPOutput(t *testing.T) {
@@ -66,6 +101,16 @@ func TestOTLPOutput(t *testing.T) {
 				Record:    "test2",
 			}},
 		},
+		{
+			"Gzip",
+			func(cfg *OTLPOutputConfig) {
+				cfg.Compression = compressionGzip
+			},
+			[]*entry.Entry{{
+				Timestamp: time.Date(2016, 10, 10, 8, 58, 52, 0, time.UTC),
+				Record:    "test",
+			}},
+		},
 	}
 
 	for _, tc := range cases {
@@ -151,7 +196,16 @@ func handle(ch chan []byte) func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(200)
 		rw.Write([]byte(`{}`))
 
-		body, err := ioutil.ReadAll(req.Body)
+		var reader io.Reader = req.Body
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(req.Body)
+			if err != nil {
+				panic(err)
+			}
+			reader = gz
+		}
+
+		body, err := ioutil.ReadAll(reader)
 		if err != nil {
 			panic(err)
 		}
@@ -28,6 +28,7 @@ type CSVParserConfig struct {
 	helper.ParserConfig `yaml:",inline"`
 
 	Header         string `json:"header" yaml:"header"`
+	HeaderLabel    string `json:"header_label,omitempty" yaml:"header_label,omitempty"`
 	FieldDelimiter string `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
 }
 
@@ -38,8 +39,12 @@ func (c CSVParserConfig) Build(context operator.BuildContext) ([]operator.Operat
 		return nil, err
 	}
 
-	if c.Header == "" {
-		return nil, fmt.Errorf("Missing required field 'header'")
+	if c.Header == "" && c.HeaderLabel == "" {
+		return nil, fmt.Errorf("Missing required field 'header' or 'header_label'")
+	}
+
+	if c.Header != "" && c.HeaderLabel != "" {
+		return nil, fmt.Errorf("only one of 'header' or 'header_label' can be set")
 	}
 
 	if c.FieldDelimiter == "" {
@@ -52,17 +57,15 @@ func (c CSVParserConfig) Build(context operator.BuildContext) ([]operator.Operat
 
 	fieldDelimiter := []rune(c.FieldDelimiter)[0]
 
-	if !strings.Contains(c.Header, c.FieldDelimiter) {
+	if c.Header != "" && !strings.Contains(c.Header, c.FieldDelimiter) {
 		return nil, fmt.Errorf("missing field delimiter in header")
 	}
 
-	numFields := len(strings.Split(c.Header, c.FieldDelimiter))
-
 	csvParser := &CSVParser{
 		ParserOperator: parserOperator,
 		header:         c.Header,
+		headerLabel:    c.HeaderLabel,
 		fieldDelimiter: fieldDelimiter,
-		numFields:      numFields,
 	}
 
 	return []operator.Operator{csvParser}, nil
@@ -72,17 +75,33 @@ func (c CSVParserConfig) Build(context operator.BuildContext) ([]operator.Operat
 type CSVParser struct {
 	helper.ParserOperator
 	header         string
+	headerLabel    string
 	fieldDelimiter rune
-	numFields      int
 }
 
 // Process will parse an entry for csv.
 func (r *CSVParser) Process(ctx context.Context, entry *entry.Entry) error {
-	return r.ParserOperator.ProcessWith(ctx, entry, r.parse)
+	if r.headerLabel == "" {
+		return r.ParserOperator.ProcessWith(ctx, entry, r.parse)
+	}
+
+	// The header is read from the entry, such as a header that file_input read from the file
+	header, ok := entry.Labels[r.headerLabel]
+	if !ok || header == "" {
+		return r.HandleEntryError(ctx, entry, fmt.Errorf("missing header label '%s'", r.headerLabel))
+	}
+	return r.ParserOperator.ProcessWith(ctx, entry, func(value interface{}) (interface{}, error) {
+		return r.parseWithHeader(value, header)
+	})
 }
 
 // parse will parse a value using the supplied csv header.
 func (r *CSVParser) parse(value interface{}) (interface{}, error) {
+	return r.parseWithHeader(value, r.header)
+}
+
+// parseWithHeader will parse a value using the given csv header.
+func (r *CSVParser) parseWithHeader(value interface{}, header string) (interface{}, error) {
 	var csvLine string
 	switch value.(type) {
 	case string:
@@ -94,10 +113,11 @@ func (r *CSVParser) parse(value interface{}) (interface{}, error) {
 	}
 
 	delimiterStr := string([]rune{r.fieldDelimiter})
+	keys := strings.Split(header, delimiterStr)
 
 	reader := csvparser.NewReader(strings.NewReader(csvLine))
 	reader.Comma = r.fieldDelimiter
-	reader.FieldsPerRecord = r.numFields
+	reader.FieldsPerRecord = len(keys)
 	parsedValues := make(map[string]interface{})
 
 	for {
@@ -110,7 +130,7 @@ func (r *CSVParser) parse(value interface{}) (interface{}, error) {
 			return nil, err
 		}
 
-		for i, key := range strings.Split(r.header, delimiterStr) {
+		for i, key := range keys {
 			parsedValues[key] = record[i]
 		}
 	}
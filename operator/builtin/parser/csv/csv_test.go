@@ -265,6 +265,22 @@ func TestBuildParserCSV(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("HeaderLabel", func(t *testing.T) {
+		c := newBasicCSVParser()
+		c.Header = ""
+		c.HeaderLabel = "file_header"
+		_, err := c.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+	})
+
+	t.Run("HeaderAndHeaderLabel", func(t *testing.T) {
+		c := newBasicCSVParser()
+		c.HeaderLabel = "file_header"
+		_, err := c.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "only one of 'header' or 'header_label' can be set")
+	})
+
 	t.Run("InvalidHeaderFieldMissingDelimiter", func(t *testing.T) {
 		c := newBasicCSVParser()
 		c.Header = "name"
@@ -289,3 +305,58 @@ func TestBuildParserCSV(t *testing.T) {
 		require.Contains(t, err.Error(), "missing field delimiter in header")
 	})
 }
+
+func TestParserCSVHeaderLabel(t *testing.T) {
+	cfg := NewCSVParserConfig("test")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.HeaderLabel = "file_header"
+	cfg.FieldDelimiter = " "
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0]
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, op.SetOutputs([]operator.Operator{fake}))
+
+	// Each entry is parsed with its own header, which may change mid-file
+	e := entry.New()
+	e.Record = "2021-07-01 GET /index.html"
+	e.AddLabel("file_header", "date cs-method cs-uri-stem")
+	require.NoError(t, op.Process(context.Background(), e))
+	fake.ExpectRecord(t, map[string]interface{}{
+		"date":        "2021-07-01",
+		"cs-method":   "GET",
+		"cs-uri-stem": "/index.html",
+	})
+
+	e = entry.New()
+	e.Record = "2021-07-01 12:00:00 200"
+	e.AddLabel("file_header", "date time sc-status")
+	require.NoError(t, op.Process(context.Background(), e))
+	fake.ExpectRecord(t, map[string]interface{}{
+		"date":      "2021-07-01",
+		"time":      "12:00:00",
+		"sc-status": "200",
+	})
+}
+
+func TestParserCSVMissingHeaderLabel(t *testing.T) {
+	cfg := NewCSVParserConfig("test")
+	cfg.OutputIDs = []string{"fake"}
+	cfg.HeaderLabel = "file_header"
+
+	ops, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	op := ops[0]
+
+	fake := testutil.NewFakeOutput(t)
+	require.NoError(t, op.SetOutputs([]operator.Operator{fake}))
+
+	e := entry.New()
+	e.Record = "stanza,INFO,started agent"
+	err = op.Process(context.Background(), e)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing header label 'file_header'")
+	fake.ExpectRecord(t, "stanza,INFO,started agent")
+}
@@ -100,9 +100,16 @@ func (s *SyslogParser) parse(value interface{}) (interface{}, error) {
 		return nil, err
 	}
 
+	return ParseMessage(b, s.protocol, s.location)
+}
+
+// ParseMessage parses a syslog message with the given protocol, which is
+// either rfc3164 or rfc5424. The location is used for rfc3164 timestamps,
+// which do not include a timezone.
+func ParseMessage(b []byte, protocol string, location *time.Location) (map[string]interface{}, error) {
 	b = handleSymbols(b)
 
-	machine, err := buildMachine(s.protocol, s.location)
+	machine, err := buildMachine(protocol, location)
 	if err != nil {
 		return nil, err
 	}
@@ -114,16 +121,16 @@ func (s *SyslogParser) parse(value interface{}) (interface{}, error) {
 
 	switch message := slog.(type) {
 	case *rfc3164.SyslogMessage:
-		return s.parseRFC3164(message)
+		return parseRFC3164(message)
 	case *rfc5424.SyslogMessage:
-		return s.parseRFC5424(message)
+		return parseRFC5424(message)
 	default:
 		return nil, fmt.Errorf("parsed value was not rfc3164 or rfc5424 compliant")
 	}
 }
 
 // parseRFC3164 will parse an RFC3164 syslog message.
-func (s *SyslogParser) parseRFC3164(syslogMessage *rfc3164.SyslogMessage) (map[string]interface{}, error) {
+func parseRFC3164(syslogMessage *rfc3164.SyslogMessage) (map[string]interface{}, error) {
 	value := map[string]interface{}{
 		"timestamp": syslogMessage.Timestamp,
 		"priority":  syslogMessage.Priority,
@@ -135,11 +142,11 @@ func (s *SyslogParser) parseRFC3164(syslogMessage *rfc3164.SyslogMessage) (map[s
 		"msg_id":    syslogMessage.MsgID,
 		"message":   syslogMessage.Message,
 	}
-	return s.toSafeMap(value)
+	return toSafeMap(value)
 }
 
 // parseRFC5424 will parse an RFC5424 syslog message.
-func (s *SyslogParser) parseRFC5424(syslogMessage *rfc5424.SyslogMessage) (map[string]interface{}, error) {
+func parseRFC5424(syslogMessage *rfc5424.SyslogMessage) (map[string]interface{}, error) {
 	value := map[string]interface{}{
 		"timestamp":       syslogMessage.Timestamp,
 		"priority":        syslogMessage.Priority,
@@ -153,11 +160,11 @@ func (s *SyslogParser) parseRFC5424(syslogMessage *rfc5424.SyslogMessage) (map[s
 		"structured_data": syslogMessage.StructuredData,
 		"version":         syslogMessage.Version,
 	}
-	return s.toSafeMap(value)
+	return toSafeMap(value)
 }
 
 // toSafeMap will dereference any pointers on the supplied map.
-func (s *SyslogParser) toSafeMap(message map[string]interface{}) (map[string]interface{}, error) {
+func toSafeMap(message map[string]interface{}) (map[string]interface{}, error) {
 	for key, val := range message {
 		switch v := val.(type) {
 		case *string:
@@ -248,11 +255,20 @@ func promoteSeverity(e *entry.Entry) error {
 		return fmt.Errorf("severity field is not an int")
 	}
 
-	if sevInt < 0 || sevInt > 7 {
-		return fmt.Errorf("invalid severity '%d'", sevInt)
+	severity, text, err := ConvertSeverity(sevInt)
+	if err != nil {
+		return err
 	}
 
-	e.Severity = severityMapping[sevInt]
-	e.SeverityText = severityText[sevInt]
+	e.Severity = severity
+	e.SeverityText = text
 	return nil
 }
+
+// ConvertSeverity returns the entry severity and severity text of a syslog severity
+func ConvertSeverity(sev int) (entry.Severity, string, error) {
+	if sev < 0 || sev > 7 {
+		return entry.Default, "", fmt.Errorf("invalid severity '%d'", sev)
+	}
+	return severityMapping[sev], severityText[sev], nil
+}
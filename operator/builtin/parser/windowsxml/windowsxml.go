@@ -0,0 +1,84 @@
+package windowsxml
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/operator/helper/eventxml"
+)
+
+func init() {
+	operator.Register("windows_xml_parser", func() operator.Builder { return NewWindowsXMLParserConfig("") })
+}
+
+// NewWindowsXMLParserConfig creates a new windows xml parser config with default values
+func NewWindowsXMLParserConfig(operatorID string) *WindowsXMLParserConfig {
+	return &WindowsXMLParserConfig{
+		ParserConfig: helper.NewParserConfig(operatorID, "windows_xml_parser"),
+	}
+}
+
+// WindowsXMLParserConfig is the configuration of a windows xml parser operator.
+type WindowsXMLParserConfig struct {
+	helper.ParserConfig `yaml:",inline"`
+}
+
+// Build will build a windows xml parser operator.
+func (c WindowsXMLParserConfig) Build(context operator.BuildContext) ([]operator.Operator, error) {
+	parserOperator, err := c.ParserConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	windowsXMLParser := &WindowsXMLParser{
+		ParserOperator: parserOperator,
+	}
+	return []operator.Operator{windowsXMLParser}, nil
+}
+
+// WindowsXMLParser is an operator that parses the rendered xml of windows events.
+type WindowsXMLParser struct {
+	helper.ParserOperator
+}
+
+// Process will parse an entry's field as the xml of a windows event. Unless a
+// timestamp or severity block is configured, the entry's timestamp and severity
+// are set from the event, the same as the windows event log input.
+func (w *WindowsXMLParser) Process(ctx context.Context, e *entry.Entry) error {
+	var timestamp time.Time
+	var severity entry.Severity
+	parse := func(value interface{}) (interface{}, error) {
+		record, eventTime, eventSeverity, err := w.parse(value)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, severity = eventTime, eventSeverity
+		return record, nil
+	}
+
+	return w.ParserOperator.ProcessWithCallback(ctx, e, parse, func(parsed *entry.Entry) error {
+		if w.TimeParser == nil && !timestamp.IsZero() {
+			parsed.Timestamp = timestamp
+		}
+		if w.SeverityParser == nil {
+			parsed.Severity = severity
+		}
+		return nil
+	})
+}
+
+// parse will parse a string or byte value as the xml of a windows event.
+func (w *WindowsXMLParser) parse(value interface{}) (map[string]interface{}, time.Time, entry.Severity, error) {
+	switch v := value.(type) {
+	case string:
+		return eventxml.Parse([]byte(v))
+	case []byte:
+		return eventxml.Parse(v)
+	default:
+		return nil, time.Time{}, entry.Default, fmt.Errorf("type %T cannot be parsed as windows event xml", value)
+	}
+}
@@ -0,0 +1,236 @@
+package windowsxml
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/observiq/stanza/entry"
+	"github.com/observiq/stanza/operator"
+	"github.com/observiq/stanza/operator/helper"
+	"github.com/observiq/stanza/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testRenderedEvent = `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="Microsoft-Windows-Security-Auditing" Guid="{54849625-5478-4994-A5BA-3E3B0328C30D}"/>
+    <EventID>4624</EventID>
+    <Level>0</Level>
+    <TimeCreated SystemTime="2021-05-04T10:15:03.1234567Z"/>
+    <EventRecordID>1</EventRecordID>
+    <Channel>Security</Channel>
+    <Computer>WIN-322E2C550UP</Computer>
+  </System>
+  <RenderingInfo Culture="en-US">
+    <Message>An account was successfully logged on.&#xD;&#xA;&#xD;&#xA;Subject:&#xD;&#xA;&#x9;Account Name:&#x9;&#x9;WIN-322E2C550UP$</Message>
+    <Level>Information</Level>
+    <Task>Logon</Task>
+    <Keywords><Keyword>Audit Success</Keyword></Keywords>
+  </RenderingInfo>
+</Event>`
+
+const testRawEvent = `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="Application Error"/>
+    <EventID Qualifiers="0">1000</EventID>
+    <Level>2</Level>
+    <TimeCreated SystemTime="2021-05-04T10:15:04.0000000Z"/>
+    <EventRecordID>2</EventRecordID>
+    <Channel>Application</Channel>
+    <Computer>WIN-322E2C550UP</Computer>
+  </System>
+  <EventData><Data>app.exe</Data></EventData>
+</Event>`
+
+func newTestParser(t *testing.T) *WindowsXMLParser {
+	config := NewWindowsXMLParserConfig("test")
+	ops, err := config.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	return ops[0].(*WindowsXMLParser)
+}
+
+func TestWindowsXMLParserConfigBuild(t *testing.T) {
+	config := NewWindowsXMLParserConfig("test")
+	ops, err := config.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.IsType(t, &WindowsXMLParser{}, ops[0])
+}
+
+func TestWindowsXMLParserConfigBuildFailure(t *testing.T) {
+	config := NewWindowsXMLParserConfig("test")
+	config.OnError = "invalid_on_error"
+	_, err := config.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid `on_error` field")
+}
+
+func TestWindowsXMLParserStringFailure(t *testing.T) {
+	parser := newTestParser(t)
+	_, _, _, err := parser.parse("invalid")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to unmarshal xml bytes into event")
+}
+
+func TestWindowsXMLParserByteFailure(t *testing.T) {
+	parser := newTestParser(t)
+	_, _, _, err := parser.parse([]byte("invalid"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to unmarshal xml bytes into event")
+}
+
+func TestWindowsXMLParserInvalidType(t *testing.T) {
+	parser := newTestParser(t)
+	_, _, _, err := parser.parse([]int{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "type []int cannot be parsed as windows event xml")
+}
+
+func NewFakeWindowsXMLOperator() (*WindowsXMLParser, *testutil.Operator) {
+	mock := testutil.Operator{}
+	logger, _ := zap.NewProduction()
+	return &WindowsXMLParser{
+		ParserOperator: helper.ParserOperator{
+			TransformerOperator: helper.TransformerOperator{
+				WriterOperator: helper.WriterOperator{
+					BasicOperator: helper.BasicOperator{
+						OperatorID:    "test",
+						OperatorType:  "windows_xml_parser",
+						SugaredLogger: logger.Sugar(),
+					},
+					OutputOperators: []operator.Operator{&mock},
+				},
+			},
+			ParseFrom: entry.NewRecordField("testfield"),
+			ParseTo:   entry.NewRecordField("testparsed"),
+		},
+	}, &mock
+}
+
+func TestWindowsXMLImplementations(t *testing.T) {
+	require.Implements(t, (*operator.Operator)(nil), new(WindowsXMLParser))
+}
+
+func TestWindowsXMLParser(t *testing.T) {
+	cases := []struct {
+		name              string
+		inputRecord       map[string]interface{}
+		expectedRecord    map[string]interface{}
+		expectedTimestamp time.Time
+		expectedSeverity  entry.Severity
+	}{
+		{
+			"Rendered",
+			map[string]interface{}{
+				"testfield": testRenderedEvent,
+			},
+			map[string]interface{}{
+				"testparsed": map[string]interface{}{
+					"event_id": map[string]interface{}{
+						"qualifiers": uint16(0),
+						"id":         uint32(4624),
+					},
+					"provider": map[string]interface{}{
+						"name":         "Microsoft-Windows-Security-Auditing",
+						"guid":         "{54849625-5478-4994-A5BA-3E3B0328C30D}",
+						"event_source": "",
+					},
+					"system_time": "2021-05-04T10:15:03.1234567Z",
+					"computer":    "WIN-322E2C550UP",
+					"channel":     "Security",
+					"record_id":   uint64(1),
+					"level":       "Information",
+					"message":     "An account was successfully logged on.",
+					"details": map[string]interface{}{
+						"Subject": map[string]interface{}{
+							"Account Name": "WIN-322E2C550UP$",
+						},
+					},
+					"task":     "Logon",
+					"opcode":   "",
+					"keywords": []string{"Audit Success"},
+				},
+			},
+			time.Date(2021, 5, 4, 10, 15, 3, 123456700, time.UTC),
+			entry.Info,
+		},
+		{
+			"Raw",
+			map[string]interface{}{
+				"testfield": []byte(testRawEvent),
+			},
+			map[string]interface{}{
+				"testparsed": map[string]interface{}{
+					"event_id": map[string]interface{}{
+						"qualifiers": uint16(0),
+						"id":         uint32(1000),
+					},
+					"provider": map[string]interface{}{
+						"name":         "Application Error",
+						"guid":         "",
+						"event_source": "",
+					},
+					"system_time": "2021-05-04T10:15:04.0000000Z",
+					"computer":    "WIN-322E2C550UP",
+					"channel":     "Application",
+					"record_id":   uint64(2),
+					"level":       "Error",
+					"message":     "",
+					"task":        "",
+					"opcode":      "",
+					"keywords":    []string(nil),
+				},
+			},
+			time.Date(2021, 5, 4, 10, 15, 4, 0, time.UTC),
+			entry.Error,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := entry.New()
+			input.Record = tc.inputRecord
+
+			parser, mockOutput := NewFakeWindowsXMLOperator()
+			mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				e := args[1].(*entry.Entry)
+				require.Equal(t, tc.expectedRecord, e.Record)
+				require.Equal(t, tc.expectedTimestamp, e.Timestamp)
+				require.Equal(t, tc.expectedSeverity, e.Severity)
+			}).Return(nil)
+
+			err := parser.Process(context.Background(), input)
+			require.NoError(t, err)
+			mockOutput.AssertCalled(t, "Process", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestWindowsXMLParserWithSeverityParser(t *testing.T) {
+	testTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	severityField := entry.NewRecordField("severity")
+	severityConfig := helper.NewSeverityParserConfig()
+	severityConfig.ParseFrom = &severityField
+	severityParser, err := severityConfig.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	parser, mockOutput := NewFakeWindowsXMLOperator()
+	parser.ParserOperator.SeverityParser = &severityParser
+	mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		e := args[1].(*entry.Entry)
+		require.Equal(t, entry.Warning, e.Severity)
+		require.Equal(t, time.Date(2021, 5, 4, 10, 15, 4, 0, time.UTC), e.Timestamp)
+	}).Return(nil)
+
+	input := entry.New()
+	input.Timestamp = testTime
+	input.Record = map[string]interface{}{
+		"testfield": testRawEvent,
+		"severity":  "warn",
+	}
+	err = parser.Process(context.Background(), input)
+	require.NoError(t, err)
+	mockOutput.AssertCalled(t, "Process", mock.Anything, mock.Anything)
+}
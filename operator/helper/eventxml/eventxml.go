@@ -1,4 +1,6 @@
-package windows
+// Package eventxml parses the rendered xml of windows events, which is shared
+// by the windows event log inputs and the windows xml parser.
+package eventxml
 
 import (
 	"encoding/xml"
@@ -23,16 +25,16 @@ type EventXML struct {
 	Keywords    []string    `xml:"RenderingInfo>Keywords>Keyword"`
 }
 
-// parseTimestamp will parse the timestamp of the event.
-func (e *EventXML) parseTimestamp() time.Time {
+// ParseTimestamp will parse the timestamp of the event, or return the current time if it is invalid.
+func (e *EventXML) ParseTimestamp() time.Time {
 	if timestamp, err := time.Parse(time.RFC3339Nano, e.TimeCreated.SystemTime); err == nil {
 		return timestamp
 	}
 	return time.Now()
 }
 
-// parseSeverity will parse the severity of the event.
-func (e *EventXML) parseSeverity() entry.Severity {
+// ParseSeverity will parse the severity of the event.
+func (e *EventXML) ParseSeverity() entry.Severity {
 	switch e.Level {
 	case "Critical":
 		return entry.Critical
@@ -47,8 +49,8 @@ func (e *EventXML) parseSeverity() entry.Severity {
 	}
 }
 
-// parseRecord will parse a record from the event.
-func (e *EventXML) parseRecord() map[string]interface{} {
+// ParseRecord will parse a record from the event.
+func (e *EventXML) ParseRecord() map[string]interface{} {
 	message, details := e.parseMessage()
 	record := map[string]interface{}{
 		"event_id": map[string]interface{}{
@@ -86,8 +88,8 @@ func (e *EventXML) parseMessage() (string, map[string]interface{}) {
 	}
 }
 
-// unmarshalEventXML will unmarshal EventXML from xml bytes.
-func unmarshalEventXML(bytes []byte) (EventXML, error) {
+// Unmarshal will unmarshal EventXML from xml bytes.
+func Unmarshal(bytes []byte) (EventXML, error) {
 	var eventXML EventXML
 	if err := xml.Unmarshal(bytes, &eventXML); err != nil {
 		return EventXML{}, fmt.Errorf("failed to unmarshal xml bytes into event: %s", err)
@@ -95,6 +97,39 @@ func unmarshalEventXML(bytes []byte) (EventXML, error) {
 	return eventXML, nil
 }
 
+// eventSystem is the level of an event, which is used when the event does not
+// include the name of its level, as it is only included when events are
+// rendered or forwarded with their rendering info
+type eventSystem struct {
+	Level uint8 `xml:"System>Level"`
+}
+
+// levelNames are the names of the standard event levels
+var levelNames = map[uint8]string{
+	1: "Critical",
+	2: "Error",
+	3: "Warning",
+	4: "Information",
+	5: "Verbose",
+}
+
+// UnmarshalWithLevel will unmarshal EventXML from xml bytes, and use the
+// name of the event's level when the xml has no rendering info
+func UnmarshalWithLevel(bytes []byte) (EventXML, error) {
+	eventXML, err := Unmarshal(bytes)
+	if err != nil {
+		return EventXML{}, err
+	}
+
+	if eventXML.Level == "" {
+		var system eventSystem
+		if err := xml.Unmarshal(bytes, &system); err == nil {
+			eventXML.Level = levelNames[system.Level]
+		}
+	}
+	return eventXML, nil
+}
+
 // EventID is the identifier of the event.
 type EventID struct {
 	Qualifiers uint16 `xml:"Qualifiers,attr"`
@@ -112,3 +147,16 @@ type Provider struct {
 	GUID            string `xml:"Guid,attr"`
 	EventSourceName string `xml:"EventSourceName,attr"`
 }
+
+// Parse will parse the rendered xml of an event into a record, along with
+// the time the event was created and its severity. The time is zero when
+// the event does not have a valid creation time.
+func Parse(bytes []byte) (map[string]interface{}, time.Time, entry.Severity, error) {
+	eventXML, err := UnmarshalWithLevel(bytes)
+	if err != nil {
+		return nil, time.Time{}, entry.Default, err
+	}
+
+	timestamp, _ := time.Parse(time.RFC3339Nano, eventXML.TimeCreated.SystemTime)
+	return eventXML.ParseRecord(), timestamp, eventXML.ParseSeverity(), nil
+}
@@ -1,4 +1,4 @@
-package windows
+package eventxml
 
 import (
 	"testing"
@@ -14,7 +14,7 @@ func TestParseValidTimestamp(t *testing.T) {
 			SystemTime: "2020-07-30T01:01:01.123456789Z",
 		},
 	}
-	timestamp := xml.parseTimestamp()
+	timestamp := xml.ParseTimestamp()
 	expected, _ := time.Parse(time.RFC3339Nano, "2020-07-30T01:01:01.123456789Z")
 	require.Equal(t, expected, timestamp)
 }
@@ -25,7 +25,7 @@ func TestParseInvalidTimestamp(t *testing.T) {
 			SystemTime: "invalid",
 		},
 	}
-	timestamp := xml.parseTimestamp()
+	timestamp := xml.ParseTimestamp()
 	require.Equal(t, time.Now().Year(), timestamp.Year())
 	require.Equal(t, time.Now().Month(), timestamp.Month())
 	require.Equal(t, time.Now().Day(), timestamp.Day())
@@ -37,11 +37,11 @@ func TestParseSeverity(t *testing.T) {
 	xmlWarning := EventXML{Level: "Warning"}
 	xmlInformation := EventXML{Level: "Information"}
 	xmlUnknown := EventXML{Level: "Unknown"}
-	require.Equal(t, entry.Critical, xmlCritical.parseSeverity())
-	require.Equal(t, entry.Error, xmlError.parseSeverity())
-	require.Equal(t, entry.Warning, xmlWarning.parseSeverity())
-	require.Equal(t, entry.Info, xmlInformation.parseSeverity())
-	require.Equal(t, entry.Default, xmlUnknown.parseSeverity())
+	require.Equal(t, entry.Critical, xmlCritical.ParseSeverity())
+	require.Equal(t, entry.Error, xmlError.ParseSeverity())
+	require.Equal(t, entry.Warning, xmlWarning.ParseSeverity())
+	require.Equal(t, entry.Info, xmlInformation.ParseSeverity())
+	require.Equal(t, entry.Default, xmlUnknown.ParseSeverity())
 }
 
 func TestParseRecord(t *testing.T) {
@@ -89,5 +89,5 @@ func TestParseRecord(t *testing.T) {
 		"keywords":    []string{"keyword"},
 	}
 
-	require.Equal(t, expected, xml.parseRecord())
+	require.Equal(t, expected, xml.ParseRecord())
 }
@@ -1,4 +1,4 @@
-package windows
+package eventxml
 
 import (
 	"encoding/json"
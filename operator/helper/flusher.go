@@ -0,0 +1,135 @@
+package helper
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ForceFlushedLabel is the label added to entries that were force flushed
+// because no new data arrived within the force flush period
+const ForceFlushedLabel = "force_flushed"
+
+// Flusher tracks the incomplete entry buffered by a split func, so that
+// it can be force flushed once no new data has arrived within a period
+type Flusher struct {
+	period time.Duration
+
+	// lastDataChange is when the length of the buffered data last changed
+	lastDataChange     time.Time
+	previousDataLength int
+
+	// forced is whether the last token was force flushed
+	forced bool
+}
+
+// NewFlusher creates a new flusher. A period of zero disables force flushing.
+func NewFlusher(period time.Duration) *Flusher {
+	return &Flusher{period: period}
+}
+
+// NewFlusher creates a new flusher with the force flush period of the config
+func (c MultilineConfig) NewFlusher() *Flusher {
+	return NewFlusher(c.ForceFlushPeriod.Raw())
+}
+
+// SplitFunc wraps a split func, returning all buffered data as a token at EOF
+// once no new data has been buffered within the force flush period. This is
+// used by readers that call the split func repeatedly with the same data, such
+// as when a file is polled.
+func (f *Flusher) SplitFunc(splitFunc bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		f.forced = false
+		advance, token, err := splitFunc(data, atEOF)
+		if err != nil || advance > 0 || token != nil {
+			f.reset()
+			return advance, token, err
+		}
+
+		if f.period <= 0 || len(data) == 0 {
+			return advance, token, err
+		}
+
+		// The buffered data only grows until a token is returned, but
+		// may be passed in smaller chunks when it is read again
+		if len(data) > f.previousDataLength {
+			f.previousDataLength = len(data)
+			f.lastDataChange = time.Now()
+			return advance, token, err
+		}
+
+		if !atEOF || len(data) < f.previousDataLength || time.Since(f.lastDataChange) < f.period {
+			return advance, token, err
+		}
+
+		f.reset()
+		f.forced = true
+		return len(data), data, nil
+	}
+}
+
+// Forced returns whether the last token was force flushed
+func (f *Flusher) Forced() bool {
+	return f.forced
+}
+
+// Deadline returns when the buffered incomplete entry should be force flushed,
+// or the zero time if no data is buffered or force flushing is disabled. This is
+// used by readers of streams, which are not read again until new data arrives.
+func (f *Flusher) Deadline() time.Time {
+	if f.period <= 0 || f.previousDataLength == 0 {
+		return time.Time{}
+	}
+	return f.lastDataChange.Add(f.period)
+}
+
+func (f *Flusher) reset() {
+	f.previousDataLength = 0
+	f.lastDataChange = time.Time{}
+}
+
+// DeadlineReader is a stream that supports read deadlines, such as a connection or a pipe
+type DeadlineReader interface {
+	io.Reader
+	SetReadDeadline(time.Time) error
+}
+
+// FlushReader reads from a stream, reporting the end of the stream once the
+// force flush deadline of an incomplete entry is exceeded so that the entry
+// is flushed. The stream is read again afterwards.
+type FlushReader struct {
+	reader  DeadlineReader
+	flusher *Flusher
+
+	// ForceFlushed is whether the end of the stream was reported
+	// because the force flush deadline was exceeded
+	ForceFlushed bool
+}
+
+// NewFlushReader creates a reader that force flushes with the deadlines of the flusher
+func NewFlushReader(reader DeadlineReader, flusher *Flusher) *FlushReader {
+	return &FlushReader{
+		reader:  reader,
+		flusher: flusher,
+	}
+}
+
+// Read reads from the stream, returning io.EOF if the force flush deadline is exceeded.
+// Streams that do not support deadlines are read without force flushing.
+func (r *FlushReader) Read(p []byte) (int, error) {
+	if err := r.reader.SetReadDeadline(r.flusher.Deadline()); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+		return 0, err
+	}
+
+	n, err := r.reader.Read(p)
+	if os.IsTimeout(err) {
+		if n > 0 {
+			return n, nil
+		}
+		r.ForceFlushed = true
+		return 0, io.EOF
+	}
+	return n, err
+}
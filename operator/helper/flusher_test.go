@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlusherSplitFunc(t *testing.T) {
+	flusher := NewFlusher(50 * time.Millisecond)
+	splitFunc := flusher.SplitFunc(NewLineStartSplitFunc(regexp.MustCompile("(?m)^START"), false))
+	data := []byte("START 1\nline\nSTART 2\nline")
+
+	// Complete entries are returned without being force flushed
+	advance, token, err := splitFunc(data, false)
+	require.NoError(t, err)
+	require.Equal(t, []byte("START 1\nline\n"), token)
+	require.False(t, flusher.Forced())
+	require.True(t, flusher.Deadline().IsZero())
+
+	data = data[advance:]
+	advance, token, err = splitFunc(data, true)
+	require.NoError(t, err)
+	require.Equal(t, 0, advance)
+	require.Nil(t, token)
+	require.False(t, flusher.Deadline().IsZero())
+
+	// The incomplete entry is flushed once the period has elapsed without new data
+	time.Sleep(60 * time.Millisecond)
+	advance, token, err = splitFunc(data, true)
+	require.NoError(t, err)
+	require.Equal(t, len(data), advance)
+	require.Equal(t, data, token)
+	require.True(t, flusher.Forced())
+	require.True(t, flusher.Deadline().IsZero())
+}
+
+func TestFlusherNewData(t *testing.T) {
+	flusher := NewFlusher(50 * time.Millisecond)
+	splitFunc := flusher.SplitFunc(bufio.ScanLines)
+
+	_, token, _ := splitFunc([]byte("partial"), false)
+	require.Nil(t, token)
+
+	// New data restarts the period
+	time.Sleep(60 * time.Millisecond)
+	_, token, _ = splitFunc([]byte("partial entry"), false)
+	require.Nil(t, token)
+	_, token, _ = splitFunc([]byte("partial entry"), true)
+	require.Equal(t, []byte("partial entry"), token)
+	require.False(t, flusher.Forced())
+}
+
+func TestFlusherDisabled(t *testing.T) {
+	flusher := NewFlusher(0)
+	splitFunc := flusher.SplitFunc(NewLineStartSplitFunc(regexp.MustCompile("(?m)^START"), false))
+
+	_, token, err := splitFunc([]byte("START 1\nline"), true)
+	require.NoError(t, err)
+	require.Nil(t, token)
+	require.True(t, flusher.Deadline().IsZero())
+}
+
+func TestFlushReader(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	flusher := NewFlusher(50 * time.Millisecond)
+	reader := NewFlushReader(server, flusher)
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(flusher.SplitFunc(bufio.ScanLines))
+
+	go func() {
+		_, _ = client.Write([]byte("complete\npartial"))
+	}()
+
+	require.True(t, scanner.Scan())
+	require.Equal(t, "complete", scanner.Text())
+	require.False(t, reader.ForceFlushed)
+
+	// The partial entry is flushed once the deadline is exceeded
+	require.True(t, scanner.Scan())
+	require.Equal(t, "partial", scanner.Text())
+	require.True(t, reader.ForceFlushed)
+	require.False(t, scanner.Scan())
+	require.NoError(t, scanner.Err())
+
+	// The stream can be read again afterwards
+	reader.ForceFlushed = false
+	scanner = bufio.NewScanner(reader)
+	go func() {
+		_, _ = client.Write([]byte("next\n"))
+	}()
+	require.True(t, scanner.Scan())
+	require.Equal(t, "next", scanner.Text())
+	require.False(t, reader.ForceFlushed)
+}
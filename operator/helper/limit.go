@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"fmt"
+	"io"
+)
+
+// SizeLimitError is returned by a LimitedReader once its limit is exceeded
+type SizeLimitError int64
+
+func (e SizeLimitError) Error() string {
+	return fmt.Sprintf("size exceeds the maximum of %d bytes", int64(e))
+}
+
+// LimitedReader is an io.LimitReader that fails once the limit is exceeded,
+// rather than silently truncating
+type LimitedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+// NewLimitedReader creates a reader that fails with a SizeLimitError after
+// more than limit bytes are read from r
+func NewLimitedReader(r io.Reader, limit int64) *LimitedReader {
+	return &LimitedReader{r: r, limit: limit, remaining: limit}
+}
+
+// Read will read from the underlying reader until the limit is exceeded
+func (l *LimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, SizeLimitError(l.limit)
+	}
+	// Read one byte more than the limit to detect that it was exceeded
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, SizeLimitError(l.limit)
+	}
+	return n, err
+}
@@ -0,0 +1,38 @@
+package helper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitedReader(t *testing.T) {
+	cases := []struct {
+		name      string
+		size      int
+		limit     int64
+		expectErr bool
+	}{
+		{"Empty", 0, 10, false},
+		{"UnderLimit", 5, 10, false},
+		{"AtLimit", 10, 10, false},
+		{"OverLimit", 11, 10, true},
+		{"FarOverLimit", 1 << 20, 10, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("a"), tc.size)
+			read, err := ioutil.ReadAll(NewLimitedReader(bytes.NewReader(data), tc.limit))
+			if tc.expectErr {
+				require.Equal(t, SizeLimitError(tc.limit), err)
+				require.LessOrEqual(t, int64(len(read)), tc.limit+1)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, data, read)
+		})
+	}
+}
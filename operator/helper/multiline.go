@@ -21,12 +21,16 @@ func NewMultilineConfig() MultilineConfig {
 
 // MultilineConfig is the configuration of a multiline helper
 type MultilineConfig struct {
-	LineStartPattern string `mapstructure:"line_start_pattern"  json:"line_start_pattern" yaml:"line_start_pattern"`
-	LineEndPattern   string `mapstructure:"line_end_pattern"    json:"line_end_pattern"   yaml:"line_end_pattern"`
+	LineStartPattern string   `mapstructure:"line_start_pattern"  json:"line_start_pattern" yaml:"line_start_pattern"`
+	LineEndPattern   string   `mapstructure:"line_end_pattern"    json:"line_end_pattern"   yaml:"line_end_pattern"`
+	ForceFlushPeriod Duration `mapstructure:"force_flush_period"  json:"force_flush_period,omitempty" yaml:"force_flush_period,omitempty"`
 }
 
 // Build will build a Multiline operator.
 func (c MultilineConfig) Build(context operator.BuildContext, encoding encoding.Encoding, flushAtEOF bool) (bufio.SplitFunc, error) {
+	if c.ForceFlushPeriod.Raw() < 0 {
+		return nil, fmt.Errorf("force_flush_period must not be negative")
+	}
 	return c.getSplitFunc(encoding, flushAtEOF)
 }
 
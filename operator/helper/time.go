@@ -134,19 +134,19 @@ func (t *TimeParser) Parse(entry *entry.Entry) error {
 		if !ok {
 			return fmt.Errorf("native time.Time field required, but found %v of type %T", value, value)
 		}
-		entry.Timestamp = setTimestampYear(timeValue)
+		entry.Timestamp = SetTimestampYear(timeValue)
 	case GotimeKey:
 		timeValue, err := t.parseGotime(value)
 		if err != nil {
 			return err
 		}
-		entry.Timestamp = setTimestampYear(timeValue)
+		entry.Timestamp = SetTimestampYear(timeValue)
 	case EpochKey:
 		timeValue, err := t.parseEpochTime(value)
 		if err != nil {
 			return err
 		}
-		entry.Timestamp = setTimestampYear(timeValue)
+		entry.Timestamp = SetTimestampYear(timeValue)
 	default:
 		return fmt.Errorf("unsupported layout type: %s", t.LayoutType)
 	}
@@ -269,9 +269,9 @@ var toTime = map[string]toTimeFunc{
 }
 var subsecToNs = map[string]int64{"s.ms": 1e6, "s.us": 1e3, "s.ns": 1}
 
-// setTimestampYear sets the year of a timestamp to the current year.
+// SetTimestampYear sets the year of a timestamp to the current year.
 // This is needed because year is missing from some time formats, such as rfc3164.
-func setTimestampYear(t time.Time) time.Time {
+func SetTimestampYear(t time.Time) time.Time {
 	if t.Year() > 1970 {
 		return t
 	}
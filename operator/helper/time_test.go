@@ -17,7 +17,7 @@ func Test_setTimestampYear(t *testing.T) {
 		}
 
 		noYear := time.Date(0, 06, 16, 3, 31, 34, 525, time.UTC)
-		yearAdded := setTimestampYear(noYear)
+		yearAdded := SetTimestampYear(noYear)
 		expected := time.Date(2020, 06, 16, 3, 31, 34, 525, time.UTC)
 		require.Equal(t, expected, yearAdded)
 	})
@@ -28,7 +28,7 @@ func Test_setTimestampYear(t *testing.T) {
 		}
 
 		noYear := time.Date(0, 01, 17, 3, 31, 34, 525, time.UTC)
-		yearAdded := setTimestampYear(noYear)
+		yearAdded := SetTimestampYear(noYear)
 		expected := time.Date(2020, 01, 17, 3, 31, 34, 525, time.UTC)
 		require.Equal(t, expected, yearAdded)
 	})
@@ -39,7 +39,7 @@ func Test_setTimestampYear(t *testing.T) {
 		}
 
 		noYear := time.Date(0, 01, 24, 3, 31, 34, 525, time.UTC)
-		yearAdded := setTimestampYear(noYear)
+		yearAdded := SetTimestampYear(noYear)
 		expected := time.Date(2019, 01, 24, 3, 31, 34, 525, time.UTC)
 		require.Equal(t, expected, yearAdded)
 	})
@@ -50,7 +50,7 @@ func Test_setTimestampYear(t *testing.T) {
 		}
 
 		noYear := time.Date(0, 12, 31, 3, 31, 34, 525, time.UTC)
-		yearAdded := setTimestampYear(noYear)
+		yearAdded := SetTimestampYear(noYear)
 		expected := time.Date(2019, 12, 31, 3, 31, 34, 525, time.UTC)
 		require.Equal(t, expected, yearAdded)
 	})
@@ -0,0 +1,43 @@
+package helper
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSServerConfig is the configuration for a TLS listener
+type TLSServerConfig struct {
+	// Enable forces the use of TLS
+	Enable bool `json:"enable,omitempty" yaml:"enable,omitempty"`
+
+	// Certificate is the file path for the certificate
+	Certificate string `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+
+	// PrivateKey is the file path for the private key
+	PrivateKey string `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+}
+
+// Build will build the tls.Config of a listener, which is nil if TLS is not enabled
+func (c TLSServerConfig) Build() (*tls.Config, error) {
+	if !c.Enable {
+		return nil, nil
+	}
+
+	if c.Certificate == "" {
+		return nil, fmt.Errorf("missing required parameter 'certificate', required when TLS is enabled")
+	}
+
+	if c.PrivateKey == "" {
+		return nil, fmt.Errorf("missing required parameter 'private_key', required when TLS is enabled")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.Certificate, c.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
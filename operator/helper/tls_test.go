@@ -0,0 +1,45 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSServerConfigBuild(t *testing.T) {
+	cases := []struct {
+		name        string
+		config      TLSServerConfig
+		expectedErr string
+	}{
+		{
+			"MissingCertificate",
+			TLSServerConfig{Enable: true, PrivateKey: "key.pem"},
+			"missing required parameter 'certificate'",
+		},
+		{
+			"MissingPrivateKey",
+			TLSServerConfig{Enable: true, Certificate: "cert.pem"},
+			"missing required parameter 'private_key'",
+		},
+		{
+			"MissingFiles",
+			TLSServerConfig{Enable: true, Certificate: "missing.pem", PrivateKey: "missing.pem"},
+			"failed to load tls certificate",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.config.Build()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func TestTLSServerConfigDisabled(t *testing.T) {
+	config, err := TLSServerConfig{Certificate: "missing.pem", PrivateKey: "missing.pem"}.Build()
+	require.NoError(t, err)
+	require.Nil(t, config)
+}